@@ -3,6 +3,7 @@ package main
 import (
 	"api-gateway/internal/config"
 	"api-gateway/internal/router"
+	"api-gateway/internal/version"
 	"api-gateway/pkg/logger"
 	"log"
 	"os"
@@ -28,8 +29,11 @@ func main() {
 
 	testLogger.Info("=== API GATEWAY STARTING ===", map[string]interface{}{
 		"timestamp":   time.Now().UTC(),
-		"version":     "1.0.0",
+		"version":     version.Version,
+		"commit":      version.Commit,
+		"build_date":  version.BuildDate,
 		"environment": os.Getenv("ENVIRONMENT"),
+		"profile":     cfg.Profile,
 		"config": map[string]interface{}{
 			"log_level":  cfg.Logging.Level,
 			"log_format": "json",
@@ -1,13 +1,14 @@
 package config
 
 import (
+	gatewayproxy "api-gateway/internal/proxy"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/joho/godotenv"
 )
 
 type Config struct {
@@ -17,6 +18,69 @@ type Config struct {
 	Health     HealthConfig
 	Kubernetes KubernetesConfig
 	Logging    LoggingConfig
+	Proxy      ProxyConfig
+	Admin      AdminConfig
+	Middleware MiddlewareConfig
+
+	// Profile is the active profile whose env file overlays the base one, empty for none
+	Profile    string
+	profileErr error
+}
+
+// MiddlewareConfig toggles optional global middlewares. Panic recovery and
+// client IP resolution always run and can't be turned off.
+type MiddlewareConfig struct {
+	RequestID      bool
+	ClientCert     bool
+	RequestLogging bool
+	RateLimiting   bool
+
+	// Signs generated correlation IDs so peer gateways sharing the key can trust them;
+	// inbound IDs without a valid signature are replaced. Empty disables signing.
+	CorrelationIDKey string
+
+	// Panics recovered within a minute before every goroutine's stack is logged once; 0 disables it
+	PanicGoroutineDumpThreshold int
+}
+
+// AdminConfig holds access control for the /admin API
+type AdminConfig struct {
+	AuthEnabled bool   // Require Token on admin requests; with no token set the API is closed
+	Token       string // Shared secret sent as X-Admin-Token or a bearer token
+}
+
+// ProxyConfig holds upstream proxying configuration
+type ProxyConfig struct {
+	MaxAttempts      int   // Attempts per request; only connection failures are retried
+	RetryBufferBytes int64 // Largest request body buffered for replay on retry
+	MaxBodyBytes     int64 // Largest request body accepted; 0 disables the limit
+
+	// Backend URL that requests matching no route are proxied to; empty returns 404
+	DefaultBackend string
+
+	// Load balancing strategy of services and static routes that don't name one
+	LoadBalancing string
+
+	// TLS settings for HTTPS upstreams
+	UpstreamInsecureSkipVerify bool
+	UpstreamCAFile             string
+
+	// Keep-alive pool sizes for the shared upstream transport
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+
+	// How often buffered response data is flushed to clients; 0 flushes only at the end
+	// and a negative value after every write. Services can override it.
+	FlushInterval time.Duration
+	BufferSize    int // Size of the pooled buffers response bodies are copied through
+
+	// Circuit breaker of each discovered service: probes let through while half-open,
+	// consecutive successes that close it, the window failures are counted over and
+	// how long it stays open
+	CircuitBreakerMaxRequests      int
+	CircuitBreakerSuccessThreshold int
+	CircuitBreakerInterval         time.Duration
+	CircuitBreakerTimeout          time.Duration
 }
 
 // LoggingConfig holds logging-related configuration
@@ -30,6 +94,7 @@ type LoggingConfig struct {
 	ErrorWebhookURL string        `yaml:"error_webhook_url" json:"error_webhook_url"`
 	SlackWebhookURL string        `yaml:"slack_webhook_url" json:"slack_webhook_url"`
 	AlertCooldown   time.Duration `yaml:"alert_cooldown" json:"alert_cooldown"`
+	AlertThresholds []int         `yaml:"alert_thresholds" json:"alert_thresholds"` // Error counts that alert within the cooldown
 
 	// Request logging configuration
 	LogRequests          bool          `yaml:"log_requests" json:"log_requests"`
@@ -37,31 +102,119 @@ type LoggingConfig struct {
 	LogHeaders           bool          `yaml:"log_headers" json:"log_headers"`
 	SensitiveHeaders     []string      `yaml:"sensitive_headers" json:"sensitive_headers"`
 	SlowRequestThreshold time.Duration `yaml:"slow_request_threshold" json:"slow_request_threshold"`
+	MaxLoggedHeaders     int           `yaml:"max_logged_headers" json:"max_logged_headers"` // 0 logs every header
+
+	// NCSA access log, "common" or "combined"; empty disables it. Output is stdout, stderr or a file path.
+	AccessLogFormat string `yaml:"access_log_format" json:"access_log_format"`
+	AccessLogOutput string `yaml:"access_log_output" json:"access_log_output"`
 
 	// Loki
 	LokiURL string `yaml:"loki_url" json:"loki_url"`
+
+	// Asynchronous hook dispatch
+	HookBufferSize int `yaml:"hook_buffer_size" json:"hook_buffer_size"`
+	HookWorkers    int `yaml:"hook_workers" json:"hook_workers"`
+
+	// File output, used when Output is "file"
+	FilePath          string        `yaml:"file_path" json:"file_path"`
+	FileMaxSizeMB     int           `yaml:"file_max_size_mb" json:"file_max_size_mb"`
+	FileMaxBackups    int           `yaml:"file_max_backups" json:"file_max_backups"`
+	FileMaxAge        time.Duration `yaml:"file_max_age" json:"file_max_age"`
+	FileFlushInterval time.Duration `yaml:"file_flush_interval" json:"file_flush_interval"`
+
+	// Regular expressions for field keys whose values are masked in logs
+	RedactPatterns []string `yaml:"redact_patterns" json:"redact_patterns"`
+
+	// Sampling of DEBUG/INFO entries
+	SampleRate  float64 `yaml:"sample_rate" json:"sample_rate"`
+	SampleBurst int     `yaml:"sample_burst" json:"sample_burst"`
+
+	// Stack traces of ERROR entries: frames captured and packages whose frames are dropped
+	StackDepth  int      `yaml:"stack_depth" json:"stack_depth"`
+	StackFilter []string `yaml:"stack_filter" json:"stack_filter"`
 }
 
 type ServerConfig struct {
-	Port         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Port              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration // Guards against slow header (Slowloris) clients
+	WriteTimeout      time.Duration // 0 disables it, for long-lived streaming responses
+	IdleTimeout       time.Duration // How long idle keep-alive connections stay open
+	H2C               bool          // Accept cleartext HTTP/2 so gRPC clients can connect without TLS
+	RequestTimeout    time.Duration // Total time a request may take, retries included; 0 disables it
+	MaxHeaderCount    int           // Requests with more header lines get a 431; 0 disables the check
+	MaxHeaderBytes    int           // Requests with more header name and value bytes get a 431
+
+	// TLS termination; the gateway serves HTTPS when both files are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Mutual TLS; clients must present a certificate signed by the CA bundle
+	TLSClientCAFile      string
+	TLSRequireClientCert bool
+
+	// CIDRs of proxies whose X-Forwarded-For/X-Real-IP headers are trusted
+	TrustedProxies []string
+}
+
+// TLSEnabled reports whether the gateway should serve HTTPS
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
 }
 
 type JWTConfig struct {
 	Secret     string
+	Algorithm  string // HMAC algorithm: HS256, HS384 or HS512
+	Issuer     string // Required iss claim; empty accepts any issuer
 	Expiration time.Duration
+	CookieName string // Cookie read for the token when there is no Authorization header; empty disables it
+
+	// Tolerance for clock differences with token issuers when checking exp, nbf and iat
+	ClockSkewLeeway time.Duration
+
+	// Further token issuers that routes select with the gateway.io/auth-provider annotation
+	Providers []AuthProviderConfig
+}
+
+// AuthProviderConfig is a named token issuer, configured by JWT_PROVIDERS=<name>,... and
+// JWT_PROVIDER_<NAME>_SECRET, _ALGORITHM and _ISSUER
+type AuthProviderConfig struct {
+	Name      string
+	Secret    string
+	Algorithm string
+	Issuer    string
 }
 
+// validJWTAlgorithms are the supported token signing algorithms
+var validJWTAlgorithms = map[string]bool{"HS256": true, "HS384": true, "HS512": true}
+
 type RateLimitConfig struct {
 	Limit           int
 	BurstLimit      int
-	CleanupInterval time.Duration
+	CleanupInterval time.Duration // How often idle clients are swept
+	ClientTTL       time.Duration // How long a client may be idle before its limiter is dropped
+
+	// Authenticated callers are limited per user instead of per IP; 0 disables it
+	UserLimit      int
+	UserBurstLimit int
+
+	// Exact paths and client IPs/CIDRs that bypass rate limiting, e.g. probes and monitoring
+	ExemptPaths []string
+	ExemptCIDRs []string
 }
 
 type HealthConfig struct {
-	CheckInterval time.Duration
-	Timeout       time.Duration
+	CheckInterval     time.Duration
+	Timeout           time.Duration
+	ReadinessBackends bool
+
+	// How backends are probed; annotations and static routes can override them
+	Path           string // Appended to static targets; dynamic services set their own
+	Method         string // GET or HEAD
+	ExpectedStatus string // Healthy status code or inclusive range, e.g. "204" or "200-399"
+
+	// Probe every upstream once at startup and log a summary; readiness doesn't wait for it
+	StartupSelfTest bool
 }
 
 type KubernetesConfig struct {
@@ -71,29 +224,80 @@ type KubernetesConfig struct {
 	KubeconfigPath     string
 	ServiceDiscovery   bool
 	WatchAllNamespaces bool
-}
 
-func Load() *Config {
-	godotenv.Load()
+	// Unmatched requests get 503 + Retry-After instead of 404 until discovery has synced
+	StartupUnavailable bool
+	StartupRetryAfter  time.Duration
+
+	// How often the route table is reconciled against discovered services; 0 disables it
+	ResyncInterval time.Duration
+
+	// Zone of the gateway for zone-aware load balancing. When empty it is the zone label
+	// of NodeName, which needs WatchNodeZones.
+	Zone           string
+	NodeName       string
+	WatchNodeZones bool // Watch nodes so endpoints take their node's zone label
 
+	// Route of services without path or method annotations. The template may use
+	// {name} and {namespace}; the methods are a comma-separated list or ANY.
+	DefaultMethod       string
+	DefaultPathTemplate string
+
+	// Route table changes are batched until none arrives for RouteEventsDebounce, or for
+	// at most RouteEventsMaxDelay, then posted to RouteEventsWebhookURL when set and
+	// streamed from /admin/events
+	RouteEventsWebhookURL string
+	RouteEventsDebounce   time.Duration
+	RouteEventsMaxDelay   time.Duration
+}
+
+func fromEnv() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:         getEnv("PORT", ":8080"),
-			ReadTimeout:  getEnvAsDuration("READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getEnvAsDuration("WRITE_TIMEOUT", 30*time.Second),
+			Port:              getEnv("PORT", ":8080"),
+			ReadTimeout:       getEnvAsDuration("READ_TIMEOUT", 30*time.Second),
+			ReadHeaderTimeout: getEnvAsDuration("READ_HEADER_TIMEOUT", 10*time.Second),
+			WriteTimeout:      getEnvAsDuration("WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:       getEnvAsDuration("IDLE_TIMEOUT", 120*time.Second),
+			H2C:               getEnvAsBool("H2C_ENABLED", true),
+			RequestTimeout:    getEnvAsDuration("REQUEST_TIMEOUT", 25*time.Second),
+			MaxHeaderCount:    getEnvAsInt("MAX_HEADER_COUNT", 100),
+			MaxHeaderBytes:    getEnvAsInt("MAX_HEADER_BYTES", 64<<10),
+
+			TLSCertFile:          getEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:           getEnv("TLS_KEY_FILE", ""),
+			TLSClientCAFile:      getEnv("TLS_CLIENT_CA_FILE", ""),
+			TLSRequireClientCert: getEnvAsBool("TLS_REQUIRE_CLIENT_CERT", false),
+			TrustedProxies:       getEnvAsStringSlice("TRUSTED_PROXIES", nil),
 		},
 		JWT: JWTConfig{
 			Secret:     getEnv("JWT_SECRET", "supersecret"),
+			Algorithm:  getEnv("JWT_ALGORITHM", "HS256"),
+			Issuer:     getEnv("JWT_ISSUER", ""),
 			Expiration: getEnvAsDuration("JWT_EXPIRATION", 24*time.Hour),
+			CookieName: getEnv("JWT_COOKIE_NAME", ""),
+			Providers:  getAuthProviders(),
+
+			ClockSkewLeeway: getEnvAsDuration("JWT_CLOCK_SKEW_LEEWAY", 30*time.Second),
 		},
 		Rate: RateLimitConfig{
 			Limit:           getEnvAsInt("RATE_LIMIT", 1),
 			BurstLimit:      getEnvAsInt("RATE_BURST_LIMIT", 5),
 			CleanupInterval: getEnvAsDuration("RATE_CLEANUP", 1*time.Minute),
+			ClientTTL:       getEnvAsDuration("RATE_CLIENT_TTL", 10*time.Minute),
+			UserLimit:       getEnvAsInt("RATE_USER_LIMIT", 0),
+			UserBurstLimit:  getEnvAsInt("RATE_USER_BURST_LIMIT", 20),
+			ExemptPaths:     getEnvAsStringSlice("RATE_EXEMPT_PATHS", []string{"/health", "/health/detail", "/ready", "/livez", "/metrics"}),
+			ExemptCIDRs:     getEnvAsStringSlice("RATE_EXEMPT_CIDRS", nil),
 		},
 		Health: HealthConfig{
-			CheckInterval: getEnvAsDuration("HEALTH_CHECK_INTERVAL", 10*time.Second),
-			Timeout:       getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 5*time.Second),
+			CheckInterval:     getEnvAsDuration("HEALTH_CHECK_INTERVAL", 10*time.Second),
+			Timeout:           getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 5*time.Second),
+			ReadinessBackends: getEnvAsBool("HEALTH_READINESS_BACKENDS", false),
+			Path:              getEnv("HEALTH_CHECK_PATH", "/health"),
+			Method:            getEnv("HEALTH_CHECK_METHOD", "GET"),
+			ExpectedStatus:    getEnv("HEALTH_CHECK_EXPECTED_STATUS", "200-399"),
+			StartupSelfTest:   getEnvAsBool("HEALTH_STARTUP_SELF_TEST", false),
 		},
 		Kubernetes: KubernetesConfig{
 			Enabled:            getEnvAsBool("KUBERNETES_ENABLED", true),
@@ -102,6 +306,51 @@ func Load() *Config {
 			KubeconfigPath:     getEnv("KUBECONFIG_PATH", ""),
 			ServiceDiscovery:   getEnvAsBool("KUBERNETES_SERVICE_DISCOVERY", true),
 			WatchAllNamespaces: getEnvAsBool("KUBERNETES_WATCH_ALL_NAMESPACES", false),
+			StartupUnavailable: getEnvAsBool("KUBERNETES_STARTUP_UNAVAILABLE", true),
+			StartupRetryAfter:  getEnvAsDuration("KUBERNETES_STARTUP_RETRY_AFTER", 5*time.Second),
+			ResyncInterval:     getEnvAsDuration("KUBERNETES_RESYNC_INTERVAL", 5*time.Minute),
+			Zone:               getEnv("GATEWAY_ZONE", ""),
+			NodeName:           getEnv("NODE_NAME", ""),
+			WatchNodeZones:     getEnvAsBool("KUBERNETES_WATCH_NODE_ZONES", false),
+
+			DefaultMethod:       getEnv("KUBERNETES_DEFAULT_METHOD", "GET"),
+			DefaultPathTemplate: getEnv("KUBERNETES_DEFAULT_PATH_TEMPLATE", "/{name}"),
+
+			RouteEventsWebhookURL: getEnv("ROUTE_EVENTS_WEBHOOK_URL", ""),
+			RouteEventsDebounce:   getEnvAsDuration("ROUTE_EVENTS_DEBOUNCE", 2*time.Second),
+			RouteEventsMaxDelay:   getEnvAsDuration("ROUTE_EVENTS_MAX_DELAY", 10*time.Second),
+		},
+		Admin: AdminConfig{
+			AuthEnabled: getEnvAsBool("ADMIN_AUTH_ENABLED", true),
+			Token:       getEnv("ADMIN_TOKEN", ""),
+		},
+		Middleware: MiddlewareConfig{
+			RequestID:      getEnvAsBool("MIDDLEWARE_REQUEST_ID_ENABLED", true),
+			ClientCert:     getEnvAsBool("MIDDLEWARE_CLIENT_CERT_ENABLED", true),
+			RequestLogging: getEnvAsBool("MIDDLEWARE_REQUEST_LOGGING_ENABLED", true),
+			RateLimiting:   getEnvAsBool("MIDDLEWARE_RATE_LIMITING_ENABLED", true),
+
+			CorrelationIDKey:            getEnv("CORRELATION_ID_SIGNING_KEY", ""),
+			PanicGoroutineDumpThreshold: getEnvAsInt("PANIC_GOROUTINE_DUMP_THRESHOLD", 0),
+		},
+		Proxy: ProxyConfig{
+			MaxAttempts:      getEnvAsInt("PROXY_MAX_ATTEMPTS", 3),
+			RetryBufferBytes: int64(getEnvAsInt("PROXY_RETRY_BUFFER_BYTES", 1<<20)),
+			MaxBodyBytes:     int64(getEnvAsInt("PROXY_MAX_BODY_BYTES", 10<<20)),
+			DefaultBackend:   getEnv("PROXY_DEFAULT_BACKEND", ""),
+			LoadBalancing:    getEnv("PROXY_LOAD_BALANCING", "round-robin"),
+
+			UpstreamInsecureSkipVerify: getEnvAsBool("PROXY_UPSTREAM_INSECURE_SKIP_VERIFY", false),
+			UpstreamCAFile:             getEnv("PROXY_UPSTREAM_CA_FILE", ""),
+			MaxIdleConns:               getEnvAsInt("PROXY_MAX_IDLE_CONNS", 100),
+			MaxIdleConnsPerHost:        getEnvAsInt("PROXY_MAX_IDLE_CONNS_PER_HOST", 32),
+			FlushInterval:              getEnvAsDuration("PROXY_FLUSH_INTERVAL", 100*time.Millisecond),
+			BufferSize:                 getEnvAsInt("PROXY_BUFFER_SIZE", 32<<10),
+
+			CircuitBreakerMaxRequests:      getEnvAsInt("CIRCUIT_BREAKER_MAX_REQUESTS", 5),
+			CircuitBreakerSuccessThreshold: getEnvAsInt("CIRCUIT_BREAKER_SUCCESS_THRESHOLD", 3),
+			CircuitBreakerInterval:         getEnvAsDuration("CIRCUIT_BREAKER_INTERVAL", 60*time.Second),
+			CircuitBreakerTimeout:          getEnvAsDuration("CIRCUIT_BREAKER_TIMEOUT", 30*time.Second),
 		},
 		Logging: LoggingConfig{
 			Level:                getEnv("LOG_LEVEL", "info"),
@@ -111,29 +360,156 @@ func Load() *Config {
 			ErrorWebhookURL:      getEnv("ERROR_WEBHOOK_URL", ""),
 			SlackWebhookURL:      getEnv("SLACK_WEBHOOK_URL", ""),
 			AlertCooldown:        getEnvAsDuration("ALERT_COOLDOWN", 5*time.Minute),
+			AlertThresholds:      getEnvAsIntSlice("ALERT_THRESHOLDS", []int{10, 50, 100}),
 			LogRequests:          getEnvAsBool("LOG_REQUESTS", true),
 			LogResponses:         getEnvAsBool("LOG_RESPONSES", false),
 			LogHeaders:           getEnvAsBool("LOG_HEADERS", false),
-			SensitiveHeaders:     getEnvAsStringSlice("SENSITIVE_HEADERS", []string{"authorization", "cookie", "x-api-key"}),
+			SensitiveHeaders:     getEnvAsStringSlice("SENSITIVE_HEADERS", []string{"authorization", "cookie", "x-api-key", "x-auth-token"}),
 			SlowRequestThreshold: getEnvAsDuration("SLOW_REQUEST_THRESHOLD", 5*time.Second),
+			MaxLoggedHeaders:     getEnvAsInt("LOG_MAX_HEADERS", 50),
+			AccessLogFormat:      getEnv("ACCESS_LOG_FORMAT", ""),
+			AccessLogOutput:      getEnv("ACCESS_LOG_OUTPUT", "stdout"),
 			LokiURL:              getEnv("LOG_LOKI_URL", ""),
+			HookBufferSize:       getEnvAsInt("LOG_HOOK_BUFFER_SIZE", 1000),
+			HookWorkers:          getEnvAsInt("LOG_HOOK_WORKERS", 2),
+			FilePath:             getEnv("LOG_FILE_PATH", "logs/gateway.log"),
+			FileMaxSizeMB:        getEnvAsInt("LOG_FILE_MAX_SIZE_MB", 100),
+			FileMaxBackups:       getEnvAsInt("LOG_FILE_MAX_BACKUPS", 5),
+			FileMaxAge:           getEnvAsDuration("LOG_FILE_MAX_AGE", 7*24*time.Hour),
+			FileFlushInterval:    getEnvAsDuration("LOG_FILE_FLUSH_INTERVAL", 1*time.Second),
+			RedactPatterns:       getEnvAsStringSlice("LOG_REDACT_PATTERNS", []string{`(?i)password`, `(?i)token`, `(?i)secret`, `(?i)_key$`}),
+			SampleRate:           getEnvAsFloat("LOG_SAMPLE_RATE", 0),
+			SampleBurst:          getEnvAsInt("LOG_SAMPLE_BURST", 100),
+			StackDepth:           getEnvAsInt("LOG_STACK_DEPTH", 32),
+			StackFilter:          getEnvAsStringSlice("LOG_STACK_FILTER", nil),
 		},
 	}
 }
 
 func (c *Config) Validate() error {
+	if c.profileErr != nil {
+		return c.profileErr
+	}
 	if c.JWT.Secret == "supersecret" {
 		return errors.New("JWT_SECRET must be changed from default value")
 	}
+	if c.JWT.ClockSkewLeeway < 0 {
+		return errors.New("JWT_CLOCK_SKEW_LEEWAY must not be negative")
+	}
+	if !validJWTAlgorithms[c.JWT.Algorithm] {
+		return errors.New("JWT_ALGORITHM must be one of: HS256, HS384, HS512")
+	}
+	providerNames := make(map[string]bool)
+	for _, provider := range c.JWT.Providers {
+		prefix := providerEnvPrefix(provider.Name)
+		if provider.Name == "default" || providerNames[provider.Name] {
+			return fmt.Errorf("JWT_PROVIDERS must not repeat a name or use %q", "default")
+		}
+		providerNames[provider.Name] = true
+		if provider.Secret == "" {
+			return fmt.Errorf("%sSECRET must be set", prefix)
+		}
+		if !validJWTAlgorithms[provider.Algorithm] {
+			return fmt.Errorf("%sALGORITHM must be one of: HS256, HS384, HS512", prefix)
+		}
+	}
 	if c.Rate.Limit <= 0 {
 		return errors.New("RATE_LIMIT must be positive")
 	}
 	if c.Rate.BurstLimit <= 0 {
 		return errors.New("RATE_BURST_LIMIT must be positive")
 	}
+	if c.Rate.UserLimit < 0 || (c.Rate.UserLimit > 0 && c.Rate.UserBurstLimit <= 0) {
+		return errors.New("RATE_USER_LIMIT must not be negative and needs a positive RATE_USER_BURST_LIMIT")
+	}
+	if len(c.Rate.ExemptCIDRs) > 0 {
+		if _, err := gatewayproxy.ParseCIDRList(strings.Join(c.Rate.ExemptCIDRs, ",")); err != nil {
+			return errors.New("RATE_EXEMPT_CIDRS must be a comma-separated list of IPs or CIDRs")
+		}
+	}
+	if c.Rate.CleanupInterval <= 0 {
+		return errors.New("RATE_CLEANUP must be positive")
+	}
+	if c.Rate.ClientTTL < c.Rate.CleanupInterval {
+		return errors.New("RATE_CLIENT_TTL must be at least RATE_CLEANUP")
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 ||
+		c.Server.RequestTimeout < 0 {
+		return errors.New("READ_TIMEOUT, READ_HEADER_TIMEOUT, WRITE_TIMEOUT, IDLE_TIMEOUT and REQUEST_TIMEOUT must not be negative")
+	}
+	if c.Server.MaxHeaderCount < 0 || c.Server.MaxHeaderBytes < 0 {
+		return errors.New("MAX_HEADER_COUNT and MAX_HEADER_BYTES must not be negative")
+	}
+	if c.Logging.MaxLoggedHeaders < 0 {
+		return errors.New("LOG_MAX_HEADERS must not be negative")
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.Server.TLSRequireClientCert && (!c.Server.TLSEnabled() || c.Server.TLSClientCAFile == "") {
+		return errors.New("TLS_REQUIRE_CLIENT_CERT requires TLS_CERT_FILE, TLS_KEY_FILE and TLS_CLIENT_CA_FILE")
+	}
+	if c.Proxy.BufferSize < 1024 {
+		return errors.New("PROXY_BUFFER_SIZE must be at least 1024")
+	}
+	if c.Proxy.CircuitBreakerSuccessThreshold < 1 || c.Proxy.CircuitBreakerMaxRequests < c.Proxy.CircuitBreakerSuccessThreshold {
+		return errors.New("CIRCUIT_BREAKER_SUCCESS_THRESHOLD must be positive and at most CIRCUIT_BREAKER_MAX_REQUESTS")
+	}
+	if c.Proxy.CircuitBreakerInterval <= 0 || c.Proxy.CircuitBreakerTimeout <= 0 {
+		return errors.New("CIRCUIT_BREAKER_INTERVAL and CIRCUIT_BREAKER_TIMEOUT must be positive")
+	}
+	if c.Proxy.MaxBodyBytes < 0 {
+		return errors.New("PROXY_MAX_BODY_BYTES must not be negative")
+	}
+	if c.Proxy.DefaultBackend != "" {
+		if u, err := url.Parse(c.Proxy.DefaultBackend); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("PROXY_DEFAULT_BACKEND must be an absolute http or https URL")
+		}
+	}
+	validStrategies := map[string]bool{
+		"round-robin": true, "weighted-round-robin": true, "random": true,
+		"least-connections": true, "zone-aware": true, "least-response-time": true,
+	}
+	if !validStrategies[c.Proxy.LoadBalancing] {
+		return errors.New("PROXY_LOAD_BALANCING must be one of: round-robin, weighted-round-robin, random, least-connections, zone-aware, least-response-time")
+	}
+	if c.Kubernetes.RouteEventsWebhookURL != "" {
+		if u, err := url.Parse(c.Kubernetes.RouteEventsWebhookURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("ROUTE_EVENTS_WEBHOOK_URL must be an absolute http or https URL")
+		}
+	}
+	if c.Kubernetes.RouteEventsDebounce <= 0 {
+		return errors.New("ROUTE_EVENTS_DEBOUNCE must be positive")
+	}
+	if c.Kubernetes.RouteEventsMaxDelay < c.Kubernetes.RouteEventsDebounce {
+		return errors.New("ROUTE_EVENTS_MAX_DELAY must be at least ROUTE_EVENTS_DEBOUNCE")
+	}
+	if _, err := gatewayproxy.ParseHealthCheckMethod(c.Health.Method); err != nil {
+		return errors.New("HEALTH_CHECK_METHOD must be GET or HEAD")
+	}
+	if _, err := gatewayproxy.ParseStatusRange(c.Health.ExpectedStatus); err != nil {
+		return errors.New("HEALTH_CHECK_EXPECTED_STATUS must be a status code or range such as 200-399")
+	}
 	if c.Kubernetes.Enabled && c.Kubernetes.Namespace == "" {
 		return errors.New("KUBERNETES_NAMESPACE must be set when Kubernetes is enabled")
 	}
+	if c.Middleware.CorrelationIDKey != "" {
+		if len(c.Middleware.CorrelationIDKey) < 32 {
+			return errors.New("CORRELATION_ID_SIGNING_KEY must be at least 32 characters")
+		}
+		if !c.Middleware.RequestID {
+			return errors.New("CORRELATION_ID_SIGNING_KEY needs MIDDLEWARE_REQUEST_ID_ENABLED")
+		}
+	}
+	if c.Middleware.PanicGoroutineDumpThreshold < 0 {
+		return errors.New("PANIC_GOROUTINE_DUMP_THRESHOLD must not be negative")
+	}
+	if !strings.HasPrefix(c.Kubernetes.DefaultPathTemplate, "/") {
+		return errors.New("KUBERNETES_DEFAULT_PATH_TEMPLATE must start with /")
+	}
+	if strings.TrimSpace(c.Kubernetes.DefaultMethod) == "" {
+		return errors.New("KUBERNETES_DEFAULT_METHOD must list at least one method or be ANY")
+	}
 
 	validLevels := map[string]bool{
 		"debug": true, "info": true, "warn": true, "error": true, "fatal": true,
@@ -143,10 +519,45 @@ func (c *Config) Validate() error {
 	}
 
 	validFormats := map[string]bool{
-		"json": true, "text": true,
+		"json": true, "text": true, "console": true,
 	}
 	if !validFormats[c.Logging.Format] {
-		return errors.New("LOG_FORMAT must be one of: json, text")
+		return errors.New("LOG_FORMAT must be one of: json, text, console")
+	}
+
+	validOutputs := map[string]bool{
+		"stdout": true, "stderr": true, "file": true,
+	}
+	if !validOutputs[c.Logging.Output] {
+		return errors.New("LOG_OUTPUT must be one of: stdout, stderr, file")
+	}
+
+	if c.Logging.AccessLogFormat != "" && c.Logging.AccessLogFormat != "common" && c.Logging.AccessLogFormat != "combined" {
+		return errors.New("ACCESS_LOG_FORMAT must be one of: common, combined")
+	}
+	if c.Logging.AccessLogFormat != "" && c.Logging.AccessLogOutput == "" {
+		return errors.New("ACCESS_LOG_OUTPUT must be set when ACCESS_LOG_FORMAT is")
+	}
+	if c.Logging.Output == "file" && c.Logging.FilePath == "" {
+		return errors.New("LOG_FILE_PATH must be set when LOG_OUTPUT is file")
+	}
+
+	if c.Logging.AlertCooldown <= 0 {
+		return errors.New("ALERT_COOLDOWN must be positive")
+	}
+	if len(c.Logging.AlertThresholds) == 0 {
+		return errors.New("ALERT_THRESHOLDS must list at least one error count")
+	}
+	for i, threshold := range c.Logging.AlertThresholds {
+		if threshold <= 0 || (i > 0 && threshold <= c.Logging.AlertThresholds[i-1]) {
+			return errors.New("ALERT_THRESHOLDS must be positive and ascending")
+		}
+	}
+	if c.Logging.StackDepth <= 0 {
+		return errors.New("LOG_STACK_DEPTH must be positive")
+	}
+	if c.Logging.SampleRate < 0 || c.Logging.SampleRate > 1 {
+		return errors.New("LOG_SAMPLE_RATE must be between 0 and 1")
 	}
 
 	return nil
@@ -171,6 +582,34 @@ func getEnvAsInt(key string, fallback int) int {
 	return val
 }
 
+func getEnvAsFloat(key string, fallback float64) float64 {
+	valStr := getEnv(key, "")
+	if valStr == "" {
+		return fallback
+	}
+	val, err := strconv.ParseFloat(valStr, 64)
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+// getEnvAsIntSlice parses a comma-separated list of integers, falling back when any is invalid
+func getEnvAsIntSlice(key string, fallback []int) []int {
+	var values []int
+	for _, part := range getEnvAsStringSlice(key, nil) {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return fallback
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
 func getEnvAsBool(key string, fallback bool) bool {
 	valStr := getEnv(key, "")
 	if valStr == "" {
@@ -195,6 +634,26 @@ func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
 	return val
 }
 
+// getAuthProviders reads the providers listed in JWT_PROVIDERS
+func getAuthProviders() []AuthProviderConfig {
+	var providers []AuthProviderConfig
+	for _, name := range getEnvAsStringSlice("JWT_PROVIDERS", nil) {
+		prefix := providerEnvPrefix(name)
+		providers = append(providers, AuthProviderConfig{
+			Name:      name,
+			Secret:    getEnv(prefix+"SECRET", ""),
+			Algorithm: getEnv(prefix+"ALGORITHM", "HS256"),
+			Issuer:    getEnv(prefix+"ISSUER", ""),
+		})
+	}
+	return providers
+}
+
+// providerEnvPrefix is the prefix of a provider's variables, e.g. JWT_PROVIDER_PARTNER_API_
+func providerEnvPrefix(name string) string {
+	return "JWT_PROVIDER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
 func getEnvAsStringSlice(key string, fallback []string) []string {
 	valStr := getEnv(key, "")
 	if valStr == "" {
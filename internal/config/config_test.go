@@ -0,0 +1,598 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv removes variables for the duration of the test; the env files loaded
+// by a test set them in the process environment
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestServerTimeoutsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    ServerConfig
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: ServerConfig{ReadHeaderTimeout: 10 * time.Second, WriteTimeout: 30 * time.Second, IdleTimeout: 120 * time.Second},
+		},
+		{
+			name: "configured",
+			env:  map[string]string{"READ_HEADER_TIMEOUT": "2s", "WRITE_TIMEOUT": "1m", "IDLE_TIMEOUT": "45s"},
+			want: ServerConfig{ReadHeaderTimeout: 2 * time.Second, WriteTimeout: time.Minute, IdleTimeout: 45 * time.Second},
+		},
+		{
+			name: "write timeout disabled",
+			env:  map[string]string{"WRITE_TIMEOUT": "0s"},
+			want: ServerConfig{ReadHeaderTimeout: 10 * time.Second, IdleTimeout: 120 * time.Second},
+		},
+		{
+			name:    "negative timeout",
+			env:     map[string]string{"IDLE_TIMEOUT": "-1s"},
+			wantErr: "IDLE_TIMEOUT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "READ_HEADER_TIMEOUT", "WRITE_TIMEOUT", "IDLE_TIMEOUT", "REQUEST_TIMEOUT")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			got := cfg.Server
+			if got.ReadHeaderTimeout != tt.want.ReadHeaderTimeout || got.WriteTimeout != tt.want.WriteTimeout || got.IdleTimeout != tt.want.IdleTimeout {
+				t.Errorf("read header, write, idle = %v, %v, %v, want %v, %v, %v",
+					got.ReadHeaderTimeout, got.WriteTimeout, got.IdleTimeout,
+					tt.want.ReadHeaderTimeout, tt.want.WriteTimeout, tt.want.IdleTimeout)
+			}
+		})
+	}
+}
+
+func TestRateClientTTLFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantCleanup time.Duration
+		wantTTL     time.Duration
+		wantErr     string
+	}{
+		{name: "defaults", wantCleanup: time.Minute, wantTTL: 10 * time.Minute},
+		{
+			name:        "configured separately",
+			env:         map[string]string{"RATE_CLEANUP": "30s", "RATE_CLIENT_TTL": "15m"},
+			wantCleanup: 30 * time.Second,
+			wantTTL:     15 * time.Minute,
+		},
+		{
+			name:    "TTL shorter than the sweep",
+			env:     map[string]string{"RATE_CLEANUP": "5m", "RATE_CLIENT_TTL": "1m"},
+			wantErr: "RATE_CLIENT_TTL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "RATE_CLEANUP", "RATE_CLIENT_TTL")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Rate.CleanupInterval != tt.wantCleanup || cfg.Rate.ClientTTL != tt.wantTTL {
+				t.Errorf("cleanup, TTL = %v, %v, want %v, %v", cfg.Rate.CleanupInterval, cfg.Rate.ClientTTL, tt.wantCleanup, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestHealthProbeFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantMethod string
+		wantStatus string
+		wantErr    string
+	}{
+		{name: "defaults", wantMethod: "GET", wantStatus: "200-399"},
+		{
+			name:       "HEAD check with 204 as healthy",
+			env:        map[string]string{"HEALTH_CHECK_METHOD": "HEAD", "HEALTH_CHECK_EXPECTED_STATUS": "204"},
+			wantMethod: "HEAD",
+			wantStatus: "204",
+		},
+		{name: "method that changes state", env: map[string]string{"HEALTH_CHECK_METHOD": "POST"}, wantErr: "HEALTH_CHECK_METHOD"},
+		{name: "malformed status", env: map[string]string{"HEALTH_CHECK_EXPECTED_STATUS": "2xx"}, wantErr: "HEALTH_CHECK_EXPECTED_STATUS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "HEALTH_CHECK_METHOD", "HEALTH_CHECK_EXPECTED_STATUS")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Health.Method != tt.wantMethod || cfg.Health.ExpectedStatus != tt.wantStatus {
+				t.Errorf("method, status = %q, %q, want %q, %q", cfg.Health.Method, cfg.Health.ExpectedStatus, tt.wantMethod, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCorrelationIDKeyFromEnv(t *testing.T) {
+	key := strings.Repeat("k", 32)
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "signing disabled by default"},
+		{name: "configured", env: map[string]string{"CORRELATION_ID_SIGNING_KEY": key}, want: key},
+		{name: "key too short", env: map[string]string{"CORRELATION_ID_SIGNING_KEY": "short"}, wantErr: "at least 32"},
+		{
+			name:    "request ID middleware disabled",
+			env:     map[string]string{"CORRELATION_ID_SIGNING_KEY": key, "MIDDLEWARE_REQUEST_ID_ENABLED": "false"},
+			wantErr: "MIDDLEWARE_REQUEST_ID_ENABLED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "CORRELATION_ID_SIGNING_KEY", "MIDDLEWARE_REQUEST_ID_ENABLED")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Middleware.CorrelationIDKey != tt.want {
+				t.Errorf("CorrelationIDKey = %q, want %q", cfg.Middleware.CorrelationIDKey, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthProvidersFromEnv(t *testing.T) {
+	partner := AuthProviderConfig{Name: "partner-api", Secret: "partner-secret", Algorithm: "HS256"}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []AuthProviderConfig
+		wantErr string
+	}{
+		{name: "none by default"},
+		{
+			name: "configured",
+			env: map[string]string{
+				"JWT_PROVIDERS":                   "partner-api, legacy",
+				"JWT_PROVIDER_PARTNER_API_SECRET": "partner-secret",
+				"JWT_PROVIDER_LEGACY_SECRET":      "legacy-secret",
+				"JWT_PROVIDER_LEGACY_ALGORITHM":   "HS512",
+				"JWT_PROVIDER_LEGACY_ISSUER":      "legacy.example.com",
+			},
+			want: []AuthProviderConfig{partner, {Name: "legacy", Secret: "legacy-secret", Algorithm: "HS512", Issuer: "legacy.example.com"}},
+		},
+		{name: "missing secret", env: map[string]string{"JWT_PROVIDERS": "partner-api"}, wantErr: "JWT_PROVIDER_PARTNER_API_SECRET"},
+		{
+			name:    "invalid algorithm",
+			env:     map[string]string{"JWT_PROVIDERS": "partner-api", "JWT_PROVIDER_PARTNER_API_SECRET": "partner-secret", "JWT_PROVIDER_PARTNER_API_ALGORITHM": "RS256"},
+			wantErr: "JWT_PROVIDER_PARTNER_API_ALGORITHM",
+		},
+		{
+			name:    "repeated name",
+			env:     map[string]string{"JWT_PROVIDERS": "partner-api,partner-api", "JWT_PROVIDER_PARTNER_API_SECRET": "partner-secret"},
+			wantErr: "JWT_PROVIDERS",
+		},
+		{name: "reserved name", env: map[string]string{"JWT_PROVIDERS": "default", "JWT_PROVIDER_DEFAULT_SECRET": "secret"}, wantErr: "JWT_PROVIDERS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "JWT_PROVIDERS")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if !reflect.DeepEqual(cfg.JWT.Providers, tt.want) {
+				t.Errorf("Providers = %+v, want %+v", cfg.JWT.Providers, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateExemptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantPaths []string
+		wantCIDRs []string
+		wantErr   string
+	}{
+		{name: "probe and metrics paths by default", wantPaths: []string{"/health", "/health/detail", "/ready", "/livez", "/metrics"}},
+		{
+			name:      "configured",
+			env:       map[string]string{"RATE_EXEMPT_PATHS": "/health, /status", "RATE_EXEMPT_CIDRS": "10.0.0.0/8, 192.0.2.1"},
+			wantPaths: []string{"/health", "/status"},
+			wantCIDRs: []string{"10.0.0.0/8", "192.0.2.1"},
+		},
+		{name: "invalid CIDR", env: map[string]string{"RATE_EXEMPT_CIDRS": "10.0.0.0/33"}, wantErr: "RATE_EXEMPT_CIDRS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "RATE_EXEMPT_PATHS", "RATE_EXEMPT_CIDRS")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Rate.ExemptPaths, tt.wantPaths) || !reflect.DeepEqual(cfg.Rate.ExemptCIDRs, tt.wantCIDRs) {
+				t.Errorf("exemptions = %v %v, want %v %v", cfg.Rate.ExemptPaths, cfg.Rate.ExemptCIDRs, tt.wantPaths, tt.wantCIDRs)
+			}
+		})
+	}
+}
+
+func TestJWTClockSkewLeewayFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "default", want: 30 * time.Second},
+		{name: "configured", value: "2m", want: 2 * time.Minute},
+		{name: "disabled", value: "0s", want: 0},
+		{name: "negative", value: "-1s", wantErr: "JWT_CLOCK_SKEW_LEEWAY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "JWT_CLOCK_SKEW_LEEWAY")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			if tt.value != "" {
+				t.Setenv("JWT_CLOCK_SKEW_LEEWAY", tt.value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.JWT.ClockSkewLeeway != tt.want {
+				t.Errorf("ClockSkewLeeway = %v, want %v", cfg.JWT.ClockSkewLeeway, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyFlushSettingsFromEnv(t *testing.T) {
+	tests := []struct {
+		name              string
+		env               map[string]string
+		wantFlushInterval time.Duration
+		wantBufferSize    int
+		wantErr           string
+	}{
+		{name: "defaults", wantFlushInterval: 100 * time.Millisecond, wantBufferSize: 32 << 10},
+		{
+			name:              "configured",
+			env:               map[string]string{"PROXY_FLUSH_INTERVAL": "-1ns", "PROXY_BUFFER_SIZE": "65536"},
+			wantFlushInterval: -1,
+			wantBufferSize:    65536,
+		},
+		{name: "buffer too small", env: map[string]string{"PROXY_BUFFER_SIZE": "512"}, wantErr: "PROXY_BUFFER_SIZE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "PROXY_FLUSH_INTERVAL", "PROXY_BUFFER_SIZE")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Proxy.FlushInterval != tt.wantFlushInterval || cfg.Proxy.BufferSize != tt.wantBufferSize {
+				t.Errorf("flush interval, buffer size = %v, %d, want %v, %d",
+					cfg.Proxy.FlushInterval, cfg.Proxy.BufferSize, tt.wantFlushInterval, tt.wantBufferSize)
+			}
+		})
+	}
+}
+
+func TestAlertSettingsFromEnv(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		wantThresholds []int
+		wantErr        string
+	}{
+		{name: "defaults", wantThresholds: []int{10, 50, 100}},
+		{name: "configured", env: map[string]string{"ALERT_THRESHOLDS": "5, 20"}, wantThresholds: []int{5, 20}},
+		{name: "unparsable list falls back", env: map[string]string{"ALERT_THRESHOLDS": "5,many"}, wantThresholds: []int{10, 50, 100}},
+		{name: "not ascending", env: map[string]string{"ALERT_THRESHOLDS": "50,10"}, wantErr: "ALERT_THRESHOLDS"},
+		{name: "zero threshold", env: map[string]string{"ALERT_THRESHOLDS": "0,10"}, wantErr: "ALERT_THRESHOLDS"},
+		{name: "zero cooldown", env: map[string]string{"ALERT_COOLDOWN": "0s"}, wantErr: "ALERT_COOLDOWN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "ALERT_THRESHOLDS", "ALERT_COOLDOWN")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Logging.AlertThresholds, tt.wantThresholds) {
+				t.Errorf("AlertThresholds = %v, want %v", cfg.Logging.AlertThresholds, tt.wantThresholds)
+			}
+		})
+	}
+}
+
+func TestStackTraceSettingsFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantDepth  int
+		wantFilter []string
+		wantErr    string
+	}{
+		{name: "defaults", wantDepth: 32},
+		{
+			name:       "configured",
+			env:        map[string]string{"LOG_STACK_DEPTH": "8", "LOG_STACK_FILTER": "runtime, example.com/app/vendor"},
+			wantDepth:  8,
+			wantFilter: []string{"runtime", "example.com/app/vendor"},
+		},
+		{name: "zero depth", env: map[string]string{"LOG_STACK_DEPTH": "0"}, wantErr: "LOG_STACK_DEPTH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "LOG_STACK_DEPTH", "LOG_STACK_FILTER")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Logging.StackDepth != tt.wantDepth || !reflect.DeepEqual(cfg.Logging.StackFilter, tt.wantFilter) {
+				t.Errorf("stack settings = %d %q, want %d %q", cfg.Logging.StackDepth, cfg.Logging.StackFilter, tt.wantDepth, tt.wantFilter)
+			}
+		})
+	}
+}
+
+func TestRouteEventsSettingsFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantDebounce time.Duration
+		wantMaxDelay time.Duration
+		wantErr      string
+	}{
+		{name: "defaults", wantDebounce: 2 * time.Second, wantMaxDelay: 10 * time.Second},
+		{
+			name:         "configured",
+			env:          map[string]string{"ROUTE_EVENTS_WEBHOOK_URL": "https://hooks.example.com/routes", "ROUTE_EVENTS_DEBOUNCE": "500ms", "ROUTE_EVENTS_MAX_DELAY": "5s"},
+			wantDebounce: 500 * time.Millisecond,
+			wantMaxDelay: 5 * time.Second,
+		},
+		{name: "relative webhook URL", env: map[string]string{"ROUTE_EVENTS_WEBHOOK_URL": "/routes"}, wantErr: "ROUTE_EVENTS_WEBHOOK_URL"},
+		{name: "zero debounce", env: map[string]string{"ROUTE_EVENTS_DEBOUNCE": "0s"}, wantErr: "ROUTE_EVENTS_DEBOUNCE"},
+		{name: "max delay below debounce", env: map[string]string{"ROUTE_EVENTS_DEBOUNCE": "5s", "ROUTE_EVENTS_MAX_DELAY": "1s"}, wantErr: "ROUTE_EVENTS_MAX_DELAY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "ROUTE_EVENTS_WEBHOOK_URL", "ROUTE_EVENTS_DEBOUNCE", "ROUTE_EVENTS_MAX_DELAY")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Kubernetes.RouteEventsDebounce != tt.wantDebounce || cfg.Kubernetes.RouteEventsMaxDelay != tt.wantMaxDelay {
+				t.Errorf("debounce, max delay = %v, %v, want %v, %v",
+					cfg.Kubernetes.RouteEventsDebounce, cfg.Kubernetes.RouteEventsMaxDelay, tt.wantDebounce, tt.wantMaxDelay)
+			}
+		})
+	}
+}
+
+func TestDefaultLoadBalancingFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "default", want: "round-robin"},
+		{name: "configured", env: map[string]string{"PROXY_LOAD_BALANCING": "least-connections"}, want: "least-connections"},
+		{name: "unknown strategy", env: map[string]string{"PROXY_LOAD_BALANCING": "fastest"}, wantErr: "PROXY_LOAD_BALANCING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "PROXY_LOAD_BALANCING")
+			t.Setenv("JWT_SECRET", "config-test-secret")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := Load()
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Proxy.LoadBalancing != tt.want {
+				t.Errorf("LoadBalancing = %q, want %q", cfg.Proxy.LoadBalancing, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsProcessEnvironment(t *testing.T) {
+	unsetEnv(t, "PROFILE", "ENVIRONMENT", "LOG_LEVEL")
+	t.Setenv("PORT", ":7070") // Set by the process environment, e.g. the pod spec
+	t.Setenv("JWT_SECRET", "reload-test-secret")
+
+	path := filepath.Join(t.TempDir(), ".env")
+	writeFile := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write .env: %v", err)
+		}
+	}
+	writeFile("PORT=:8080\nLOG_LEVEL=info\n")
+	if cfg := LoadFromFile(path); cfg.Server.Port != ":7070" || cfg.Logging.Level != "info" {
+		t.Fatalf("Load: PORT = %q, LOG_LEVEL = %q", cfg.Server.Port, cfg.Logging.Level)
+	}
+
+	writeFile("PORT=:9090\nLOG_LEVEL=debug\n")
+	cfg := Reload()
+	if cfg.Server.Port != ":7070" {
+		t.Errorf("PORT after reload = %q, want the process environment's :7070", cfg.Server.Port)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Errorf("LOG_LEVEL after reload = %q, want the edited file's debug", cfg.Logging.Level)
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultEnvFile is the base env file Load reads
+const DefaultEnvFile = ".env"
+
+// envFile is the base env file the configuration was last loaded from, re-read by Reload
+var envFile = DefaultEnvFile
+
+// processEnv is the environment as it was before Load applied any env file. Reload
+// re-applies it over the files, so the real environment keeps winning on SIGHUP.
+var processEnv []string
+
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Load reads the configuration from .env and the active profile's overrides
+func Load() *Config {
+	return LoadFromFile(DefaultEnvFile)
+}
+
+// LoadFromFile reads the configuration from a base env file, overlaid by the active
+// profile's file path.<profile> (.env.prod for PROFILE=prod). Variables already in
+// the environment win over both files. Profile problems are reported by Validate.
+func LoadFromFile(path string) *Config {
+	envFile = path
+	processEnv = os.Environ()
+	profile, file, err := resolveProfile(path)
+
+	// godotenv.Load never overrides a variable, so the profile file goes first
+	if file != "" {
+		if loadErr := godotenv.Load(file); loadErr != nil {
+			err = fmt.Errorf("PROFILE %q: %w", profile, loadErr)
+		}
+	}
+	godotenv.Load(path)
+
+	return withProfile(fromEnv(), profile, err)
+}
+
+// Reload re-reads configuration for a runtime reload. Edits to the env files take
+// effect, while variables set in the environment before Load still win over them.
+func Reload() *Config {
+	godotenv.Overload(envFile)
+	restoreProcessEnv()
+	profile, file, err := resolveProfile(envFile)
+
+	if file != "" {
+		if loadErr := godotenv.Overload(file); loadErr != nil {
+			err = fmt.Errorf("PROFILE %q: %w", profile, loadErr)
+		}
+		restoreProcessEnv()
+	}
+
+	return withProfile(fromEnv(), profile, err)
+}
+
+// restoreProcessEnv sets the variables the gateway started with back over values read from env files
+func restoreProcessEnv() {
+	for _, variable := range processEnv {
+		if key, value, found := strings.Cut(variable, "="); found {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+// resolveProfile finds the active profile and its override file. PROFILE selects it,
+// falling back to ENVIRONMENT, from the environment or else the base file. A profile
+// named by PROFILE must have a file; one only named by ENVIRONMENT may have none.
+func resolveProfile(path string) (profile, file string, err error) {
+	base, _ := godotenv.Read(path)
+
+	required := true
+	profile = lookupEnv("PROFILE", base)
+	if profile == "" {
+		required = false
+		profile = lookupEnv("ENVIRONMENT", base)
+	}
+	if profile == "" {
+		return "", "", nil
+	}
+	if !profileName.MatchString(profile) {
+		return profile, "", fmt.Errorf("PROFILE %q must be lowercase letters, digits, '-' or '_'", profile)
+	}
+
+	file = path + "." + profile
+	if _, statErr := os.Stat(file); statErr != nil {
+		if required || !errors.Is(statErr, fs.ErrNotExist) {
+			return profile, "", fmt.Errorf("PROFILE %q: %w", profile, statErr)
+		}
+		return profile, "", nil
+	}
+	return profile, file, nil
+}
+
+// lookupEnv returns a variable from the environment, else from the parsed base file
+func lookupEnv(key string, base map[string]string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return base[key]
+}
+
+func withProfile(cfg *Config, profile string, err error) *Config {
+	cfg.Profile = profile
+	cfg.profileErr = err
+	return cfg
+}
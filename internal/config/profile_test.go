@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeEnvFile writes an env file into dir and returns its path
+func writeEnvFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadFromFileProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        string
+		profiles    map[string]string
+		env         map[string]string
+		wantProfile string
+		wantPort    string
+		wantLevel   string
+		wantErr     string
+	}{
+		{
+			name:        "prod profile overrides the base",
+			base:        "PROFILE=prod\nPORT=:8080\nLOG_LEVEL=debug\n",
+			profiles:    map[string]string{"prod": "PORT=:9090\n"},
+			wantProfile: "prod",
+			wantPort:    ":9090",
+			wantLevel:   "debug",
+		},
+		{
+			name:        "profile selected from the environment",
+			base:        "PORT=:8080\n",
+			profiles:    map[string]string{"prod": "PORT=:9090\nLOG_LEVEL=warn\n"},
+			env:         map[string]string{"PROFILE": "prod"},
+			wantProfile: "prod",
+			wantPort:    ":9090",
+			wantLevel:   "warn",
+		},
+		{
+			name:        "environment variables win over the profile",
+			base:        "PROFILE=prod\nPORT=:8080\n",
+			profiles:    map[string]string{"prod": "PORT=:9090\n"},
+			env:         map[string]string{"PORT": ":7070"},
+			wantProfile: "prod",
+			wantPort:    ":7070",
+			wantLevel:   "info",
+		},
+		{
+			name:        "ENVIRONMENT without a profile file",
+			base:        "ENVIRONMENT=staging\nPORT=:8080\n",
+			wantProfile: "staging",
+			wantPort:    ":8080",
+			wantLevel:   "info",
+		},
+		{
+			name:    "PROFILE without a profile file",
+			base:    "PROFILE=prod\n",
+			wantErr: `PROFILE "prod"`,
+		},
+		{
+			name:    "invalid profile name",
+			base:    "PROFILE=../prod\n",
+			wantErr: "must be lowercase letters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "PROFILE", "ENVIRONMENT", "PORT", "LOG_LEVEL")
+			t.Setenv("JWT_SECRET", "profile-test-secret")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			dir := t.TempDir()
+			path := writeEnvFile(t, dir, ".env", tt.base)
+			for profile, content := range tt.profiles {
+				writeEnvFile(t, dir, ".env."+profile, content)
+			}
+
+			cfg := LoadFromFile(path)
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if cfg.Profile != tt.wantProfile {
+				t.Errorf("Profile = %q, want %q", cfg.Profile, tt.wantProfile)
+			}
+			if cfg.Server.Port != tt.wantPort {
+				t.Errorf("PORT = %q, want %q", cfg.Server.Port, tt.wantPort)
+			}
+			if cfg.Logging.Level != tt.wantLevel {
+				t.Errorf("LOG_LEVEL = %q, want %q", cfg.Logging.Level, tt.wantLevel)
+			}
+		})
+	}
+}
@@ -1,55 +1,218 @@
 package handlers
 
 import (
+	"api-gateway/internal/version"
+	"context"
 	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// Readiness status values used in the readiness schema
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not ready"
+	StatusPass     = "pass"
+	StatusFail     = "fail"
+)
+
+// Liveness status values and the time a liveness check may take before it counts as stuck
+const (
+	StatusAlive   = "alive"
+	StatusDead    = "dead"
+	livenessLimit = 2 * time.Second
+)
+
 type HealthResponse struct {
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
 	Service   string    `json:"service"`
 	Version   string    `json:"version,omitempty"`
+	Commit    string    `json:"commit,omitempty"`
+	BuildDate string    `json:"build_date,omitempty"`
 }
 
-// HealthHandler returns the health status of the API Gateway
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+// CheckResult is the machine-parseable result of a single readiness check
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+	Latency string `json:"latency"`
+}
 
-	response := HealthResponse{
-		Status:    "healthy",
+// ReadinessResponse is the schema returned by /ready and /health/detail
+type ReadinessResponse struct {
+	Status    string        `json:"status"`
+	Timestamp time.Time     `json:"timestamp"`
+	Service   string        `json:"service"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// ReadinessChecker is a dependency the gateway needs before it can serve traffic
+type ReadinessChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// CheckFunc reports an error when the dependency it checks is not ready
+type CheckFunc func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+func (c namedCheck) Name() string {
+	return c.name
+}
+
+func (c namedCheck) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+// NewCheck adapts a plain function into a named ReadinessChecker
+func NewCheck(name string, check CheckFunc) ReadinessChecker {
+	return namedCheck{name: name, check: check}
+}
+
+// Readiness runs registered checks and reports them using the readiness schema
+type Readiness struct {
+	checks []ReadinessChecker
+	mu     sync.RWMutex
+}
+
+// NewReadiness creates a readiness reporter with no checks registered
+func NewReadiness() *Readiness {
+	return &Readiness{
+		checks: make([]ReadinessChecker, 0),
+	}
+}
+
+// Register adds a check to the readiness report
+func (rd *Readiness) Register(checker ReadinessChecker) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.checks = append(rd.checks, checker)
+}
+
+// Evaluate runs every registered check and builds the readiness report
+func (rd *Readiness) Evaluate(ctx context.Context) ReadinessResponse {
+	rd.mu.RLock()
+	checks := make([]ReadinessChecker, len(rd.checks))
+	copy(checks, rd.checks)
+	rd.mu.RUnlock()
+
+	response := ReadinessResponse{
+		Status:    StatusReady,
 		Timestamp: time.Now().UTC(),
 		Service:   "api-gateway",
-		Version:   "1.0.0",
+		Checks:    make([]CheckResult, 0, len(checks)),
 	}
 
-	json.NewEncoder(w).Encode(response)
+	for _, c := range checks {
+		start := time.Now()
+		err := c.Check(ctx)
+
+		result := CheckResult{
+			Name:    c.Name(),
+			Status:  StatusPass,
+			Latency: time.Since(start).String(),
+		}
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = err.Error()
+			response.Status = StatusNotReady
+		}
+
+		response.Checks = append(response.Checks, result)
+	}
+
+	return response
 }
 
-// ReadinessHandler checks if the gateway is ready to serve traffic
-func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+// Handle serves the readiness report, returning 503 when any check fails
+func (rd *Readiness) Handle(w http.ResponseWriter, r *http.Request) {
+	response := rd.Evaluate(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
+	if response.Status == StatusReady {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 
-	// TODO Add any readiness checks here (database connections, etc.)
-	ready := true
+	json.NewEncoder(w).Encode(response)
+}
 
-	if ready {
-		w.WriteHeader(http.StatusOK)
-		response := HealthResponse{
-			Status:    "ready",
-			Timestamp: time.Now().UTC(),
-			Service:   "api-gateway",
+// LivenessResponse is the schema returned by /livez
+type LivenessResponse struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Service   string    `json:"service"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Liveness answers whether the process should be restarted. Unlike readiness,
+// it must not depend on external state such as discovery being synced: it only
+// fails when the gateway is stuck in a state it cannot recover from on its own,
+// like a deadlocked internal goroutine. A failing readiness check takes the pod
+// out of rotation; a failing liveness check gets it killed.
+type Liveness struct {
+	check CheckFunc
+}
+
+// NewLiveness creates a liveness probe; a nil check always reports alive
+func NewLiveness(check CheckFunc) *Liveness {
+	return &Liveness{check: check}
+}
+
+// Handle serves the liveness probe, returning 503 when the check fails or hangs
+func (l *Liveness) Handle(w http.ResponseWriter, r *http.Request) {
+	response := LivenessResponse{
+		Status:    StatusAlive,
+		Timestamp: time.Now().UTC(),
+		Service:   "api-gateway",
+	}
+
+	if l.check != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), livenessLimit)
+		defer cancel()
+
+		if err := l.check(ctx); err != nil {
+			response.Status = StatusDead
+			response.Detail = err.Error()
 		}
-		json.NewEncoder(w).Encode(response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status == StatusAlive {
+		w.WriteHeader(http.StatusOK)
 	} else {
 		w.WriteHeader(http.StatusServiceUnavailable)
-		response := HealthResponse{
-			Status:    "not ready",
-			Timestamp: time.Now().UTC(),
-			Service:   "api-gateway",
-		}
-		json.NewEncoder(w).Encode(response)
 	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// LivenessHandler serves /livez with no internal checks registered
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	NewLiveness(nil).Handle(w, r)
+}
+
+// HealthHandler returns the health status of the API Gateway
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC(),
+		Service:   "api-gateway",
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.BuildDate,
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"api-gateway/internal/version"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func passing(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("discovery not synced") }
+
+func TestReadinessSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]CheckFunc
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "all ready",
+			checks:     map[string]CheckFunc{"discovery": passing, "backends": passing},
+			wantStatus: StatusReady,
+			wantChecks: map[string]string{"discovery": StatusPass, "backends": StatusPass},
+		},
+		{
+			name:       "partially failing",
+			checks:     map[string]CheckFunc{"discovery": failing, "backends": passing},
+			wantStatus: StatusNotReady,
+			wantChecks: map[string]string{"discovery": StatusFail, "backends": StatusPass},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness := NewReadiness()
+			for name, check := range tt.checks {
+				readiness.Register(NewCheck(name, check))
+			}
+			rec := httptest.NewRecorder()
+			readiness.Handle(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+
+			// Decoded generically so the test pins the wire format, not the Go types
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if body["status"] != tt.wantStatus {
+				t.Errorf("status = %v, want %q", body["status"], tt.wantStatus)
+			}
+			if body["service"] != "api-gateway" {
+				t.Errorf("service = %v, want api-gateway", body["service"])
+			}
+			if timestamp, _ := body["timestamp"].(string); timestamp == "" {
+				t.Error("timestamp missing")
+			} else if _, err := time.Parse(time.RFC3339Nano, timestamp); err != nil {
+				t.Errorf("timestamp %q is not RFC 3339: %v", timestamp, err)
+			}
+
+			checks, ok := body["checks"].([]interface{})
+			if !ok || len(checks) != len(tt.wantChecks) {
+				t.Fatalf("checks = %v, want %d entries", body["checks"], len(tt.wantChecks))
+			}
+			for _, raw := range checks {
+				check := raw.(map[string]interface{})
+				name, _ := check["name"].(string)
+				if check["status"] != tt.wantChecks[name] {
+					t.Errorf("check %q status = %v, want %q", name, check["status"], tt.wantChecks[name])
+				}
+				if latency, _ := check["latency"].(string); latency == "" {
+					t.Errorf("check %q has no latency", name)
+				} else if _, err := time.ParseDuration(latency); err != nil {
+					t.Errorf("check %q latency %q is not a duration", name, latency)
+				}
+				_, hasDetail := check["detail"]
+				if hasDetail != (check["status"] == StatusFail) {
+					t.Errorf("check %q detail = %v, want one only on failure", name, check["detail"])
+				}
+			}
+		})
+	}
+}
+
+// staticChecker is a ReadinessChecker with a fixed result
+type staticChecker struct {
+	name string
+	err  error
+}
+
+func (c staticChecker) Name() string                    { return c.name }
+func (c staticChecker) Check(ctx context.Context) error { return c.err }
+
+func TestReadinessHandleStatusCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		checkers []ReadinessChecker
+		want     int
+	}{
+		{name: "no checks", want: http.StatusOK},
+		{
+			name:     "all pass",
+			checkers: []ReadinessChecker{staticChecker{name: "discovery"}, staticChecker{name: "backends"}},
+			want:     http.StatusOK,
+		},
+		{
+			name:     "one failing",
+			checkers: []ReadinessChecker{staticChecker{name: "discovery"}, staticChecker{name: "backends", err: errors.New("2 of 3 down")}},
+			want:     http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness := NewReadiness()
+			for _, checker := range tt.checkers {
+				readiness.Register(checker)
+			}
+			rec := httptest.NewRecorder()
+			readiness.Handle(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			var response ReadinessResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if len(response.Checks) != len(tt.checkers) {
+				t.Fatalf("report has %d checks, want %d", len(response.Checks), len(tt.checkers))
+			}
+			for i, checker := range tt.checkers {
+				want := StatusPass
+				if checker.(staticChecker).err != nil {
+					want = StatusFail
+				}
+				if got := response.Checks[i]; got.Name != checker.Name() || got.Status != want {
+					t.Errorf("check %d = %s %s, want %s %s", i, got.Name, got.Status, checker.Name(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestLivenessHandle(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantCode   int
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "default handler", handler: LivenessHandler, wantCode: http.StatusOK, wantStatus: StatusAlive},
+		{name: "passing check", handler: NewLiveness(passing).Handle, wantCode: http.StatusOK, wantStatus: StatusAlive},
+		{
+			name:       "injected failure",
+			handler:    NewLiveness(func(ctx context.Context) error { return errors.New("discovery goroutine stalled") }).Handle,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: StatusDead,
+			wantDetail: "discovery goroutine stalled",
+		},
+		{
+			name: "stuck check",
+			handler: NewLiveness(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}).Handle,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: StatusDead,
+			wantDetail: context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The request deadline stands in for livenessLimit so a stuck check fails fast
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, "/livez", nil).WithContext(ctx))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var response LivenessResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if response.Status != tt.wantStatus || response.Detail != tt.wantDetail {
+				t.Errorf("response = %s %q, want %s %q", response.Status, response.Detail, tt.wantStatus, tt.wantDetail)
+			}
+		})
+	}
+}
+
+// setBuildInfo overrides the ldflags build variables for the rest of the test
+func setBuildInfo(t *testing.T, v, commit, buildDate string) {
+	t.Helper()
+	saved := [3]string{version.Version, version.Commit, version.BuildDate}
+	t.Cleanup(func() { version.Version, version.Commit, version.BuildDate = saved[0], saved[1], saved[2] })
+	version.Version, version.Commit, version.BuildDate = v, commit, buildDate
+}
+
+func TestHealthReportsBuildInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		commit    string
+		buildDate string
+	}{
+		{name: "development build", version: "dev", commit: "unknown", buildDate: "unknown"},
+		{name: "release build", version: "v1.4.2", commit: "3f9c2ab", buildDate: "2026-10-01T12:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setBuildInfo(t, tt.version, tt.commit, tt.buildDate)
+
+			rec := httptest.NewRecorder()
+			HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+			var response HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if response.Version != tt.version || response.Commit != tt.commit || response.BuildDate != tt.buildDate {
+				t.Errorf("health build info = %s %s %s, want %s %s %s",
+					response.Version, response.Commit, response.BuildDate, tt.version, tt.commit, tt.buildDate)
+			}
+
+			rec = httptest.NewRecorder()
+			MetricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			want := fmt.Sprintf(`gateway_info{version=%q,commit=%q,build_date=%q,service="api-gateway"} 1`, tt.version, tt.commit, tt.buildDate)
+			if !strings.Contains(rec.Body.String(), want+"\n") {
+				t.Errorf("metrics missing %q", want)
+			}
+		})
+	}
+}
@@ -1,37 +1,96 @@
 package handlers
 
 import (
+	"api-gateway/internal/version"
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// MetricsHandler provides basic Prometheus-style metrics
+// Exposition formats served by /metrics
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// processStart is when the gateway started, for gateway_uptime_seconds
+var processStart = time.Now()
+
+// MetricsCollector writes additional metrics in the Prometheus text format
+type MetricsCollector interface {
+	WriteMetrics(w io.Writer)
+}
+
+// Metrics serves the built-in gateway metrics plus any registered collectors
+type Metrics struct {
+	collectors []MetricsCollector
+	mu         sync.RWMutex
+}
+
+// NewMetrics creates a metrics endpoint with no extra collectors
+func NewMetrics() *Metrics {
+	return &Metrics{
+		collectors: make([]MetricsCollector, 0),
+	}
+}
+
+// Register adds a collector whose output is appended to /metrics
+func (m *Metrics) Register(collector MetricsCollector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectors = append(m.collectors, collector)
+}
+
+// Handle writes the built-in metrics followed by every registered collector
+func (m *Metrics) Handle(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	writeBuiltinMetrics(&buf)
+
+	m.mu.RLock()
+	for _, collector := range m.collectors {
+		fmt.Fprintln(&buf)
+		collector.WriteMetrics(&buf)
+	}
+	m.mu.RUnlock()
+
+	writeExposition(w, r, buf.Bytes())
+}
+
+// MetricsHandler serves the built-in gateway metrics only
 func MetricsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
+	var buf bytes.Buffer
+	writeBuiltinMetrics(&buf)
+	writeExposition(w, r, buf.Bytes())
+}
 
+// writeBuiltinMetrics writes the gateway's own process metrics in the Prometheus text format
+func writeBuiltinMetrics(w io.Writer) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	now := time.Now().Unix()
-
-	metrics := fmt.Sprintf(`# HELP gateway_info Information about the gateway
+	fmt.Fprintf(w, `# HELP gateway_info Information about the gateway
 # TYPE gateway_info gauge
-gateway_info{version="1.0.0",service="api-gateway"} 1
+gateway_info{version=%q,commit=%q,build_date=%q,service="api-gateway"} 1
 
-# HELP gateway_uptime_seconds Total uptime of the gateway in seconds
-# TYPE gateway_uptime_seconds counter
-gateway_uptime_seconds %d
+# HELP gateway_uptime_seconds Time since the gateway started in seconds
+# TYPE gateway_uptime_seconds gauge
+gateway_uptime_seconds %s
 
 # HELP gateway_memory_alloc_bytes Number of bytes allocated and still in use
 # TYPE gateway_memory_alloc_bytes gauge
 gateway_memory_alloc_bytes %d
 
-# HELP gateway_memory_total_alloc_bytes Total number of bytes allocated
-# TYPE gateway_memory_total_alloc_bytes counter
-gateway_memory_total_alloc_bytes %d
+# HELP gateway_memory_alloc_bytes_total Total number of bytes allocated
+# TYPE gateway_memory_alloc_bytes_total counter
+gateway_memory_alloc_bytes_total %d
 
 # HELP gateway_memory_sys_bytes Number of bytes obtained from system
 # TYPE gateway_memory_sys_bytes gauge
@@ -44,28 +103,86 @@ gateway_gc_runs_total %d
 # HELP gateway_goroutines Current number of goroutines
 # TYPE gateway_goroutines gauge
 gateway_goroutines %d
-
-# HELP gateway_requests_total Total number of HTTP requests processed
-# TYPE gateway_requests_total counter
-gateway_requests_total{method="GET",status="200"} 0
-gateway_requests_total{method="POST",status="200"} 0
-
-# HELP gateway_request_duration_seconds Request duration in seconds
-# TYPE gateway_request_duration_seconds histogram
-gateway_request_duration_seconds_bucket{le="0.1"} 0
-gateway_request_duration_seconds_bucket{le="0.5"} 0
-gateway_request_duration_seconds_bucket{le="1.0"} 0
-gateway_request_duration_seconds_bucket{le="+Inf"} 0
-gateway_request_duration_seconds_sum 0
-gateway_request_duration_seconds_count 0
 `,
-		now,
+		version.Version,
+		version.Commit,
+		version.BuildDate,
+		strconv.FormatFloat(time.Since(processStart).Seconds(), 'f', 3, 64),
 		m.Alloc,
 		m.TotalAlloc,
 		m.Sys,
 		m.NumGC,
 		runtime.NumGoroutine(),
 	)
+}
+
+// writeExposition sends the metrics as OpenMetrics when the scraper asks for it,
+// else in the Prometheus text format
+func writeExposition(w http.ResponseWriter, r *http.Request, text []byte) {
+	if acceptsOpenMetrics(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(toOpenMetrics(text))
+		return
+	}
+
+	w.Header().Set("Content-Type", textContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(text)
+}
+
+// acceptsOpenMetrics reports whether an Accept header lists OpenMetrics with a non-zero quality
+func acceptsOpenMetrics(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil || mediaType != "application/openmetrics-text" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// toOpenMetrics converts Prometheus text output to OpenMetrics: counter families are
+// named without their _total suffix, blank lines are dropped and the output ends in # EOF
+func toOpenMetrics(text []byte) []byte {
+	counters := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		if name, metricType, ok := typeLine(scanner.Text()); ok && metricType == "counter" {
+			counters[name] = true
+		}
+	}
+
+	var out bytes.Buffer
+	scanner = bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) >= 3 && counters[fields[2]] {
+				fields[2] = strings.TrimSuffix(fields[2], "_total")
+				line = strings.Join(fields, " ")
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	out.WriteString("# EOF\n")
+	return out.Bytes()
+}
 
-	fmt.Fprint(w, metrics)
+// typeLine parses a "# TYPE name type" line
+func typeLine(line string) (name, metricType string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[0] != "#" || fields[1] != "TYPE" {
+		return "", "", false
+	}
+	return fields[2], fields[3], true
 }
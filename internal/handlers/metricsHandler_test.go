@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/metrics"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// parseExposition parses Prometheus text output, failing on parser errors and on
+// series that appear twice within a family
+func parseExposition(t *testing.T, text string) map[string]*dto.MetricFamily {
+	t.Helper()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("output does not parse: %v\n%s", err, text)
+	}
+
+	for name, family := range families {
+		seen := make(map[string]bool)
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			sort.Strings(labels)
+			series := strings.Join(labels, ",")
+			if seen[series] {
+				t.Errorf("%s has duplicate series {%s}", name, series)
+			}
+			seen[series] = true
+		}
+	}
+	return families
+}
+
+func TestMetricsOutputParses(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.IncCounter("gateway_http_requests_total", metrics.Labels{"method": "GET", "status": "200"})
+	registry.SetGauge("gateway_circuit_breaker_state", metrics.Labels{"service": "orders"}, 2)
+	registry.ObserveHistogram("gateway_http_request_duration_seconds", metrics.Labels{"method": "GET"}, 0.2)
+
+	metricsHook := logger.NewMetricsHook()
+	metricsHook.Fire(&logger.LogEntry{Level: "error", Service: "api-gateway", Component: "proxy"})
+	errorTracking := logger.NewErrorTrackingHook()
+	errorTracking.Fire(&logger.LogEntry{Level: "error", Service: "api-gateway", Message: "upstream failed"})
+	upstreamErrors := gatewayproxy.NewErrorCounter()
+	upstreamErrors.Record("orders", context.DeadlineExceeded)
+
+	m := NewMetrics()
+	for _, collector := range []MetricsCollector{registry, metricsHook, errorTracking, upstreamErrors} {
+		m.Register(collector)
+	}
+	rec := httptest.NewRecorder()
+	m.Handle(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != textContentType {
+		t.Errorf("Content-Type = %q, want %q", got, textContentType)
+	}
+	families := parseExposition(t, rec.Body.String())
+	for _, name := range []string{
+		"gateway_info",
+		"gateway_uptime_seconds",
+		"gateway_goroutines",
+		"gateway_http_requests_total",
+		"gateway_http_request_duration_seconds",
+		"gateway_upstream_errors_total",
+	} {
+		if _, exists := families[name]; !exists {
+			t.Errorf("family %s missing", name)
+		}
+	}
+}
+
+func TestMetricsHandlerOutputParses(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	parseExposition(t, rec.Body.String())
+}
+
+func TestMetricsContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+	}{
+		{name: "no Accept header", wantContentType: textContentType},
+		{name: "Prometheus text", accept: "text/plain;version=0.0.4", wantContentType: textContentType},
+		{name: "OpenMetrics", accept: "application/openmetrics-text;version=1.0.0", wantContentType: openMetricsContentType},
+		{
+			name:            "Prometheus scraper preference list",
+			accept:          "application/openmetrics-text;version=1.0.0;q=0.5,text/plain;version=0.0.4;q=0.4,*/*;q=0.1",
+			wantContentType: openMetricsContentType,
+		},
+		{name: "OpenMetrics refused", accept: "application/openmetrics-text;q=0, text/plain", wantContentType: textContentType},
+	}
+
+	registry := metrics.NewRegistry()
+	registry.IncCounter("gateway_http_requests_total", metrics.Labels{"method": "GET"})
+	m := NewMetrics()
+	m.Register(registry)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			m.Handle(rec, req)
+
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			body := rec.Body.String()
+			if tt.wantContentType == textContentType {
+				parseExposition(t, body)
+				return
+			}
+
+			if !strings.HasSuffix(body, "\n# EOF\n") {
+				t.Errorf("OpenMetrics output does not end in # EOF:\n%s", body)
+			}
+			if strings.Contains(body, "\n\n") {
+				t.Error("OpenMetrics output has blank lines")
+			}
+			for _, line := range []string{
+				"# TYPE gateway_http_requests counter",
+				`gateway_http_requests_total{method="GET"} 1`,
+				"# TYPE gateway_gc_runs counter",
+			} {
+				if !strings.Contains(body, line+"\n") {
+					t.Errorf("OpenMetrics output missing %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestToOpenMetricsKeepsGaugeNames(t *testing.T) {
+	text := []byte("# HELP queue_total Items queued, a gauge despite its name\n# TYPE queue_total gauge\nqueue_total 3\n")
+	got := toOpenMetrics(text)
+	if !bytes.Contains(got, []byte("# TYPE queue_total gauge\n")) {
+		t.Errorf("gauge family was renamed:\n%s", got)
+	}
+}
+
+func TestLogEntriesAppearInMetrics(t *testing.T) {
+	l := logger.NewLogger(logger.Config{Level: "info", Format: "json", Service: "api-gateway", Output: "file", FilePath: t.TempDir() + "/gateway.log"})
+	defer l.Close()
+	metricsHook := logger.NewMetricsHook()
+	l.AddHook(metricsHook)
+	m := NewMetrics()
+	m.Register(metricsHook)
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		m.Handle(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+	if strings.Contains(scrape(), "gateway_log_entries_total{") {
+		t.Fatal("log entry series present before anything was logged")
+	}
+
+	proxyLogger := l.WithComponent("proxy")
+	proxyLogger.Error("upstream failed")
+	proxyLogger.Error("upstream failed again")
+	l.WithComponent("discovery").Warn("watch restarted")
+
+	output := scrape()
+	parseExposition(t, output)
+	for _, line := range []string{
+		`gateway_log_entries_total{service="api-gateway",component="proxy",level="ERROR"} 2`,
+		`gateway_log_entries_total{service="api-gateway",component="discovery",level="WARN"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, output)
+		}
+	}
+
+	proxyLogger.Error("upstream failed a third time")
+	if want := `gateway_log_entries_total{service="api-gateway",component="proxy",level="ERROR"} 3`; !strings.Contains(scrape(), want+"\n") {
+		t.Errorf("metrics missing %q after another error", want)
+	}
+}
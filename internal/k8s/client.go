@@ -1,24 +1,31 @@
 package k8s
 
 import (
+	"api-gateway/pkg/logger"
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
+// apiCallTimeout bounds one-off Kubernetes API calls so a hung API server can't block callers
+const apiCallTimeout = 10 * time.Second
+
 // Client wraps the Kubernetes client with additional functionality
 type Client struct {
 	Clientset kubernetes.Interface
 	Config    *rest.Config
 	Namespace string
+	logger    *logger.Logger
 }
 
 // ClientConfig holds configuration for the Kubernetes client
@@ -29,7 +36,9 @@ type ClientConfig struct {
 }
 
 // NewClient creates a new Kubernetes client
-func NewClient(config ClientConfig) (*Client, error) {
+func NewClient(config ClientConfig, structuredLogger *logger.Logger) (*Client, error) {
+	clientLogger := structuredLogger.WithComponent("k8s_client")
+
 	var restConfig *rest.Config
 	var err error
 
@@ -38,7 +47,7 @@ func NewClient(config ClientConfig) (*Client, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create in-cluster config: %w", err)
 		}
-		log.Println("Using in-cluster Kubernetes configuration")
+		clientLogger.Info("Using in-cluster Kubernetes configuration")
 	} else {
 		kubeconfigPath := config.KubeConfig
 		if kubeconfigPath == "" {
@@ -51,7 +60,9 @@ func NewClient(config ClientConfig) (*Client, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create kubeconfig from %s: %w", kubeconfigPath, err)
 		}
-		log.Printf("Using kubeconfig from: %s", kubeconfigPath)
+		clientLogger.Info("Using kubeconfig", map[string]interface{}{
+			"kubeconfig": kubeconfigPath,
+		})
 	}
 
 	clientset, err := kubernetes.NewForConfig(restConfig)
@@ -71,30 +82,58 @@ func NewClient(config ClientConfig) (*Client, error) {
 		Clientset: clientset,
 		Config:    restConfig,
 		Namespace: namespace,
+		logger:    clientLogger,
 	}
 
-	if err := client.TestConnection(); err != nil {
+	if err := client.TestConnection(context.Background()); err != nil {
 		return nil, fmt.Errorf("failed to connect to Kubernetes cluster: %w", err)
 	}
 
-	log.Printf("Successfully connected to Kubernetes cluster, using namespace: %s", namespace)
+	clientLogger.Info("Successfully connected to Kubernetes cluster", map[string]interface{}{
+		"namespace": namespace,
+	})
 	return client, nil
 }
 
 // TestConnection verifies that the client can connect to the Kubernetes API
-func (c *Client) TestConnection() error {
-	_, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c *Client) TestConnection(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, apiCallTimeout)
 	defer cancel()
 
-	version, err := c.Clientset.Discovery().ServerVersion()
+	// Discovery().ServerVersion() takes no context, so issue the same request directly
+	body, err := c.Clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
 	if err != nil {
 		return fmt.Errorf("failed to get server version: %w", err)
 	}
 
-	log.Printf("Connected to Kubernetes server version: %s", version.String())
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return fmt.Errorf("failed to decode server version: %w", err)
+	}
+
+	c.logger.Info("Connected to Kubernetes server", map[string]interface{}{
+		"server_version": info.String(),
+	})
 	return nil
 }
 
+// GetBasicAuthSecret reads the username and password keys of a Secret
+func (c *Client) GetBasicAuthSecret(ctx context.Context, namespace, name string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiCallTimeout)
+	defer cancel()
+
+	secret, err := c.Clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+	}
+
+	username, password := string(secret.Data["username"]), string(secret.Data["password"])
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("secret %s/%s needs non-empty username and password keys", namespace, name)
+	}
+	return username, password, nil
+}
+
 // GetNamespace returns the default namespace for this client
 func (c *Client) GetNamespace() string {
 	return c.Namespace
@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"api-gateway/pkg/logger"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newSlowAPIClient returns a client for an API server that answers after delay
+func newSlowAPIClient(t *testing.T, delay time.Duration) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(`{"major":"1","minor":"30","gitVersion":"v1.30.0"}`))
+		default:
+			w.Write([]byte(`{"kind":"ServiceList","apiVersion":"v1","items":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	restConfig := &rest.Config{Host: server.URL}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		t.Fatalf("NewForConfig: %v", err)
+	}
+	return &Client{
+		Clientset: clientset,
+		Config:    restConfig,
+		Namespace: "default",
+		logger:    logger.NewLogger(logger.Config{Level: "fatal"}),
+	}
+}
+
+func TestAPICallsRespectDeadline(t *testing.T) {
+	calls := []struct {
+		name string
+		call func(ctx context.Context, client *Client) error
+	}{
+		{name: "TestConnection", call: func(ctx context.Context, client *Client) error {
+			return client.TestConnection(ctx)
+		}},
+		{name: "ListServices", call: func(ctx context.Context, client *Client) error {
+			_, err := NewServiceDiscovery(client, client.logger).ListServices(ctx)
+			return err
+		}},
+	}
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		wantErr bool
+	}{
+		{name: "responsive API server"},
+		{name: "hung API server", delay: time.Minute, wantErr: true},
+	}
+
+	for _, call := range calls {
+		for _, tt := range tests {
+			t.Run(call.name+"/"+tt.name, func(t *testing.T) {
+				client := newSlowAPIClient(t, tt.delay)
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				start := time.Now()
+				err := call.call(ctx, client)
+				if elapsed := time.Since(start); elapsed > 5*time.Second {
+					t.Errorf("call returned after %v, want it bound by the deadline", elapsed)
+				}
+				if !tt.wantErr {
+					if err != nil {
+						t.Errorf("error = %v, want none", err)
+					}
+					return
+				}
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("error = %v, want a deadline exceeded error", err)
+				}
+			})
+		}
+	}
+}
@@ -1,48 +1,174 @@
 package k8s
 
 import (
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/logger"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 )
 
 // ServiceDiscovery manages dynamic service discovery using Kubernetes API
 type ServiceDiscovery struct {
-	client    *Client
-	services  map[string]*DiscoveredService
-	endpoints map[string]*corev1.Endpoints
-	mutex     sync.RWMutex
-	stopCh    chan struct{}
-	eventCh   chan ServiceEvent
-	informers []cache.SharedIndexInformer
+	client         *Client
+	services       map[string]*DiscoveredService
+	endpoints      map[string]*corev1.Endpoints
+	mutex          sync.RWMutex
+	stopCh         chan struct{}
+	eventCh        chan ServiceEvent
+	informers      []cache.SharedIndexInformer
+	synced         bool
+	warnings       map[string]string // Services skipped because of invalid annotations
+	nodeZones      map[string]string // Node name to topology zone, filled when node zones are watched
+	watchNodes     bool
+	defaultMethods []string     // Methods of services without a method annotation
+	defaultPath    string       // Path template of services without a path annotation
+	defaultLB      string       // Load balancing strategy of services without an annotation
+	dropped        atomic.Int64 // Events dropped because the event channel stayed full
+	watchErrs      atomic.Int64 // Failed list or watch calls reported by the informers
+	malformed      atomic.Int64 // Informer objects of an unexpected type
+	lastErr        string
+	lastErrAt      time.Time
+	logger         *logger.Logger
 }
 
 // DiscoveredService represents a service discovered from Kubernetes
 type DiscoveredService struct {
-	Name          string            `json:"name"`
-	Namespace     string            `json:"namespace"`
-	Path          string            `json:"path"`
-	Method        string            `json:"method"`
-	AuthRequired  bool              `json:"auth_required"`
-	LoadBalancing string            `json:"load_balancing"`
-	Annotations   map[string]string `json:"annotations"`
-	Endpoints     []ServiceEndpoint `json:"endpoints"`
-	LastUpdated   time.Time         `json:"last_updated"`
+	Name               string                      `json:"name"`
+	Namespace          string                      `json:"namespace"`
+	Path               string                      `json:"path"`    // First of Paths, kept for single-path callers
+	Paths              []string                    `json:"paths"`   // Every path the service is routed under
+	Method             string                      `json:"method"`  // First of Methods, kept for single-method callers
+	Methods            []string                    `json:"methods"` // Every method the route accepts
+	AuthRequired       bool                        `json:"auth_required"`
+	AuthOptional       bool                        `json:"auth_optional"`           // A token is verified when sent, but anonymous requests pass
+	AuthProvider       string                      `json:"auth_provider,omitempty"` // Token issuer the route's tokens are verified against; empty is the default one
+	LoadBalancing      string                      `json:"load_balancing"`
+	ForwardTLS         bool                        `json:"forward_tls"`
+	Scheme             string                      `json:"scheme"`
+	ServerName         string                      `json:"server_name,omitempty"` // Name an HTTPS backend's certificate is verified against
+	MaxBodyBytes       int64                       `json:"max_body_bytes,omitempty"`
+	MaxConcurrent      int                         `json:"max_concurrent,omitempty"`   // Requests in flight to the service before new ones get a 503
+	AllowCIDRs         gatewayproxy.CIDRList       `json:"allow_cidrs,omitempty"`      // Only these client networks may call the service when set
+	DenyCIDRs          gatewayproxy.CIDRList       `json:"deny_cidrs,omitempty"`       // Client networks refused, even when also allowed
+	FallbackBackend    string                      `json:"fallback_backend,omitempty"` // URL used when no endpoint is healthy
+	MirrorService      string                      `json:"mirror_service,omitempty"`   // Discovered service receiving a copy of each request
+	CanaryService      string                      `json:"canary_service,omitempty"`   // Discovered service taking CanaryWeight percent of traffic
+	CanaryWeight       int                         `json:"canary_weight,omitempty"`
+	CanaryStickyHeader string                      `json:"canary_sticky_header,omitempty"`
+	RequestTimeout     time.Duration               `json:"request_timeout,omitempty"`         // Overrides REQUEST_TIMEOUT when set
+	Streaming          bool                        `json:"streaming"`                         // Long-lived responses exempt from the server write timeout
+	HeaderTimeout      time.Duration               `json:"response_header_timeout,omitempty"` // Upstream must send response headers within this
+	FlushInterval      time.Duration               `json:"flush_interval,omitempty"`          // Overrides PROXY_FLUSH_INTERVAL when set
+	StatusMap          map[int]int                 `json:"status_map,omitempty"`              // Upstream status codes rewritten before reaching the client
+	CacheTTL           time.Duration               `json:"cache_ttl,omitempty"`               // GET responses are cached this long when set
+	Coalesce           bool                        `json:"coalesce"`                          // Concurrent identical GETs share one upstream call
+	WaitForEndpoints   bool                        `json:"wait_for_endpoints"`                // Keep the routes out of matching until the service is Ready
+	Ready              bool                        `json:"ready"`                             // A ready endpoint has been seen since the service was discovered
+	Protocol           string                      `json:"protocol"`                          // ProtocolHTTP or ProtocolGRPC
+	UpstreamAuth       string                      `json:"upstream_auth,omitempty"`           // Where Basic credentials for the backend come from, never the credentials
+	HealthCheck        *HealthCheck                `json:"health_check,omitempty"`
+	RequestTransform   *gatewayproxy.BodyTransform `json:"request_transform,omitempty"` // Applied to JSON request bodies before forwarding
+	Weights            map[string]int              `json:"endpoint_weights,omitempty"`  // Endpoint weights keyed by pod name, IP or IP:port
+	Zones              map[string]string           `json:"endpoint_zones,omitempty"`    // Endpoint zones keyed by pod name, IP or IP:port
+	Annotations        map[string]string           `json:"annotations"`
+	Endpoints          []ServiceEndpoint           `json:"endpoints"`
+	LastUpdated        time.Time                   `json:"last_updated"`
 }
 
+// Route is a single method and path pair served by a discovered service
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Key returns the identifier used for the route in route tables
+func (r Route) Key() string {
+	return r.Method + ":" + r.Path
+}
+
+// Held reports whether the service's routes are kept out of matching because it
+// waits for endpoints and none has been ready yet
+func (s *DiscoveredService) Held() bool {
+	return s.WaitForEndpoints && !s.Ready
+}
+
+// hasReadyEndpoint reports whether any endpoint is ready
+func hasReadyEndpoint(endpoints []ServiceEndpoint) bool {
+	for _, endpoint := range endpoints {
+		if endpoint.Ready {
+			return true
+		}
+	}
+	return false
+}
+
+// Routes returns every method and path pair the service serves
+func (s *DiscoveredService) Routes() []Route {
+	methods := s.Methods
+	if len(methods) == 0 {
+		methods = []string{s.Method}
+	}
+	paths := s.Paths
+	if len(paths) == 0 {
+		paths = []string{s.Path}
+	}
+
+	routes := make([]Route, 0, len(methods)*len(paths))
+	for _, path := range paths {
+		for _, method := range methods {
+			routes = append(routes, Route{Method: method, Path: path})
+		}
+	}
+	return routes
+}
+
+// HealthCheck configures active probing of a service's endpoints
+type HealthCheck struct {
+	Path           string                   `json:"path"`
+	Interval       time.Duration            `json:"interval"`
+	Timeout        time.Duration            `json:"timeout"`
+	Method         string                   `json:"method,omitempty"`          // Empty uses HEALTH_CHECK_METHOD
+	ExpectedStatus gatewayproxy.StatusRange `json:"expected_status,omitempty"` // Zero uses HEALTH_CHECK_EXPECTED_STATUS
+}
+
+// Defaults for health check annotations that are missing or invalid
+const (
+	DefaultHealthCheckInterval = 10 * time.Second
+	DefaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// Authentication modes of the gateway.io/auth annotation
+const (
+	AuthModeRequired = "required"
+	AuthModeOptional = "optional"
+	AuthModeNone     = "none"
+)
+
 // ServiceEndpoint represents a backend endpoint for a service
 type ServiceEndpoint struct {
 	IP       string `json:"ip"`
 	Port     int32  `json:"port"`
 	Ready    bool   `json:"ready"`
 	NodeName string `json:"node_name,omitempty"`
+	PodName  string `json:"pod_name,omitempty"`
+	Weight   int    `json:"weight"`
+	Zone     string `json:"zone,omitempty"`
 }
 
 // ServiceEvent represents a change in service discovery
@@ -65,25 +191,136 @@ const (
 const (
 	AnnotationEnabled       = "gateway.io/enabled"
 	AnnotationPath          = "gateway.io/path"
+	AnnotationPaths         = "gateway.io/paths"
 	AnnotationMethod        = "gateway.io/method"
 	AnnotationAuthRequired  = "gateway.io/auth-required"
+	AnnotationAuth          = "gateway.io/auth"
+	AnnotationAuthProvider  = "gateway.io/auth-provider"
 	AnnotationLoadBalancing = "gateway.io/load-balancing"
+	AnnotationForwardTLS    = "gateway.io/forward-client-tls"
+	AnnotationScheme        = "gateway.io/upstream-scheme"
+	AnnotationServerName    = "gateway.io/upstream-server-name"
+	AnnotationMaxBodyBytes  = "gateway.io/max-body-bytes"
+	AnnotationMaxConcurrent = "gateway.io/max-concurrent"
+	AnnotationAllowCIDRs    = "gateway.io/allow-cidrs"
+	AnnotationDenyCIDRs     = "gateway.io/deny-cidrs"
+	AnnotationWeights       = "gateway.io/endpoint-weights"
+	AnnotationZones         = "gateway.io/endpoint-zones"
+	AnnotationFallback      = "gateway.io/fallback-backend"
+	AnnotationMirrorService = "gateway.io/mirror-service"
+	AnnotationStreaming     = "gateway.io/streaming"
+	AnnotationWaitEndpoints = "gateway.io/wait-for-endpoints"
+	AnnotationCoalesce      = "gateway.io/coalesce"
+	AnnotationCacheTTL      = "gateway.io/cache-ttl"
+	AnnotationStatusMap     = "gateway.io/status-map"
+	AnnotationHeaderTimeout = "gateway.io/response-header-timeout"
+	AnnotationFlushInterval = "gateway.io/flush-interval"
+	AnnotationProtocol      = "gateway.io/protocol"
+	AnnotationUpstreamAuth  = "gateway.io/upstream-auth"
+	AnnotationTimeout       = "gateway.io/request-timeout"
+	AnnotationTransform     = "gateway.io/request-transform"
+
+	AnnotationCanaryService      = "gateway.io/canary-service"
+	AnnotationCanaryWeight       = "gateway.io/canary-weight"
+	AnnotationCanaryStickyHeader = "gateway.io/canary-sticky-header"
+
+	AnnotationHealthCheckPath     = "gateway.io/health-check-path"
+	AnnotationHealthCheckInterval = "gateway.io/health-check-interval"
+	AnnotationHealthCheckTimeout  = "gateway.io/health-check-timeout"
+	AnnotationHealthCheckMethod   = "gateway.io/health-check-method"
+	AnnotationHealthCheckStatus   = "gateway.io/health-check-status"
 )
 
+// eventSendTimeout bounds how long an informer handler waits for room in the event channel
+const eventSendTimeout = 5 * time.Second
+
+// Sources the upstream-auth annotation can reference, as "<source>:<name>"
+const (
+	UpstreamAuthSecret = "secret" // Secret in the service's namespace with username and password keys
+	UpstreamAuthEnv    = "env"    // Environment variable of the gateway holding "username:password"
+)
+
+// UpstreamAuthEnvPrefix is the prefix every env upstream-auth reference must carry,
+// so a service annotation can't read arbitrary gateway environment variables
+const UpstreamAuthEnvPrefix = "GATEWAY_UPSTREAM_AUTH_"
+
+// Backend protocols a service can speak
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+)
+
+// DefaultCanaryStickyHeader keeps a client on one side of a canary split when sent
+const DefaultCanaryStickyHeader = "X-Canary-Key"
+
+// LabelTopologyZone is the well-known node label holding the node's zone
+const LabelTopologyZone = "topology.kubernetes.io/zone"
+
+// DefaultEndpointWeight is the weight of an endpoint not listed in the weights annotation
+const DefaultEndpointWeight = 1
+
 // NewServiceDiscovery creates a new service discovery manager
-func NewServiceDiscovery(client *Client) *ServiceDiscovery {
+func NewServiceDiscovery(client *Client, structuredLogger *logger.Logger) *ServiceDiscovery {
 	return &ServiceDiscovery{
-		client:    client,
-		services:  make(map[string]*DiscoveredService),
-		endpoints: make(map[string]*corev1.Endpoints),
-		stopCh:    make(chan struct{}),
-		eventCh:   make(chan ServiceEvent, 100),
+		logger:         structuredLogger.WithComponent("service_discovery"),
+		client:         client,
+		services:       make(map[string]*DiscoveredService),
+		endpoints:      make(map[string]*corev1.Endpoints),
+		stopCh:         make(chan struct{}),
+		eventCh:        make(chan ServiceEvent, 100),
+		warnings:       make(map[string]string),
+		nodeZones:      make(map[string]string),
+		defaultMethods: []string{http.MethodGet},
+		defaultPath:    "/{name}",
+		defaultLB:      "round-robin",
+	}
+}
+
+// SetRouteDefaults changes the route of services without path or method annotations.
+// methods is a comma-separated list or ANY; pathTemplate may use {name} and {namespace}.
+// Call it before Start.
+func (sd *ServiceDiscovery) SetRouteDefaults(methods, pathTemplate string) error {
+	parsed, err := ParseMethods(methods)
+	if err != nil {
+		return fmt.Errorf("invalid default method: %w", err)
 	}
+	if !strings.HasPrefix(pathTemplate, "/") {
+		return fmt.Errorf("invalid default path template %q, it must start with /", pathTemplate)
+	}
+
+	sd.defaultMethods = parsed
+	sd.defaultPath = pathTemplate
+	return nil
+}
+
+// SetDefaultLoadBalancing changes the load balancing strategy of services without a
+// load balancing annotation. Call it before Start.
+func (sd *ServiceDiscovery) SetDefaultLoadBalancing(strategy string) {
+	sd.defaultLB = strategy
+}
+
+// WatchNodeZones makes discovery watch nodes so endpoints not listed in the
+// endpoint zones annotation take the zone label of their node. It needs
+// permission to list and watch nodes; call it before Start.
+func (sd *ServiceDiscovery) WatchNodeZones() {
+	sd.watchNodes = true
+}
+
+// NodeZone returns the zone of a watched node, or "" when it is unknown
+func (sd *ServiceDiscovery) NodeZone(nodeName string) string {
+	sd.mutex.RLock()
+	defer sd.mutex.RUnlock()
+	return sd.nodeZones[nodeName]
 }
 
 // Start begins watching for service and endpoint changes
 func (sd *ServiceDiscovery) Start(ctx context.Context) error {
-	log.Println("Starting service discovery...")
+	sd.logger.Info("Starting service discovery")
+
+	// Node zones go first so endpoints converted during the initial sync can use them
+	if sd.watchNodes {
+		sd.informers = append(sd.informers, sd.createNodeInformer())
+	}
 
 	// Start service informer
 	serviceInformer := sd.createServiceInformer()
@@ -99,23 +336,34 @@ func (sd *ServiceDiscovery) Start(ctx context.Context) error {
 	}
 
 	// Wait for cache sync
-	log.Println("Waiting for cache sync...")
+	sd.logger.Info("Waiting for cache sync")
 	for _, informer := range sd.informers {
 		if !cache.WaitForCacheSync(sd.stopCh, informer.HasSynced) {
 			return fmt.Errorf("failed to sync cache")
 		}
 	}
 
-	log.Println("Service discovery started successfully")
+	sd.mutex.Lock()
+	sd.synced = true
+	sd.mutex.Unlock()
+
+	sd.logger.Info("Service discovery started successfully")
 	return nil
 }
 
 // Stop stops the service discovery
 func (sd *ServiceDiscovery) Stop() {
-	log.Println("Stopping service discovery...")
+	sd.logger.Info("Stopping service discovery")
 	close(sd.stopCh)
 }
 
+// HasSynced reports whether the informer caches completed their initial sync
+func (sd *ServiceDiscovery) HasSynced() bool {
+	sd.mutex.RLock()
+	defer sd.mutex.RUnlock()
+	return sd.synced
+}
+
 // GetServices returns all discovered services
 func (sd *ServiceDiscovery) GetServices() map[string]*DiscoveredService {
 	sd.mutex.RLock()
@@ -142,14 +390,127 @@ func (sd *ServiceDiscovery) GetEventChannel() <-chan ServiceEvent {
 	return sd.eventCh
 }
 
+// GetDroppedEvents returns how many events were dropped because the event channel stayed full
+func (sd *ServiceDiscovery) GetDroppedEvents() int64 {
+	return sd.dropped.Load()
+}
+
+// DiscoveryErrorStats counts informer failures that would otherwise only show up as stale routes
+type DiscoveryErrorStats struct {
+	WatchErrors      int64     `json:"watch_errors"`
+	MalformedObjects int64     `json:"malformed_objects"`
+	LastWatchError   string    `json:"last_watch_error,omitempty"`
+	LastWatchErrorAt time.Time `json:"last_watch_error_at"`
+}
+
+// GetErrorStats returns the informer watch error and malformed object counts
+func (sd *ServiceDiscovery) GetErrorStats() DiscoveryErrorStats {
+	sd.mutex.RLock()
+	defer sd.mutex.RUnlock()
+
+	return DiscoveryErrorStats{
+		WatchErrors:      sd.watchErrs.Load(),
+		MalformedObjects: sd.malformed.Load(),
+		LastWatchError:   sd.lastErr,
+		LastWatchErrorAt: sd.lastErrAt,
+	}
+}
+
+// setWatchErrorHandler reports failed list and watch calls of an informer. Watches
+// closed by the server and expired resource versions are routine and only relist.
+func (sd *ServiceDiscovery) setWatchErrorHandler(informer cache.SharedIndexInformer, resource string) {
+	err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			sd.logger.Debug("Watch expired, relisting", map[string]interface{}{
+				"resource": resource,
+				"error":    err.Error(),
+			})
+			return
+		}
+		sd.recordWatchError(resource, err)
+	})
+	if err != nil {
+		sd.logger.Warn("Failed to set watch error handler", map[string]interface{}{
+			"resource": resource,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// recordWatchError counts and logs a failed list or watch call
+func (sd *ServiceDiscovery) recordWatchError(resource string, err error) {
+	count := sd.watchErrs.Add(1)
+
+	sd.mutex.Lock()
+	sd.lastErr = fmt.Sprintf("%s: %v", resource, err)
+	sd.lastErrAt = time.Now()
+	sd.mutex.Unlock()
+
+	sd.logger.Error("Kubernetes watch failed", map[string]interface{}{
+		"resource":     resource,
+		"error":        err.Error(),
+		"watch_errors": count,
+	})
+}
+
+// informerObject unwraps an informer callback object, including the tombstone left
+// when a delete was missed, counting objects of any other type as malformed
+func informerObject[T any](sd *ServiceDiscovery, resource string, obj interface{}) (T, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if typed, ok := obj.(T); ok {
+		return typed, true
+	}
+
+	count := sd.malformed.Add(1)
+	sd.logger.Error("Unexpected object from informer", map[string]interface{}{
+		"resource":          resource,
+		"type":              fmt.Sprintf("%T", obj),
+		"malformed_objects": count,
+	})
+	var zero T
+	return zero, false
+}
+
+// sendEvent delivers an event, waiting up to eventSendTimeout when the channel is full
+func (sd *ServiceDiscovery) sendEvent(event ServiceEvent) {
+	select {
+	case sd.eventCh <- event:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(eventSendTimeout)
+	defer timer.Stop()
+
+	select {
+	case sd.eventCh <- event:
+	case <-sd.stopCh:
+	case <-timer.C:
+		dropped := sd.dropped.Add(1)
+		sd.logger.Warn("Event channel full, dropping service event", map[string]interface{}{
+			"event":          string(event.Type),
+			"service":        event.Service.Name,
+			"dropped_events": dropped,
+		})
+	}
+}
+
 // createServiceInformer creates an informer for Kubernetes services
 func (sd *ServiceDiscovery) createServiceInformer() cache.SharedIndexInformer {
-	listWatcher := cache.NewListWatchFromClient(
-		sd.client.Clientset.CoreV1().RESTClient(),
-		"services",
-		sd.client.Namespace,
-		fields.Everything(),
-	)
+	services := sd.client.Clientset.CoreV1().Services(sd.client.Namespace)
+	listWatcher := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return services.List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return services.Watch(context.Background(), options)
+		},
+	}
 
 	informer := cache.NewSharedIndexInformer(
 		listWatcher,
@@ -157,20 +518,21 @@ func (sd *ServiceDiscovery) createServiceInformer() cache.SharedIndexInformer {
 		30*time.Second, // Resync period
 		cache.Indexers{},
 	)
+	sd.setWatchErrorHandler(informer, "services")
 
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			if service, ok := obj.(*corev1.Service); ok {
+			if service, ok := informerObject[*corev1.Service](sd, "services", obj); ok {
 				sd.handleServiceEvent(service, ServiceAdded)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			if service, ok := newObj.(*corev1.Service); ok {
+			if service, ok := informerObject[*corev1.Service](sd, "services", newObj); ok {
 				sd.handleServiceEvent(service, ServiceModified)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
-			if service, ok := obj.(*corev1.Service); ok {
+			if service, ok := informerObject[*corev1.Service](sd, "services", obj); ok {
 				sd.handleServiceEvent(service, ServiceDeleted)
 			}
 		},
@@ -181,12 +543,15 @@ func (sd *ServiceDiscovery) createServiceInformer() cache.SharedIndexInformer {
 
 // createEndpointInformer creates an informer for Kubernetes endpoints
 func (sd *ServiceDiscovery) createEndpointInformer() cache.SharedIndexInformer {
-	listWatcher := cache.NewListWatchFromClient(
-		sd.client.Clientset.CoreV1().RESTClient(),
-		"endpoints",
-		sd.client.Namespace,
-		fields.Everything(),
-	)
+	endpoints := sd.client.Clientset.CoreV1().Endpoints(sd.client.Namespace)
+	listWatcher := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return endpoints.List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return endpoints.Watch(context.Background(), options)
+		},
+	}
 
 	informer := cache.NewSharedIndexInformer(
 		listWatcher,
@@ -194,20 +559,21 @@ func (sd *ServiceDiscovery) createEndpointInformer() cache.SharedIndexInformer {
 		30*time.Second, // Resync period
 		cache.Indexers{},
 	)
+	sd.setWatchErrorHandler(informer, "endpoints")
 
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			if endpoints, ok := obj.(*corev1.Endpoints); ok {
+			if endpoints, ok := informerObject[*corev1.Endpoints](sd, "endpoints", obj); ok {
 				sd.handleEndpointEvent(endpoints)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			if endpoints, ok := newObj.(*corev1.Endpoints); ok {
+			if endpoints, ok := informerObject[*corev1.Endpoints](sd, "endpoints", newObj); ok {
 				sd.handleEndpointEvent(endpoints)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
-			if endpoints, ok := obj.(*corev1.Endpoints); ok {
+			if endpoints, ok := informerObject[*corev1.Endpoints](sd, "endpoints", obj); ok {
 				sd.handleEndpointEvent(endpoints)
 			}
 		},
@@ -216,6 +582,60 @@ func (sd *ServiceDiscovery) createEndpointInformer() cache.SharedIndexInformer {
 	return informer
 }
 
+// createNodeInformer creates an informer tracking the zone label of every node
+func (sd *ServiceDiscovery) createNodeInformer() cache.SharedIndexInformer {
+	nodes := sd.client.Clientset.CoreV1().Nodes()
+	listWatcher := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return nodes.List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return nodes.Watch(context.Background(), options)
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(
+		listWatcher,
+		&corev1.Node{},
+		30*time.Second, // Resync period
+		cache.Indexers{},
+	)
+	sd.setWatchErrorHandler(informer, "nodes")
+
+	// Endpoints converted before their node was seen pick the zone up on their next resync
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if node, ok := informerObject[*corev1.Node](sd, "nodes", obj); ok {
+				sd.setNodeZone(node.Name, node.Labels[LabelTopologyZone])
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if node, ok := informerObject[*corev1.Node](sd, "nodes", newObj); ok {
+				sd.setNodeZone(node.Name, node.Labels[LabelTopologyZone])
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if node, ok := informerObject[*corev1.Node](sd, "nodes", obj); ok {
+				sd.setNodeZone(node.Name, "")
+			}
+		},
+	})
+
+	return informer
+}
+
+// setNodeZone records a node's zone, forgetting nodes without one
+func (sd *ServiceDiscovery) setNodeZone(nodeName, zone string) {
+	sd.mutex.Lock()
+	defer sd.mutex.Unlock()
+
+	if zone == "" {
+		delete(sd.nodeZones, nodeName)
+		return
+	}
+	sd.nodeZones[nodeName] = zone
+}
+
 // handleServiceEvent processes service events
 func (sd *ServiceDiscovery) handleServiceEvent(service *corev1.Service, eventType ServiceEventType) {
 	// Check if service should be discovered
@@ -223,53 +643,118 @@ func (sd *ServiceDiscovery) handleServiceEvent(service *corev1.Service, eventTyp
 		return
 	}
 
+	// Send outside the lock so a slow consumer can't block readers
+	if event, ok := sd.applyServiceEvent(service, eventType); ok {
+		sd.sendEvent(event)
+	}
+}
+
+// applyServiceEvent updates the discovered services and returns the event to publish
+func (sd *ServiceDiscovery) applyServiceEvent(service *corev1.Service, eventType ServiceEventType) (ServiceEvent, bool) {
 	sd.mutex.Lock()
 	defer sd.mutex.Unlock()
 
 	serviceName := service.Name
 
+	var discoveredService *DiscoveredService
 	if eventType == ServiceDeleted {
+		delete(sd.warnings, serviceName)
+	} else {
+		var err error
+		discoveredService, err = sd.createDiscoveredService(service)
+		if err != nil {
+			// Skip the service, withdrawing any route it registered before the bad update
+			sd.warnings[serviceName] = err.Error()
+			sd.logger.Warn("Skipping service with invalid annotations", map[string]interface{}{
+				"service":   serviceName,
+				"namespace": service.Namespace,
+				"error":     err,
+			})
+			eventType = ServiceDeleted
+		}
+	}
+
+	if eventType == ServiceDeleted {
+		// Deletion events carry the last known service so processors can find its routes
+		existing, exists := sd.services[serviceName]
+		if !exists {
+			return ServiceEvent{}, false
+		}
+		discoveredService = existing
 		delete(sd.services, serviceName)
-		log.Printf("Service removed from discovery: %s", serviceName)
+		sd.logger.Info("Service removed from discovery", map[string]interface{}{
+			"service": serviceName,
+		})
 	} else {
-		// Create or update discovered service
-		discoveredService := sd.createDiscoveredService(service)
+		delete(sd.warnings, serviceName)
+		previous, existed := sd.services[serviceName]
 		sd.services[serviceName] = discoveredService
 
 		// Update endpoints if we have them
 		if endpoints, exists := sd.endpoints[serviceName]; exists {
-			discoveredService.Endpoints = sd.convertEndpoints(endpoints)
+			discoveredService.Endpoints = sd.convertEndpoints(endpoints, discoveredService)
 		}
+		discoveredService.Ready = (existed && previous.Ready) || hasReadyEndpoint(discoveredService.Endpoints)
 
-		log.Printf("Service %s in discovery: %s -> %s %s", eventType, serviceName, discoveredService.Method, discoveredService.Path)
+		sd.logger.Info("Service updated in discovery", map[string]interface{}{
+			"event":   string(eventType),
+			"service": serviceName,
+			"methods": discoveredService.Methods,
+			"paths":   discoveredService.Paths,
+		})
 	}
 
-	// Send event notification
-	select {
-	case sd.eventCh <- ServiceEvent{
+	return ServiceEvent{
 		Type:      eventType,
-		Service:   sd.services[serviceName],
+		Service:   discoveredService,
 		Timestamp: time.Now(),
-	}:
-	default:
-		log.Printf("Warning: Event channel full, dropping service event for %s", serviceName)
-	}
+	}, true
 }
 
 // handleEndpointEvent processes endpoint events
 func (sd *ServiceDiscovery) handleEndpointEvent(endpoints *corev1.Endpoints) {
+	if event, ok := sd.applyEndpointEvent(endpoints); ok {
+		sd.sendEvent(event)
+	}
+}
+
+// applyEndpointEvent records endpoints and returns an event when a discovered service changed
+func (sd *ServiceDiscovery) applyEndpointEvent(endpoints *corev1.Endpoints) (ServiceEvent, bool) {
 	sd.mutex.Lock()
 	defer sd.mutex.Unlock()
 
 	serviceName := endpoints.Name
 	sd.endpoints[serviceName] = endpoints
 
-	// Update service endpoints if service is discovered
-	if service, exists := sd.services[serviceName]; exists {
-		service.Endpoints = sd.convertEndpoints(endpoints)
+	// Update service endpoints if service is discovered. Processors hold on to the
+	// published service, so it is replaced by an updated copy rather than changed.
+	if current, exists := sd.services[serviceName]; exists {
+		updated := *current
+		service := &updated
+		sd.services[serviceName] = service
+		service.Endpoints = sd.convertEndpoints(endpoints, service)
 		service.LastUpdated = time.Now()
-		log.Printf("Updated endpoints for service: %s (%d endpoints)", serviceName, len(service.Endpoints))
+		if !service.Ready && hasReadyEndpoint(service.Endpoints) {
+			service.Ready = true
+			if service.WaitForEndpoints {
+				sd.logger.Info("Service has a ready endpoint, releasing its routes", map[string]interface{}{
+					"service": serviceName,
+				})
+			}
+		}
+		sd.logger.Info("Updated service endpoints", map[string]interface{}{
+			"service":   serviceName,
+			"endpoints": len(service.Endpoints),
+		})
+
+		// Notify processors so load balancers and metrics see the new endpoints
+		return ServiceEvent{
+			Type:      ServiceModified,
+			Service:   service,
+			Timestamp: time.Now(),
+		}, true
 	}
+	return ServiceEvent{}, false
 }
 
 // shouldDiscoverService checks if a service should be included in discovery
@@ -282,7 +767,7 @@ func (sd *ServiceDiscovery) shouldDiscoverService(service *corev1.Service) bool
 }
 
 // createDiscoveredService converts a Kubernetes service to a discovered service
-func (sd *ServiceDiscovery) createDiscoveredService(service *corev1.Service) *DiscoveredService {
+func (sd *ServiceDiscovery) createDiscoveredService(service *corev1.Service) (*DiscoveredService, error) {
 	discovered := &DiscoveredService{
 		Name:        service.Name,
 		Namespace:   service.Namespace,
@@ -291,33 +776,425 @@ func (sd *ServiceDiscovery) createDiscoveredService(service *corev1.Service) *Di
 	}
 
 	// Extract routing configuration from annotations
-	if path, exists := service.Annotations[AnnotationPath]; exists {
-		discovered.Path = path
-	} else {
-		discovered.Path = "/" + service.Name // Default path
+	discovered.Paths = parsePaths(service.Annotations[AnnotationPath], service.Annotations[AnnotationPaths])
+	if len(discovered.Paths) == 0 {
+		discovered.Paths = []string{sd.defaultRoutePath(service)}
 	}
+	discovered.Path = discovered.Paths[0]
 
 	if method, exists := service.Annotations[AnnotationMethod]; exists {
-		discovered.Method = method
+		methods, err := ParseMethods(method)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationMethod, err)
+		}
+		discovered.Methods = methods
 	} else {
-		discovered.Method = "GET" // Default method
+		discovered.Methods = append([]string(nil), sd.defaultMethods...)
 	}
+	discovered.Method = discovered.Methods[0]
 
 	if authRequired, exists := service.Annotations[AnnotationAuthRequired]; exists {
 		discovered.AuthRequired = authRequired == "true"
 	}
 
+	// gateway.io/auth supersedes gateway.io/auth-required and adds the optional mode
+	if auth, exists := service.Annotations[AnnotationAuth]; exists {
+		switch auth {
+		case AuthModeRequired:
+			discovered.AuthRequired, discovered.AuthOptional = true, false
+		case AuthModeOptional:
+			discovered.AuthRequired, discovered.AuthOptional = false, true
+		case AuthModeNone:
+			discovered.AuthRequired, discovered.AuthOptional = false, false
+		default:
+			sd.warnInvalidAnnotation(service, AnnotationAuth, auth)
+		}
+	}
+
+	if provider, exists := service.Annotations[AnnotationAuthProvider]; exists {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			discovered.AuthProvider = provider
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationAuthProvider, provider)
+		}
+	}
+
+	if forwardTLS, exists := service.Annotations[AnnotationForwardTLS]; exists {
+		discovered.ForwardTLS = forwardTLS == "true"
+	}
+
+	discovered.Protocol = ProtocolHTTP
+	if protocol, exists := service.Annotations[AnnotationProtocol]; exists {
+		switch strings.ToLower(strings.TrimSpace(protocol)) {
+		case ProtocolGRPC:
+			discovered.Protocol = ProtocolGRPC
+		case ProtocolHTTP:
+		default:
+			sd.warnInvalidAnnotation(service, AnnotationProtocol, protocol)
+		}
+	}
+
+	discovered.RequestTimeout = sd.annotationDuration(service, AnnotationTimeout, 0)
+	discovered.CacheTTL = sd.annotationDuration(service, AnnotationCacheTTL, 0)
+	discovered.HeaderTimeout = sd.annotationDuration(service, AnnotationHeaderTimeout, 0)
+	discovered.FlushInterval = sd.annotationDuration(service, AnnotationFlushInterval, 0)
+
+	if statusMap, exists := service.Annotations[AnnotationStatusMap]; exists {
+		if parsed, err := gatewayproxy.ParseStatusMap(statusMap); err == nil {
+			discovered.StatusMap = parsed
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationStatusMap, statusMap)
+		}
+	}
+
+	if transform, exists := service.Annotations[AnnotationTransform]; exists {
+		if parsed, err := gatewayproxy.ParseBodyTransform(transform); err == nil {
+			discovered.RequestTransform = parsed
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationTransform, transform)
+		}
+	}
+
+	if streaming, exists := service.Annotations[AnnotationStreaming]; exists {
+		discovered.Streaming = streaming == "true"
+	}
+
+	if coalesce, exists := service.Annotations[AnnotationCoalesce]; exists {
+		discovered.Coalesce = coalesce == "true"
+	}
+
+	if wait, exists := service.Annotations[AnnotationWaitEndpoints]; exists {
+		discovered.WaitForEndpoints = wait == "true"
+	}
+
+	discovered.Scheme = gatewayproxy.NormalizeScheme(service.Annotations[AnnotationScheme])
+	if discovered.Scheme == gatewayproxy.SchemeHTTPS {
+		// Pods are dialed by IP, so certificates are checked against the service's DNS name
+		discovered.ServerName = service.Name + "." + service.Namespace + ".svc"
+		if serverName := strings.TrimSpace(service.Annotations[AnnotationServerName]); serverName != "" {
+			discovered.ServerName = serverName
+		}
+	}
+
+	if maxBodyBytes, exists := service.Annotations[AnnotationMaxBodyBytes]; exists {
+		if limit, err := strconv.ParseInt(maxBodyBytes, 10, 64); err == nil && limit > 0 {
+			discovered.MaxBodyBytes = limit
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationMaxBodyBytes, maxBodyBytes)
+		}
+	}
+
+	if maxConcurrent, exists := service.Annotations[AnnotationMaxConcurrent]; exists {
+		if limit, err := strconv.Atoi(maxConcurrent); err == nil && limit > 0 {
+			discovered.MaxConcurrent = limit
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationMaxConcurrent, maxConcurrent)
+		}
+	}
+
+	if allow, exists := service.Annotations[AnnotationAllowCIDRs]; exists {
+		if list, err := gatewayproxy.ParseCIDRList(allow); err == nil {
+			discovered.AllowCIDRs = list
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationAllowCIDRs, allow)
+		}
+	}
+
+	if deny, exists := service.Annotations[AnnotationDenyCIDRs]; exists {
+		if list, err := gatewayproxy.ParseCIDRList(deny); err == nil {
+			discovered.DenyCIDRs = list
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationDenyCIDRs, deny)
+		}
+	}
+
+	if fallback, exists := service.Annotations[AnnotationFallback]; exists {
+		if u, err := url.Parse(fallback); err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https") {
+			discovered.FallbackBackend = fallback
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationFallback, fallback)
+		}
+	}
+
+	if upstreamAuth, exists := service.Annotations[AnnotationUpstreamAuth]; exists {
+		if _, _, err := ParseUpstreamAuth(upstreamAuth); err == nil {
+			discovered.UpstreamAuth = strings.TrimSpace(upstreamAuth)
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationUpstreamAuth, upstreamAuth)
+		}
+	}
+
+	if mirror, exists := service.Annotations[AnnotationMirrorService]; exists {
+		if mirror = strings.TrimSpace(mirror); mirror != "" && mirror != service.Name {
+			discovered.MirrorService = mirror
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationMirrorService, mirror)
+		}
+	}
+
+	if canary, exists := service.Annotations[AnnotationCanaryService]; exists {
+		if canary = strings.TrimSpace(canary); canary != "" && canary != service.Name {
+			discovered.CanaryService = canary
+			discovered.CanaryStickyHeader = DefaultCanaryStickyHeader
+			if header, exists := service.Annotations[AnnotationCanaryStickyHeader]; exists && header != "" {
+				discovered.CanaryStickyHeader = header
+			}
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationCanaryService, canary)
+		}
+	}
+
+	if weight, exists := service.Annotations[AnnotationCanaryWeight]; exists {
+		if percent, err := strconv.Atoi(weight); err == nil && percent >= 0 && percent <= 100 {
+			discovered.CanaryWeight = percent
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationCanaryWeight, weight)
+		}
+	}
+
+	if path, exists := service.Annotations[AnnotationHealthCheckPath]; exists && path != "" {
+		discovered.HealthCheck = &HealthCheck{
+			Path:     path,
+			Interval: sd.annotationDuration(service, AnnotationHealthCheckInterval, DefaultHealthCheckInterval),
+			Timeout:  sd.annotationDuration(service, AnnotationHealthCheckTimeout, DefaultHealthCheckTimeout),
+		}
+		if method, exists := service.Annotations[AnnotationHealthCheckMethod]; exists {
+			if parsed, err := gatewayproxy.ParseHealthCheckMethod(method); err == nil {
+				discovered.HealthCheck.Method = parsed
+			} else {
+				sd.warnInvalidAnnotation(service, AnnotationHealthCheckMethod, method)
+			}
+		}
+		if status, exists := service.Annotations[AnnotationHealthCheckStatus]; exists {
+			if parsed, err := gatewayproxy.ParseStatusRange(status); err == nil {
+				discovered.HealthCheck.ExpectedStatus = parsed
+			} else {
+				sd.warnInvalidAnnotation(service, AnnotationHealthCheckStatus, status)
+			}
+		}
+	}
+
+	if weights, exists := service.Annotations[AnnotationWeights]; exists {
+		if parsed, err := parseEndpointWeights(weights); err == nil {
+			discovered.Weights = parsed
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationWeights, weights)
+		}
+	}
+
+	if zones, exists := service.Annotations[AnnotationZones]; exists {
+		if parsed, err := parseEndpointZones(zones); err == nil {
+			discovered.Zones = parsed
+		} else {
+			sd.warnInvalidAnnotation(service, AnnotationZones, zones)
+		}
+	}
+
 	if loadBalancing, exists := service.Annotations[AnnotationLoadBalancing]; exists {
 		discovered.LoadBalancing = loadBalancing
 	} else {
-		discovered.LoadBalancing = "round-robin" // Default strategy
+		discovered.LoadBalancing = sd.defaultLB
+	}
+
+	return discovered, nil
+}
+
+// standardMethods are the HTTP methods a discovered route may accept
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// anyMethods are the methods ANY and * stand for; CONNECT and TRACE must be listed explicitly
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// ParseMethods parses a comma-separated method list, rejecting unknown methods;
+// ANY or * expands to the common methods
+func ParseMethods(value string) ([]string, error) {
+	var methods []string
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ",") {
+		method := strings.ToUpper(strings.TrimSpace(part))
+		if method == "" {
+			continue
+		}
+
+		expanded := []string{method}
+		if method == "ANY" || method == "*" {
+			expanded = anyMethods
+		} else if !standardMethods[method] {
+			return nil, fmt.Errorf("unsupported HTTP method %q", strings.TrimSpace(part))
+		}
+		for _, m := range expanded {
+			if !seen[m] {
+				seen[m] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no methods listed")
+	}
+	return methods, nil
+}
+
+// defaultRoutePath expands the default path template for a service
+func (sd *ServiceDiscovery) defaultRoutePath(service *corev1.Service) string {
+	return strings.NewReplacer("{name}", service.Name, "{namespace}", service.Namespace).Replace(sd.defaultPath)
+}
+
+// ParseUpstreamAuth splits an upstream-auth reference into its source and name
+func ParseUpstreamAuth(value string) (source, name string, err error) {
+	source, name, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found || name == "" || (source != UpstreamAuthSecret && source != UpstreamAuthEnv) {
+		return "", "", fmt.Errorf("%s annotation must be %s:<name> or %s:<variable>", AnnotationUpstreamAuth, UpstreamAuthSecret, UpstreamAuthEnv)
 	}
+	if source == UpstreamAuthEnv && (!strings.HasPrefix(name, UpstreamAuthEnvPrefix) || name == UpstreamAuthEnvPrefix) {
+		return "", "", fmt.Errorf("%s environment variables must be named %s<name>", AnnotationUpstreamAuth, UpstreamAuthEnvPrefix)
+	}
+	return source, name, nil
+}
 
-	return discovered
+// parsePaths merges the single-path annotation with the comma-separated list, dropping duplicates
+func parsePaths(path, paths string) []string {
+	var result []string
+	seen := make(map[string]bool)
+
+	for _, candidate := range append([]string{path}, strings.Split(paths, ",")...) {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		result = append(result, candidate)
+	}
+	return result
 }
 
-// convertEndpoints converts Kubernetes endpoints to service endpoints
-func (sd *ServiceDiscovery) convertEndpoints(endpoints *corev1.Endpoints) []ServiceEndpoint {
+// parseEndpointWeights parses "target=weight" pairs where target is a pod name, IP or IP:port
+func parseEndpointWeights(value string) (map[string]int, error) {
+	weights := make(map[string]int)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		target, weight, found := strings.Cut(part, "=")
+		target = strings.TrimSpace(target)
+		if !found || target == "" {
+			return nil, fmt.Errorf("invalid endpoint weight %q", part)
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || parsed < 1 {
+			return nil, fmt.Errorf("invalid weight for endpoint %q", target)
+		}
+		weights[target] = parsed
+	}
+	return weights, nil
+}
+
+// parseEndpointZones parses "target=zone" pairs where target is a pod name, IP or IP:port
+func parseEndpointZones(value string) (map[string]string, error) {
+	zones := make(map[string]string)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		target, zone, found := strings.Cut(part, "=")
+		target = strings.TrimSpace(target)
+		zone = strings.TrimSpace(zone)
+		if !found || target == "" || zone == "" {
+			return nil, fmt.Errorf("invalid endpoint zone %q", part)
+		}
+		zones[target] = zone
+	}
+	return zones, nil
+}
+
+// endpointWeight looks up an endpoint's weight by IP:port, then IP, then pod name
+func endpointWeight(endpoint ServiceEndpoint, weights map[string]int) int {
+	if weight, exists := lookupEndpoint(endpoint, weights); exists {
+		return weight
+	}
+	return DefaultEndpointWeight
+}
+
+// lookupEndpoint finds an endpoint's entry in a per-endpoint annotation map by IP:port, then IP, then pod name
+func lookupEndpoint[T any](endpoint ServiceEndpoint, values map[string]T) (T, bool) {
+	candidates := []string{fmt.Sprintf("%s:%d", endpoint.IP, endpoint.Port), endpoint.IP, endpoint.PodName}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if value, exists := values[candidate]; exists {
+			return value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// GetWarnings returns services skipped because of invalid annotations, with the reason
+func (sd *ServiceDiscovery) GetWarnings() map[string]string {
+	sd.mutex.RLock()
+	defer sd.mutex.RUnlock()
+
+	warnings := make(map[string]string, len(sd.warnings))
+	for name, warning := range sd.warnings {
+		warnings[name] = warning
+	}
+	return warnings
+}
+
+// annotationDuration parses a duration annotation, falling back when it is missing or invalid
+func (sd *ServiceDiscovery) annotationDuration(service *corev1.Service, key string, fallback time.Duration) time.Duration {
+	value, exists := service.Annotations[key]
+	if !exists {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		sd.warnInvalidAnnotation(service, key, value)
+		return fallback
+	}
+	return duration
+}
+
+// warnInvalidAnnotation logs an annotation value that was ignored
+func (sd *ServiceDiscovery) warnInvalidAnnotation(service *corev1.Service, key, value string) {
+	sd.logger.Warn("Ignoring invalid annotation", map[string]interface{}{
+		"service":    service.Name,
+		"namespace":  service.Namespace,
+		"annotation": key,
+		"value":      value,
+	})
+}
+
+// convertEndpoints converts Kubernetes endpoints to service endpoints, applying the service's
+// weights and zones. Endpoints without an annotated zone take their node's zone label.
+// Callers hold sd.mutex.
+func (sd *ServiceDiscovery) convertEndpoints(endpoints *corev1.Endpoints, service *DiscoveredService) []ServiceEndpoint {
 	var serviceEndpoints []ServiceEndpoint
 
 	for _, subset := range endpoints.Subsets {
@@ -328,38 +1205,47 @@ func (sd *ServiceDiscovery) convertEndpoints(endpoints *corev1.Endpoints) []Serv
 
 		// Add ready endpoints
 		for _, addr := range subset.Addresses {
-			endpoint := ServiceEndpoint{
-				IP:    addr.IP,
-				Port:  port,
-				Ready: true,
-			}
-			if addr.NodeName != nil {
-				endpoint.NodeName = *addr.NodeName
-			}
-			serviceEndpoints = append(serviceEndpoints, endpoint)
+			serviceEndpoints = append(serviceEndpoints, sd.newServiceEndpoint(addr, port, true, service))
 		}
 
 		// Add not ready endpoints
 		for _, addr := range subset.NotReadyAddresses {
-			endpoint := ServiceEndpoint{
-				IP:    addr.IP,
-				Port:  port,
-				Ready: false,
-			}
-			if addr.NodeName != nil {
-				endpoint.NodeName = *addr.NodeName
-			}
-			serviceEndpoints = append(serviceEndpoints, endpoint)
+			serviceEndpoints = append(serviceEndpoints, sd.newServiceEndpoint(addr, port, false, service))
 		}
 	}
 
 	return serviceEndpoints
 }
 
+// newServiceEndpoint builds a service endpoint from an endpoint address
+func (sd *ServiceDiscovery) newServiceEndpoint(addr corev1.EndpointAddress, port int32, ready bool, service *DiscoveredService) ServiceEndpoint {
+	endpoint := ServiceEndpoint{
+		IP:    addr.IP,
+		Port:  port,
+		Ready: ready,
+	}
+	if addr.NodeName != nil {
+		endpoint.NodeName = *addr.NodeName
+	}
+	if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+		endpoint.PodName = addr.TargetRef.Name
+	}
+	endpoint.Weight = endpointWeight(endpoint, service.Weights)
+	if zone, exists := lookupEndpoint(endpoint, service.Zones); exists {
+		endpoint.Zone = zone
+	} else {
+		endpoint.Zone = sd.nodeZones[endpoint.NodeName]
+	}
+	return endpoint
+}
+
 // ListServices lists all services that could be discovered (for debugging)
-func (sd *ServiceDiscovery) ListServices() ([]*DiscoveredService, error) {
+func (sd *ServiceDiscovery) ListServices(ctx context.Context) ([]*DiscoveredService, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiCallTimeout)
+	defer cancel()
+
 	services, err := sd.client.Clientset.CoreV1().Services(sd.client.Namespace).List(
-		context.TODO(),
+		ctx,
 		metav1.ListOptions{},
 	)
 	if err != nil {
@@ -369,7 +1255,10 @@ func (sd *ServiceDiscovery) ListServices() ([]*DiscoveredService, error) {
 	var discoveredServices []*DiscoveredService
 	for _, service := range services.Items {
 		if sd.shouldDiscoverService(&service) {
-			discovered := sd.createDiscoveredService(&service)
+			discovered, err := sd.createDiscoveredService(&service)
+			if err != nil {
+				continue
+			}
 			discoveredServices = append(discoveredServices, discovered)
 		}
 	}
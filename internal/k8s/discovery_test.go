@@ -0,0 +1,271 @@
+package k8s
+
+import (
+	"api-gateway/pkg/logger"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseMethods(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr string
+	}{
+		{value: "GET", want: []string{"GET"}},
+		{value: "get, Post", want: []string{"GET", "POST"}},
+		{value: "GET,POST,GET", want: []string{"GET", "POST"}},
+		{value: "ANY", want: anyMethods},
+		{value: "*,TRACE", want: append(append([]string(nil), anyMethods...), "TRACE")},
+		{value: "GETT", wantErr: `unsupported HTTP method "GETT"`},
+		{value: "GET,FETCH", wantErr: `unsupported HTTP method "FETCH"`},
+		{value: " , ", wantErr: "no methods listed"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMethods(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseMethods(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMethods(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestEndpointWeight(t *testing.T) {
+	weights, err := parseEndpointWeights("10.0.0.1:8080=3, 10.0.0.2=2, orders-7d9f=5")
+	if err != nil {
+		t.Fatalf("parseEndpointWeights: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		endpoint ServiceEndpoint
+		want     int
+	}{
+		{name: "by IP and port", endpoint: ServiceEndpoint{IP: "10.0.0.1", Port: 8080}, want: 3},
+		{name: "by IP", endpoint: ServiceEndpoint{IP: "10.0.0.2", Port: 9090}, want: 2},
+		{name: "by pod name", endpoint: ServiceEndpoint{IP: "10.0.0.3", Port: 8080, PodName: "orders-7d9f"}, want: 5},
+		{name: "not listed", endpoint: ServiceEndpoint{IP: "10.0.0.4", Port: 8080}, want: DefaultEndpointWeight},
+	}
+	for _, tt := range tests {
+		if got := endpointWeight(tt.endpoint, weights); got != tt.want {
+			t.Errorf("%s: weight = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.1", "10.0.0.1=0", "=3", "10.0.0.1=heavy"} {
+		if _, err := parseEndpointWeights(invalid); err == nil {
+			t.Errorf("parseEndpointWeights(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestParseUpstreamAuth(t *testing.T) {
+	tests := []struct {
+		value      string
+		wantSource string
+		wantName   string
+		wantErr    bool
+	}{
+		{value: "secret:legacy-credentials", wantSource: UpstreamAuthSecret, wantName: "legacy-credentials"},
+		{value: " env:GATEWAY_UPSTREAM_AUTH_LEGACY ", wantSource: UpstreamAuthEnv, wantName: "GATEWAY_UPSTREAM_AUTH_LEGACY"},
+		{value: "env:JWT_SECRET", wantErr: true},
+		{value: "env:GATEWAY_UPSTREAM_AUTH_", wantErr: true},
+		{value: "secret:", wantErr: true},
+		{value: "vault:legacy", wantErr: true},
+		{value: "legacy-credentials", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		source, name, err := ParseUpstreamAuth(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseUpstreamAuth(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if source != tt.wantSource || name != tt.wantName {
+			t.Errorf("ParseUpstreamAuth(%q) = %q, %q, want %q, %q", tt.value, source, name, tt.wantSource, tt.wantName)
+		}
+	}
+}
+
+func TestEndpointZone(t *testing.T) {
+	zones, err := parseEndpointZones("10.0.0.1:8080=eu-west-1a, orders-7d9f=eu-west-1c")
+	if err != nil {
+		t.Fatalf("parseEndpointZones: %v", err)
+	}
+	sd := &ServiceDiscovery{nodeZones: map[string]string{"node-a": "eu-west-1a", "node-b": "eu-west-1b"}}
+	service := &DiscoveredService{Zones: zones}
+
+	tests := []struct {
+		name     string
+		ip       string
+		nodeName string
+		podName  string
+		want     string
+	}{
+		{name: "from the node label", ip: "10.0.0.2", nodeName: "node-b", want: "eu-west-1b"},
+		{name: "annotation overrides the node", ip: "10.0.0.1", nodeName: "node-b", want: "eu-west-1a"},
+		{name: "annotation by pod name", ip: "10.0.0.3", podName: "orders-7d9f", want: "eu-west-1c"},
+		{name: "unknown node", ip: "10.0.0.4", nodeName: "node-z"},
+	}
+	for _, tt := range tests {
+		addr := corev1.EndpointAddress{IP: tt.ip}
+		if tt.nodeName != "" {
+			addr.NodeName = &tt.nodeName
+		}
+		if tt.podName != "" {
+			addr.TargetRef = &corev1.ObjectReference{Kind: "Pod", Name: tt.podName}
+		}
+		if got := sd.newServiceEndpoint(addr, 8080, true, service).Zone; got != tt.want {
+			t.Errorf("%s: zone = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.1", "10.0.0.1=", "=eu-west-1a"} {
+		if _, err := parseEndpointZones(invalid); err == nil {
+			t.Errorf("parseEndpointZones(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestWatchErrorsAreCounted(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCounted bool
+	}{
+		{name: "forbidden", err: apierrors.NewForbidden(corev1.Resource("services"), "", errors.New("RBAC denied")), wantCounted: true},
+		{name: "API server unreachable", err: errors.New("connection refused"), wantCounted: true},
+		{name: "expired resource version is routine", err: apierrors.NewResourceExpired("too old resource version")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every services list fails, so the informer retries with backoff
+			listed := make(chan struct{}, 8)
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("list", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				select {
+				case listed <- struct{}{}:
+				default:
+				}
+				return true, nil, tt.err
+			})
+			sd := NewServiceDiscovery(&Client{Clientset: clientset, Namespace: "default"}, logger.NewLogger(logger.Config{Level: "fatal"}))
+			go sd.Start(context.Background())
+			defer sd.Stop()
+
+			// The error handler runs before the reflector's retry, so two lists mean it ran
+			for i := 0; i < 2; i++ {
+				select {
+				case <-listed:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("services listed %d times, want 2", i)
+				}
+			}
+
+			stats := sd.GetErrorStats()
+			if counted := stats.WatchErrors > 0; counted != tt.wantCounted {
+				t.Fatalf("watch errors = %d, want counted %v", stats.WatchErrors, tt.wantCounted)
+			}
+			if tt.wantCounted && (!strings.HasPrefix(stats.LastWatchError, "services: ") || stats.LastWatchErrorAt.IsZero()) {
+				t.Errorf("last watch error = %q at %v, want the services failure", stats.LastWatchError, stats.LastWatchErrorAt)
+			}
+		})
+	}
+}
+
+func TestMalformedInformerObjectsAreCounted(t *testing.T) {
+	sd := NewServiceDiscovery(&Client{Clientset: fake.NewSimpleClientset(), Namespace: "default"}, logger.NewLogger(logger.Config{Level: "fatal"}))
+	service := &corev1.Service{}
+
+	tests := []struct {
+		name          string
+		obj           interface{}
+		wantOK        bool
+		wantMalformed int64
+	}{
+		{name: "service", obj: service, wantOK: true},
+		{name: "tombstone of a service", obj: cache.DeletedFinalStateUnknown{Key: "default/orders", Obj: service}, wantOK: true},
+		{name: "wrong type", obj: &corev1.Endpoints{}, wantMalformed: 1},
+		{name: "tombstone of the wrong type", obj: cache.DeletedFinalStateUnknown{Key: "default/orders", Obj: "junk"}, wantMalformed: 2},
+	}
+
+	for _, tt := range tests {
+		if _, ok := informerObject[*corev1.Service](sd, "services", tt.obj); ok != tt.wantOK {
+			t.Errorf("%s: unwrapped = %v, want %v", tt.name, ok, tt.wantOK)
+		}
+		if got := sd.GetErrorStats().MalformedObjects; got != tt.wantMalformed {
+			t.Errorf("%s: malformed objects = %d, want %d", tt.name, got, tt.wantMalformed)
+		}
+	}
+}
+
+func TestSetRouteDefaultsRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		methods      string
+		pathTemplate string
+	}{
+		{name: "unknown method", methods: "FETCH", pathTemplate: "/{name}"},
+		{name: "no method", methods: " ", pathTemplate: "/{name}"},
+		{name: "relative path", methods: "GET", pathTemplate: "api/{name}"},
+	}
+
+	for _, tt := range tests {
+		sd := NewServiceDiscovery(&Client{Clientset: fake.NewSimpleClientset(), Namespace: "default"}, logger.NewLogger(logger.Config{Level: "fatal"}))
+		if err := sd.SetRouteDefaults(tt.methods, tt.pathTemplate); err == nil {
+			t.Errorf("%s: SetRouteDefaults(%q, %q) succeeded, want an error", tt.name, tt.methods, tt.pathTemplate)
+		}
+		if sd.defaultPath != "/{name}" || !reflect.DeepEqual(sd.defaultMethods, []string{"GET"}) {
+			t.Errorf("%s: defaults changed to %v %q", tt.name, sd.defaultMethods, sd.defaultPath)
+		}
+	}
+}
+
+func TestAuthModeAnnotation(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantRequired bool
+		wantOptional bool
+	}{
+		{name: "open by default"},
+		{name: "legacy auth-required", annotations: map[string]string{AnnotationAuthRequired: "true"}, wantRequired: true},
+		{name: "required", annotations: map[string]string{AnnotationAuth: AuthModeRequired}, wantRequired: true},
+		{name: "optional", annotations: map[string]string{AnnotationAuth: AuthModeOptional}, wantOptional: true},
+		{name: "optional supersedes auth-required", annotations: map[string]string{AnnotationAuthRequired: "true", AnnotationAuth: AuthModeOptional}, wantOptional: true},
+		{name: "none supersedes auth-required", annotations: map[string]string{AnnotationAuthRequired: "true", AnnotationAuth: AuthModeNone}},
+		{name: "invalid mode keeps auth-required", annotations: map[string]string{AnnotationAuthRequired: "true", AnnotationAuth: "sometimes"}, wantRequired: true},
+	}
+
+	sd := NewServiceDiscovery(&Client{Clientset: fake.NewSimpleClientset(), Namespace: "default"}, logger.NewLogger(logger.Config{Level: "fatal"}))
+	for _, tt := range tests {
+		service := &corev1.Service{}
+		service.Name, service.Namespace, service.Annotations = "orders", "default", tt.annotations
+		discovered, err := sd.createDiscoveredService(service)
+		if err != nil {
+			t.Fatalf("%s: createDiscoveredService: %v", tt.name, err)
+		}
+		if discovered.AuthRequired != tt.wantRequired || discovered.AuthOptional != tt.wantOptional {
+			t.Errorf("%s: required, optional = %v, %v, want %v, %v",
+				tt.name, discovered.AuthRequired, discovered.AuthOptional, tt.wantRequired, tt.wantOptional)
+		}
+	}
+}
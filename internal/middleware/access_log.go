@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats, as defined by NCSA / Apache httpd
+const (
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+)
+
+// accessLogTimeFormat is the [day/month/year:hour:minute:second zone] timestamp
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog writes one Common or Combined Log Format line per request,
+// separately from the structured application log
+type AccessLog struct {
+	format string
+	out    io.Writer
+	mu     sync.Mutex
+}
+
+// NewAccessLog creates an access log writing lines in format to out
+func NewAccessLog(format string, out io.Writer) *AccessLog {
+	return &AccessLog{format: format, out: out}
+}
+
+// Record writes the line for a completed request
+func (a *AccessLog) Record(r *http.Request, start time.Time, status, size int, clientIP, user string) {
+	line := formatAccessLogLine(a.format, r, start, status, size, clientIP, user)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	io.WriteString(a.out, line)
+}
+
+// formatAccessLogLine renders `host ident user [time] "request" status bytes`, followed
+// by `"referer" "user-agent"` in the combined format. Missing values are "-".
+func formatAccessLogLine(format string, r *http.Request, start time.Time, status, size int, clientIP, user string) string {
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.Itoa(size)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		orDash(clientIP),
+		orDash(escapeAccessLogField(user)),
+		start.Format(accessLogTimeFormat),
+		escapeAccessLogField(r.Method),
+		escapeAccessLogField(r.RequestURI),
+		escapeAccessLogField(r.Proto),
+		status,
+		bytes,
+	)
+
+	if format == AccessLogCombined {
+		line += fmt.Sprintf(" \"%s\" \"%s\"",
+			orDash(escapeAccessLogField(r.Referer())),
+			orDash(escapeAccessLogField(r.UserAgent())),
+		)
+	}
+	return line + "\n"
+}
+
+// escapeAccessLogField escapes quotes, backslashes and control characters so a
+// client-supplied value can't break out of its field or forge another line
+func escapeAccessLogField(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"api-gateway/pkg/logger"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFormatAccessLogLine(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.FixedZone("", -7*3600))
+
+	tests := []struct {
+		name     string
+		format   string
+		target   string
+		headers  map[string]string
+		status   int
+		size     int
+		clientIP string
+		user     string
+		want     string
+	}{
+		{
+			name:     "combined",
+			format:   AccessLogCombined,
+			target:   "/orders?page=2",
+			headers:  map[string]string{"Referer": "https://shop.example.com/", "User-Agent": "curl/8.5.0"},
+			status:   http.StatusOK,
+			size:     512,
+			clientIP: "203.0.113.7",
+			user:     "alice",
+			want:     `203.0.113.7 - alice [05/Mar/2024:14:07:09 -0700] "GET /orders?page=2 HTTP/1.1" 200 512 "https://shop.example.com/" "curl/8.5.0"` + "\n",
+		},
+		{
+			name:     "common omits referer and user agent",
+			format:   AccessLogCommon,
+			target:   "/orders",
+			headers:  map[string]string{"Referer": "https://shop.example.com/", "User-Agent": "curl/8.5.0"},
+			status:   http.StatusNotFound,
+			size:     19,
+			clientIP: "203.0.113.7",
+			want:     `203.0.113.7 - - [05/Mar/2024:14:07:09 -0700] "GET /orders HTTP/1.1" 404 19` + "\n",
+		},
+		{
+			name:     "missing values are dashes",
+			format:   AccessLogCombined,
+			target:   "/orders",
+			status:   http.StatusNoContent,
+			clientIP: "203.0.113.7",
+			want:     `203.0.113.7 - - [05/Mar/2024:14:07:09 -0700] "GET /orders HTTP/1.1" 204 - "-" "-"` + "\n",
+		},
+		{
+			name:     "quotes and newlines cannot forge fields",
+			format:   AccessLogCombined,
+			target:   "/orders",
+			headers:  map[string]string{"User-Agent": "evil\" 200 1\n10.0.0.1 - admin"},
+			status:   http.StatusOK,
+			size:     2,
+			clientIP: "203.0.113.7",
+			want:     `203.0.113.7 - - [05/Mar/2024:14:07:09 -0700] "GET /orders HTTP/1.1" 200 2 "-" "evil\" 200 1\x0a10.0.0.1 - admin"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := formatAccessLogLine(tt.format, req, start, tt.status, tt.size, tt.clientIP, tt.user); got != tt.want {
+				t.Errorf("line = %q\nwant   %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareWritesAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	l := logger.NewLogger(logger.Config{Level: "fatal"})
+	defer l.Close()
+	m := NewStructuredLoggingMiddlewareWithConfig(l, LoggingMiddlewareConfig{AccessLog: NewAccessLog(AccessLogCombined, &out)})
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":42}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.RemoteAddr = "203.0.113.7:40000"
+	req.Header.Set("Referer", "https://shop.example.com/cart")
+	req.Header.Set("User-Agent", "checkout/1.0")
+	req = req.WithContext(logger.WithUserID(req.Context(), "alice"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	pattern := regexp.MustCompile(`^203\.0\.113\.7 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /orders HTTP/1\.1" 201 9 "https://shop\.example\.com/cart" "checkout/1\.0"\n$`)
+	if !pattern.MatchString(out.String()) {
+		t.Errorf("access log = %q, want a combined log line", out.String())
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// AdminPathPrefix is the path prefix of the gateway's admin API
+const AdminPathPrefix = "/admin/"
+
+// HeaderAdminToken carries the admin API token; a bearer Authorization header works too
+const HeaderAdminToken = "X-Admin-Token"
+
+// AdminAuthMiddleware guards the admin API with a shared token
+type AdminAuthMiddleware struct {
+	enabled bool
+	token   string
+}
+
+// NewAdminAuthMiddleware creates the admin API guard. When enabled without a
+// token every admin request is refused, so the API is closed until one is set.
+func NewAdminAuthMiddleware(enabled bool, token string) *AdminAuthMiddleware {
+	return &AdminAuthMiddleware{enabled: enabled, token: token}
+}
+
+// Middleware rejects admin requests without the token (401) or with a wrong one (403).
+// Requests outside AdminPathPrefix pass through untouched.
+func (am *AdminAuthMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !am.enabled || !strings.HasPrefix(r.URL.Path, AdminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if am.token == "" {
+			log.Printf("AdminAuthMiddleware: Admin API is locked, no admin token configured, refusing %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Admin API disabled", http.StatusForbidden)
+			return
+		}
+
+		presented := r.Header.Get(HeaderAdminToken)
+		if presented == "" {
+			presented = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if presented == "" {
+			log.Printf("AdminAuthMiddleware: Admin token missing for %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Admin token required", http.StatusUnauthorized)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(am.token)) != 1 {
+			log.Printf("AdminAuthMiddleware: Invalid admin token for %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
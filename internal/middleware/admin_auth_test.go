@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		token   string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "no credentials", enabled: true, token: "s3cret", path: "/admin/routes", want: http.StatusUnauthorized},
+		{name: "wrong token", enabled: true, token: "s3cret", path: "/admin/routes", headers: map[string]string{HeaderAdminToken: "guess"}, want: http.StatusForbidden},
+		{name: "admin token header", enabled: true, token: "s3cret", path: "/admin/routes", headers: map[string]string{HeaderAdminToken: "s3cret"}, want: http.StatusOK},
+		{name: "bearer token", enabled: true, token: "s3cret", path: "/admin/circuit-breakers", headers: map[string]string{"Authorization": "Bearer s3cret"}, want: http.StatusOK},
+		{name: "no token configured locks the API", enabled: true, path: "/admin/routes", headers: map[string]string{HeaderAdminToken: "anything"}, want: http.StatusForbidden},
+		{name: "non-admin path is untouched", enabled: true, token: "s3cret", path: "/orders", want: http.StatusOK},
+		{name: "similar prefix is not admin", enabled: true, token: "s3cret", path: "/administrators", want: http.StatusOK},
+		{name: "disabled", token: "s3cret", path: "/admin/routes", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminAuthMiddleware(tt.enabled, tt.token).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
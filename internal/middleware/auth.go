@@ -1,21 +1,54 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"api-gateway/pkg/jwt"
+	"api-gateway/pkg/logger"
 )
 
 type AuthMiddleware struct {
-	jwtService *jwt.Service
+	jwtService  *jwt.Service
+	providers   *jwt.Providers
+	tokenCookie string
 }
 
 func NewAuthMiddleware(jwtService *jwt.Service) *AuthMiddleware {
 	return &AuthMiddleware{jwtService: jwtService}
 }
 
+// SetTokenCookie makes requests without an Authorization header authenticate with the
+// token in the named cookie. Call it before the server starts.
+func (am *AuthMiddleware) SetTokenCookie(name string) {
+	am.tokenCookie = name
+}
+
+// SetProviders lets routes verify tokens against a named provider instead of the
+// default one. Call it before the server starts.
+func (am *AuthMiddleware) SetProviders(providers *jwt.Providers) {
+	am.providers = providers
+}
+
+// HasProvider reports whether tokens can be verified against the named provider
+func (am *AuthMiddleware) HasProvider(name string) bool {
+	_, exists := am.provider(name)
+	return exists
+}
+
+// provider returns the named token verifier; an empty name is the default one
+func (am *AuthMiddleware) provider(name string) (*jwt.Service, bool) {
+	if am.providers != nil {
+		return am.providers.Get(name)
+	}
+	if name == "" || name == jwt.DefaultProvider {
+		return am.jwtService, true
+	}
+	return nil, false
+}
+
 // AuthMiddleware checks for a valid JWT token in the Authorization header.
 // It takes the next http.Handler in the chain and a boolean indicating if auth is required for this specific route.
 func (am *AuthMiddleware) Middleware(authRequired bool) func(http.Handler) http.Handler {
@@ -26,24 +59,7 @@ func (am *AuthMiddleware) Middleware(authRequired bool) func(http.Handler) http.
 				return
 			}
 
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				log.Printf("AuthMiddleware: Authorization header missing for %s %s", r.Method, r.URL.Path)
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
-				return
-			}
-
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if tokenString == authHeader {
-				log.Printf("AuthMiddleware: Invalid token format (Bearer token expected) for %s %s", r.Method, r.URL.Path)
-				http.Error(w, "Invalid token format (Bearer token expected)", http.StatusUnauthorized)
-				return
-			}
-
-			err := am.jwtService.VerifyToken(tokenString)
-			if err != nil {
-				log.Printf("AuthMiddleware: Token verification failed for %s %s: %v", r.Method, r.URL.Path, err)
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			if !am.Authenticate(w, r) {
 				return
 			}
 
@@ -51,3 +67,115 @@ func (am *AuthMiddleware) Middleware(authRequired bool) func(http.Handler) http.
 		})
 	}
 }
+
+// Authenticate verifies the request's bearer token, writing a 401 and returning false when it
+// is missing, malformed, invalid or expired. The header checks reject early without touching the JWT.
+// Without an Authorization header the token cookie is used, when one is configured.
+func (am *AuthMiddleware) Authenticate(w http.ResponseWriter, r *http.Request) bool {
+	return am.AuthenticateWith(w, r, "")
+}
+
+// AuthenticateWith is Authenticate verifying the token against the named provider.
+// A provider that isn't configured rejects every request.
+func (am *AuthMiddleware) AuthenticateWith(w http.ResponseWriter, r *http.Request, provider string) bool {
+	jwtService, exists := am.provider(provider)
+	if !exists {
+		log.Printf("AuthMiddleware: Unknown auth provider %q for %s %s", provider, r.Method, r.URL.Path)
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return false
+	}
+
+	tokenString, ok := am.requestToken(w, r)
+	if !ok {
+		return false
+	}
+
+	if err := jwtService.VerifyToken(tokenString); err != nil {
+		log.Printf("AuthMiddleware: Token verification failed for %s %s: %v", r.Method, r.URL.Path, err)
+		writeTokenRejected(w, err)
+		return false
+	}
+
+	return true
+}
+
+// AuthenticateOptional lets requests without a token through anonymously. A token that
+// is sent must be valid: a 401 is written and false returned otherwise. For a valid
+// token the returned request carries the username as the context's user ID. Tokens are
+// verified against the named provider, the default one when empty.
+func (am *AuthMiddleware) AuthenticateOptional(w http.ResponseWriter, r *http.Request, provider string) (*http.Request, bool) {
+	if !am.hasToken(r) {
+		return r, true
+	}
+
+	jwtService, exists := am.provider(provider)
+	if !exists {
+		log.Printf("AuthMiddleware: Unknown auth provider %q for %s %s", provider, r.Method, r.URL.Path)
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return r, false
+	}
+
+	tokenString, ok := am.requestToken(w, r)
+	if !ok {
+		return r, false
+	}
+
+	username, err := jwtService.Username(tokenString)
+	if err != nil {
+		log.Printf("AuthMiddleware: Token verification failed for %s %s: %v", r.Method, r.URL.Path, err)
+		writeTokenRejected(w, err)
+		return r, false
+	}
+	return r.WithContext(logger.WithUserID(r.Context(), username)), true
+}
+
+// writeTokenRejected writes the 401 for a token that failed verification, telling
+// an expired token apart from an invalid one
+func writeTokenRejected(w http.ResponseWriter, err error) {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="The token expired"`)
+		http.Error(w, "Token expired", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, "Invalid token", http.StatusUnauthorized)
+}
+
+// hasToken reports whether the request sends a token, well-formed or not
+func (am *AuthMiddleware) hasToken(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	if am.tokenCookie == "" {
+		return false
+	}
+	_, err := r.Cookie(am.tokenCookie)
+	return err == nil
+}
+
+// requestToken returns the token from the Authorization header, or else the token cookie,
+// writing a 401 when neither holds one
+func (am *AuthMiddleware) requestToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" && am.tokenCookie != "" {
+		if cookie, err := r.Cookie(am.tokenCookie); err == nil && cookie.Value != "" {
+			return cookie.Value, true
+		}
+		log.Printf("AuthMiddleware: Authorization header and %s cookie missing for %s %s", am.tokenCookie, r.Method, r.URL.Path)
+		http.Error(w, "Authorization header or token cookie required", http.StatusUnauthorized)
+		return "", false
+	}
+	if authHeader == "" {
+		log.Printf("AuthMiddleware: Authorization header missing for %s %s", r.Method, r.URL.Path)
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return "", false
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader || tokenString == "" {
+		log.Printf("AuthMiddleware: Invalid token format (Bearer token expected) for %s %s", r.Method, r.URL.Path)
+		http.Error(w, "Invalid token format (Bearer token expected)", http.StatusUnauthorized)
+		return "", false
+	}
+	return tokenString, true
+}
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/pkg/jwt"
+	"api-gateway/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testTokenCookie = "gateway_token"
+
+// newTestJWT returns a token service and a valid token it issued for username
+func newTestJWT(t *testing.T, cfg config.JWTConfig, username string) (*jwt.Service, string) {
+	t.Helper()
+	if cfg.Secret == "" {
+		cfg.Secret = "auth-test-secret"
+	}
+	if cfg.Expiration == 0 {
+		cfg.Expiration = time.Hour
+	}
+	service := jwt.NewService(cfg)
+	token, err := service.CreateToken(username)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	return service, token
+}
+
+func TestAuthenticateTokenCookie(t *testing.T) {
+	service, token := newTestJWT(t, config.JWTConfig{}, "alice")
+	_, expired := newTestJWT(t, config.JWTConfig{Expiration: -time.Minute}, "alice")
+
+	tests := []struct {
+		name          string
+		cookieName    string // Cookie the middleware reads; empty disables cookies
+		authorization string
+		cookie        string
+		want          int
+	}{
+		{name: "token in cookie", cookieName: testTokenCookie, cookie: token, want: http.StatusOK},
+		{name: "header overrides cookie", cookieName: testTokenCookie, authorization: "Bearer " + token, cookie: "not-a-token", want: http.StatusOK},
+		{name: "invalid header is not rescued by cookie", cookieName: testTokenCookie, authorization: "Bearer not-a-token", cookie: token, want: http.StatusUnauthorized},
+		{name: "neither present", cookieName: testTokenCookie, want: http.StatusUnauthorized},
+		{name: "invalid token in cookie", cookieName: testTokenCookie, cookie: "not-a-token", want: http.StatusUnauthorized},
+		{name: "expired token in cookie", cookieName: testTokenCookie, cookie: expired, want: http.StatusUnauthorized},
+		{name: "cookies disabled", cookie: token, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := NewAuthMiddleware(service)
+			if tt.cookieName != "" {
+				am.SetTokenCookie(tt.cookieName)
+			}
+			handler := am.Middleware(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: testTokenCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateOptional(t *testing.T) {
+	service, token := newTestJWT(t, config.JWTConfig{}, "alice")
+	_, expired := newTestJWT(t, config.JWTConfig{Expiration: -time.Minute}, "alice")
+
+	tests := []struct {
+		name          string
+		authorization string
+		cookie        string
+		wantOK        bool
+		wantUser      string
+	}{
+		{name: "no token proceeds anonymously", wantOK: true},
+		{name: "valid token sets the user", authorization: "Bearer " + token, wantOK: true, wantUser: "alice"},
+		{name: "valid token in cookie sets the user", cookie: token, wantOK: true, wantUser: "alice"},
+		{name: "invalid token", authorization: "Bearer not-a-token"},
+		{name: "expired token", authorization: "Bearer " + expired},
+		{name: "not a bearer token", authorization: "Basic YWxpY2U6c2VjcmV0"},
+		{name: "invalid token in cookie", cookie: "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := NewAuthMiddleware(service)
+			am.SetTokenCookie(testTokenCookie)
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: testTokenCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+
+			authenticated, ok := am.AuthenticateOptional(rec, req, "")
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				if rec.Code != http.StatusUnauthorized {
+					t.Errorf("status = %d, want 401", rec.Code)
+				}
+				return
+			}
+			if rec.Code != http.StatusOK || rec.Body.Len() > 0 {
+				t.Errorf("response written for an accepted request: %d %q", rec.Code, rec.Body.String())
+			}
+			if got := logger.GetUserID(authenticated.Context()); got != tt.wantUser {
+				t.Errorf("user = %q, want %q", got, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithProvider(t *testing.T) {
+	defaultConfig := config.JWTConfig{
+		Secret:     "auth-test-secret",
+		Expiration: time.Hour,
+		Providers: []config.AuthProviderConfig{
+			{Name: "partner", Secret: "partner-secret", Algorithm: "HS256", Issuer: "partner.example.com"},
+		},
+	}
+	service, defaultToken := newTestJWT(t, defaultConfig, "alice")
+	_, partnerToken := newTestJWT(t, config.JWTConfig{Secret: "partner-secret", Issuer: "partner.example.com"}, "bob")
+
+	tests := []struct {
+		name      string
+		providers bool // Whether the middleware is given the configured providers
+		provider  string
+		token     string
+		wantKnown bool // Whether HasProvider reports the provider
+		want      bool
+	}{
+		{name: "default token on default route", providers: true, token: defaultToken, wantKnown: true, want: true},
+		{name: "default token on explicitly default route", providers: true, provider: jwt.DefaultProvider, token: defaultToken, wantKnown: true, want: true},
+		{name: "partner token on partner route", providers: true, provider: "partner", token: partnerToken, wantKnown: true, want: true},
+		{name: "partner token on default route", providers: true, token: partnerToken, wantKnown: true},
+		{name: "default token on partner route", providers: true, provider: "partner", token: defaultToken, wantKnown: true},
+		{name: "unknown provider", providers: true, provider: "unknown", token: defaultToken},
+		{name: "default token without providers", token: defaultToken, wantKnown: true, want: true},
+		{name: "partner route without providers", provider: "partner", token: partnerToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := NewAuthMiddleware(service)
+			if tt.providers {
+				am.SetProviders(jwt.NewProviders(defaultConfig, service))
+			}
+			if got := am.HasProvider(tt.provider); got != tt.wantKnown {
+				t.Errorf("HasProvider(%q) = %v, want %v", tt.provider, got, tt.wantKnown)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			if got := am.AuthenticateWith(rec, req, tt.provider); got != tt.want {
+				t.Errorf("AuthenticateWith = %v, want %v", got, tt.want)
+			}
+			if !tt.want && rec.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", rec.Code)
+			}
+
+			rec = httptest.NewRecorder()
+			if _, got := am.AuthenticateOptional(rec, req, tt.provider); got != tt.want {
+				t.Errorf("AuthenticateOptional = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateTokenRejections(t *testing.T) {
+	service, _ := newTestJWT(t, config.JWTConfig{ClockSkewLeeway: 30 * time.Second}, "alice")
+	_, withinLeeway := newTestJWT(t, config.JWTConfig{Expiration: -10 * time.Second}, "alice")
+	_, expired := newTestJWT(t, config.JWTConfig{Expiration: -time.Minute}, "alice")
+	_, forged := newTestJWT(t, config.JWTConfig{Secret: "forged-secret"}, "alice")
+
+	tests := []struct {
+		name             string
+		token            string
+		want             int
+		wantBody         string
+		wantAuthenticate string
+	}{
+		{name: "expired within the leeway", token: withinLeeway, want: http.StatusOK},
+		{
+			name:             "expired",
+			token:            expired,
+			want:             http.StatusUnauthorized,
+			wantBody:         "Token expired\n",
+			wantAuthenticate: `Bearer error="invalid_token", error_description="The token expired"`,
+		},
+		{name: "invalid signature", token: forged, want: http.StatusUnauthorized, wantBody: "Invalid token\n", wantAuthenticate: `Bearer error="invalid_token"`},
+		{name: "malformed", token: "not-a-token", want: http.StatusUnauthorized, wantBody: "Invalid token\n", wantAuthenticate: `Bearer error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthMiddleware(service).Middleware(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantAuthenticate {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantAuthenticate)
+			}
+		})
+	}
+}
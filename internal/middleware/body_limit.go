@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// BodyLimitMiddleware rejects request bodies larger than a configured size
+type BodyLimitMiddleware struct {
+	limit int64
+}
+
+// NewBodyLimitMiddleware creates a body limit middleware; a limit <= 0 disables it
+func NewBodyLimitMiddleware(limit int64) *BodyLimitMiddleware {
+	return &BodyLimitMiddleware{limit: limit}
+}
+
+// Middleware returns the HTTP middleware function for body size limits
+func (m *BodyLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !LimitRequestBody(w, r, m.limit) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitRequestBody caps the request body at limit bytes. Requests whose declared
+// length is already too large get a 413 and false; otherwise the body is wrapped so
+// reading past the limit fails with an error IsRequestBodyTooLarge recognises.
+func LimitRequestBody(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+
+	if r.ContentLength > limit {
+		log.Printf("Request body of %d bytes exceeds limit of %d for %s %s", r.ContentLength, limit, r.Method, r.URL.Path)
+		WriteRequestBodyTooLarge(w)
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	return true
+}
+
+// IsRequestBodyTooLarge reports whether err came from reading past the body limit
+func IsRequestBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// WriteRequestBodyTooLarge writes the 413 response for an oversized body
+func WriteRequestBodyTooLarge(w http.ResponseWriter) {
+	http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int64
+		body       string
+		chunked    bool // Send without a Content-Length so the limit is hit while reading
+		wantStatus int
+		wantRead   string
+	}{
+		{name: "under the limit", limit: 16, body: "small", wantStatus: http.StatusOK, wantRead: "small"},
+		{name: "exactly the limit", limit: 5, body: "small", wantStatus: http.StatusOK, wantRead: "small"},
+		{name: "declared length over the limit", limit: 4, body: "too large", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked body over the limit", limit: 4, body: "too large", chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "limit disabled", limit: 0, body: "too large", wantStatus: http.StatusOK, wantRead: "too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var read string
+			handler := NewBodyLimitMiddleware(tt.limit).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if IsRequestBodyTooLarge(err) {
+					WriteRequestBodyTooLarge(w)
+					return
+				}
+				read = string(body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/uploads", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if read != tt.wantRead {
+				t.Errorf("handler read %q, want %q", read, tt.wantRead)
+			}
+		})
+	}
+}
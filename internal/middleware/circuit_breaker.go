@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"api-gateway/pkg/clock"
 	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -31,12 +35,14 @@ func (s CircuitBreakerState) String() string {
 
 // CircuitBreakerConfig holds configuration for a circuit breaker
 type CircuitBreakerConfig struct {
-	MaxRequests   uint32                                                              `json:"max_requests"` // Max requests allowed in half-open state
-	Interval      time.Duration                                                       `json:"interval"`     // Statistical window duration
-	Timeout       time.Duration                                                       `json:"timeout"`      // Time after which open circuit goes to half-open
-	ReadyToTrip   func(counts Counts) bool                                            `json:"-"`            // Function to determine when to trip
-	OnStateChange func(name string, from CircuitBreakerState, to CircuitBreakerState) `json:"-"`
-	IsSuccessful  func(err error) bool                                                `json:"-"` // Function to determine if request was successful
+	MaxRequests      uint32                                                              `json:"max_requests"`      // Max requests allowed in half-open state
+	Interval         time.Duration                                                       `json:"interval"`          // Rolling window the counts cover; 0 counts since the last state change
+	Timeout          time.Duration                                                       `json:"timeout"`           // Time after which open circuit goes to half-open
+	SuccessThreshold uint32                                                              `json:"success_threshold"` // Consecutive half-open successes needed to close, at least 1
+	ReadyToTrip      func(counts Counts) bool                                            `json:"-"`                 // Function to determine when to trip
+	OnStateChange    func(name string, from CircuitBreakerState, to CircuitBreakerState) `json:"-"`
+	IsSuccessful     func(err error) bool                                                `json:"-"` // Function to determine if request was successful
+	Clock            clock.Clock                                                         `json:"-"` // Time source, defaults to the real clock
 }
 
 // Counts holds statistics about requests
@@ -66,19 +72,35 @@ func (c Counts) SuccessRate() float64 {
 
 // CircuitBreaker implements the circuit breaker pattern
 type CircuitBreaker struct {
-	name          string
-	maxRequests   uint32
-	interval      time.Duration
-	timeout       time.Duration
-	readyToTrip   func(counts Counts) bool
-	isSuccessful  func(err error) bool
-	onStateChange func(name string, from CircuitBreakerState, to CircuitBreakerState)
+	name             string
+	maxRequests      uint32
+	successThreshold uint32
+	interval         time.Duration
+	timeout          time.Duration
+	readyToTrip      func(counts Counts) bool
+	isSuccessful     func(err error) bool
+	onStateChange    func(name string, from CircuitBreakerState, to CircuitBreakerState)
+	clock            clock.Clock
+
+	mutex       sync.Mutex
+	state       CircuitBreakerState
+	generation  uint64
+	counts      Counts        // Totals over the buckets below
+	buckets     []countBucket // Oldest first
+	bucketWidth time.Duration
+	expiry      time.Time
+}
+
+// windowBuckets is how many buckets the rolling window is split into; counts
+// leave the window one bucket at a time instead of all at once
+const windowBuckets = 10
 
-	mutex      sync.Mutex
-	state      CircuitBreakerState
-	generation uint64
-	counts     Counts
-	expiry     time.Time
+// countBucket holds the requests recorded during one slice of the window
+type countBucket struct {
+	start     time.Time
+	requests  uint32
+	successes uint32
+	failures  uint32
 }
 
 var (
@@ -86,6 +108,21 @@ var (
 	ErrOpenState       = errors.New("circuit breaker: open state")
 )
 
+// IsCircuitBreakerRejection reports whether err means a circuit breaker refused the call
+func IsCircuitBreakerRejection(err error) bool {
+	return errors.Is(err, ErrOpenState) || errors.Is(err, ErrTooManyRequests)
+}
+
+// WriteCircuitBreakerOpen responds 503 with a Retry-After of at least one second
+func WriteCircuitBreakerOpen(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Service Temporarily Unavailable", http.StatusServiceUnavailable)
+}
+
 // NewCircuitBreaker creates a new circuit breaker with the given config
 func NewCircuitBreaker(name string, config CircuitBreakerConfig) *CircuitBreaker {
 	cb := &CircuitBreaker{
@@ -93,8 +130,13 @@ func NewCircuitBreaker(name string, config CircuitBreakerConfig) *CircuitBreaker
 		maxRequests: config.MaxRequests,
 		interval:    config.Interval,
 		timeout:     config.Timeout,
+		bucketWidth: config.Interval / windowBuckets,
 	}
 
+	// Half-open admits maxRequests probes, so it has to cover the successes needed to close
+	cb.successThreshold = max(config.SuccessThreshold, 1)
+	cb.maxRequests = max(cb.maxRequests, cb.successThreshold)
+
 	if config.ReadyToTrip == nil {
 		cb.readyToTrip = defaultReadyToTrip
 	} else {
@@ -109,7 +151,13 @@ func NewCircuitBreaker(name string, config CircuitBreakerConfig) *CircuitBreaker
 
 	cb.onStateChange = config.OnStateChange
 
-	cb.toNewGeneration(time.Now())
+	if config.Clock == nil {
+		cb.clock = clock.Real{}
+	} else {
+		cb.clock = config.Clock
+	}
+
+	cb.toNewGeneration(cb.clock.Now())
 
 	return cb
 }
@@ -138,16 +186,48 @@ func (cb *CircuitBreaker) State() CircuitBreakerState {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.clock.Now()
 	state, _ := cb.currentState(now)
 	return state
 }
 
-// Counts returns the current counts
+// Reset closes the breaker and clears its counts
+func (cb *CircuitBreaker) Reset() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	now := cb.clock.Now()
+	if cb.state != StateClosed {
+		cb.setState(StateClosed, now)
+		return
+	}
+	cb.toNewGeneration(now)
+}
+
+// RetryAfter returns how long until the breaker lets requests through again:
+// the rest of the open timeout, or zero when it is closed
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	now := cb.clock.Now()
+	state, _ := cb.currentState(now)
+	switch state {
+	case StateOpen:
+		return cb.expiry.Sub(now)
+	case StateHalfOpen:
+		return time.Second // Probe requests are in flight; the verdict comes shortly
+	default:
+		return 0
+	}
+}
+
+// Counts returns the counts over the current window
 func (cb *CircuitBreaker) Counts() Counts {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
+	cb.currentState(cb.clock.Now())
 	return cb.counts
 }
 
@@ -160,7 +240,7 @@ func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.clock.Now()
 	state, generation := cb.currentState(now)
 
 	if state == StateOpen {
@@ -170,6 +250,7 @@ func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
 	}
 
 	cb.counts.Requests++
+	cb.bucket(now).requests++
 	return generation, nil
 }
 
@@ -177,7 +258,7 @@ func (cb *CircuitBreaker) afterRequest(before uint64, success bool) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.clock.Now()
 	state, generation := cb.currentState(now)
 	if generation != before {
 		return
@@ -192,38 +273,66 @@ func (cb *CircuitBreaker) afterRequest(before uint64, success bool) {
 
 func (cb *CircuitBreaker) onSuccess(state CircuitBreakerState, now time.Time) {
 	cb.counts.TotalSuccesses++
+	cb.bucket(now).successes++
 	cb.counts.ConsecutiveSuccesses++
 	cb.counts.ConsecutiveFailures = 0
 
-	if state == StateHalfOpen {
+	if state == StateHalfOpen && cb.counts.ConsecutiveSuccesses >= cb.successThreshold {
 		cb.setState(StateClosed, now)
 	}
 }
 
 func (cb *CircuitBreaker) onFailure(state CircuitBreakerState, now time.Time) {
 	cb.counts.TotalFailures++
+	cb.bucket(now).failures++
 	cb.counts.ConsecutiveFailures++
 	cb.counts.ConsecutiveSuccesses = 0
 
-	if cb.readyToTrip(cb.counts) {
+	// A recovering backend gets no second chance while half-open
+	if state == StateHalfOpen || cb.readyToTrip(cb.counts) {
 		cb.setState(StateOpen, now)
 	}
 }
 
 func (cb *CircuitBreaker) currentState(now time.Time) (CircuitBreakerState, uint64) {
-	switch cb.state {
-	case StateClosed:
-		if !cb.expiry.IsZero() && cb.expiry.Before(now) {
-			cb.toNewGeneration(now)
-		}
-	case StateOpen:
-		if cb.expiry.Before(now) {
-			cb.setState(StateHalfOpen, now)
-		}
+	if cb.state == StateOpen && cb.expiry.Before(now) {
+		cb.setState(StateHalfOpen, now)
 	}
+	cb.rollWindow(now)
 	return cb.state, cb.generation
 }
 
+// rollWindow drops buckets that have left the window, taking their requests out of
+// the counts. Consecutive counts are streaks, so they are kept.
+func (cb *CircuitBreaker) rollWindow(now time.Time) {
+	if cb.interval <= 0 {
+		return
+	}
+
+	expired := 0
+	for _, b := range cb.buckets {
+		if now.Sub(b.start) < cb.interval {
+			break
+		}
+		cb.counts.Requests -= min(b.requests, cb.counts.Requests)
+		cb.counts.TotalSuccesses -= min(b.successes, cb.counts.TotalSuccesses)
+		cb.counts.TotalFailures -= min(b.failures, cb.counts.TotalFailures)
+		expired++
+	}
+	if expired > 0 {
+		cb.buckets = append(cb.buckets[:0], cb.buckets[expired:]...)
+	}
+}
+
+// bucket returns the bucket recording requests made at now
+func (cb *CircuitBreaker) bucket(now time.Time) *countBucket {
+	if n := len(cb.buckets); n > 0 && (cb.bucketWidth <= 0 || now.Before(cb.buckets[n-1].start.Add(cb.bucketWidth))) {
+		return &cb.buckets[n-1]
+	}
+	cb.buckets = append(cb.buckets, countBucket{start: now})
+	return &cb.buckets[len(cb.buckets)-1]
+}
+
 func (cb *CircuitBreaker) setState(state CircuitBreakerState, now time.Time) {
 	if cb.state == state {
 		return
@@ -242,19 +351,12 @@ func (cb *CircuitBreaker) setState(state CircuitBreakerState, now time.Time) {
 func (cb *CircuitBreaker) toNewGeneration(now time.Time) {
 	cb.generation++
 	cb.counts = Counts{}
+	cb.buckets = cb.buckets[:0]
 
-	var zero time.Time
-	switch cb.state {
-	case StateClosed:
-		if cb.interval == 0 {
-			cb.expiry = zero
-		} else {
-			cb.expiry = now.Add(cb.interval)
-		}
-	case StateOpen:
+	if cb.state == StateOpen {
 		cb.expiry = now.Add(cb.timeout)
-	default: // StateHalfOpen
-		cb.expiry = zero
+	} else {
+		cb.expiry = time.Time{} // Closed and half-open breakers only change state on results
 	}
 }
 
@@ -327,6 +429,17 @@ func (cbm *CircuitBreakerManager) GetCircuitBreaker(serviceName string) *Circuit
 	return cb
 }
 
+// ResetAll closes every circuit breaker and clears its counts, returning how many were reset
+func (cbm *CircuitBreakerManager) ResetAll() int {
+	cbm.mutex.RLock()
+	defer cbm.mutex.RUnlock()
+
+	for _, cb := range cbm.breakers {
+		cb.Reset()
+	}
+	return len(cbm.breakers)
+}
+
 // GetAllStates returns the states of all circuit breakers
 func (cbm *CircuitBreakerManager) GetAllStates() map[string]CircuitBreakerState {
 	cbm.mutex.RLock()
@@ -353,14 +466,15 @@ func (cbm *CircuitBreakerManager) GetAllCounts() map[string]Counts {
 
 // CircuitBreakerStats provides comprehensive statistics
 type CircuitBreakerStats struct {
-	Name        string              `json:"name"`
-	State       CircuitBreakerState `json:"state"`
-	Counts      Counts              `json:"counts"`
-	ErrorRate   float64             `json:"error_rate"`
-	SuccessRate float64             `json:"success_rate"`
-	MaxRequests uint32              `json:"max_requests"`
-	Interval    time.Duration       `json:"interval"`
-	Timeout     time.Duration       `json:"timeout"`
+	Name             string              `json:"name"`
+	State            CircuitBreakerState `json:"state"`
+	Counts           Counts              `json:"counts"`
+	ErrorRate        float64             `json:"error_rate"`
+	SuccessRate      float64             `json:"success_rate"`
+	MaxRequests      uint32              `json:"max_requests"`
+	SuccessThreshold uint32              `json:"success_threshold"`
+	Interval         time.Duration       `json:"interval"`
+	Timeout          time.Duration       `json:"timeout"`
 }
 
 // GetStats returns comprehensive statistics for all circuit breakers
@@ -372,14 +486,15 @@ func (cbm *CircuitBreakerManager) GetStats() map[string]CircuitBreakerStats {
 	for name, cb := range cbm.breakers {
 		counts := cb.Counts()
 		stats[name] = CircuitBreakerStats{
-			Name:        name,
-			State:       cb.State(),
-			Counts:      counts,
-			ErrorRate:   counts.ErrorRate(),
-			SuccessRate: counts.SuccessRate(),
-			MaxRequests: cb.maxRequests,
-			Interval:    cb.interval,
-			Timeout:     cb.timeout,
+			Name:             name,
+			State:            cb.State(),
+			Counts:           counts,
+			ErrorRate:        counts.ErrorRate(),
+			SuccessRate:      counts.SuccessRate(),
+			MaxRequests:      cb.maxRequests,
+			SuccessThreshold: cb.successThreshold,
+			Interval:         cb.interval,
+			Timeout:          cb.timeout,
 		}
 	}
 	return stats
@@ -0,0 +1,328 @@
+package middleware
+
+import (
+	"api-gateway/pkg/clock"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errUpstream = errors.New("upstream failed")
+
+// openBreaker returns a breaker that has tripped and whose open timeout has passed
+func openBreaker(t *testing.T, successThreshold uint32) *CircuitBreaker {
+	t.Helper()
+	fake := clock.NewFake(time.Unix(0, 0))
+	cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+		MaxRequests:      5,
+		SuccessThreshold: successThreshold,
+		Interval:         time.Minute,
+		Timeout:          30 * time.Second,
+		ReadyToTrip:      func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+		Clock:            fake,
+	})
+	cb.Execute(func() (interface{}, error) { return nil, errUpstream })
+	if cb.State() != StateOpen {
+		t.Fatalf("state = %s after a failure, want OPEN", cb.State())
+	}
+	fake.Advance(31 * time.Second)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("state = %s after the timeout, want HALF_OPEN", cb.State())
+	}
+	return cb
+}
+
+func TestCircuitBreakerHalfOpenSuccessThreshold(t *testing.T) {
+	tests := []struct {
+		name             string
+		successThreshold uint32
+		results          []error
+		want             CircuitBreakerState
+	}{
+		{name: "one success closes with threshold 1", successThreshold: 1, results: []error{nil}, want: StateClosed},
+		{name: "one success stays half-open with threshold 3", successThreshold: 3, results: []error{nil}, want: StateHalfOpen},
+		{name: "two successes stay half-open with threshold 3", successThreshold: 3, results: []error{nil, nil}, want: StateHalfOpen},
+		{name: "three successes close with threshold 3", successThreshold: 3, results: []error{nil, nil, nil}, want: StateClosed},
+		{name: "a failure reopens", successThreshold: 3, results: []error{nil, nil, errUpstream}, want: StateOpen},
+		{name: "zero threshold acts as 1", successThreshold: 0, results: []error{nil}, want: StateClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := openBreaker(t, tt.successThreshold)
+			for _, result := range tt.results {
+				cb.Execute(func() (interface{}, error) { return nil, result })
+			}
+			if got := cb.State(); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenAdmitsSuccessThreshold(t *testing.T) {
+	// MaxRequests below the threshold is raised, or the circuit could never close
+	fake := clock.NewFake(time.Unix(0, 0))
+	cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+		MaxRequests:      1,
+		SuccessThreshold: 3,
+		Timeout:          time.Second,
+		ReadyToTrip:      func(counts Counts) bool { return true },
+		Clock:            fake,
+	})
+	cb.Execute(func() (interface{}, error) { return nil, errUpstream })
+	fake.Advance(2 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Execute(func() (interface{}, error) { return nil, nil }); err != nil {
+			t.Fatalf("probe %d rejected: %v", i+1, err)
+		}
+	}
+	if got := cb.State(); got != StateClosed {
+		t.Errorf("state = %s, want CLOSED", got)
+	}
+}
+
+func TestCircuitBreakerTransitionsWithFakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	var transitions []string
+	cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+		MaxRequests: 1,
+		Timeout:     30 * time.Second,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 2 },
+		OnStateChange: func(name string, from, to CircuitBreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+		Clock: fake,
+	})
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		result  error // Outcome of a request made after advancing, if execute is set
+		execute bool
+		want    CircuitBreakerState
+		wantErr error
+	}{
+		{name: "first failure", execute: true, result: errUpstream, want: StateClosed},
+		{name: "second failure trips", execute: true, result: errUpstream, want: StateOpen},
+		{name: "rejected while open", execute: true, wantErr: ErrOpenState, want: StateOpen},
+		{name: "still open before the timeout", advance: 29 * time.Second, want: StateOpen},
+		{name: "half-open after the timeout", advance: 2 * time.Second, want: StateHalfOpen},
+		{name: "probe succeeds and closes", execute: true, want: StateClosed},
+	}
+
+	for _, step := range steps {
+		fake.Advance(step.advance)
+		if step.execute {
+			_, err := cb.Execute(func() (interface{}, error) { return nil, step.result })
+			if step.wantErr != nil && !errors.Is(err, step.wantErr) {
+				t.Errorf("%s: err = %v, want %v", step.name, err, step.wantErr)
+			}
+		}
+		if got := cb.State(); got != step.want {
+			t.Fatalf("%s: state = %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	want := []string{"CLOSED->OPEN", "OPEN->HALF_OPEN", "HALF_OPEN->CLOSED"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d = %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestCircuitBreakerRetryAfterFollowsClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+		Timeout:     30 * time.Second,
+		ReadyToTrip: func(counts Counts) bool { return true },
+		Clock:       fake,
+	})
+	cb.Execute(func() (interface{}, error) { return nil, errUpstream })
+
+	fake.Advance(10 * time.Second)
+	if got := cb.RetryAfter(); got != 20*time.Second {
+		t.Errorf("RetryAfter = %v, want 20s", got)
+	}
+}
+
+func TestWriteCircuitBreakerOpen(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       string
+	}{
+		{retryAfter: 20 * time.Second, want: "20"},
+		{retryAfter: 1200 * time.Millisecond, want: "2"}, // Rounded up so clients don't retry early
+		{retryAfter: 0, want: "1"},
+		{retryAfter: -time.Second, want: "1"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		WriteCircuitBreakerOpen(rec, tt.retryAfter)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("retryAfter %v: status = %d, want 503", tt.retryAfter, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != tt.want {
+			t.Errorf("retryAfter %v: Retry-After = %q, want %q", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestIsCircuitBreakerRejection(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: ErrOpenState, want: true},
+		{err: ErrTooManyRequests, want: true},
+		{err: fmt.Errorf("orders: %w", ErrOpenState), want: true},
+		{err: errors.New("circuit breaker is open"), want: false}, // Matched by identity, not by message
+		{err: errUpstream, want: false},
+		{err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsCircuitBreakerRejection(tt.err); got != tt.want {
+			t.Errorf("IsCircuitBreakerRejection(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCircuitBreakerCountsRollingWindow(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+		Interval:    10 * time.Second, // Ten 1s buckets
+		ReadyToTrip: func(counts Counts) bool { return false },
+		Clock:       fake,
+	})
+
+	// One failure every 3 seconds, each followed by a success
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			fake.Advance(3 * time.Second)
+		}
+		cb.Execute(func() (interface{}, error) { return nil, errUpstream })
+		cb.Execute(func() (interface{}, error) { return nil, nil })
+	}
+
+	steps := []struct {
+		name         string
+		advance      time.Duration
+		wantRequests uint32
+		wantFailures uint32
+	}{
+		{name: "all within the window", wantRequests: 8, wantFailures: 4},
+		{name: "first bucket leaves the window", advance: time.Second, wantRequests: 6, wantFailures: 3},
+		{name: "one bucket at a time", advance: 3 * time.Second, wantRequests: 4, wantFailures: 2},
+		{name: "last bucket still in", advance: 5 * time.Second, wantRequests: 2, wantFailures: 1},
+		{name: "window empty", advance: time.Second},
+	}
+
+	for _, step := range steps {
+		fake.Advance(step.advance)
+		counts := cb.Counts()
+		if counts.Requests != step.wantRequests || counts.TotalFailures != step.wantFailures {
+			t.Errorf("%s: requests, failures = %d, %d, want %d, %d",
+				step.name, counts.Requests, counts.TotalFailures, step.wantRequests, step.wantFailures)
+		}
+		if counts.TotalSuccesses != step.wantRequests-step.wantFailures {
+			t.Errorf("%s: successes = %d, want %d", step.name, counts.TotalSuccesses, step.wantRequests-step.wantFailures)
+		}
+	}
+}
+
+func TestCircuitBreakerStaysOpenAcrossWindowBoundary(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+		MaxRequests: 1,
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		ReadyToTrip: func(counts Counts) bool { return counts.TotalFailures >= 5 },
+		Clock:       fake,
+	})
+
+	// Healthy for 7 seconds, then failing every second. Counts reset when the
+	// interval rolled over would see 3 failures, then 2, and never trip.
+	steps := []struct {
+		name    string
+		advance time.Duration
+		result  error
+		want    CircuitBreakerState
+	}{
+		{name: "healthy", want: StateClosed},
+		{name: "healthy", advance: 3 * time.Second, want: StateClosed},
+		{name: "healthy", advance: 3 * time.Second, want: StateClosed},
+		{name: "failing", advance: time.Second, result: errUpstream, want: StateClosed},
+		{name: "failing", advance: time.Second, result: errUpstream, want: StateClosed},
+		{name: "failing", advance: time.Second, result: errUpstream, want: StateClosed},
+		{name: "failing past the interval", advance: time.Second, result: errUpstream, want: StateClosed},
+		{name: "fifth failure in the window trips", advance: time.Second, result: errUpstream, want: StateOpen},
+		{name: "probe after the timeout fails", advance: 6 * time.Second, result: errUpstream, want: StateOpen},
+		{name: "second probe fails", advance: 6 * time.Second, result: errUpstream, want: StateOpen},
+	}
+
+	for _, step := range steps {
+		fake.Advance(step.advance)
+		cb.Execute(func() (interface{}, error) { return nil, step.result })
+		if got := cb.State(); got != step.want {
+			t.Fatalf("%s at %v: state = %s, want %s", step.name, fake.Now().Sub(time.Unix(0, 0)), got, step.want)
+		}
+	}
+}
+
+func TestCircuitBreakerReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) *CircuitBreaker
+	}{
+		{name: "half-open", setup: func(t *testing.T) *CircuitBreaker { return openBreaker(t, 1) }},
+		{
+			name: "open",
+			setup: func(t *testing.T) *CircuitBreaker {
+				cb := openBreaker(t, 1)
+				cb.Execute(func() (interface{}, error) { return nil, errUpstream })
+				return cb
+			},
+		},
+		{
+			name: "closed with failures counted",
+			setup: func(t *testing.T) *CircuitBreaker {
+				cb := NewCircuitBreaker("orders", CircuitBreakerConfig{
+					MaxRequests: 1,
+					Interval:    time.Minute,
+					Timeout:     30 * time.Second,
+					ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 5 },
+					Clock:       clock.NewFake(time.Unix(0, 0)),
+				})
+				cb.Execute(func() (interface{}, error) { return nil, nil })
+				cb.Execute(func() (interface{}, error) { return nil, errUpstream })
+				return cb
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := tt.setup(t)
+			cb.Reset()
+			if got := cb.State(); got != StateClosed {
+				t.Errorf("state = %s, want CLOSED", got)
+			}
+			if counts := cb.Counts(); counts != (Counts{}) {
+				t.Errorf("counts = %+v, want zero", counts)
+			}
+			if _, err := cb.Execute(func() (interface{}, error) { return nil, nil }); err != nil {
+				t.Errorf("request after reset: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+type clientCertContextKey struct{}
+
+// ClientCertMiddleware exposes the verified client certificate subject to later handlers
+type ClientCertMiddleware struct{}
+
+// NewClientCertMiddleware creates a new client certificate middleware
+func NewClientCertMiddleware() *ClientCertMiddleware {
+	return &ClientCertMiddleware{}
+}
+
+// Middleware stores the subject of the client's leaf certificate in the request
+// context. Only chains verified during the TLS handshake are trusted.
+func (m *ClientCertMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			subject := r.TLS.VerifiedChains[0][0].Subject.String()
+			r = r.WithContext(context.WithValue(r.Context(), clientCertContextKey{}, subject))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientCertSubject returns the verified client certificate subject, if any
+func ClientCertSubject(ctx context.Context) string {
+	if subject, ok := ctx.Value(clientCertContextKey{}).(string); ok {
+		return subject
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPContextKey struct{}
+
+// clientAddress is what ClientIPMiddleware stores in the request context
+type clientAddress struct {
+	ip          string
+	trustedPeer bool // The direct peer is a trusted proxy
+}
+
+// ClientIPMiddleware resolves the client IP once per request. Forwarding headers
+// are honored only when the direct peer is a trusted proxy, so clients can't
+// spoof their address to dodge rate limits or poison logs.
+type ClientIPMiddleware struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPMiddleware creates a client IP resolver trusting the given CIDRs;
+// bare IPs are treated as single-host ranges
+func NewClientIPMiddleware(trustedProxies []string) (*ClientIPMiddleware, error) {
+	m := &ClientIPMiddleware{}
+	for _, entry := range trustedProxies {
+		network, err := ParseTrustedProxy(entry)
+		if err != nil {
+			return nil, err
+		}
+		m.trusted = append(m.trusted, network)
+	}
+	return m, nil
+}
+
+// ParseTrustedProxy parses a trusted proxy CIDR or bare IP
+func ParseTrustedProxy(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+	}
+	return network, nil
+}
+
+// Middleware stores the resolved client IP in the request context
+func (m *ClientIPMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := clientAddress{ip: m.resolve(r), trustedPeer: m.isTrusted(remoteIP(r))}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, addr)))
+	})
+}
+
+// resolve picks the client IP. From a trusted peer, X-Forwarded-For is walked
+// right to left skipping trusted hops, so the first untrusted address wins;
+// X-Real-IP and CF-Connecting-IP are used when there is no X-Forwarded-For.
+func (m *ClientIPMiddleware) resolve(r *http.Request) string {
+	remote := remoteIP(r)
+	if !m.isTrusted(remote) {
+		return remote
+	}
+
+	if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !m.isTrusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+
+	for _, header := range []string{"X-Real-IP", "CF-Connecting-IP"} {
+		if ip := strings.TrimSpace(r.Header.Get(header)); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	return remote
+}
+
+// isTrusted reports whether ip falls in a trusted proxy range
+func (m *ClientIPMiddleware) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range m.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client IP resolved by ClientIPMiddleware, or the
+// direct peer address when the middleware didn't run
+func ClientIP(r *http.Request) string {
+	if addr, ok := r.Context().Value(clientIPContextKey{}).(clientAddress); ok {
+		return addr.ip
+	}
+	return remoteIP(r)
+}
+
+// FromTrustedProxy reports whether the request arrived through a trusted proxy,
+// whose forwarding headers may be passed on to backends
+func FromTrustedProxy(r *http.Request) bool {
+	addr, ok := r.Context().Value(clientIPContextKey{}).(clientAddress)
+	return ok && addr.trustedPeer
+}
+
+// remoteIP returns the host part of the request's peer address
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
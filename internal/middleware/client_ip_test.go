@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPMiddleware(t *testing.T) {
+	m, err := NewClientIPMiddleware([]string{"10.0.0.0/8", "192.168.1.5", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("NewClientIPMiddleware: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		headers     map[string]string
+		want        string
+		wantTrusted bool
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:40000", want: "203.0.113.7"},
+		{
+			name:       "untrusted peer cannot spoof X-Forwarded-For",
+			remoteAddr: "203.0.113.7:40000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof X-Real-IP",
+			remoteAddr: "203.0.113.7:40000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1", "CF-Connecting-IP": "198.51.100.2"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:        "trusted proxy",
+			remoteAddr:  "10.1.2.3:40000",
+			headers:     map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:        "198.51.100.1",
+			wantTrusted: true,
+		},
+		{
+			name:        "trusted hops are skipped",
+			remoteAddr:  "10.1.2.3:40000",
+			headers:     map[string]string{"X-Forwarded-For": "198.51.100.1, 10.9.9.9, 192.168.1.5"},
+			want:        "198.51.100.1",
+			wantTrusted: true,
+		},
+		{
+			name:        "spoofed entry left of an untrusted hop is ignored",
+			remoteAddr:  "10.1.2.3:40000",
+			headers:     map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.9.9.9"},
+			want:        "198.51.100.1",
+			wantTrusted: true,
+		},
+		{
+			name:        "every hop trusted",
+			remoteAddr:  "10.1.2.3:40000",
+			headers:     map[string]string{"X-Forwarded-For": "10.0.0.2, 10.0.0.3"},
+			want:        "10.0.0.2",
+			wantTrusted: true,
+		},
+		{
+			name:        "X-Real-IP from a trusted proxy",
+			remoteAddr:  "192.168.1.5:40000",
+			headers:     map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:        "198.51.100.1",
+			wantTrusted: true,
+		},
+		{
+			name:        "CF-Connecting-IP from a trusted proxy",
+			remoteAddr:  "10.1.2.3:40000",
+			headers:     map[string]string{"CF-Connecting-IP": "198.51.100.2"},
+			want:        "198.51.100.2",
+			wantTrusted: true,
+		},
+		{
+			name:        "garbage header falls back to the peer",
+			remoteAddr:  "10.1.2.3:40000",
+			headers:     map[string]string{"X-Forwarded-For": "not-an-ip"},
+			want:        "10.1.2.3",
+			wantTrusted: true,
+		},
+		{name: "untrusted host in a bare-IP entry's subnet", remoteAddr: "192.168.1.6:40000", headers: map[string]string{"X-Real-IP": "198.51.100.1"}, want: "192.168.1.6"},
+		{
+			name:        "IPv6 trusted proxy",
+			remoteAddr:  "[fd00::1]:40000",
+			headers:     map[string]string{"X-Forwarded-For": "2001:db8::7"},
+			want:        "2001:db8::7",
+			wantTrusted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			var gotIP string
+			var gotTrusted bool
+			m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotIP, gotTrusted = ClientIP(r), FromTrustedProxy(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if gotIP != tt.want {
+				t.Errorf("ClientIP = %q, want %q", gotIP, tt.want)
+			}
+			if gotTrusted != tt.wantTrusted {
+				t.Errorf("FromTrustedProxy = %v, want %v", gotTrusted, tt.wantTrusted)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxy(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/8", " 192.168.1.5 ", "fd00::1", "fd00::/8"} {
+		if _, err := ParseTrustedProxy(entry); err != nil {
+			t.Errorf("ParseTrustedProxy(%q) = %v", entry, err)
+		}
+	}
+	for _, entry := range []string{"", "proxy.internal", "10.0.0.0/33"} {
+		if _, err := ParseTrustedProxy(entry); err == nil {
+			t.Errorf("ParseTrustedProxy(%q) accepted an invalid entry", entry)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+)
+
+// HeaderLimitMiddleware rejects requests carrying too many headers or too many
+// header bytes before they reach logging and the upstreams
+type HeaderLimitMiddleware struct {
+	maxCount int
+	maxBytes int
+}
+
+// NewHeaderLimitMiddleware creates a header limit middleware; a limit <= 0 disables that check
+func NewHeaderLimitMiddleware(maxCount, maxBytes int) *HeaderLimitMiddleware {
+	return &HeaderLimitMiddleware{maxCount: maxCount, maxBytes: maxBytes}
+}
+
+// Middleware returns the HTTP middleware function for header limits
+func (m *HeaderLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, size := headerSize(r.Header)
+		if (m.maxCount > 0 && count > m.maxCount) || (m.maxBytes > 0 && size > m.maxBytes) {
+			log.Printf("Request headers exceed limits for %s %s: %d headers, %d bytes", r.Method, r.URL.Path, count, size)
+			http.Error(w, "Request Header Fields Too Large", http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headerSize counts header lines, repeated headers once per value, and the bytes of their names and values
+func headerSize(headers http.Header) (int, int) {
+	count, size := 0, 0
+	for name, values := range headers {
+		for _, value := range values {
+			count++
+			size += len(name) + len(value)
+		}
+	}
+	return count, size
+}
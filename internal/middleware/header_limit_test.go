@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaderLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxCount   int
+		maxBytes   int
+		headers    int    // Number of distinct X-Header-N: v headers
+		repeated   int    // Extra values of X-Repeated, each counted as a header
+		large      string // Value of an X-Large header when set
+		wantStatus int
+	}{
+		{name: "within limits", maxCount: 5, maxBytes: 100, headers: 5, wantStatus: http.StatusOK},
+		{name: "too many headers", maxCount: 5, maxBytes: 100, headers: 6, wantStatus: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "repeated values count", maxCount: 5, maxBytes: 100, headers: 3, repeated: 3, wantStatus: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "headers too large", maxCount: 5, maxBytes: 100, large: strings.Repeat("x", 100), wantStatus: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "exactly the byte limit", maxCount: 5, maxBytes: 100, large: strings.Repeat("x", 100-len("X-Large")), wantStatus: http.StatusOK},
+		{name: "limits disabled", headers: 500, large: strings.Repeat("x", 1<<16), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			handler := NewHeaderLimitMiddleware(tt.maxCount, tt.maxBytes).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			for i := 0; i < tt.headers; i++ {
+				req.Header.Set(fmt.Sprintf("X-Header-%d", i), "v")
+			}
+			for i := 0; i < tt.repeated; i++ {
+				req.Header.Add("X-Repeated", "v")
+			}
+			if tt.large != "" {
+				req.Header.Set("X-Large", tt.large)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("next handler reached = %v, want %v", reached, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
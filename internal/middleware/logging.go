@@ -2,15 +2,51 @@ package middleware
 
 import (
 	"api-gateway/pkg/logger"
-	"net"
+	"api-gateway/pkg/metrics"
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// LoggingMiddlewareConfig controls what the logging middleware records
+type LoggingMiddlewareConfig struct {
+	LogRequests          bool             // Log an entry when a request starts
+	LogResponses         bool             // Include response size and headers on completion
+	LogHeaders           bool             // Include headers in request/response entries
+	SensitiveHeaders     []string         // Header names whose values are redacted, case-insensitive
+	SlowRequestThreshold time.Duration    // Requests slower than this emit a warning; 0 disables
+	Metrics              metrics.Recorder // Receives request counts and durations; nil discards them
+	AccessLog            *AccessLog       // Common/Combined Log Format output; nil disables it
+	MaxLoggedHeaders     int              // Headers captured per entry, the rest are counted; 0 captures all
+}
+
+// DefaultLoggingMiddlewareConfig returns the middleware's built-in defaults
+func DefaultLoggingMiddlewareConfig() LoggingMiddlewareConfig {
+	return LoggingMiddlewareConfig{
+		LogRequests:          true,
+		LogResponses:         false,
+		LogHeaders:           true,
+		SensitiveHeaders:     []string{"authorization", "cookie", "x-api-key", "x-auth-token"},
+		SlowRequestThreshold: 5 * time.Second,
+		MaxLoggedHeaders:     50,
+	}
+}
+
 // StructuredLoggingMiddleware provides comprehensive request/response logging
 type StructuredLoggingMiddleware struct {
-	logger *logger.Logger
+	logger           *logger.Logger
+	config           LoggingMiddlewareConfig
+	sensitiveHeaders map[string]bool
+	slowThreshold    atomic.Int64 // SlowRequestThreshold, adjustable at runtime
 }
 
 // ResponseWriter wrapper to capture status code and response size
@@ -31,11 +67,45 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Flush sends buffered data to the client so streamed responses such as SSE aren't held back
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for flushes and deadlines
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // NewStructuredLoggingMiddleware creates a new structured logging middleware
 func NewStructuredLoggingMiddleware(logger *logger.Logger) *StructuredLoggingMiddleware {
-	return &StructuredLoggingMiddleware{
-		logger: logger,
+	return NewStructuredLoggingMiddlewareWithConfig(logger, DefaultLoggingMiddlewareConfig())
+}
+
+// NewStructuredLoggingMiddlewareWithConfig creates a logging middleware using the given config
+func NewStructuredLoggingMiddlewareWithConfig(logger *logger.Logger, config LoggingMiddlewareConfig) *StructuredLoggingMiddleware {
+	sensitive := make(map[string]bool, len(config.SensitiveHeaders))
+	for _, header := range config.SensitiveHeaders {
+		sensitive[strings.ToLower(strings.TrimSpace(header))] = true
+	}
+	if config.Metrics == nil {
+		config.Metrics = metrics.Discard
 	}
+
+	m := &StructuredLoggingMiddleware{
+		logger:           logger,
+		config:           config,
+		sensitiveHeaders: sensitive,
+	}
+	m.slowThreshold.Store(int64(config.SlowRequestThreshold))
+	return m
+}
+
+// SetSlowRequestThreshold changes the slow request threshold; 0 disables the warning
+func (m *StructuredLoggingMiddleware) SetSlowRequestThreshold(threshold time.Duration) {
+	m.slowThreshold.Store(int64(threshold))
 }
 
 // Middleware returns the HTTP middleware function
@@ -43,14 +113,20 @@ func (m *StructuredLoggingMiddleware) Middleware(next http.Handler) http.Handler
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Enrich context with correlation and request IDs
-		ctx := logger.EnrichContext(r.Context())
-
-		// Extract correlation ID from header if present
-		if correlationID := r.Header.Get("X-Correlation-ID"); correlationID != "" {
-			ctx = logger.WithCorrelationID(ctx, correlationID)
+		// Take a valid client correlation ID unless RequestIDMiddleware already has
+		ctx := r.Context()
+		var rejectedCorrelationID bool
+		if logger.GetCorrelationID(ctx) == "" {
+			var correlationID string
+			correlationID, rejectedCorrelationID = correlationIDFromHeader(r)
+			if correlationID != "" {
+				ctx = logger.WithCorrelationID(ctx, correlationID)
+			}
 		}
 
+		// Enrich context with correlation and request IDs
+		ctx = logger.EnrichContext(ctx)
+
 		// Extract user ID from context/headers if available
 		if userID := extractUserID(r); userID != "" {
 			ctx = logger.WithUserID(ctx, userID)
@@ -71,19 +147,32 @@ func (m *StructuredLoggingMiddleware) Middleware(next http.Handler) http.Handler
 		}
 
 		// Get client IP
-		clientIP := getClientIP(r)
+		clientIP := ClientIP(r)
 
-		// Log request start
 		contextLogger := m.logger.WithContext(ctx).WithComponent("http")
-		contextLogger.Info("Request started", map[string]interface{}{
-			"method":     r.Method,
-			"path":       r.URL.Path,
-			"query":      r.URL.RawQuery,
-			"client_ip":  clientIP,
-			"user_agent": r.UserAgent(),
-			"referer":    r.Referer(),
-			"headers":    sanitizeHeaders(r.Header),
-		})
+		if rejectedCorrelationID {
+			contextLogger.Warn("Replaced invalid X-Correlation-ID", map[string]interface{}{
+				"length":    len(r.Header.Get("X-Correlation-ID")),
+				"client_ip": clientIP,
+			})
+			r.Header.Set("X-Correlation-ID", correlationID) // Upstreams get the replacement too
+		}
+
+		// Log request start
+		if m.config.LogRequests {
+			startFields := map[string]interface{}{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"query":      r.URL.RawQuery,
+				"client_ip":  clientIP,
+				"user_agent": r.UserAgent(),
+				"referer":    r.Referer(),
+			}
+			if m.config.LogHeaders {
+				startFields["headers"] = m.sanitizeHeaders(r.Header)
+			}
+			contextLogger.Info("Request started", startFields)
+		}
 
 		// Process request
 		next.ServeHTTP(wrapped, r)
@@ -91,6 +180,18 @@ func (m *StructuredLoggingMiddleware) Middleware(next http.Handler) http.Handler
 		// Calculate duration
 		duration := time.Since(start)
 
+		if m.config.AccessLog != nil {
+			m.config.AccessLog.Record(r, start, wrapped.statusCode, wrapped.size, clientIP, logger.GetUserID(ctx))
+		}
+
+		m.config.Metrics.IncCounter("gateway_http_requests_total", metrics.Labels{
+			"method": r.Method,
+			"status": strconv.Itoa(wrapped.statusCode),
+		})
+		m.config.Metrics.ObserveHistogram("gateway_http_request_duration_seconds", metrics.Labels{
+			"method": r.Method,
+		}, duration.Seconds())
+
 		// Prepare log fields
 		fields := map[string]interface{}{
 			"app":            "api-gateway",
@@ -109,6 +210,13 @@ func (m *StructuredLoggingMiddleware) Middleware(next http.Handler) http.Handler
 			fields["query"] = r.URL.RawQuery
 		}
 
+		if m.config.LogResponses {
+			fields["response_size"] = wrapped.size
+			if m.config.LogHeaders {
+				fields["response_headers"] = m.sanitizeHeaders(wrapped.Header())
+			}
+		}
+
 		// Log based on status code
 		message := "Request completed"
 		if wrapped.statusCode >= 500 {
@@ -120,12 +228,13 @@ func (m *StructuredLoggingMiddleware) Middleware(next http.Handler) http.Handler
 		}
 
 		// Log slow requests
-		if duration > 5*time.Second {
+		threshold := time.Duration(m.slowThreshold.Load())
+		if threshold > 0 && duration > threshold {
 			contextLogger.Warn("Slow request detected", map[string]interface{}{
 				"method":    r.Method,
 				"path":      r.URL.Path,
 				"duration":  duration,
-				"threshold": "5s",
+				"threshold": threshold.String(),
 			})
 		}
 	})
@@ -149,61 +258,66 @@ func extractUserID(r *http.Request) string {
 	return ""
 }
 
-// getClientIP extracts the real client IP from request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (most common)
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		// Take the first IP if multiple are present
-		ips := strings.Split(xForwardedFor, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	xRealIP := r.Header.Get("X-Real-IP")
-	if xRealIP != "" {
-		return xRealIP
-	}
+// maxLoggedHeaderValue is the longest header value logged before it is truncated
+const maxLoggedHeaderValue = 512
 
-	// Check CF-Connecting-IP (Cloudflare)
-	cfConnectingIP := r.Header.Get("CF-Connecting-IP")
-	if cfConnectingIP != "" {
-		return cfConnectingIP
+// sanitizeHeaders removes configured sensitive headers from logging. At most
+// MaxLoggedHeaders headers are captured, in name order; the number left out is
+// logged under "[omitted]".
+func (m *StructuredLoggingMiddleware) sanitizeHeaders(headers http.Header) map[string]string {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
 	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	sort.Strings(keys)
+	if limit := m.config.MaxLoggedHeaders; limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
 	}
-	return ip
-}
 
-// sanitizeHeaders removes sensitive headers from logging
-func sanitizeHeaders(headers http.Header) map[string]string {
-	sanitized := make(map[string]string)
-	sensitiveHeaders := map[string]bool{
-		"authorization": true,
-		"cookie":        true,
-		"x-api-key":     true,
-		"x-auth-token":  true,
+	sanitized := make(map[string]string, len(keys)+1)
+	if omitted := len(headers) - len(keys); omitted > 0 {
+		sanitized["[omitted]"] = strconv.Itoa(omitted)
 	}
 
-	for key, values := range headers {
+	for _, key := range keys {
+		values := headers[key]
 		lowerKey := strings.ToLower(key)
-		if sensitiveHeaders[lowerKey] {
+		if m.sensitiveHeaders[lowerKey] {
 			sanitized[key] = "[REDACTED]"
 		} else if len(values) > 0 {
-			sanitized[key] = values[0] // Only log first value
+			value := values[0] // Only log first value
+			if len(value) > maxLoggedHeaderValue {
+				value = value[:maxLoggedHeaderValue] + "..."
+			}
+			sanitized[key] = value
 		}
 	}
 
 	return sanitized
 }
 
-// PanicRecoveryMiddleware recovers from panics and logs them
+// panicDumpWindow is the window in which repeated panics trigger a goroutine dump
+const panicDumpWindow = time.Minute
+
+// maxGoroutineDumpBytes bounds the goroutine dump logged on repeated panics
+const maxGoroutineDumpBytes = 1 << 20
+
+// PanicResponse is the body sent when a handler panics
+type PanicResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// PanicRecoveryMiddleware recovers from panics and logs them with the stack of the panic
 type PanicRecoveryMiddleware struct {
 	logger *logger.Logger
+
+	// Goroutine dumps on repeated panics
+	dumpThreshold int
+	mu            sync.Mutex
+	windowStart   time.Time
+	panics        int
+	dumped        bool
 }
 
 // NewPanicRecoveryMiddleware creates a new panic recovery middleware
@@ -217,34 +331,103 @@ func NewPanicRecoveryMiddleware(logger *logger.Logger) *PanicRecoveryMiddleware
 func (m *PanicRecoveryMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
-			if err := recover(); err != nil {
-				// Log the panic with full context
-				contextLogger := m.logger.WithContext(r.Context()).WithComponent("panic_recovery")
-				contextLogger.Error("Panic recovered", map[string]interface{}{
-					"error":      err,
-					"method":     r.Method,
-					"path":       r.URL.Path,
-					"client_ip":  getClientIP(r),
-					"user_agent": r.UserAgent(),
-				})
-
-				// Return 500 error
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered) // Deliberate abort, the server closes the connection quietly
+			}
+
+			// Log the panic with full context
+			fields := map[string]interface{}{
+				"error":       fmt.Errorf("panic: %v", recovered),
+				"stack_trace": string(debug.Stack()),
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"client_ip":   ClientIP(r),
+				"user_agent":  r.UserAgent(),
+			}
+			if m.shouldDumpGoroutines(time.Now()) {
+				fields["goroutines"] = goroutineDump()
 			}
+			contextLogger := m.logger.WithContext(r.Context()).WithComponent("panic_recovery")
+			contextLogger.Error("Panic recovered", fields)
+
+			writeInternalServerError(w, logger.GetRequestID(r.Context()))
 		}()
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// SetGoroutineDumpThreshold logs the stacks of all goroutines, once per minute, when that many
+// panics are recovered within it; 0 disables the dump. Call it before the server starts.
+func (m *PanicRecoveryMiddleware) SetGoroutineDumpThreshold(threshold int) {
+	m.dumpThreshold = threshold
+}
+
+// shouldDumpGoroutines counts a panic and reports whether it is the one reaching the
+// threshold in the current window
+func (m *PanicRecoveryMiddleware) shouldDumpGoroutines(now time.Time) bool {
+	if m.dumpThreshold <= 0 {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.windowStart) >= panicDumpWindow {
+		m.windowStart, m.panics, m.dumped = now, 0, false
+	}
+	m.panics++
+	if m.panics < m.dumpThreshold || m.dumped {
+		return false
+	}
+	m.dumped = true
+	return true
+}
+
+// goroutineDump returns the stacks of every goroutine, truncated to maxGoroutineDumpBytes
+func goroutineDump() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDumpBytes {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// writeInternalServerError writes the JSON 500 sent for a recovered panic
+func writeInternalServerError(w http.ResponseWriter, requestID string) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	json.NewEncoder(w).Encode(PanicResponse{
+		Error:     "internal server error",
+		RequestID: requestID,
+	})
+}
+
 // RequestIDMiddleware ensures every request has a request ID
-type RequestIDMiddleware struct{}
+type RequestIDMiddleware struct {
+	signingKey []byte
+}
 
 // NewRequestIDMiddleware creates a new request ID middleware
 func NewRequestIDMiddleware() *RequestIDMiddleware {
 	return &RequestIDMiddleware{}
 }
 
+// SetSigningKey makes the middleware sign the correlation IDs it generates and only
+// accept inbound ones signed with the same key. Call it before the server starts.
+func (m *RequestIDMiddleware) SetSigningKey(key []byte) {
+	m.signingKey = key
+}
+
 // Middleware returns the HTTP middleware function for request IDs
 func (m *RequestIDMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -258,11 +441,20 @@ func (m *RequestIDMiddleware) Middleware(next http.Handler) http.Handler {
 		// Generate correlation ID if not present
 		if logger.GetCorrelationID(ctx) == "" {
 			// Check if provided in header
-			if correlationID := r.Header.Get("X-Correlation-ID"); correlationID != "" {
-				ctx = logger.WithCorrelationID(ctx, correlationID)
-			} else {
-				ctx = logger.WithCorrelationID(ctx, logger.GenerateCorrelationID())
+			correlationID, rejected := correlationIDFromHeader(r)
+			if rejected {
+				log.Printf("Replacing invalid X-Correlation-ID of %d bytes for %s %s", len(r.Header.Get("X-Correlation-ID")), r.Method, r.URL.Path)
+			} else if correlationID != "" && m.signingKey != nil && !logger.VerifyCorrelationID(correlationID, m.signingKey) {
+				log.Printf("Replacing unsigned X-Correlation-ID for %s %s", r.Method, r.URL.Path)
+				correlationID, rejected = "", true
 			}
+			if correlationID == "" {
+				correlationID = m.generateCorrelationID()
+			}
+			if rejected {
+				r.Header.Set("X-Correlation-ID", correlationID) // Upstreams get the replacement too
+			}
+			ctx = logger.WithCorrelationID(ctx, correlationID)
 		}
 
 		// Set headers
@@ -272,3 +464,44 @@ func (m *RequestIDMiddleware) Middleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// generateCorrelationID returns a new correlation ID, signed when a key is set
+func (m *RequestIDMiddleware) generateCorrelationID() string {
+	if m.signingKey != nil {
+		return logger.GenerateSignedCorrelationID(m.signingKey)
+	}
+	return logger.GenerateCorrelationID()
+}
+
+// maxCorrelationIDLength is the longest client correlation ID accepted
+const maxCorrelationIDLength = 128
+
+// correlationIDFromHeader returns the client's X-Correlation-ID once control characters
+// are stripped, provided it is at most maxCorrelationIDLength letters, digits, '-', '_',
+// '.' or ':'. It reports true when a value was sent but rejected, so the caller can
+// replace it; client values are never logged as is.
+func correlationIDFromHeader(r *http.Request) (string, bool) {
+	value := r.Header.Get("X-Correlation-ID")
+	if value == "" {
+		return "", false
+	}
+
+	value = strings.Map(func(c rune) rune {
+		if c < 0x20 || c == 0x7f {
+			return -1
+		}
+		return c
+	}, value)
+
+	if value == "" || len(value) > maxCorrelationIDLength {
+		return "", true
+	}
+	for _, c := range value {
+		valid := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == ':'
+		if !valid {
+			return "", true
+		}
+	}
+	return value, false
+}
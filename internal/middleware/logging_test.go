@@ -0,0 +1,498 @@
+package middleware
+
+import (
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/metrics"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureHook keeps the entries it is fired for
+type captureHook struct {
+	mu      sync.Mutex
+	entries []*logger.LogEntry
+}
+
+func (h *captureHook) Fire(entry *logger.LogEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+	return nil
+}
+func (h *captureHook) Levels() []logger.LogLevel { return nil }
+func (h *captureHook) Synchronous() bool         { return true }
+
+// find returns the first entry with the message, or nil
+func (h *captureHook) find(message string) *logger.LogEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, entry := range h.entries {
+		if entry.Message == message {
+			return entry
+		}
+	}
+	return nil
+}
+
+// newCapturingLogger returns a logger whose entries are kept by the returned hook
+func newCapturingLogger(t *testing.T) (*logger.Logger, *captureHook) {
+	t.Helper()
+	l := logger.NewLogger(logger.Config{Level: "debug", Format: "json"})
+	t.Cleanup(l.Close)
+	hook := &captureHook{}
+	l.AddHook(hook)
+	return l, hook
+}
+
+func TestLoggingMiddlewareHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      LoggingMiddlewareConfig
+		wantHeaders map[string]string // nil means headers must not be logged
+	}{
+		{
+			name: "custom sensitive header is redacted",
+			config: LoggingMiddlewareConfig{
+				LogRequests:      true,
+				LogHeaders:       true,
+				SensitiveHeaders: []string{" X-Tenant-Id "},
+			},
+			wantHeaders: map[string]string{"X-Tenant-Id": "[REDACTED]", "Accept": "application/json"},
+		},
+		{
+			name: "defaults no longer apply once configured",
+			config: LoggingMiddlewareConfig{
+				LogRequests:      true,
+				LogHeaders:       true,
+				SensitiveHeaders: []string{"x-tenant-id"},
+			},
+			wantHeaders: map[string]string{"X-Api-Version": "2"},
+		},
+		{
+			name: "LogHeaders false omits headers",
+			config: LoggingMiddlewareConfig{
+				LogRequests:      true,
+				LogHeaders:       false,
+				SensitiveHeaders: []string{"x-tenant-id"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, hook := newCapturingLogger(t)
+			m := NewStructuredLoggingMiddlewareWithConfig(l, tt.config)
+			handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("X-Tenant-Id", "tenant-42")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("X-Api-Version", "2")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entry := hook.find("Request started")
+			if entry == nil {
+				t.Fatal("no request started entry")
+			}
+			headers, logged := entry.Fields["headers"].(map[string]string)
+			if tt.wantHeaders == nil {
+				if _, present := entry.Fields["headers"]; present {
+					t.Errorf("headers logged with LogHeaders false: %v", entry.Fields["headers"])
+				}
+				return
+			}
+			if !logged {
+				t.Fatalf("headers = %#v, want a map", entry.Fields["headers"])
+			}
+			for name, want := range tt.wantHeaders {
+				if got := headers[name]; got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareSlowRequestThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		wantWarn  bool
+	}{
+		{name: "slower than the threshold", threshold: time.Millisecond, wantWarn: true},
+		{name: "faster than the threshold", threshold: time.Hour},
+		{name: "disabled", threshold: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, hook := newCapturingLogger(t)
+			m := NewStructuredLoggingMiddlewareWithConfig(l, LoggingMiddlewareConfig{SlowRequestThreshold: tt.threshold})
+			handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(5 * time.Millisecond)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			if got := hook.find("Slow request detected") != nil; got != tt.wantWarn {
+				t.Errorf("slow request warning = %v, want %v", got, tt.wantWarn)
+			}
+		})
+	}
+}
+
+// metricCall is one call a fakeRecorder received
+type metricCall struct {
+	kind   string
+	name   string
+	labels metrics.Labels
+	value  float64
+}
+
+// fakeRecorder is a metrics.Recorder that keeps every call
+type fakeRecorder struct {
+	mu    sync.Mutex
+	calls []metricCall
+}
+
+func (f *fakeRecorder) record(call metricCall) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeRecorder) IncCounter(name string, labels metrics.Labels) {
+	f.record(metricCall{kind: "counter", name: name, labels: labels, value: 1})
+}
+func (f *fakeRecorder) SetGauge(name string, labels metrics.Labels, value float64) {
+	f.record(metricCall{kind: "gauge", name: name, labels: labels, value: value})
+}
+func (f *fakeRecorder) ObserveHistogram(name string, labels metrics.Labels, value float64) {
+	f.record(metricCall{kind: "histogram", name: name, labels: labels, value: value})
+}
+
+func TestLoggingMiddlewareRecordsMetrics(t *testing.T) {
+	recorder := &fakeRecorder{}
+	l := logger.NewLogger(logger.Config{Level: "fatal"})
+	defer l.Close()
+	m := NewStructuredLoggingMiddlewareWithConfig(l, LoggingMiddlewareConfig{Metrics: recorder})
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/orders"},
+		{method: http.MethodGet, path: "/orders"},
+		{method: http.MethodPost, path: "/orders"},
+		{method: http.MethodGet, path: "/missing"},
+	}
+	for _, req := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	counters := make(map[string]int)
+	durations := make(map[string]int)
+	for _, call := range recorder.calls {
+		switch {
+		case call.kind == "counter" && call.name == "gateway_http_requests_total":
+			counters[call.labels["method"]+" "+call.labels["status"]]++
+		case call.kind == "histogram" && call.name == "gateway_http_request_duration_seconds":
+			durations[call.labels["method"]]++
+			if call.value < 0 {
+				t.Errorf("negative duration %v", call.value)
+			}
+		default:
+			t.Errorf("unexpected metric call %+v", call)
+		}
+	}
+
+	wantCounters := map[string]int{"GET 200": 2, "POST 200": 1, "GET 404": 1}
+	for key, want := range wantCounters {
+		if counters[key] != want {
+			t.Errorf("requests_total{%s} = %d, want %d", key, counters[key], want)
+		}
+	}
+	if durations["GET"] != 3 || durations["POST"] != 1 {
+		t.Errorf("duration observations = %v, want 3 GET and 1 POST", durations)
+	}
+}
+
+func TestLoggingMiddlewareCapsLoggedHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxHeaders  int
+		wantLogged  int
+		wantOmitted string
+	}{
+		{name: "capped", maxHeaders: 3, wantLogged: 3, wantOmitted: "7"},
+		{name: "under the cap", maxHeaders: 50, wantLogged: 10},
+		{name: "no cap", maxHeaders: 0, wantLogged: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, hook := newCapturingLogger(t)
+			m := NewStructuredLoggingMiddlewareWithConfig(l, LoggingMiddlewareConfig{
+				LogRequests:      true,
+				LogHeaders:       true,
+				MaxLoggedHeaders: tt.maxHeaders,
+			})
+			handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			for i := 0; i < 9; i++ {
+				req.Header.Set(fmt.Sprintf("X-Header-%d", i), "v")
+			}
+			req.Header.Set("X-Large", strings.Repeat("x", 2*maxLoggedHeaderValue))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entry := hook.find("Request started")
+			if entry == nil {
+				t.Fatal("no request started entry")
+			}
+			headers, _ := entry.Fields["headers"].(map[string]string)
+			omitted := headers["[omitted]"]
+			delete(headers, "[omitted]")
+			if len(headers) != tt.wantLogged || omitted != tt.wantOmitted {
+				t.Errorf("logged %d headers with %q omitted, want %d with %q", len(headers), omitted, tt.wantLogged, tt.wantOmitted)
+			}
+			if large, logged := headers["X-Large"]; logged && len(large) != maxLoggedHeaderValue+len("...") {
+				t.Errorf("X-Large logged with %d bytes, want it truncated to %d", len(large), maxLoggedHeaderValue)
+			}
+		})
+	}
+}
+
+func TestCorrelationIDValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		want         string // Empty means a fresh ID replaces the value
+		wantReplaced bool
+	}{
+		{name: "valid ID passes through", value: "checkout-7f3a:v2.1_b", want: "checkout-7f3a:v2.1_b"},
+		{name: "at the length limit", value: strings.Repeat("a", maxCorrelationIDLength), want: strings.Repeat("a", maxCorrelationIDLength)},
+		{name: "oversized value replaced", value: strings.Repeat("a", maxCorrelationIDLength+1), wantReplaced: true},
+		{name: "header injection replaced", value: "checkout\r\nX-Admin: true", wantReplaced: true},
+		{name: "control characters stripped", value: "check\x00out\t-7f3a", want: "checkout-7f3a"},
+		{name: "only control characters replaced", value: "\n\r", wantReplaced: true},
+		{name: "markup replaced", value: "bad<script>", wantReplaced: true},
+	}
+
+	middlewares := map[string]func(l *logger.Logger) func(http.Handler) http.Handler{
+		"structured logging": func(l *logger.Logger) func(http.Handler) http.Handler {
+			return NewStructuredLoggingMiddleware(l).Middleware
+		},
+		"request ID": func(l *logger.Logger) func(http.Handler) http.Handler {
+			return NewRequestIDMiddleware().Middleware
+		},
+	}
+
+	for middlewareName, newMiddleware := range middlewares {
+		for _, tt := range tests {
+			t.Run(middlewareName+"/"+tt.name, func(t *testing.T) {
+				l, hook := newCapturingLogger(t)
+				var inContext, forwarded string
+				handler := newMiddleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					inContext = logger.GetCorrelationID(r.Context())
+					forwarded = r.Header.Get("X-Correlation-ID")
+				}))
+
+				req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+				req.Header.Set("X-Correlation-ID", tt.value)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				got := rec.Header().Get("X-Correlation-ID")
+				if got != inContext {
+					t.Errorf("response ID %q differs from the request context's %q", got, inContext)
+				}
+				if tt.want != "" && got != tt.want {
+					t.Errorf("correlation ID = %q, want %q", got, tt.want)
+				}
+				if tt.wantReplaced {
+					if got == "" || got == tt.value || strings.ContainsAny(got, "\r\n<") {
+						t.Errorf("correlation ID = %q, want a fresh one", got)
+					}
+					if forwarded != got {
+						t.Errorf("upstream header = %q, want the replacement %q", forwarded, got)
+					}
+				}
+				if middlewareName == "structured logging" {
+					entry := hook.find("Replaced invalid X-Correlation-ID")
+					if (entry != nil) != tt.wantReplaced {
+						t.Errorf("replacement logged = %v, want %v", entry != nil, tt.wantReplaced)
+					}
+					for _, logged := range hook.entries {
+						if tt.wantReplaced && logged.CorrelationID == tt.value {
+							t.Errorf("entry %q logged the rejected value", logged.Message)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSignedCorrelationIDs(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	peerSigned := logger.GenerateSignedCorrelationID(key)
+
+	tests := []struct {
+		name         string
+		value        string
+		wantTrusted  bool // The inbound value is kept
+		wantReplaced bool // The upstream header is rewritten
+	}{
+		{name: "signed by a peer gateway", value: peerSigned, wantTrusted: true},
+		{name: "no inbound ID"},
+		{name: "unsigned", value: "checkout-7f3a", wantReplaced: true},
+		{name: "signed with another key", value: logger.GenerateSignedCorrelationID([]byte("another-key-another-key-another!!")), wantReplaced: true},
+		{name: "invalid", value: "bad<script>", wantReplaced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestID := NewRequestIDMiddleware()
+			requestID.SetSigningKey(key)
+			l := logger.NewLogger(logger.Config{Level: "fatal"})
+			var inContext, forwarded string
+			handler := requestID.Middleware(NewStructuredLoggingMiddleware(l).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = logger.GetCorrelationID(r.Context())
+				forwarded = r.Header.Get("X-Correlation-ID")
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.value != "" {
+				req.Header.Set("X-Correlation-ID", tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("X-Correlation-ID")
+			if got != inContext {
+				t.Errorf("response ID %q differs from the request context's %q", got, inContext)
+			}
+			if tt.wantTrusted && got != tt.value {
+				t.Errorf("correlation ID = %q, want the peer's %q", got, tt.value)
+			}
+			if !tt.wantTrusted && got == tt.value {
+				t.Errorf("correlation ID %q was trusted", got)
+			}
+			if !logger.VerifyCorrelationID(got, key) {
+				t.Errorf("correlation ID %q is not signed with the key", got)
+			}
+			if tt.wantReplaced && forwarded != got {
+				t.Errorf("upstream header = %q, want the replacement %q", forwarded, got)
+			}
+		})
+	}
+}
+
+// panickingHandler is named so its frame can be found in the recovered stack
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Length", "7")
+	panic("nil order")
+}
+
+func TestPanicRecoveryLogsPanicSite(t *testing.T) {
+	l, hook := newCapturingLogger(t)
+	handler := NewPanicRecoveryMiddleware(l).Middleware(http.HandlerFunc(panickingHandler))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req = req.WithContext(logger.WithRequestID(req.Context(), "req-42"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length set by the handler was kept: %q", got)
+	}
+	var body PanicResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q is not JSON: %v", rec.Body.String(), err)
+	}
+	if body != (PanicResponse{Error: "internal server error", RequestID: "req-42"}) {
+		t.Errorf("body = %+v", body)
+	}
+
+	entry := hook.find("Panic recovered")
+	if entry == nil {
+		t.Fatal("panic not logged")
+	}
+	if !strings.Contains(entry.Error, "nil order") {
+		t.Errorf("error = %q, want the panic value", entry.Error)
+	}
+	if !strings.Contains(entry.StackTrace, "middleware.panickingHandler") {
+		t.Errorf("stack trace does not contain the panicking function:\n%s", entry.StackTrace)
+	}
+	if _, exists := entry.Fields["goroutines"]; exists {
+		t.Error("goroutines dumped without a threshold")
+	}
+}
+
+func TestPanicRecoveryRepanicsAbortHandler(t *testing.T) {
+	handler := NewPanicRecoveryMiddleware(logger.NewLogger(logger.Config{Level: "fatal"})).Middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }))
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", recovered)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+}
+
+func TestPanicGoroutineDump(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name      string
+		threshold int
+		panics    []time.Duration // When each panic happens, relative to the first
+		want      []bool
+	}{
+		{name: "disabled", panics: []time.Duration{0, time.Second, 2 * time.Second}, want: []bool{false, false, false}},
+		{name: "dumped once the threshold is reached", threshold: 3, panics: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second}, want: []bool{false, false, true, false}},
+		{name: "panics spread over windows", threshold: 2, panics: []time.Duration{0, time.Minute, 2 * time.Minute}, want: []bool{false, false, false}},
+		{name: "dumped again in a later window", threshold: 2, panics: []time.Duration{0, time.Second, time.Minute, time.Minute + time.Second}, want: []bool{false, true, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewPanicRecoveryMiddleware(logger.NewLogger(logger.Config{Level: "fatal"}))
+			m.SetGoroutineDumpThreshold(tt.threshold)
+			for i, offset := range tt.panics {
+				if got := m.shouldDumpGoroutines(start.Add(offset)); got != tt.want[i] {
+					t.Errorf("panic %d: dump = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	l, hook := newCapturingLogger(t)
+	m := NewPanicRecoveryMiddleware(l)
+	m.SetGoroutineDumpThreshold(1)
+	m.Middleware(http.HandlerFunc(panickingHandler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	entry := hook.find("Panic recovered")
+	if entry == nil {
+		t.Fatal("panic not logged")
+	}
+	if dump, _ := entry.Fields["goroutines"].(string); !strings.Contains(dump, "goroutine ") {
+		t.Errorf("goroutines = %q, want a stack dump", dump)
+	}
+}
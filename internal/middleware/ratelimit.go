@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/clock"
+	"api-gateway/pkg/jwt"
 	"log"
-	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,20 +19,45 @@ type RateLimiter struct {
 	mu              sync.Mutex
 	limit           rate.Limit
 	burst           int
-	cleanupInterval time.Duration
+	cleanupInterval time.Duration // How often stale clients are swept
+	clientTTL       time.Duration // How long a client may stay idle before it is evicted
+	clock           clock.Clock
+
+	// Authenticated callers get their own bucket and quota when identify is set
+	identify  func(r *http.Request) string
+	userLimit rate.Limit
+	userBurst int
+
+	// Requests to these paths or from these networks are never limited
+	exemptPaths map[string]bool
+	exemptCIDRs gatewayproxy.CIDRList
 }
 
 type client struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+	limiter       *rate.Limiter
+	lastSeen      time.Time
+	authenticated bool
+}
+
+// NewRateLimiter creates a rate limiter that sweeps every cleanupInterval and evicts
+// clients idle longer than clientTTL; a non-positive TTL falls back to cleanupInterval
+func NewRateLimiter(limit rate.Limit, burst int, cleanupInterval, clientTTL time.Duration) *RateLimiter {
+	return NewRateLimiterWithClock(limit, burst, cleanupInterval, clientTTL, clock.Real{})
 }
 
-func NewRateLimiter(limit rate.Limit, burst int, cleanupInterval time.Duration) *RateLimiter {
+// NewRateLimiterWithClock creates a rate limiter driven by the given clock
+func NewRateLimiterWithClock(limit rate.Limit, burst int, cleanupInterval, clientTTL time.Duration, clk clock.Clock) *RateLimiter {
+	if clientTTL <= 0 {
+		clientTTL = cleanupInterval
+	}
+
 	rl := &RateLimiter{
 		clients:         make(map[string]*client),
 		limit:           limit,
 		burst:           burst,
 		cleanupInterval: cleanupInterval,
+		clientTTL:       clientTTL,
+		clock:           clk,
 	}
 
 	// Start cleanup goroutine
@@ -37,39 +66,118 @@ func NewRateLimiter(limit rate.Limit, burst int, cleanupInterval time.Duration)
 	return rl
 }
 
+// SetLimit changes the rate and burst for new and already-tracked anonymous clients
+func (rl *RateLimiter) SetLimit(limit rate.Limit, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.limit = limit
+	rl.burst = burst
+	now := rl.clock.Now()
+	for _, c := range rl.clients {
+		if !c.authenticated {
+			c.limiter.SetLimitAt(now, limit)
+			c.limiter.SetBurstAt(now, burst)
+		}
+	}
+}
+
+// EnableUserLimits keys authenticated requests by the user identify returns, with
+// their own limit and burst; requests identify can't attribute stay keyed by IP
+func (rl *RateLimiter) EnableUserLimits(identify func(r *http.Request) string, limit rate.Limit, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.identify = identify
+	rl.userLimit = limit
+	rl.userBurst = burst
+}
+
+// SetExemptions lets requests to the exact paths, or from client IPs in the CIDRs,
+// bypass rate limiting. Call it before the server starts.
+func (rl *RateLimiter) SetExemptions(paths, cidrs []string) error {
+	var exemptCIDRs gatewayproxy.CIDRList
+	if len(cidrs) > 0 {
+		var err error
+		if exemptCIDRs, err = gatewayproxy.ParseCIDRList(strings.Join(cidrs, ",")); err != nil {
+			return err
+		}
+	}
+
+	exemptPaths := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		exemptPaths[path] = true
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.exemptPaths = exemptPaths
+	rl.exemptCIDRs = exemptCIDRs
+	return nil
+}
+
+// exempt reports whether a request bypasses rate limiting
+func (rl *RateLimiter) exempt(r *http.Request) bool {
+	rl.mu.Lock()
+	exemptPaths, exemptCIDRs := rl.exemptPaths, rl.exemptCIDRs
+	rl.mu.Unlock()
+
+	if exemptPaths[r.URL.Path] {
+		return true
+	}
+	if len(exemptCIDRs) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ClientIP(r))
+	return err == nil && exemptCIDRs.Contains(addr)
+}
+
 func (rl *RateLimiter) cleanup() {
-	for {
-		time.Sleep(rl.cleanupInterval)
-		rl.mu.Lock()
-		for ip, c := range rl.clients {
-			if time.Since(c.lastSeen) > rl.cleanupInterval {
-				delete(rl.clients, ip)
-				log.Printf("RateLimiter: Cleaned up limiter for IP: %s", ip)
-			}
+	ticker := rl.clock.NewTicker(rl.cleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		rl.sweep()
+	}
+}
+
+// sweep drops the limiters of clients idle longer than clientTTL
+func (rl *RateLimiter) sweep() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for key, c := range rl.clients {
+		if rl.clock.Since(c.lastSeen) > rl.clientTTL {
+			delete(rl.clients, key)
+			log.Printf("RateLimiter: Cleaned up limiter for %s", key)
 		}
-		rl.mu.Unlock()
 	}
 }
 
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			log.Printf("RateLimiter: Could not parse remote address %s: %v", r.RemoteAddr, err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if rl.exempt(r) {
+			next.ServeHTTP(w, r)
 			return
 		}
 
+		key, authenticated := rl.clientKey(r)
+
 		rl.mu.Lock()
-		if _, ok := rl.clients[ip]; !ok {
-			rl.clients[ip] = &client{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		if _, ok := rl.clients[key]; !ok {
+			limit, burst := rl.limit, rl.burst
+			if authenticated {
+				limit, burst = rl.userLimit, rl.userBurst
+			}
+			rl.clients[key] = &client{limiter: rate.NewLimiter(limit, burst), authenticated: authenticated}
 		}
-		rl.clients[ip].lastSeen = time.Now()
-		limiter := rl.clients[ip].limiter
+		now := rl.clock.Now()
+		rl.clients[key].lastSeen = now
+		limiter := rl.clients[key].limiter
 		rl.mu.Unlock()
 
-		if !limiter.Allow() {
-			log.Printf("RateLimiter: Request from IP %s is rate limited for %s %s", ip, r.Method, r.URL.Path)
+		if !limiter.AllowN(now, 1) {
+			log.Printf("RateLimiter: Request from %s is rate limited for %s %s", key, r.Method, r.URL.Path)
 			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 			return
 		}
@@ -77,3 +185,34 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// clientKey returns the bucket key for a request: the user when user limits are
+// enabled and the caller is authenticated, the client IP otherwise
+func (rl *RateLimiter) clientKey(r *http.Request) (string, bool) {
+	rl.mu.Lock()
+	identify := rl.identify
+	rl.mu.Unlock()
+
+	if identify != nil {
+		if user := identify(r); user != "" {
+			return "user:" + user, true
+		}
+	}
+	return "ip:" + ClientIP(r), false
+}
+
+// JWTUserIdentifier returns an identify function for EnableUserLimits that reads
+// the username from a valid bearer token
+func JWTUserIdentifier(jwtService *jwt.Service) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == r.Header.Get("Authorization") {
+			return ""
+		}
+		username, err := jwtService.Username(tokenString)
+		if err != nil {
+			return ""
+		}
+		return username
+	}
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/pkg/clock"
+	"api-gateway/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimited sends one request from the client IP and reports whether it was limited
+func rateLimited(handler http.Handler, ip string) bool {
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.RemoteAddr = ip + ":40000"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code == http.StatusTooManyRequests
+}
+
+func TestRateLimiterClientTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		idle     time.Duration
+		wantKept bool
+	}{
+		{name: "idle for one cleanup interval", idle: 90 * time.Second, wantKept: true},
+		{name: "idle just under the TTL", idle: 5*time.Minute - time.Second, wantKept: true},
+		{name: "idle longer than the TTL", idle: 5*time.Minute + time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Unix(0, 0))
+			// A refill rate this slow keeps the spent burst from coming back during the test
+			rl := NewRateLimiterWithClock(rate.Every(time.Hour), 1, time.Minute, 5*time.Minute, fake)
+			handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			if rateLimited(handler, "10.0.0.1") {
+				t.Fatal("first request was limited")
+			}
+			fake.Advance(tt.idle)
+			rl.sweep()
+
+			rl.mu.Lock()
+			_, kept := rl.clients["ip:10.0.0.1"]
+			rl.mu.Unlock()
+			if kept != tt.wantKept {
+				t.Fatalf("limiter kept = %v, want %v", kept, tt.wantKept)
+			}
+			// A kept limiter still has its burst spent; an evicted client starts over
+			if got := rateLimited(handler, "10.0.0.1"); got != tt.wantKept {
+				t.Errorf("request after idling limited = %v, want %v", got, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestRateLimiterUserKeys(t *testing.T) {
+	jwtService := jwt.NewService(config.JWTConfig{Secret: "rate-test-secret", Expiration: time.Hour})
+	token := func(username string) string {
+		t.Helper()
+		signed, err := jwtService.CreateToken(username)
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		return "Bearer " + signed
+	}
+	alice, bob := token("alice"), token("bob")
+
+	tests := []struct {
+		name          string
+		authorization []string // One request each, all from the same IP
+		wantLimited   []bool
+	}{
+		{
+			name:          "two users behind one IP have their own buckets",
+			authorization: []string{alice, alice, bob, bob},
+			wantLimited:   []bool{false, false, false, false},
+		},
+		{
+			name:          "users get the higher user burst",
+			authorization: []string{alice, alice, alice},
+			wantLimited:   []bool{false, false, true},
+		},
+		{
+			name:          "anonymous callers share the IP bucket",
+			authorization: []string{"", ""},
+			wantLimited:   []bool{false, true},
+		},
+		{
+			name:          "an invalid token falls back to the IP",
+			authorization: []string{"Bearer forged", ""},
+			wantLimited:   []bool{false, true},
+		},
+		{
+			name:          "a user does not spend the IP bucket",
+			authorization: []string{alice, alice, ""},
+			wantLimited:   []bool{false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiterWithClock(rate.Every(time.Hour), 1, time.Minute, time.Minute, clock.NewFake(time.Unix(0, 0)))
+			rl.EnableUserLimits(JWTUserIdentifier(jwtService), rate.Every(time.Hour), 2)
+			handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			for i, authorization := range tt.authorization {
+				req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+				req.RemoteAddr = "203.0.113.7:40000"
+				if authorization != "" {
+					req.Header.Set("Authorization", authorization)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if limited := rec.Code == http.StatusTooManyRequests; limited != tt.wantLimited[i] {
+					t.Errorf("request %d limited = %v, want %v", i+1, limited, tt.wantLimited[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterExemptions(t *testing.T) {
+	type request struct {
+		path string
+		ip   string
+	}
+	tests := []struct {
+		name        string
+		requests    []request
+		wantLimited []bool
+	}{
+		{
+			name:        "health probes are never limited",
+			requests:    []request{{"/health", "203.0.113.7"}, {"/health", "203.0.113.7"}, {"/health", "203.0.113.7"}, {"/ready", "203.0.113.7"}},
+			wantLimited: []bool{false, false, false, false},
+		},
+		{
+			name:        "a normal route still throttles",
+			requests:    []request{{"/orders", "203.0.113.7"}, {"/orders", "203.0.113.7"}},
+			wantLimited: []bool{false, true},
+		},
+		{
+			name:        "probes do not spend the client's bucket",
+			requests:    []request{{"/metrics", "203.0.113.7"}, {"/metrics", "203.0.113.7"}, {"/orders", "203.0.113.7"}},
+			wantLimited: []bool{false, false, false},
+		},
+		{
+			name:        "paths match exactly",
+			requests:    []request{{"/health/", "203.0.113.7"}, {"/healthz", "203.0.113.7"}},
+			wantLimited: []bool{false, true},
+		},
+		{
+			name:        "allowlisted network",
+			requests:    []request{{"/orders", "10.1.2.3"}, {"/orders", "10.1.2.3"}, {"/orders", "10.1.2.3"}},
+			wantLimited: []bool{false, false, false},
+		},
+		{
+			name:        "allowlisted IP",
+			requests:    []request{{"/orders", "198.51.100.9"}, {"/orders", "198.51.100.9"}},
+			wantLimited: []bool{false, false},
+		},
+		{
+			name:        "outside the allowlist",
+			requests:    []request{{"/orders", "10.2.0.1"}, {"/orders", "10.2.0.1"}},
+			wantLimited: []bool{false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiterWithClock(rate.Every(time.Hour), 1, time.Minute, time.Minute, clock.NewFake(time.Unix(0, 0)))
+			if err := rl.SetExemptions([]string{"/health", "/ready", "/metrics"}, []string{"10.1.0.0/16", "198.51.100.9"}); err != nil {
+				t.Fatalf("SetExemptions: %v", err)
+			}
+			handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			for i, request := range tt.requests {
+				req := httptest.NewRequest(http.MethodGet, request.path, nil)
+				req.RemoteAddr = request.ip + ":40000"
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if limited := rec.Code == http.StatusTooManyRequests; limited != tt.wantLimited[i] {
+					t.Errorf("request %d (%s from %s) limited = %v, want %v", i+1, request.path, request.ip, limited, tt.wantLimited[i])
+				}
+			}
+		})
+	}
+
+	if err := NewRateLimiterWithClock(rate.Every(time.Hour), 1, time.Minute, time.Minute, clock.NewFake(time.Unix(0, 0))).SetExemptions(nil, []string{"10.1.0.0/33"}); err == nil {
+		t.Error("SetExemptions accepted an invalid CIDR")
+	}
+}
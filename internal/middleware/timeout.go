@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutMiddleware bounds the total time a request may spend in the gateway,
+// retries and mirrors included. The request context is cancelled at the deadline,
+// which aborts in-flight upstream calls, and a 504 is sent if no response has started.
+type TimeoutMiddleware struct {
+	timeout      time.Duration
+	routeTimeout func(r *http.Request) (time.Duration, bool)
+}
+
+// GatewayTimeoutResponse is the body sent when a request exceeds its deadline
+type GatewayTimeoutResponse struct {
+	Error   string `json:"error"`
+	Timeout string `json:"timeout"`
+}
+
+// NewTimeoutMiddleware creates a timeout middleware; a timeout <= 0 disables it
+func NewTimeoutMiddleware(timeout time.Duration) *TimeoutMiddleware {
+	return &TimeoutMiddleware{timeout: timeout}
+}
+
+// SetRouteTimeouts installs a per-request override of the global timeout. The lookup
+// returns false to keep the global value and a duration <= 0 to disable the deadline.
+// Call it before the server starts.
+func (tm *TimeoutMiddleware) SetRouteTimeouts(lookup func(r *http.Request) (time.Duration, bool)) {
+	tm.routeTimeout = lookup
+}
+
+// Middleware returns the HTTP middleware function for request timeouts
+func (tm *TimeoutMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := tm.timeout
+		if tm.routeTimeout != nil {
+			if override, ok := tm.routeTimeout(r); ok {
+				timeout = override
+			}
+		}
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		// Headers set before the handler ran belong to the gateway and survive a 504
+		gatewayHeaders := w.Header().Clone()
+		tw := &timeoutWriter{ResponseWriter: w}
+		stop := context.AfterFunc(ctx, func() {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				tw.expire()
+			}
+		})
+		defer stop()
+
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		// A handler that returns as soon as its context is done can beat the AfterFunc
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			tw.expire()
+		}
+		if tw.expired() {
+			log.Printf("TimeoutMiddleware: %s %s exceeded its %s deadline", r.Method, r.URL.Path, timeout)
+			writeGatewayTimeout(w, gatewayHeaders, timeout)
+		}
+	})
+}
+
+// writeGatewayTimeout writes the 504 response for a request that ran out of time.
+// The handler's headers, such as an upstream's Content-Encoding, don't describe
+// this body, so only the headers set before the handler ran are kept.
+func writeGatewayTimeout(w http.ResponseWriter, gatewayHeaders http.Header, timeout time.Duration) {
+	header := w.Header()
+	clear(header)
+	for name, values := range gatewayHeaders {
+		header[name] = values
+	}
+	header.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+
+	json.NewEncoder(w).Encode(GatewayTimeoutResponse{
+		Error:   "gateway timeout",
+		Timeout: timeout.String(),
+	})
+}
+
+// timeoutWriter drops the handler's output once the deadline passes before a
+// response started, leaving the 504 to the middleware. Responses already under
+// way are left alone; the cancelled context ends them.
+type timeoutWriter struct {
+	http.ResponseWriter
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so streamed responses keep flowing
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		tw.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// expire marks the request as timed out unless its response already started
+func (tw *timeoutWriter) expire() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.wroteHeader {
+		tw.timedOut = true
+	}
+}
+
+func (tw *timeoutWriter) expired() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.timedOut
+}
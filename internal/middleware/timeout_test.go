@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	// slow waits for the request to be cancelled or for its delay, whichever is first
+	slow := func(delay time.Duration, started bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if started {
+				w.WriteHeader(http.StatusOK)
+				io.WriteString(w, "partial")
+			}
+			select {
+			case <-r.Context().Done():
+			case <-time.After(delay):
+				io.WriteString(w, "done")
+			}
+		}
+	}
+
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		routeTimeout time.Duration
+		override     bool
+		handler      http.HandlerFunc
+		wantStatus   int
+		wantBody     string
+		wantTimeout  string // Timeout reported in the JSON body of a 504
+	}{
+		{name: "fast handler", timeout: time.Second, handler: slow(0, false), wantStatus: http.StatusOK, wantBody: "done"},
+		{name: "handler overruns the global deadline", timeout: 20 * time.Millisecond, handler: slow(time.Second, false), wantStatus: http.StatusGatewayTimeout, wantTimeout: "20ms"},
+		{name: "route override is shorter", timeout: time.Second, routeTimeout: 20 * time.Millisecond, override: true, handler: slow(time.Second, false), wantStatus: http.StatusGatewayTimeout, wantTimeout: "20ms"},
+		{name: "route override disables the deadline", timeout: 20 * time.Millisecond, override: true, handler: slow(50*time.Millisecond, false), wantStatus: http.StatusOK, wantBody: "done"},
+		{name: "global timeout disabled", handler: slow(50*time.Millisecond, false), wantStatus: http.StatusOK, wantBody: "done"},
+		{name: "response already started is kept", timeout: 20 * time.Millisecond, handler: slow(time.Second, true), wantStatus: http.StatusOK, wantBody: "partial"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := NewTimeoutMiddleware(tt.timeout)
+			if tt.override {
+				tm.SetRouteTimeouts(func(r *http.Request) (time.Duration, bool) { return tt.routeTimeout, true })
+			}
+			cancelled := make(chan bool, 1)
+			handler := tm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(w, r)
+				cancelled <- r.Context().Err() != nil
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusGatewayTimeout {
+				if !<-cancelled {
+					t.Error("handler's context was not cancelled at the deadline")
+				}
+				var body GatewayTimeoutResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("504 body is not JSON: %v", err)
+				}
+				if body.Timeout != tt.wantTimeout {
+					t.Errorf("timeout = %q, want %q", body.Timeout, tt.wantTimeout)
+				}
+				return
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGatewayTimeoutDropsHandlerHeaders(t *testing.T) {
+	tm := NewTimeoutMiddleware(20 * time.Millisecond)
+	handler := tm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers copied from an upstream response that never got written
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Length", "512")
+		<-r.Context().Done()
+	}))
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Request-ID", "req-1") // Set by an outer middleware
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"Content-Encoding": "",
+		"Content-Length":   "",
+		"Content-Type":     "application/json",
+		"X-Request-ID":     "req-1",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
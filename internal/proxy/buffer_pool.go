@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"net/http/httputil"
+	"sync"
+)
+
+// BufferPool hands reverse proxies fixed-size buffers for copying response bodies,
+// so large payloads don't allocate a fresh buffer per request
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+var _ httputil.BufferPool = (*BufferPool)(nil)
+
+// NewBufferPool creates a pool of buffers of the given size in bytes
+func NewBufferPool(size int) *BufferPool {
+	bp := &BufferPool{size: size}
+	bp.pool.New = func() interface{} {
+		return make([]byte, size)
+	}
+	return bp
+}
+
+// Get returns a buffer from the pool
+func (bp *BufferPool) Get() []byte {
+	return bp.pool.Get().([]byte)
+}
+
+// Put returns a buffer to the pool; buffers of another size are dropped
+func (bp *BufferPool) Put(buf []byte) {
+	if cap(buf) != bp.size {
+		return
+	}
+	bp.pool.Put(buf[:bp.size])
+}
@@ -0,0 +1,23 @@
+package proxy
+
+import "testing"
+
+func TestBufferPool(t *testing.T) {
+	bp := NewBufferPool(4096)
+
+	buf := bp.Get()
+	if len(buf) != 4096 {
+		t.Fatalf("buffer length = %d, want 4096", len(buf))
+	}
+	bp.Put(buf[:10])
+	if again := bp.Get(); len(again) != 4096 {
+		t.Errorf("buffer returned resliced comes back with length %d, want 4096", len(again))
+	}
+
+	bp.Put(make([]byte, 512))
+	for i := 0; i < 4; i++ {
+		if got := bp.Get(); len(got) != 4096 {
+			t.Errorf("pool handed out a %d-byte buffer, want 4096", len(got))
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// CIDRList is a set of client networks used for per-service access control
+type CIDRList []netip.Prefix
+
+// ParseCIDRList parses a comma-separated list such as "10.0.0.0/8, 192.168.1.10";
+// a bare IP matches that single address
+func ParseCIDRList(value string) (CIDRList, error) {
+	var list CIDRList
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP or CIDR %q", part)
+			}
+			addr = addr.Unmap()
+			list = append(list, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", part)
+		}
+		list = append(list, prefix.Masked())
+	}
+
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no IPs or CIDRs in %q", value)
+	}
+	return list, nil
+}
+
+// Contains reports whether addr falls in any network of the list
+func (l CIDRList) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestCIDRListContains(t *testing.T) {
+	list, err := ParseCIDRList("10.0.0.0/8, 192.168.1.10, fd00::/64")
+	if err != nil {
+		t.Fatalf("ParseCIDRList: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "10.20.30.40", want: true},
+		{ip: "192.168.1.10", want: true},
+		{ip: "192.168.1.11"},
+		{ip: "::ffff:10.0.0.1", want: true}, // IPv4-mapped clients match IPv4 networks
+		{ip: "fd00::1", want: true},
+		{ip: "fd00:0:0:1::1"},
+	}
+
+	for _, tt := range tests {
+		if got := list.Contains(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"", " , ", "10.0.0.0/33", "not-an-ip", "10.0.0.1, bad"} {
+		if _, err := ParseCIDRList(invalid); err == nil {
+			t.Errorf("ParseCIDRList(%q) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ConnectionStats describes the upstream connections to one endpoint
+type ConnectionStats struct {
+	Service string `json:"service"`
+	Open    int64  `json:"open"`   // Connections currently dialed
+	Active  int64  `json:"active"` // Requests currently in flight
+	Idle    int64  `json:"idle"`   // Open connections not serving a request
+}
+
+// ConnectionTracker counts the connections a transport opens per endpoint
+// (host:port) and the requests in flight on them. Multiplexed HTTP/2
+// connections can carry several requests, so idle is clamped at zero.
+type ConnectionTracker struct {
+	endpoints map[string]*endpointConnections
+	mu        sync.Mutex
+}
+
+type endpointConnections struct {
+	service string
+	open    int64
+	active  int64
+}
+
+// NewConnectionTracker creates an empty connection tracker
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{
+		endpoints: make(map[string]*endpointConnections),
+	}
+}
+
+// Instrument wraps the transport's dialer so every connection it opens is counted
+// until closed. Transports cloned afterwards share the instrumented dialer.
+func (t *ConnectionTracker) Instrument(transport *http.Transport) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		t.add(addr, func(e *endpointConnections) { e.open++ })
+		return &trackedConn{Conn: conn, onClose: func() {
+			t.add(addr, func(e *endpointConnections) { e.open-- })
+		}}, nil
+	}
+}
+
+// Begin marks a request to the service's endpoint as in flight; call the returned func when it completes
+func (t *ConnectionTracker) Begin(service, addr string) func() {
+	t.add(addr, func(e *endpointConnections) {
+		e.service = service
+		e.active++
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.add(addr, func(e *endpointConnections) { e.active-- })
+		})
+	}
+}
+
+// add applies update to an endpoint's counts, dropping the entry once it is unused
+func (t *ConnectionTracker) add(addr string, update func(e *endpointConnections)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, exists := t.endpoints[addr]
+	if !exists {
+		e = &endpointConnections{}
+		t.endpoints[addr] = e
+	}
+	update(e)
+	if e.open <= 0 && e.active <= 0 {
+		delete(t.endpoints, addr)
+	}
+}
+
+// Stats returns the current counts keyed by endpoint address
+func (t *ConnectionTracker) Stats() map[string]ConnectionStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]ConnectionStats, len(t.endpoints))
+	for addr, e := range t.endpoints {
+		idle := e.open - e.active
+		if idle < 0 {
+			idle = 0
+		}
+		stats[addr] = ConnectionStats{
+			Service: e.service,
+			Open:    e.open,
+			Active:  e.active,
+			Idle:    idle,
+		}
+	}
+	return stats
+}
+
+// WriteMetrics writes per-endpoint connection gauges in the Prometheus text format
+func (t *ConnectionTracker) WriteMetrics(w io.Writer) {
+	stats := t.Stats()
+
+	addrs := make([]string, 0, len(stats))
+	for addr := range stats {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	fmt.Fprintln(w, "# HELP gateway_upstream_connections Upstream connections per service endpoint by state")
+	fmt.Fprintln(w, "# TYPE gateway_upstream_connections gauge")
+	for _, addr := range addrs {
+		s := stats[addr]
+		fmt.Fprintf(w, "gateway_upstream_connections{service=%q,endpoint=%q,state=\"active\"} %d\n", s.Service, addr, s.Active)
+		fmt.Fprintf(w, "gateway_upstream_connections{service=%q,endpoint=%q,state=\"idle\"} %d\n", s.Service, addr, s.Idle)
+	}
+}
+
+// trackedConn reports its first Close back to the tracker
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
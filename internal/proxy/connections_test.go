@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConnectionTrackerCounts(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 8)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer backend.Close()
+	addr := strings.TrimPrefix(backend.URL, "http://")
+
+	tracker := NewConnectionTracker()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tracker.Instrument(transport)
+	client := &http.Client{Transport: transport}
+
+	tests := []struct {
+		name       string
+		concurrent int
+	}{
+		{name: "one request", concurrent: 1},
+		{name: "concurrent requests", concurrent: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < tt.concurrent; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					done := tracker.Begin("orders", addr)
+					defer done()
+					resp, err := client.Get(backend.URL)
+					if err != nil {
+						t.Errorf("GET: %v", err)
+						return
+					}
+					resp.Body.Close()
+				}()
+			}
+			for i := 0; i < tt.concurrent; i++ {
+				<-arrived
+			}
+
+			during := tracker.Stats()[addr]
+			if during.Service != "orders" || during.Active != int64(tt.concurrent) || during.Open < int64(tt.concurrent) {
+				t.Errorf("while in flight = %+v, want %d active on at least as many connections", during, tt.concurrent)
+			}
+
+			for i := 0; i < tt.concurrent; i++ {
+				release <- struct{}{}
+			}
+			wg.Wait()
+
+			after := tracker.Stats()[addr]
+			if after.Active != 0 || after.Idle != after.Open {
+				t.Errorf("after completion = %+v, want no active requests and every open connection idle", after)
+			}
+		})
+	}
+
+	transport.CloseIdleConnections()
+	backend.CloseClientConnections()
+	if stats := tracker.Stats(); len(stats) != 0 {
+		t.Errorf("stats after closing every connection = %+v, want none", stats)
+	}
+}
+
+func TestConnectionTrackerMetrics(t *testing.T) {
+	tracker := NewConnectionTracker()
+	done := tracker.Begin("orders", "10.0.0.1:8080")
+	tracker.Begin("orders", "10.0.0.1:8080")
+	done()
+	done() // Only the first call counts
+
+	var buf bytes.Buffer
+	tracker.WriteMetrics(&buf)
+	for _, line := range []string{
+		`gateway_upstream_connections{service="orders",endpoint="10.0.0.1:8080",state="active"} 1`,
+		`gateway_upstream_connections{service="orders",endpoint="10.0.0.1:8080",state="idle"} 0`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, buf.String())
+		}
+	}
+}
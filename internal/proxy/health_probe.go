@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// DefaultHealthyStatus treats any 2xx or 3xx response as healthy
+var DefaultHealthyStatus = StatusRange{Min: 200, Max: 399}
+
+// ParseStatusRange parses a single status ("204") or an inclusive range ("200-299")
+func ParseStatusRange(value string) (StatusRange, error) {
+	low, high, isRange := strings.Cut(strings.TrimSpace(value), "-")
+	if !isRange {
+		high = low
+	}
+
+	min, errMin := strconv.Atoi(strings.TrimSpace(low))
+	max, errMax := strconv.Atoi(strings.TrimSpace(high))
+	if errMin != nil || errMax != nil || min < 100 || max > 599 || min > max {
+		return StatusRange{}, fmt.Errorf("invalid status range %q, want a code or range such as 200-399", value)
+	}
+	return StatusRange{Min: min, Max: max}, nil
+}
+
+// Contains reports whether status falls in the range
+func (sr StatusRange) Contains(status int) bool {
+	return status >= sr.Min && status <= sr.Max
+}
+
+func (sr StatusRange) String() string {
+	if sr.Min == sr.Max {
+		return strconv.Itoa(sr.Min)
+	}
+	return fmt.Sprintf("%d-%d", sr.Min, sr.Max)
+}
+
+// ParseHealthCheckMethod validates a health check method; only GET and HEAD are allowed
+// since probes must not change backend state
+func ParseHealthCheckMethod(value string) (string, error) {
+	method := strings.ToUpper(strings.TrimSpace(value))
+	if method != http.MethodGet && method != http.MethodHead {
+		return "", fmt.Errorf("invalid health check method %q, want GET or HEAD", value)
+	}
+	return method, nil
+}
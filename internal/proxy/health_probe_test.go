@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    StatusRange
+		wantErr bool
+	}{
+		{value: "204", want: StatusRange{Min: 204, Max: 204}},
+		{value: "200-299", want: StatusRange{Min: 200, Max: 299}},
+		{value: " 200 - 399 ", want: StatusRange{Min: 200, Max: 399}},
+		{value: "", wantErr: true},
+		{value: "2xx", wantErr: true},
+		{value: "299-200", wantErr: true},
+		{value: "99", wantErr: true},
+		{value: "200-600", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStatusRange(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseStatusRange(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseStatusRange(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestStatusRangeContains(t *testing.T) {
+	noContent := StatusRange{Min: 204, Max: 204}
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{status: 204, want: true},
+		{status: 200},
+		{status: 205},
+	}
+
+	for _, tt := range tests {
+		if got := noContent.Contains(tt.status); got != tt.want {
+			t.Errorf("%v.Contains(%d) = %v, want %v", noContent, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestParseHealthCheckMethod(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "GET", want: http.MethodGet},
+		{value: " head ", want: http.MethodHead},
+		{value: "POST", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHealthCheckMethod(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseHealthCheckMethod(%q) = %q, %v, want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
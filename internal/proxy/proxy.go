@@ -1 +1,387 @@
 package proxy
+
+import (
+	"api-gateway/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Headers used to forward the client's TLS details to backends
+const (
+	HeaderForwardedFor        = "X-Forwarded-For"
+	HeaderForwardedHost       = "X-Forwarded-Host"
+	HeaderForwardedPort       = "X-Forwarded-Port"
+	HeaderForwardedProto      = "X-Forwarded-Proto"
+	HeaderForwardedClientCert = "X-Forwarded-Client-Cert"
+	HeaderForwardedTLSVersion = "X-Forwarded-TLS-Version"
+)
+
+// Upstream schemes a backend can be reached over
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+)
+
+// UpstreamTransportConfig controls how the gateway verifies HTTPS backends and pools connections
+type UpstreamTransportConfig struct {
+	InsecureSkipVerify bool   // Skip certificate verification; for testing only
+	CAFile             string // PEM bundle trusted in addition to the system roots
+
+	MaxIdleConns        int // Idle keep-alive connections kept across all backends; 0 keeps the default
+	MaxIdleConnsPerHost int // Idle keep-alive connections kept per backend; 0 keeps the default
+}
+
+// NewUpstreamTransport builds the transport used to reach backends over HTTP or HTTPS
+func NewUpstreamTransport(cfg UpstreamTransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read upstream CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("upstream CA bundle %s contains no certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// serverNameKey carries the name an HTTPS backend's certificate is verified against
+type serverNameKey struct{}
+
+// WithServerName returns a context whose HTTPS requests, sent through a
+// ServerNameTransport, verify the backend's certificate against name
+func WithServerName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, serverNameKey{}, name)
+}
+
+// ServerNameTransport sends requests through base, except that HTTPS requests
+// carrying a server name use a copy of base verifying certificates against it.
+// Backends are dialed by pod IP, which their certificates don't name.
+type ServerNameTransport struct {
+	base   *http.Transport
+	clones map[string]*http.Transport
+	mutex  sync.Mutex
+}
+
+// NewServerNameTransport wraps base; copies for each server name share its settings
+func NewServerNameTransport(base *http.Transport) *ServerNameTransport {
+	return &ServerNameTransport{base: base, clones: make(map[string]*http.Transport)}
+}
+
+// RoundTrip sends the request over the transport for its server name
+func (t *ServerNameTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, _ := req.Context().Value(serverNameKey{}).(string)
+	if name == "" || req.URL.Scheme != SchemeHTTPS {
+		return t.base.RoundTrip(req)
+	}
+	return t.forServerName(name).RoundTrip(req)
+}
+
+// forServerName returns the copy of base verifying certificates against name, creating it on first use
+func (t *ServerNameTransport) forServerName(name string) *http.Transport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if clone, exists := t.clones[name]; exists {
+		return clone
+	}
+	clone := t.base.Clone()
+	if clone.TLSClientConfig == nil {
+		clone.TLSClientConfig = &tls.Config{}
+	}
+	clone.TLSClientConfig.ServerName = name
+	t.clones[name] = clone
+	return clone
+}
+
+// CloseIdleConnections closes idle connections of base and every copy
+func (t *ServerNameTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, clone := range t.clones {
+		clone.CloseIdleConnections()
+	}
+}
+
+// NewGRPCTransport derives an HTTP/2-only transport for gRPC backends from base:
+// prior-knowledge h2c for http:// targets and negotiated HTTP/2 for https://
+func NewGRPCTransport(base http.RoundTripper) *http.Transport {
+	var transport *http.Transport
+	if t, ok := base.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	transport.Protocols = protocols
+	return transport
+}
+
+// IsGRPCRequest reports whether a request carries a gRPC payload
+func IsGRPCRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// IsEventStreamResponse reports whether an upstream answered with Server-Sent Events.
+// Only the upstream decides this; a client's Accept header never does.
+func IsEventStreamResponse(resp *http.Response) bool {
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream")
+}
+
+// NormalizeScheme returns the upstream scheme to use, defaulting to plain HTTP
+func NormalizeScheme(scheme string) string {
+	if strings.EqualFold(strings.TrimSpace(scheme), SchemeHTTPS) {
+		return SchemeHTTPS
+	}
+	return SchemeHTTP
+}
+
+// Headers used to propagate tracing IDs to backends
+const (
+	HeaderRequestID     = "X-Request-ID"
+	HeaderCorrelationID = "X-Correlation-ID"
+)
+
+// ApplyTracingHeaders copies the request and correlation IDs from the request
+// context onto an outbound request, keeping any values the client already sent
+func ApplyTracingHeaders(req *http.Request) {
+	ctx := req.Context()
+
+	if requestID := logger.GetRequestID(ctx); requestID != "" && req.Header.Get(HeaderRequestID) == "" {
+		req.Header.Set(HeaderRequestID, requestID)
+	}
+	if correlationID := logger.GetCorrelationID(ctx); correlationID != "" && req.Header.Get(HeaderCorrelationID) == "" {
+		req.Header.Set(HeaderCorrelationID, correlationID)
+	}
+}
+
+// ApplyForwardedHeaders sets X-Forwarded-Host/Proto/Port on an outbound request and
+// prepares X-Forwarded-For, to which the reverse proxy appends the peer address. Values
+// sent by a trusted proxy are kept so the chain survives; anyone else's are replaced,
+// since they could be spoofed. Call it before the request's Host is rewritten.
+func ApplyForwardedHeaders(req *http.Request, trustedPeer bool) {
+	if !trustedPeer {
+		req.Header.Del(HeaderForwardedFor)
+		req.Header.Del(HeaderForwardedHost)
+		req.Header.Del(HeaderForwardedPort)
+		req.Header.Del(HeaderForwardedProto)
+	}
+
+	proto := SchemeHTTP
+	if req.TLS != nil {
+		proto = SchemeHTTPS
+	}
+	port := "80"
+	if proto == SchemeHTTPS {
+		port = "443"
+	}
+	if _, hostPort, err := net.SplitHostPort(req.Host); err == nil {
+		port = hostPort
+	}
+
+	setIfMissing(req.Header, HeaderForwardedHost, req.Host)
+	setIfMissing(req.Header, HeaderForwardedProto, proto)
+	setIfMissing(req.Header, HeaderForwardedPort, port)
+}
+
+// setIfMissing sets a header unless a value is already present
+func setIfMissing(header http.Header, key, value string) {
+	if header.Get(key) == "" && value != "" {
+		header.Set(key, value)
+	}
+}
+
+// ApplyClientTLSHeaders prepares the TLS forwarding headers on an outbound request.
+// Client-supplied values are always stripped so they can't be spoofed; when forward
+// is set they are replaced with values taken from the connection the gateway terminated.
+func ApplyClientTLSHeaders(req *http.Request, forward bool) {
+	req.Header.Del(HeaderForwardedClientCert)
+	req.Header.Del(HeaderForwardedTLSVersion)
+
+	if !forward || req.TLS == nil {
+		return
+	}
+
+	req.Header.Set(HeaderForwardedTLSVersion, tls.VersionName(req.TLS.Version))
+
+	if len(req.TLS.PeerCertificates) > 0 {
+		cert := req.TLS.PeerCertificates[0]
+		hash := sha256.Sum256(cert.Raw)
+		req.Header.Set(HeaderForwardedClientCert,
+			"Hash="+hex.EncodeToString(hash[:])+";Subject=\""+sanitizeXFCCValue(cert.Subject.String())+"\"")
+	}
+}
+
+// sanitizeXFCCValue escapes quotes and drops control characters so a
+// certificate subject can't break out of its quoted XFCC element
+func sanitizeXFCCValue(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			// Skip control characters
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Upstream error types used to classify proxy failures
+const (
+	ErrorTypeDial     = "dial"
+	ErrorTypeTimeout  = "timeout"
+	ErrorTypeReset    = "reset"
+	ErrorTypeTLS      = "tls"
+	ErrorTypeCanceled = "canceled"
+	ErrorTypeOther    = "other"
+)
+
+// ClassifyError maps a reverse proxy error to a coarse error type
+func ClassifyError(err error) string {
+	if err == nil {
+		return ErrorTypeOther
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorTypeCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTypeTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorTypeTimeout
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorTypeDial
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorTypeDial
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorTypeDial
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorTypeReset
+	}
+
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) {
+		return ErrorTypeTLS
+	}
+
+	return ErrorTypeOther
+}
+
+// ErrorCounter counts upstream proxy failures by service and error type
+type ErrorCounter struct {
+	counts map[errorKey]int64
+	mu     sync.RWMutex
+}
+
+type errorKey struct {
+	service   string
+	errorType string
+}
+
+// NewErrorCounter creates an empty upstream error counter
+func NewErrorCounter() *ErrorCounter {
+	return &ErrorCounter{
+		counts: make(map[errorKey]int64),
+	}
+}
+
+// Record classifies err and increments the counter for the service, returning the type
+func (c *ErrorCounter) Record(service string, err error) string {
+	errorType := ClassifyError(err)
+
+	c.mu.Lock()
+	c.counts[errorKey{service: service, errorType: errorType}]++
+	c.mu.Unlock()
+
+	return errorType
+}
+
+// Count returns the current count for a service and error type
+func (c *ErrorCounter) Count(service, errorType string) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counts[errorKey{service: service, errorType: errorType}]
+}
+
+// WriteMetrics writes the counters in the Prometheus text format
+func (c *ErrorCounter) WriteMetrics(w io.Writer) {
+	c.mu.RLock()
+	keys := make([]errorKey, 0, len(c.counts))
+	for k := range c.counts {
+		keys = append(keys, k)
+	}
+	counts := make(map[errorKey]int64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].service != keys[j].service {
+			return keys[i].service < keys[j].service
+		}
+		return keys[i].errorType < keys[j].errorType
+	})
+
+	fmt.Fprintln(w, "# HELP gateway_upstream_errors_total Upstream proxy failures by service and error type")
+	fmt.Fprintln(w, "# TYPE gateway_upstream_errors_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "gateway_upstream_errors_total{service=%q,type=%q} %d\n", k.service, k.errorType, counts[k])
+	}
+}
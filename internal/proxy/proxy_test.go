@@ -0,0 +1,262 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestApplyClientTLSHeaders(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("client-cert"), Subject: pkix.Name{CommonName: "orders-client"}}
+	hash := sha256.Sum256(cert.Raw)
+	wantXFCC := "Hash=" + hex.EncodeToString(hash[:]) + ";Subject=\"CN=orders-client\""
+
+	tests := []struct {
+		name        string
+		state       *tls.ConnectionState
+		forward     bool
+		spoofed     bool
+		wantXFCC    string
+		wantVersion string
+	}{
+		{
+			name:        "client certificate",
+			state:       &tls.ConnectionState{Version: tls.VersionTLS13, PeerCertificates: []*x509.Certificate{cert}},
+			forward:     true,
+			wantXFCC:    wantXFCC,
+			wantVersion: "TLS 1.3",
+		},
+		{
+			name:        "no client certificate",
+			state:       &tls.ConnectionState{Version: tls.VersionTLS12},
+			forward:     true,
+			wantVersion: "TLS 1.2",
+		},
+		{
+			name:  "forwarding disabled",
+			state: &tls.ConnectionState{Version: tls.VersionTLS13, PeerCertificates: []*x509.Certificate{cert}},
+		},
+		{name: "plain HTTP", forward: true},
+		{name: "spoofed headers are stripped", forward: true, spoofed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.TLS = tt.state
+			if tt.spoofed {
+				req.Header.Set(HeaderForwardedClientCert, "Hash=forged")
+				req.Header.Set(HeaderForwardedTLSVersion, "TLS 1.3")
+			}
+
+			ApplyClientTLSHeaders(req, tt.forward)
+
+			if got := req.Header.Get(HeaderForwardedClientCert); got != tt.wantXFCC {
+				t.Errorf("%s = %q, want %q", HeaderForwardedClientCert, got, tt.wantXFCC)
+			}
+			if got := req.Header.Get(HeaderForwardedTLSVersion); got != tt.wantVersion {
+				t.Errorf("%s = %q, want %q", HeaderForwardedTLSVersion, got, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSanitizeXFCCValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "CN=orders", want: "CN=orders"},
+		{value: `CN=a",Subject="CN=admin`, want: `CN=a\",Subject=\"CN=admin`},
+		{value: `CN=a\b`, want: `CN=a\\b`},
+		{value: "CN=a\r\nX-Injected: 1", want: "CN=aX-Injected: 1"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeXFCCValue(tt.value); got != tt.want {
+			t.Errorf("sanitizeXFCCValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: ErrorTypeDial},
+		{name: "dns failure", err: &net.DNSError{Err: "no such host", Name: "orders"}, want: ErrorTypeDial},
+		{name: "deadline", err: context.DeadlineExceeded, want: ErrorTypeTimeout},
+		{name: "client went away", err: fmt.Errorf("proxy: %w", context.Canceled), want: ErrorTypeCanceled},
+		{name: "reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, want: ErrorTypeReset},
+		{name: "truncated response", err: io.ErrUnexpectedEOF, want: ErrorTypeReset},
+		{name: "plain HTTP to a TLS port", err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, want: ErrorTypeTLS},
+		{name: "unknown", err: errors.New("boom"), want: ErrorTypeOther},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyError = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCounterMetrics(t *testing.T) {
+	counter := NewErrorCounter()
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	counter.Record("orders", refused)
+	counter.Record("orders", refused)
+	counter.Record("billing", context.DeadlineExceeded)
+
+	var buf bytes.Buffer
+	counter.WriteMetrics(&buf)
+	for _, line := range []string{
+		`gateway_upstream_errors_total{service="billing",type="timeout"} 1`,
+		`gateway_upstream_errors_total{service="orders",type="dial"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+// writeServerCA writes the certificate of a TLS test server as a PEM bundle
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, block, 0o600); err != nil {
+		t.Fatalf("write CA bundle: %v", err)
+	}
+	return path
+}
+
+func TestNewUpstreamTransport(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	caFile := writeServerCA(t, backend)
+	notPEM := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		config        UpstreamTransportConfig
+		wantConfigErr bool
+		wantVerified  bool
+	}{
+		{name: "trusted by the CA bundle", config: UpstreamTransportConfig{CAFile: caFile}, wantVerified: true},
+		{name: "unknown CA", config: UpstreamTransportConfig{}},
+		{name: "verification skipped", config: UpstreamTransportConfig{InsecureSkipVerify: true}, wantVerified: true},
+		{name: "missing bundle", config: UpstreamTransportConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, wantConfigErr: true},
+		{name: "bundle without certificates", config: UpstreamTransportConfig{CAFile: notPEM}, wantConfigErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewUpstreamTransport(tt.config)
+			if (err != nil) != tt.wantConfigErr {
+				t.Fatalf("NewUpstreamTransport error = %v, want error %v", err, tt.wantConfigErr)
+			}
+			if err != nil {
+				return
+			}
+			defer transport.CloseIdleConnections()
+
+			resp, err := (&http.Client{Transport: transport}).Get(backend.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err == nil) != tt.wantVerified {
+				t.Errorf("GET over HTTPS error = %v, want success %v", err, tt.wantVerified)
+			}
+			var unknownAuthority x509.UnknownAuthorityError
+			if !tt.wantVerified && !errors.As(err, &unknownAuthority) {
+				t.Errorf("error = %v, want an unknown authority error", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeScheme(t *testing.T) {
+	for scheme, want := range map[string]string{"": SchemeHTTP, "http": SchemeHTTP, " HTTPS ": SchemeHTTPS, "ftp": SchemeHTTP} {
+		if got := NormalizeScheme(scheme); got != want {
+			t.Errorf("NormalizeScheme(%q) = %q, want %q", scheme, got, want)
+		}
+	}
+}
+
+func TestApplyForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        string
+		tls         bool
+		trustedPeer bool
+		incoming    map[string]string
+		want        map[string]string
+	}{
+		{
+			name: "direct client",
+			host: "api.example.com",
+			want: map[string]string{HeaderForwardedFor: "", HeaderForwardedHost: "api.example.com", HeaderForwardedProto: "http", HeaderForwardedPort: "80"},
+		},
+		{
+			name: "direct TLS client on a custom port",
+			host: "api.example.com:8443",
+			tls:  true,
+			want: map[string]string{HeaderForwardedHost: "api.example.com:8443", HeaderForwardedProto: "https", HeaderForwardedPort: "8443"},
+		},
+		{
+			name:     "untrusted peer's headers are replaced",
+			host:     "api.example.com",
+			incoming: map[string]string{HeaderForwardedFor: "1.2.3.4", HeaderForwardedHost: "evil.example", HeaderForwardedProto: "https", HeaderForwardedPort: "443"},
+			want:     map[string]string{HeaderForwardedFor: "", HeaderForwardedHost: "api.example.com", HeaderForwardedProto: "http", HeaderForwardedPort: "80"},
+		},
+		{
+			name:        "trusted proxy's chain is kept",
+			host:        "gateway.internal",
+			trustedPeer: true,
+			incoming:    map[string]string{HeaderForwardedFor: "198.51.100.1", HeaderForwardedHost: "api.example.com", HeaderForwardedProto: "https", HeaderForwardedPort: "443"},
+			want:        map[string]string{HeaderForwardedFor: "198.51.100.1", HeaderForwardedHost: "api.example.com", HeaderForwardedProto: "https", HeaderForwardedPort: "443"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Host = tt.host
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for name, value := range tt.incoming {
+				req.Header.Set(name, value)
+			}
+
+			ApplyForwardedHeaders(req, tt.trustedPeer)
+
+			for name, want := range tt.want {
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseStatusMap parses upstream to client status remappings such as "418=400,509=429"
+func ParseStatusMap(value string) (map[int]int, error) {
+	statusMap := make(map[int]int)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, found := strings.Cut(part, "=")
+		fromCode, errFrom := strconv.Atoi(strings.TrimSpace(from))
+		toCode, errTo := strconv.Atoi(strings.TrimSpace(to))
+		if !found || errFrom != nil || errTo != nil || !validStatus(fromCode) || !validStatus(toCode) {
+			return nil, fmt.Errorf("invalid status mapping %q, want upstream=client such as 418=400", part)
+		}
+		statusMap[fromCode] = toCode
+	}
+
+	if len(statusMap) == 0 {
+		return nil, fmt.Errorf("no status mappings in %q", value)
+	}
+	return statusMap, nil
+}
+
+func validStatus(code int) bool {
+	return code >= 100 && code <= 599
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import "testing"
+
+func TestParseStatusMap(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[int]int
+		wantErr bool
+	}{
+		{value: "418=400", want: map[int]int{418: 400}},
+		{value: " 418 = 400 , 509=429,", want: map[int]int{418: 400, 509: 429}},
+		{value: "418=400,418=404", want: map[int]int{418: 404}}, // The last mapping wins
+		{value: "", wantErr: true},
+		{value: ",", wantErr: true},
+		{value: "418", wantErr: true},
+		{value: "418=teapot", wantErr: true},
+		{value: "99=400", wantErr: true},
+		{value: "418=600", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseStatusMap(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseStatusMap(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseStatusMap(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for from, to := range tt.want {
+			if got[from] != to {
+				t.Errorf("ParseStatusMap(%q)[%d] = %d, want %d", tt.value, from, got[from], to)
+			}
+		}
+	}
+}
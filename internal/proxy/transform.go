@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// ErrInvalidJSONBody is returned by BodyTransform.Apply when the body is not a JSON object
+var ErrInvalidJSONBody = errors.New("request body is not a JSON object")
+
+// BodyTransform reshapes a JSON object request body for a backend expecting a
+// different schema. Only top-level fields are touched: renames run first, then
+// defaults are added for fields still missing.
+type BodyTransform struct {
+	Rename   map[string]string          `json:"rename,omitempty"`   // Old field name to new
+	Defaults map[string]json.RawMessage `json:"defaults,omitempty"` // Values for missing fields
+
+	// RejectInvalid rejects bodies that aren't a JSON object instead of forwarding them untouched
+	RejectInvalid bool `json:"reject_invalid,omitempty"`
+}
+
+// ParseBodyTransform parses a transform such as
+// {"rename": {"userName": "user_name"}, "defaults": {"source": "gateway"}, "reject_invalid": true}
+func ParseBodyTransform(value string) (*BodyTransform, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+
+	var transform BodyTransform
+	if err := decoder.Decode(&transform); err != nil {
+		return nil, fmt.Errorf("invalid request transform: %w", err)
+	}
+	if len(transform.Rename) == 0 && len(transform.Defaults) == 0 {
+		return nil, errors.New("invalid request transform: no rename or defaults")
+	}
+	for from, to := range transform.Rename {
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("invalid request transform: empty field name in rename %q to %q", from, to)
+		}
+	}
+	return &transform, nil
+}
+
+// Apply returns the transformed body. A body that isn't a JSON object yields
+// ErrInvalidJSONBody; an empty body is returned as is.
+func (t *BodyTransform) Apply(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, ErrInvalidJSONBody
+	}
+
+	for from, to := range t.Rename {
+		if value, exists := fields[from]; exists {
+			delete(fields, from)
+			fields[to] = value
+		}
+	}
+	for name, value := range t.Defaults {
+		if _, exists := fields[name]; !exists {
+			fields[name] = value
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// IsJSONContentType reports whether a Content-Type is application/json or a +json type
+func IsJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBodyTransformApply(t *testing.T) {
+	transform, err := ParseBodyTransform(`{"rename": {"userName": "user_name"}, "defaults": {"source": "gateway", "retries": 3}}`)
+	if err != nil {
+		t.Fatalf("ParseBodyTransform: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string // Compared as decoded JSON, so field order doesn't matter
+		wantErr error
+	}{
+		{name: "field renamed and defaults injected", body: `{"userName": "ada"}`, want: `{"user_name": "ada", "source": "gateway", "retries": 3}`},
+		{name: "present field keeps its value", body: `{"userName": "ada", "source": "mobile"}`, want: `{"user_name": "ada", "source": "mobile", "retries": 3}`},
+		{name: "nested fields untouched", body: `{"profile": {"userName": "ada"}}`, want: `{"profile": {"userName": "ada"}, "source": "gateway", "retries": 3}`},
+		{name: "empty body", body: "", want: ""},
+		{name: "malformed JSON", body: `{"userName": `, wantErr: ErrInvalidJSONBody},
+		{name: "JSON array", body: `[{"userName": "ada"}]`, wantErr: ErrInvalidJSONBody},
+		{name: "JSON null", body: `null`, wantErr: ErrInvalidJSONBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transform.Apply([]byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.want == "" {
+				if len(got) != 0 {
+					t.Errorf("Apply = %q, want an empty body", got)
+				}
+				return
+			}
+
+			var gotFields, wantFields map[string]interface{}
+			if err := json.Unmarshal(got, &gotFields); err != nil {
+				t.Fatalf("transformed body %q is not JSON: %v", got, err)
+			}
+			json.Unmarshal([]byte(tt.want), &wantFields)
+			if !reflect.DeepEqual(gotFields, wantFields) {
+				t.Errorf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBodyTransformRejectsInvalid(t *testing.T) {
+	for _, value := range []string{
+		`{}`,
+		`{"rename": {}}`,
+		`{"rename": {"userName": ""}}`,
+		`{"renames": {"userName": "user_name"}}`,
+		`not json`,
+	} {
+		if _, err := ParseBodyTransform(value); err == nil {
+			t.Errorf("ParseBodyTransform(%s) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{contentType: "application/json", want: true},
+		{contentType: "application/json; charset=utf-8", want: true},
+		{contentType: "application/merge-patch+json", want: true},
+		{contentType: "text/plain"},
+		{contentType: ""},
+	}
+
+	for _, tt := range tests {
+		if got := IsJSONContentType(tt.contentType); got != tt.want {
+			t.Errorf("IsJSONContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
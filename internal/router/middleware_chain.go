@@ -0,0 +1,41 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// middlewareChain registers the gateway's middleware on the router and remembers it,
+// because mux only runs router middleware for requests a route matched. Requests
+// handed to NotFoundHandler or MethodNotAllowedHandler, which include default backend
+// traffic and the 405 and 503 answers for dynamic routes, get the chain from wrapUnmatched.
+type middlewareChain struct {
+	router      *mux.Router
+	middlewares []mux.MiddlewareFunc
+}
+
+// Use adds middleware to the router, in the same order as mux.Router.Use
+func (c *middlewareChain) Use(mw mux.MiddlewareFunc) {
+	c.router.Use(mw)
+	c.middlewares = append(c.middlewares, mw)
+}
+
+// wrapUnmatched applies the chain to the router's NotFoundHandler and
+// MethodNotAllowedHandler. Call it once both are set.
+func (c *middlewareChain) wrapUnmatched() {
+	if c.router.NotFoundHandler != nil {
+		c.router.NotFoundHandler = c.then(c.router.NotFoundHandler)
+	}
+	if c.router.MethodNotAllowedHandler != nil {
+		c.router.MethodNotAllowedHandler = c.then(c.router.MethodNotAllowedHandler)
+	}
+}
+
+// then wraps handler in the chain, the first middleware added running first
+func (c *middlewareChain) then(handler http.Handler) http.Handler {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i].Middleware(handler)
+	}
+	return handler
+}
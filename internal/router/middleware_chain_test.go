@@ -0,0 +1,176 @@
+package router
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/handlers"
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/internal/services"
+	"api-gateway/pkg/jwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMiddlewareChainWrapsUnmatchedRequests(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodGet)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	})
+
+	var order []string
+	chain := &middlewareChain{router: r}
+	chain.Use(middleware.NewRequestIDMiddleware().Middleware)
+	for _, name := range []string{"first", "second"} {
+		chain.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+	chain.wrapUnmatched()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "matched route", method: http.MethodGet, path: "/users", want: http.StatusOK},
+		{name: "unmatched path", method: http.MethodGet, path: "/missing", want: http.StatusNotFound},
+		{name: "unmatched method", method: http.MethodPost, path: "/users", want: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Header().Get("X-Request-ID") == "" {
+				t.Error("response has no X-Request-ID")
+			}
+			if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+				t.Errorf("middleware ran as %v, want [first second]", order)
+			}
+		})
+	}
+}
+
+func TestDynamicRouteErrorsGetMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		syncing   bool
+		method    string
+		path      string
+		want      int
+		wantAllow string
+	}{
+		{name: "method not allowed", method: http.MethodPost, path: "/orders", want: http.StatusMethodNotAllowed, wantAllow: "GET"},
+		{name: "discovery syncing", syncing: true, method: http.MethodGet, path: "/missing", want: http.StatusServiceUnavailable},
+		{name: "not found", method: http.MethodGet, path: "/missing", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.Kubernetes.ServiceDiscovery = true
+			cfg.Kubernetes.StartupUnavailable = tt.syncing
+
+			r := mux.NewRouter()
+			chain := &middlewareChain{router: r}
+			chain.Use(middleware.NewRequestIDMiddleware().Middleware)
+
+			jwtService := jwt.NewService(cfg.JWT)
+			discoveryManager := services.NewDiscoveryManager(cfg, newTestLogger())
+			drm, _ := setupRoutes(r, cfg, middleware.NewAuthMiddleware(jwtService), jwtService, discoveryManager,
+				handlers.NewReadiness(), handlers.NewMetrics(), gatewayproxy.NewErrorCounter(), nil, newTestLogger())
+			chain.wrapUnmatched()
+
+			if err := drm.RegisterRoute(services.RouteSpec{
+				Name:      "orders",
+				Methods:   []string{http.MethodGet},
+				Paths:     []string{"/orders"},
+				Endpoints: []string{"127.0.0.1:1"},
+			}); err != nil {
+				t.Fatalf("RegisterRoute: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if rec.Header().Get("X-Request-ID") == "" {
+				t.Error("response has no X-Request-ID")
+			}
+		})
+	}
+}
+
+func TestRateLimitingToggle(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantLimited int
+	}{
+		{name: "enabled throttles past the burst", enabled: true, wantLimited: 3},
+		{name: "disabled lets everything through"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.Rate.Limit, cfg.Rate.BurstLimit = 1, 2
+			cfg.Rate.ExemptPaths = nil
+			cfg.Middleware.RateLimiting = tt.enabled
+
+			r := mux.NewRouter()
+			r.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+			if _, err := setupRateLimiter(&middlewareChain{router: r}, cfg, jwt.NewService(cfg.JWT)); err != nil {
+				t.Fatalf("setupRateLimiter: %v", err)
+			}
+
+			limited := 0
+			for i := 0; i < 5; i++ {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+				if rec.Code == http.StatusTooManyRequests {
+					limited++
+				}
+			}
+			if limited != tt.wantLimited {
+				t.Errorf("%d of 5 requests limited, want %d", limited, tt.wantLimited)
+			}
+			if got := disabledMiddlewares(cfg.Middleware); containsString(got, "rate_limiting") == tt.enabled {
+				t.Errorf("disabled middlewares = %v with rate limiting enabled %v", got, tt.enabled)
+			}
+		})
+	}
+}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
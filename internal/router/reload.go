@@ -0,0 +1,122 @@
+package router
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/middleware"
+	"api-gateway/pkg/logger"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/time/rate"
+)
+
+// reloader applies configuration changes on SIGHUP. Only settings the running
+// components can adopt in place are applied: the log level, rate limits and the
+// slow request threshold. Changes to anything else need a restart and are ignored.
+type reloader struct {
+	applied        config.Config
+	loader         func() *config.Config
+	rootLogger     *logger.Logger
+	rateLimiter    *middleware.RateLimiter
+	requestLogging *middleware.StructuredLoggingMiddleware
+	logger         *logger.Logger
+}
+
+// newReloader creates a reloader starting from the configuration the gateway booted with
+func newReloader(cfg *config.Config, rootLogger *logger.Logger, rateLimiter *middleware.RateLimiter,
+	requestLogging *middleware.StructuredLoggingMiddleware) *reloader {
+	return &reloader{
+		applied:        *cfg,
+		loader:         config.Reload,
+		rootLogger:     rootLogger,
+		rateLimiter:    rateLimiter,
+		requestLogging: requestLogging,
+		logger:         rootLogger.WithComponent("config_reload"),
+	}
+}
+
+// watch reloads the configuration every time the process receives SIGHUP
+func (rl *reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			rl.logger.Info("SIGHUP received, reloading configuration")
+			if err := rl.reload(rl.loader()); err != nil {
+				rl.logger.Error("Configuration reload rejected", map[string]interface{}{
+					"error": err,
+				})
+			}
+		}
+	}()
+}
+
+// reload validates next and applies its runtime-changeable settings
+func (rl *reloader) reload(next *config.Config) error {
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if ignored := restartRequired(&rl.applied, next); len(ignored) > 0 {
+		rl.logger.Warn("Ignoring settings that require a restart", map[string]interface{}{
+			"settings": ignored,
+		})
+	}
+
+	changed := make(map[string]interface{})
+
+	if next.Logging.Level != rl.applied.Logging.Level {
+		level, ok := logger.ParseLevel(next.Logging.Level)
+		if !ok {
+			return fmt.Errorf("unknown log level %q", next.Logging.Level)
+		}
+		rl.rootLogger.SetLevel(level)
+		changed["log_level"] = fmt.Sprintf("%s -> %s", rl.applied.Logging.Level, next.Logging.Level)
+		rl.applied.Logging.Level = next.Logging.Level
+	}
+
+	if next.Rate.Limit != rl.applied.Rate.Limit || next.Rate.BurstLimit != rl.applied.Rate.BurstLimit {
+		rl.rateLimiter.SetLimit(rate.Limit(next.Rate.Limit), next.Rate.BurstLimit)
+		changed["rate_limit"] = fmt.Sprintf("%v -> %v", rl.applied.Rate.Limit, next.Rate.Limit)
+		changed["rate_burst_limit"] = fmt.Sprintf("%d -> %d", rl.applied.Rate.BurstLimit, next.Rate.BurstLimit)
+		rl.applied.Rate.Limit = next.Rate.Limit
+		rl.applied.Rate.BurstLimit = next.Rate.BurstLimit
+	}
+
+	if next.Logging.SlowRequestThreshold != rl.applied.Logging.SlowRequestThreshold {
+		rl.requestLogging.SetSlowRequestThreshold(next.Logging.SlowRequestThreshold)
+		changed["slow_request_threshold"] = fmt.Sprintf("%s -> %s", rl.applied.Logging.SlowRequestThreshold, next.Logging.SlowRequestThreshold)
+		rl.applied.Logging.SlowRequestThreshold = next.Logging.SlowRequestThreshold
+	}
+
+	if len(changed) == 0 {
+		rl.logger.Info("Configuration reloaded, no runtime settings changed")
+		return nil
+	}
+
+	rl.logger.Info("Configuration reloaded", changed)
+	return nil
+}
+
+// restartRequired lists settings that differ but can't be changed without a restart
+func restartRequired(current, next *config.Config) []string {
+	var settings []string
+	if next.Server.Port != current.Server.Port {
+		settings = append(settings, "PORT")
+	}
+	if next.Server.TLSCertFile != current.Server.TLSCertFile || next.Server.TLSKeyFile != current.Server.TLSKeyFile ||
+		next.Server.TLSClientCAFile != current.Server.TLSClientCAFile || next.Server.TLSRequireClientCert != current.Server.TLSRequireClientCert {
+		settings = append(settings, "TLS")
+	}
+	if next.JWT.Secret != current.JWT.Secret {
+		settings = append(settings, "JWT_SECRET")
+	}
+	if next.Kubernetes.Enabled != current.Kubernetes.Enabled || next.Kubernetes.Namespace != current.Kubernetes.Namespace ||
+		next.Kubernetes.ServiceDiscovery != current.Kubernetes.ServiceDiscovery {
+		settings = append(settings, "KUBERNETES")
+	}
+	return settings
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/middleware"
+	"api-gateway/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// newTestReloader returns a reloader over a valid configuration whose logger is at fatal
+func newTestReloader(t *testing.T) (*reloader, *config.Config, *middleware.RateLimiter) {
+	t.Helper()
+	cfg := config.Load()
+	cfg.JWT.Secret = "reload-test-secret"
+	cfg.Logging.Level = "fatal"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("test configuration is invalid: %v", err)
+	}
+	rateLimiter := middleware.NewRateLimiter(rate.Limit(cfg.Rate.Limit), cfg.Rate.BurstLimit, time.Minute, time.Minute)
+	rl := newReloader(cfg, newTestLogger(), rateLimiter, middleware.NewStructuredLoggingMiddleware(newTestLogger()))
+	return rl, cfg, rateLimiter
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(cfg *config.Config)
+		wantErr   bool
+		wantLevel logger.LogLevel
+		wantBurst int // Requests admitted back to back; 0 skips the check
+	}{
+		{name: "log level", change: func(cfg *config.Config) { cfg.Logging.Level = "error" }, wantLevel: logger.ERROR},
+		{
+			name:      "rate limit",
+			change:    func(cfg *config.Config) { cfg.Rate.Limit, cfg.Rate.BurstLimit = 1, 2 },
+			wantLevel: logger.FATAL,
+			wantBurst: 2,
+		},
+		{
+			name: "listen port is ignored",
+			change: func(cfg *config.Config) {
+				cfg.Server.Port = "9999"
+				cfg.Logging.Level = "warn"
+			},
+			wantLevel: logger.WARN,
+		},
+		{
+			name: "invalid configuration is rejected",
+			change: func(cfg *config.Config) {
+				cfg.Rate.Limit = 0
+				cfg.Logging.Level = "debug"
+			},
+			wantErr:   true,
+			wantLevel: logger.FATAL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, cfg, rateLimiter := newTestReloader(t)
+			next := *cfg
+			tt.change(&next)
+
+			err := rl.reload(&next)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reload error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := rl.rootLogger.GetLevel(); got != tt.wantLevel {
+				t.Errorf("level = %v, want %v", got, tt.wantLevel)
+			}
+			if rl.applied.Server.Port != cfg.Server.Port {
+				t.Errorf("applied port = %s, want the boot port %s", rl.applied.Server.Port, cfg.Server.Port)
+			}
+
+			if tt.wantBurst == 0 {
+				return
+			}
+			handler := rateLimiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for i := 0; i <= tt.wantBurst; i++ {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+				if limited := rec.Code == http.StatusTooManyRequests; limited != (i == tt.wantBurst) {
+					t.Errorf("request %d status = %d", i+1, rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestSIGHUPUpdatesLogLevel(t *testing.T) {
+	rl, cfg, _ := newTestReloader(t)
+	rl.loader = func() *config.Config {
+		next := *cfg
+		next.Logging.Level = "error"
+		return &next
+	}
+	rl.watch()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("send SIGHUP: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for rl.rootLogger.GetLevel() != logger.ERROR {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %v after SIGHUP, want ERROR", rl.rootLogger.GetLevel())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -3,17 +3,28 @@ package router
 import (
 	"api-gateway/internal/config"
 	"api-gateway/internal/handlers"
+	"api-gateway/internal/k8s"
 	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
 	"api-gateway/internal/services"
+	"api-gateway/internal/version"
+	"api-gateway/pkg/clock"
 	"api-gateway/pkg/jwt"
 	"api-gateway/pkg/logger"
+	gatewaymetrics "api-gateway/pkg/metrics"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -25,37 +36,80 @@ import (
 
 // ProxyRoute represents the structure of our gateway.yaml (legacy)
 type ProxyRoute struct {
-	Routes []struct {
-		Path         string `yaml:"path"`
-		Method       string `yaml:"method"`
-		TargetUrl    string `yaml:"target_url"`
-		AuthRequired bool   `yaml:"auth_required"`
-	} `yaml:"routes"`
+	Routes []StaticRoute `yaml:"routes"`
+}
+
+// StaticRoute represents a single route entry in gateway.yaml
+type StaticRoute struct {
+	Path             string   `yaml:"path"`
+	Method           string   `yaml:"method"`     // One method, a comma-separated list, or * / ANY
+	Methods          []string `yaml:"methods"`    // Used instead of Method when set
+	TargetUrl        string   `yaml:"target_url"` // One URL or a comma-separated list balanced by Strategy
+	Strategy         string   `yaml:"strategy"`   // Load balancing across targets, PROXY_LOAD_BALANCING by default
+	AuthRequired     bool     `yaml:"auth_required"`
+	ForwardClientTLS bool     `yaml:"forward_client_tls"`
+	MaxBodyBytes     int64    `yaml:"max_body_bytes"`    // Overrides PROXY_MAX_BODY_BYTES when set
+	HealthCheckPath  string   `yaml:"health_check_path"` // Overrides HEALTH_CHECK_PATH for the route's target
 }
 
 // HealthManager manages the health status of backend services (legacy)
 type HealthManager struct {
 	statuses      map[string]bool
+	targets       map[string]string // Health check URL of each target, set by StartHealthChecks
+	listeners     []func(targetURL string, healthy bool)
 	mu            sync.RWMutex
 	client        *http.Client
 	checkInterval time.Duration
+	path          string // Appended to targets whose routes set no health check path
+	method        string
+	healthy       gatewayproxy.StatusRange
 	stopCh        chan struct{}
+	stopOnce      sync.Once
+	wg            sync.WaitGroup
 	logger        *logger.Logger
+	clock         clock.Clock
 }
 
 // Setup initializes and starts the API Gateway server with structured logging
 func Setup(cfg *config.Config) {
+	SetupWithMetrics(cfg, nil)
+}
+
+// SetupWithMetrics is Setup with request, route and circuit breaker metrics sent
+// to recorder; nil keeps them in the built-in registry served at /metrics
+func SetupWithMetrics(cfg *config.Config, recorder gatewaymetrics.Recorder) {
 	ctx := context.Background()
 
 	structuredLogger := logger.NewLogger(logger.Config{
-		Level:       cfg.Logging.Level,
-		Format:      "json",
-		Service:     "api-gateway",
-		Output:      "stdout",
-		EnableHooks: false,
+		Level:             cfg.Logging.Level,
+		Format:            cfg.Logging.Format,
+		Service:           "api-gateway",
+		Output:            cfg.Logging.Output,
+		EnableHooks:       false,
+		HookBufferSize:    cfg.Logging.HookBufferSize,
+		HookWorkers:       cfg.Logging.HookWorkers,
+		SampleRate:        cfg.Logging.SampleRate,
+		SampleBurst:       cfg.Logging.SampleBurst,
+		StackDepth:        cfg.Logging.StackDepth,
+		StackFilter:       cfg.Logging.StackFilter,
+		RedactPatterns:    cfg.Logging.RedactPatterns,
+		FilePath:          cfg.Logging.FilePath,
+		FileMaxSizeMB:     cfg.Logging.FileMaxSizeMB,
+		FileMaxBackups:    cfg.Logging.FileMaxBackups,
+		FileMaxAge:        cfg.Logging.FileMaxAge,
+		FileFlushInterval: cfg.Logging.FileFlushInterval,
 	})
 
 	// Add custom hooks if webhook URLs are configured
+	var errorTrackingHook *logger.ErrorTrackingHook
+	if cfg.Logging.ErrorWebhookURL != "" {
+		errorTrackingHook = logger.NewErrorTrackingHook()
+		errorTrackingHook.SetWebhookURL(cfg.Logging.ErrorWebhookURL)
+		errorTrackingHook.SetAlertCooldown(cfg.Logging.AlertCooldown)
+		errorTrackingHook.SetAlertThresholds(cfg.Logging.AlertThresholds)
+		structuredLogger.AddHook(errorTrackingHook)
+	}
+
 	if cfg.Logging.SlackWebhookURL != "" {
 		slackHook := logger.NewSlackHook(cfg.Logging.SlackWebhookURL)
 		structuredLogger.AddHook(slackHook)
@@ -72,64 +126,167 @@ func Setup(cfg *config.Config) {
 
 	appLogger := structuredLogger.WithComponent("startup")
 	appLogger.Info("API Gateway starting", map[string]interface{}{
-		"version":      "1.0.0",
+		"version":      version.Version,
+		"commit":       version.Commit,
+		"build_date":   version.BuildDate,
 		"environment":  os.Getenv("ENVIRONMENT"),
-		"log_format":   "json",
+		"log_format":   cfg.Logging.Format,
 		"log_level":    cfg.Logging.Level,
 		"startup_time": time.Now().UTC(),
 	})
 
-	// Initialize discovery manager
-	discoveryManager := services.NewDiscoveryManager(cfg)
+	// Initialize discovery manager; it starts once the route managers are listening to its events
+	discoveryManager := services.NewDiscoveryManager(cfg, structuredLogger)
 	discoveryLogger := structuredLogger.WithComponent("discovery")
 
-	if err := discoveryManager.Start(ctx); err != nil {
-		appLogger.Fatal("Failed to start discovery manager", map[string]interface{}{
-			"error": err,
-		})
-	}
-
-	discoveryLogger.Info("Discovery manager started successfully")
-
 	// Initialize JWT service
 	jwtService := jwt.NewService(cfg.JWT)
 	authMiddleware := middleware.NewAuthMiddleware(jwtService)
+	authMiddleware.SetProviders(jwt.NewProviders(cfg.JWT, jwtService))
+	if cfg.JWT.CookieName != "" {
+		authMiddleware.SetTokenCookie(cfg.JWT.CookieName)
+	}
 
 	// Create router
 	r := mux.NewRouter()
 
 	// Apply middlewares in order
-	r.Use(middleware.NewRequestIDMiddleware().Middleware)
-	r.Use(middleware.NewPanicRecoveryMiddleware(structuredLogger).Middleware)
-	r.Use(middleware.NewStructuredLoggingMiddleware(structuredLogger).Middleware)
+	chain := &middlewareChain{router: r}
+	clientIP, err := middleware.NewClientIPMiddleware(cfg.Server.TrustedProxies)
+	if err != nil {
+		appLogger.Fatal("Invalid trusted proxy configuration", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	chain.Use(clientIP.Middleware)
+	chain.Use(middleware.NewHeaderLimitMiddleware(cfg.Server.MaxHeaderCount, cfg.Server.MaxHeaderBytes).Middleware)
+	if cfg.Middleware.RequestID {
+		requestID := middleware.NewRequestIDMiddleware()
+		if cfg.Middleware.CorrelationIDKey != "" {
+			requestID.SetSigningKey([]byte(cfg.Middleware.CorrelationIDKey))
+		}
+		chain.Use(requestID.Middleware)
+	}
+	if cfg.Middleware.ClientCert {
+		chain.Use(middleware.NewClientCertMiddleware().Middleware)
+	}
+	panicRecovery := middleware.NewPanicRecoveryMiddleware(structuredLogger)
+	panicRecovery.SetGoroutineDumpThreshold(cfg.Middleware.PanicGoroutineDumpThreshold)
+	chain.Use(panicRecovery.Middleware)
+	var registry *gatewaymetrics.Registry
+	if recorder == nil {
+		registry = gatewaymetrics.NewRegistry()
+		recorder = registry
+	}
+
+	requestLogging := middleware.NewStructuredLoggingMiddlewareWithConfig(structuredLogger, middleware.LoggingMiddlewareConfig{
+		LogRequests:          cfg.Logging.LogRequests,
+		LogResponses:         cfg.Logging.LogResponses,
+		LogHeaders:           cfg.Logging.LogHeaders,
+		SensitiveHeaders:     cfg.Logging.SensitiveHeaders,
+		SlowRequestThreshold: cfg.Logging.SlowRequestThreshold,
+		Metrics:              recorder,
+		AccessLog:            openAccessLog(cfg.Logging, appLogger),
+		MaxLoggedHeaders:     cfg.Logging.MaxLoggedHeaders,
+	})
+	if cfg.Middleware.RequestLogging {
+		chain.Use(requestLogging.Middleware)
+	}
+
+	// Admin API access control
+	if cfg.Admin.AuthEnabled && cfg.Admin.Token == "" {
+		appLogger.Warn("ADMIN_TOKEN not set, admin endpoints will refuse all requests")
+	}
+	chain.Use(middleware.NewAdminAuthMiddleware(cfg.Admin.AuthEnabled, cfg.Admin.Token).Middleware)
 
 	// Rate limiting
-	rateLimiter := middleware.NewRateLimiter(
-		rate.Limit(cfg.Rate.Limit),
-		cfg.Rate.BurstLimit,
-		cfg.Rate.CleanupInterval,
-	)
-	r.Use(rateLimiter.Middleware)
+	rateLimiter, err := setupRateLimiter(chain, cfg, jwtService)
+	if err != nil {
+		appLogger.Fatal("Invalid rate limit exemptions", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	// Total request budget; dynamic routes can override it once they are set up
+	requestTimeout := middleware.NewTimeoutMiddleware(cfg.Server.RequestTimeout)
+	chain.Use(requestTimeout.Middleware)
+	if cfg.Server.RequestTimeout > 0 && cfg.Server.WriteTimeout > 0 && cfg.Server.RequestTimeout >= cfg.Server.WriteTimeout {
+		appLogger.Warn("REQUEST_TIMEOUT is not below WRITE_TIMEOUT, timed out requests may not get a 504", map[string]interface{}{
+			"request_timeout": cfg.Server.RequestTimeout,
+			"write_timeout":   cfg.Server.WriteTimeout,
+		})
+	}
+
+	disabled := disabledMiddlewares(cfg.Middleware)
+	if len(disabled) > 0 {
+		appLogger.Warn("Middlewares disabled by configuration", map[string]interface{}{
+			"middlewares": disabled,
+		})
+	}
+
+	// Readiness checks are registered by the components that own them
+	readiness := handlers.NewReadiness()
+	readiness.Register(handlers.NewCheck("discovery_started", discoveryManager.CheckStarted))
+	readiness.Register(handlers.NewCheck("discovery_cache_synced", discoveryManager.CheckCacheSynced))
+
+	// Components with their own gauges register as metrics collectors
+	metrics := handlers.NewMetrics()
+	if registry != nil {
+		metrics.Register(registry)
+	}
+	metrics.Register(metricsHook)
+	if errorTrackingHook != nil {
+		metrics.Register(errorTrackingHook)
+	}
+
+	// Upstream proxy failures are counted by service and error type for both routing paths
+	upstreamErrors := gatewayproxy.NewErrorCounter()
+	metrics.Register(upstreamErrors)
 
 	// Setup routes
-	setupRoutes(r, cfg, authMiddleware, jwtService, discoveryManager, structuredLogger)
+	dynamicRouteManager, healthManager := setupRoutes(r, cfg, authMiddleware, jwtService, discoveryManager, readiness, metrics, upstreamErrors, recorder, structuredLogger)
+	chain.wrapUnmatched()
+	requestTimeout.SetRouteTimeouts(func(r *http.Request) (time.Duration, bool) {
+		if dynamicRouteManager != nil {
+			return dynamicRouteManager.RequestTimeout(r)
+		}
+		return 0, false
+	})
+
+	// Discovery warms up in the background; /health and /livez answer meanwhile
+	if err := discoveryManager.Start(ctx); err != nil {
+		appLogger.Fatal("Failed to start discovery manager", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	if cfg.Health.StartupSelfTest {
+		go runStartupSelfTest(healthManager, discoveryManager, cfg.Health.Timeout, structuredLogger)
+	}
 
-	// Initialize dynamic route manager
-	dynamicRouteManager := services.NewDynamicRouteManager(r, discoveryManager, authMiddleware)
-	_ = dynamicRouteManager
+	discoveryLogger.Info("Discovery manager started, readiness waits for its caches to sync")
 
 	// Create HTTP server
-	server := &http.Server{
-		Addr:         cfg.Server.Port,
-		Handler:      r,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+	server := newHTTPServer(cfg.Server, r)
+
+	if cfg.Server.TLSEnabled() {
+		tlsConfig, err := buildServerTLSConfig(cfg.Server)
+		if err != nil {
+			appLogger.Fatal("Failed to configure TLS", map[string]interface{}{
+				"error": err,
+			})
+		}
+		server.TLSConfig = tlsConfig
 	}
 
 	appLogger.Info("API Gateway configuration loaded", map[string]interface{}{
 		"port":              cfg.Server.Port,
+		"tls":               cfg.Server.TLSEnabled(),
+		"mtls":              cfg.Server.TLSRequireClientCert,
 		"read_timeout":      cfg.Server.ReadTimeout,
 		"write_timeout":     cfg.Server.WriteTimeout,
+		"h2c":               cfg.Server.H2C,
 		"kubernetes":        cfg.Kubernetes.Enabled,
 		"service_discovery": cfg.Kubernetes.ServiceDiscovery,
 		"namespace":         cfg.Kubernetes.Namespace,
@@ -141,7 +298,13 @@ func Setup(cfg *config.Config) {
 			"address": cfg.Server.Port,
 		})
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			appLogger.Fatal("Failed to start HTTP server", map[string]interface{}{
 				"error": err,
 			})
@@ -152,6 +315,9 @@ func Setup(cfg *config.Config) {
 		"port": cfg.Server.Port,
 	})
 
+	// SIGHUP reloads the settings that can change without a restart
+	newReloader(cfg, structuredLogger, rateLimiter, requestLogging).watch()
+
 	// Wait for shutdown signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -163,6 +329,9 @@ func Setup(cfg *config.Config) {
 
 	// Graceful shutdown
 	discoveryManager.Stop()
+	if healthManager != nil {
+		healthManager.StopHealthChecks()
+	}
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -174,31 +343,166 @@ func Setup(cfg *config.Config) {
 	} else {
 		appLogger.Info("Server shutdown completed successfully")
 	}
+
+	// Flush any log entries still queued for async hooks
+	structuredLogger.Close()
+}
+
+// openAccessLog opens the configured access log destination, or returns nil when the
+// access log is off. A file that can't be opened is logged and the access log disabled.
+func openAccessLog(cfg config.LoggingConfig, appLogger *logger.Logger) *middleware.AccessLog {
+	if cfg.AccessLogFormat == "" {
+		return nil
+	}
+
+	var out io.Writer
+	switch cfg.AccessLogOutput {
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.AccessLogOutput, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			appLogger.Error("Failed to open access log, access logging disabled", map[string]interface{}{
+				"path":  cfg.AccessLogOutput,
+				"error": err,
+			})
+			return nil
+		}
+		out = file
+	}
+
+	appLogger.Info("Access log enabled", map[string]interface{}{
+		"format": cfg.AccessLogFormat,
+		"output": cfg.AccessLogOutput,
+	})
+	return middleware.NewAccessLog(cfg.AccessLogFormat, out)
 }
 
-// setupRoutes configures both static and dynamic routes with logging
+// setupRateLimiter builds the rate limiter and adds it to the chain unless rate
+// limiting is disabled; a disabled limiter is still returned so reloads can adjust it
+func setupRateLimiter(chain *middlewareChain, cfg *config.Config, jwtService *jwt.Service) (*middleware.RateLimiter, error) {
+	rateLimiter := middleware.NewRateLimiter(
+		rate.Limit(cfg.Rate.Limit),
+		cfg.Rate.BurstLimit,
+		cfg.Rate.CleanupInterval,
+		cfg.Rate.ClientTTL,
+	)
+	if cfg.Rate.UserLimit > 0 {
+		rateLimiter.EnableUserLimits(middleware.JWTUserIdentifier(jwtService), rate.Limit(cfg.Rate.UserLimit), cfg.Rate.UserBurstLimit)
+	}
+	if err := rateLimiter.SetExemptions(cfg.Rate.ExemptPaths, cfg.Rate.ExemptCIDRs); err != nil {
+		return nil, err
+	}
+	if cfg.Middleware.RateLimiting {
+		chain.Use(rateLimiter.Middleware)
+	}
+	return rateLimiter, nil
+}
+
+// disabledMiddlewares lists the optional middlewares turned off in cfg
+func disabledMiddlewares(cfg config.MiddlewareConfig) []string {
+	var disabled []string
+	if !cfg.RequestID {
+		disabled = append(disabled, "request_id")
+	}
+	if !cfg.ClientCert {
+		disabled = append(disabled, "client_cert")
+	}
+	if !cfg.RequestLogging {
+		disabled = append(disabled, "request_logging")
+	}
+	if !cfg.RateLimiting {
+		disabled = append(disabled, "rate_limiting")
+	}
+	return disabled
+}
+
+// newHTTPServer builds the gateway's HTTP server with the configured timeouts
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes, // 0 keeps net/http's 1 MB default
+	}
+
+	if cfg.H2C {
+		// HTTP/2 over TLS is negotiated automatically; h2c has to be switched on
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		server.Protocols = protocols
+	}
+	return server
+}
+
+// buildServerTLSConfig builds the gateway's TLS settings, loading the client CA pool for mTLS
+func buildServerTLSConfig(cfg config.ServerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CA bundle %s contains no certificates", cfg.TLSClientCAFile)
+	}
+
+	tlsConfig.ClientCAs = pool
+	if cfg.TLSRequireClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// setupRoutes configures both static and dynamic routes with logging. It returns the
+// dynamic route manager when routes are discovered, or the static health manager otherwise.
 func setupRoutes(r *mux.Router, cfg *config.Config, authMiddleware *middleware.AuthMiddleware,
-	jwtService *jwt.Service, discoveryManager *services.DiscoveryManager, structuredLogger *logger.Logger) {
+	jwtService *jwt.Service, discoveryManager *services.DiscoveryManager, readiness *handlers.Readiness, metrics *handlers.Metrics,
+	upstreamErrors *gatewayproxy.ErrorCounter, recorder gatewaymetrics.Recorder, structuredLogger *logger.Logger) (*services.DynamicRouteManager, *HealthManager) {
 
 	routerLogger := structuredLogger.WithComponent("router")
 
-	setupCoreRoutes(r, jwtService, structuredLogger)
-	setupDiscoveryRoutes(r, discoveryManager, structuredLogger)
-
-	// Enhanced dynamic route manager
+	liveness := handlers.NewLiveness(discoveryManager.CheckLive)
+	setupCoreRoutes(r, jwtService, readiness, liveness, metrics, structuredLogger)
+	// Enhanced dynamic route manager, created below once the discovery admin routes are registered
 	var dynamicRouteManager *services.DynamicRouteManager
+	drained := func(serviceName string) bool {
+		return dynamicRouteManager != nil && dynamicRouteManager.IsDrained(serviceName)
+	}
+	setupDiscoveryRoutes(r, discoveryManager, drained, structuredLogger)
+
+	var healthManager *HealthManager
 
 	if !cfg.Kubernetes.ServiceDiscovery {
 		routerLogger.Info("Service discovery disabled, using static route configuration")
-		setupStaticRoutes(r, cfg, authMiddleware, structuredLogger)
+		healthManager = setupStaticRoutes(r, cfg, authMiddleware, readiness, upstreamErrors, structuredLogger)
 	} else {
 		routerLogger.Info("Service discovery enabled, routes will be managed dynamically")
 
 		// Create enhanced dynamic route manager
-		dynamicRouteManager = services.NewDynamicRouteManager(r, discoveryManager, authMiddleware)
+		dynamicRouteManager = services.NewDynamicRouteManager(r, discoveryManager, authMiddleware, upstreamErrors, recorder, structuredLogger)
 
 		// Setup admin endpoints for the enhanced features
 		dynamicRouteManager.SetupAdminEndpoints(r)
+		metrics.Register(dynamicRouteManager)
 
 		routerLogger.Info("Enhanced dynamic route manager initialized with load balancing and circuit breaking")
 	}
@@ -206,11 +510,33 @@ func setupRoutes(r *mux.Router, cfg *config.Config, authMiddleware *middleware.A
 	// Enhanced 404 handler with logging
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		contextLogger := structuredLogger.WithContext(r.Context()).WithComponent("router")
+
+		if discoveryManager.RespondIfSyncing(w) {
+			contextLogger.Info("Route not found while discovery is syncing", map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			})
+			return
+		}
+
+		// A path routed for other methods only gets a 405 listing them
+		if dynamicRouteManager != nil && dynamicRouteManager.RespondIfMethodNotAllowed(w, r) {
+			contextLogger.Warn("Method not allowed", map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			})
+			return
+		}
+
+		if dynamicRouteManager != nil && dynamicRouteManager.ServeDefaultBackend(w, r) {
+			return
+		}
+
 		contextLogger.Warn("Route not found", map[string]interface{}{
 			"method": r.Method,
 			"path":   r.URL.Path,
 		})
-		http.Error(w, "Not Found", http.StatusNotFound)
+		services.WriteNotFound(w, r)
 	})
 
 	// Enhanced 405 handler with logging
@@ -224,26 +550,31 @@ func setupRoutes(r *mux.Router, cfg *config.Config, authMiddleware *middleware.A
 	})
 
 	routerLogger.Info("All routes configured successfully")
+	return dynamicRouteManager, healthManager
 }
 
 // setupCoreRoutes sets up core API endpoints with logging
-func setupCoreRoutes(r *mux.Router, jwtService *jwt.Service, structuredLogger *logger.Logger) {
+func setupCoreRoutes(r *mux.Router, jwtService *jwt.Service, readiness *handlers.Readiness, liveness *handlers.Liveness, metrics *handlers.Metrics, structuredLogger *logger.Logger) {
 	coreLogger := structuredLogger.WithComponent("core_routes")
 
 	loginHandler := handlers.NewLoginHandler(jwtService)
 	r.HandleFunc("/login", loginHandler.Handle).Methods("POST")
 
 	r.HandleFunc("/health", handlers.HealthHandler).Methods("GET")
-	r.HandleFunc("/ready", handlers.ReadinessHandler).Methods("GET")
-	r.HandleFunc("/metrics", handlers.MetricsHandler).Methods("GET")
+	r.HandleFunc("/health/detail", readiness.Handle).Methods("GET")
+	r.HandleFunc("/ready", readiness.Handle).Methods("GET")
+	r.HandleFunc("/livez", liveness.Handle).Methods("GET")
+	r.HandleFunc("/metrics", metrics.Handle).Methods("GET")
 
 	coreLogger.Info("Core routes registered", map[string]interface{}{
-		"routes": []string{"/login", "/health", "/ready", "/metrics"},
+		"routes": []string{"/login", "/health", "/health/detail", "/ready", "/livez", "/metrics"},
 	})
 }
 
-// setupDiscoveryRoutes sets up service discovery and admin endpoints with logging
-func setupDiscoveryRoutes(r *mux.Router, discoveryManager *services.DiscoveryManager, structuredLogger *logger.Logger) {
+// setupDiscoveryRoutes sets up service discovery and admin endpoints with logging;
+// drained reports services draining through /admin/services/{name}/drain
+func setupDiscoveryRoutes(r *mux.Router, discoveryManager *services.DiscoveryManager, drained func(serviceName string) bool,
+	structuredLogger *logger.Logger) {
 	discoveryLogger := structuredLogger.WithComponent("discovery_routes")
 
 	r.HandleFunc("/admin/services", func(w http.ResponseWriter, r *http.Request) {
@@ -258,7 +589,9 @@ func setupDiscoveryRoutes(r *mux.Router, discoveryManager *services.DiscoveryMan
 				"name":           service.Name,
 				"namespace":      service.Namespace,
 				"path":           service.Path,
+				"paths":          service.Paths,
 				"method":         service.Method,
+				"methods":        service.Methods,
 				"auth_required":  service.AuthRequired,
 				"load_balancing": service.LoadBalancing,
 				"endpoints":      service.Endpoints,
@@ -292,6 +625,9 @@ func setupDiscoveryRoutes(r *mux.Router, discoveryManager *services.DiscoveryMan
 				"namespace":     route.Namespace,
 				"auth_required": route.AuthRequired,
 				"endpoints":     len(route.Endpoints),
+				"ready":         route.Service != nil && route.Service.Ready,
+				"held":          route.Service != nil && route.Service.Held(),
+				"drained":       drained(route.ServiceName),
 				"last_updated":  route.LastUpdated,
 			}
 		}
@@ -327,56 +663,138 @@ func setupDiscoveryRoutes(r *mux.Router, discoveryManager *services.DiscoveryMan
 	})
 }
 
-// setupStaticRoutes sets up legacy static routes from gateway.yaml with logging
-func setupStaticRoutes(r *mux.Router, cfg *config.Config, authMiddleware *middleware.AuthMiddleware, structuredLogger *logger.Logger) {
+// setupStaticRoutes sets up legacy static routes from gateway.yaml with logging, returning
+// the health manager checking their targets so it can be stopped on shutdown
+func setupStaticRoutes(r *mux.Router, cfg *config.Config, authMiddleware *middleware.AuthMiddleware, readiness *handlers.Readiness,
+	upstreamErrors *gatewayproxy.ErrorCounter, structuredLogger *logger.Logger) *HealthManager {
 	staticLogger := structuredLogger.WithComponent("static_routes")
 
 	pr := getProxyRoutes(structuredLogger)
 
+	// Static targets carry their own scheme; the transport supplies the upstream TLS settings
+	transport, err := gatewayproxy.NewUpstreamTransport(gatewayproxy.UpstreamTransportConfig{
+		InsecureSkipVerify:  cfg.Proxy.UpstreamInsecureSkipVerify,
+		CAFile:              cfg.Proxy.UpstreamCAFile,
+		MaxIdleConns:        cfg.Proxy.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.Proxy.MaxIdleConnsPerHost,
+	})
+	if err != nil {
+		staticLogger.Error("Failed to configure upstream transport, using defaults", map[string]interface{}{
+			"error": err,
+		})
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+
 	healthManager := NewHealthManager(cfg.Health.CheckInterval, cfg.Health.Timeout, structuredLogger)
-	healthManager.StartHealthChecks(pr.Routes)
+	healthManager.client.Transport = transport
+	healthManager.SetProbe(cfg.Health.Path, healthCheckMethod(cfg.Health), healthyStatus(cfg.Health))
 
-	pr.registerProxies(r, healthManager, authMiddleware, structuredLogger)
+	if cfg.Health.ReadinessBackends {
+		readiness.Register(handlers.NewCheck("static_backends", healthManager.CheckBackends))
+	}
+
+	loadBalancers := services.NewLoadBalancerManager()
+	loadBalancers.SetDefaultStrategy(cfg.Proxy.LoadBalancing)
+
+	// Proxies subscribe to health checks, so they are registered before checks start
+	pr.registerProxies(r, healthManager, loadBalancers, authMiddleware, transport, cfg.Proxy.MaxBodyBytes,
+		upstreamErrors, structuredLogger)
+	healthManager.StartHealthChecks(pr.Routes)
 
 	staticLogger.Info("Static routes configuration completed", map[string]interface{}{
 		"route_count": len(pr.Routes),
 	})
+	return healthManager
+}
+
+// healthCheckMethod returns the configured health check method, GET if it is invalid
+func healthCheckMethod(cfg config.HealthConfig) string {
+	if method, err := gatewayproxy.ParseHealthCheckMethod(cfg.Method); err == nil {
+		return method
+	}
+	return http.MethodGet
+}
+
+// healthyStatus returns the configured healthy status range, 2xx/3xx if it is invalid
+func healthyStatus(cfg config.HealthConfig) gatewayproxy.StatusRange {
+	if healthy, err := gatewayproxy.ParseStatusRange(cfg.ExpectedStatus); err == nil {
+		return healthy
+	}
+	return gatewayproxy.DefaultHealthyStatus
 }
 
 // NewHealthManager creates a health manager with logging
 func NewHealthManager(interval, timeout time.Duration, structuredLogger *logger.Logger) *HealthManager {
+	return NewHealthManagerWithClock(interval, timeout, structuredLogger, clock.Real{})
+}
+
+// NewHealthManagerWithClock creates a health manager driven by the given clock
+func NewHealthManagerWithClock(interval, timeout time.Duration, structuredLogger *logger.Logger, clk clock.Clock) *HealthManager {
 	return &HealthManager{
 		statuses:      make(map[string]bool),
 		client:        &http.Client{Timeout: timeout},
 		checkInterval: interval,
+		path:          "/health",
+		method:        http.MethodGet,
+		healthy:       gatewayproxy.DefaultHealthyStatus,
 		stopCh:        make(chan struct{}),
 		logger:        structuredLogger.WithComponent("health_manager"),
+		clock:         clk,
 	}
 }
 
-func (hm *HealthManager) StartHealthChecks(routes []struct {
-	Path         string `yaml:"path"`
-	Method       string `yaml:"method"`
-	TargetUrl    string `yaml:"target_url"`
-	AuthRequired bool   `yaml:"auth_required"`
-}) {
-	uniqueTargets := make(map[string]struct{})
+// SetProbe sets how targets are checked: the default path appended to each target,
+// the request method and the status range counted as healthy
+func (hm *HealthManager) SetProbe(path, method string, healthy gatewayproxy.StatusRange) {
+	hm.path = path
+	hm.method = method
+	hm.healthy = healthy
+}
+
+// OnCheck registers fn to receive every health check result; call it before StartHealthChecks
+func (hm *HealthManager) OnCheck(fn func(targetURL string, healthy bool)) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.listeners = append(hm.listeners, fn)
+}
+
+func (hm *HealthManager) StartHealthChecks(routes []StaticRoute) {
+	// A target shared by several routes uses the first health check path override among them
+	uniqueTargets := make(map[string]string)
 	for _, route := range routes {
-		uniqueTargets[route.TargetUrl] = struct{}{}
+		for _, target := range route.targets() {
+			path, seen := uniqueTargets[target]
+			if !seen {
+				path = hm.path
+			}
+			if route.HealthCheckPath != "" && path == hm.path {
+				path = route.HealthCheckPath
+			}
+			uniqueTargets[target] = path
+		}
+	}
+	for targetURL, path := range uniqueTargets {
+		uniqueTargets[targetURL] = strings.TrimSuffix(targetURL, "/") + path
 	}
 
+	hm.mu.Lock()
+	hm.targets = uniqueTargets
+	hm.mu.Unlock()
+
 	hm.logger.Info("Starting health checks", map[string]interface{}{
 		"target_count": len(uniqueTargets),
 		"interval":     hm.checkInterval,
 	})
 
-	for targetURL := range uniqueTargets {
-		go hm.checkTargetHealth(targetURL)
+	for targetURL, healthCheckURL := range uniqueTargets {
+		hm.wg.Add(1)
+		go hm.checkTargetHealth(targetURL, healthCheckURL)
 	}
 }
 
-func (hm *HealthManager) checkTargetHealth(targetURL string) {
-	ticker := time.NewTicker(hm.checkInterval)
+func (hm *HealthManager) checkTargetHealth(targetURL, healthCheckURL string) {
+	defer hm.wg.Done()
+	ticker := hm.clock.NewTicker(hm.checkInterval)
 	defer ticker.Stop()
 
 	hm.logger.Debug("Health check started for target", map[string]interface{}{
@@ -385,8 +803,8 @@ func (hm *HealthManager) checkTargetHealth(targetURL string) {
 
 	for {
 		select {
-		case <-ticker.C:
-			hm.performCheck(targetURL)
+		case <-ticker.C():
+			hm.performCheck(targetURL, healthCheckURL)
 		case <-hm.stopCh:
 			hm.logger.Debug("Health check stopped for target", map[string]interface{}{
 				"target_url": targetURL,
@@ -396,19 +814,22 @@ func (hm *HealthManager) checkTargetHealth(targetURL string) {
 	}
 }
 
-func (hm *HealthManager) performCheck(targetURL string) {
-	healthCheckURL := targetURL + "/health"
-
-	start := time.Now()
-	resp, err := hm.client.Get(healthCheckURL)
-	duration := time.Since(start)
+// performCheck probes a target and records its status, returning why it is unhealthy
+func (hm *HealthManager) performCheck(targetURL, healthCheckURL string) error {
+	start := hm.clock.Now()
+	var resp *http.Response
+	req, err := http.NewRequest(hm.method, healthCheckURL, nil)
+	if err == nil {
+		resp, err = hm.client.Do(req)
+	}
+	duration := hm.clock.Since(start)
 
 	isHealthy := false
 	statusCode := 0
 
 	if resp != nil {
 		statusCode = resp.StatusCode
-		isHealthy = err == nil && resp.StatusCode >= 200 && resp.StatusCode < 400
+		isHealthy = err == nil && hm.healthy.Contains(resp.StatusCode)
 		resp.Body.Close()
 	}
 
@@ -416,8 +837,13 @@ func (hm *HealthManager) performCheck(targetURL string) {
 	hm.mu.Lock()
 	previousStatus := hm.statuses[targetURL]
 	hm.statuses[targetURL] = isHealthy
+	listeners := hm.listeners
 	hm.mu.Unlock()
 
+	for _, listener := range listeners {
+		listener(targetURL, isHealthy)
+	}
+
 	// Log health check result
 	fields := map[string]interface{}{
 		"target_url":  targetURL,
@@ -443,6 +869,15 @@ func (hm *HealthManager) performCheck(targetURL string) {
 	} else {
 		hm.logger.Debug("Service health check successful", fields)
 	}
+
+	switch {
+	case isHealthy:
+		return nil
+	case err != nil:
+		return err
+	default:
+		return fmt.Errorf("unhealthy status %d", statusCode)
+	}
 }
 
 func (hm *HealthManager) IsHealthy(targetURL string) bool {
@@ -451,39 +886,134 @@ func (hm *HealthManager) IsHealthy(targetURL string) bool {
 	return hm.statuses[targetURL]
 }
 
+// CheckBackends is a readiness check that fails while any static backend is unhealthy
+func (hm *HealthManager) CheckBackends(ctx context.Context) error {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	var unhealthy []string
+	for targetURL, healthy := range hm.statuses {
+		if !healthy {
+			unhealthy = append(unhealthy, targetURL)
+		}
+	}
+
+	if len(unhealthy) > 0 {
+		sort.Strings(unhealthy)
+		return fmt.Errorf("unhealthy backends: %s", strings.Join(unhealthy, ", "))
+	}
+	return nil
+}
+
+// StopHealthChecks stops every target's check loop and waits for them to exit,
+// then closes the client's idle connections. It is safe to call more than once.
 func (hm *HealthManager) StopHealthChecks() {
-	hm.logger.Info("Stopping all health checks")
-	close(hm.stopCh)
+	hm.stopOnce.Do(func() {
+		hm.logger.Info("Stopping all health checks")
+		close(hm.stopCh)
+		hm.wg.Wait()
+		hm.client.CloseIdleConnections()
+	})
 }
 
-func (pr *ProxyRoute) registerProxies(r *mux.Router, hm *HealthManager, authMiddleware *middleware.AuthMiddleware, structuredLogger *logger.Logger) {
+// proxyStartKey carries when a static route request started, for the proxy's error handler
+type proxyStartKey struct{}
+
+// proxyWriterKey carries a static route request's response writer, so the
+// proxy can lift the write timeout when the upstream answers with an event stream
+type proxyWriterKey struct{}
+
+func (pr *ProxyRoute) registerProxies(r *mux.Router, hm *HealthManager, loadBalancers *services.LoadBalancerManager,
+	authMiddleware *middleware.AuthMiddleware, transport http.RoundTripper, maxBodyBytes int64,
+	upstreamErrors *gatewayproxy.ErrorCounter, structuredLogger *logger.Logger) {
 	proxyLogger := structuredLogger.WithComponent("proxy")
 
 	for _, route := range pr.Routes {
-		targetURL, err := url.Parse(route.TargetUrl)
+		methods, err := route.methods()
+		if err != nil {
+			proxyLogger.Error("Invalid route methods, skipping route", map[string]interface{}{
+				"path":  route.Path,
+				"error": err,
+			})
+			continue
+		}
+
+		forwardClientTLS := route.ForwardClientTLS
+		newProxy := func(targetURL *url.URL) *httputil.ReverseProxy {
+			proxy := httputil.NewSingleHostReverseProxy(targetURL)
+			proxy.Transport = transport
+
+			originalDirector := proxy.Director
+			proxy.Director = func(req *http.Request) {
+				originalDirector(req)
+				gatewayproxy.ApplyForwardedHeaders(req, middleware.FromTrustedProxy(req))
+				req.Host = targetURL.Host // Set original host for backend
+				gatewayproxy.ApplyClientTLSHeaders(req, forwardClientTLS)
+				gatewayproxy.ApplyTracingHeaders(req)
+			}
+
+			proxy.ModifyResponse = func(resp *http.Response) error {
+				w, _ := resp.Request.Context().Value(proxyWriterKey{}).(http.ResponseWriter)
+				if w != nil && gatewayproxy.IsEventStreamResponse(resp) {
+					// Event streams stay open far longer than the server's write timeout
+					http.NewResponseController(w).SetWriteDeadline(time.Time{})
+				}
+				return nil
+			}
+
+			// The proxy is shared by every request to the target, so request details come from the context
+			proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+				contextLogger := structuredLogger.WithContext(r.Context()).WithComponent("proxy")
+				if middleware.IsRequestBodyTooLarge(err) {
+					contextLogger.Warn("Request body too large", map[string]interface{}{
+						"method": r.Method,
+						"path":   r.URL.Path,
+					})
+					middleware.WriteRequestBodyTooLarge(w)
+					return
+				}
+
+				start, _ := r.Context().Value(proxyStartKey{}).(time.Time)
+				errorType := upstreamErrors.Record(targetURL.Host, err)
+				contextLogger.Error("Proxy request failed", map[string]interface{}{
+					"error":      err,
+					"error_type": errorType,
+					"method":     r.Method,
+					"path":       r.URL.Path,
+					"target_url": targetURL.String(),
+					"duration":   time.Since(start),
+				})
+				http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			}
+			return proxy
+		}
+
+		balancerName := strings.Join(methods, ",") + " " + route.Path
+		balancer, err := newStaticBalancer(balancerName, route, loadBalancers, hm, newProxy)
 		if err != nil {
-			proxyLogger.Error("Invalid target URL", map[string]interface{}{
+			proxyLogger.Error("Invalid route targets, skipping route", map[string]interface{}{
+				"path":       route.Path,
 				"target_url": route.TargetUrl,
 				"error":      err,
 			})
 			continue
 		}
 
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
 		// Enhanced proxy handler with detailed logging
 		proxyHandler := func(w http.ResponseWriter, req *http.Request) {
 			contextLogger := structuredLogger.WithContext(req.Context()).WithComponent("proxy")
 
-			if !hm.IsHealthy(targetURL.String()) {
+			target := balancer.next()
+			if target == nil {
 				contextLogger.Warn("Service unavailable - health check failed", map[string]interface{}{
-					"target_url": targetURL.String(),
+					"target_url": route.TargetUrl,
 					"method":     req.Method,
 					"path":       req.URL.Path,
 				})
 				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 				return
 			}
+			proxy, targetURL := target.proxy, target.url
 
 			start := time.Now()
 
@@ -493,26 +1023,15 @@ func (pr *ProxyRoute) registerProxies(r *mux.Router, hm *HealthManager, authMidd
 				"target_url": targetURL.String(),
 			})
 
-			// Set original host for backend
-			req.Host = targetURL.Host
-
-			// Custom error handler for proxy
-			proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-				duration := time.Since(start)
-				contextLogger.Error("Proxy request failed", map[string]interface{}{
-					"error":      err,
-					"method":     r.Method,
-					"path":       r.URL.Path,
-					"target_url": targetURL.String(),
-					"duration":   duration,
-				})
-				http.Error(w, "Bad Gateway", http.StatusBadGateway)
-			}
-
-			// Execute proxy
-			proxy.ServeHTTP(w, req)
+			// Execute proxy, counted in flight even if the proxy aborts the response
+			func() {
+				defer balancer.lb.BeginRequest(target.endpoint)()
+				ctx := context.WithValue(req.Context(), proxyStartKey{}, start)
+				proxy.ServeHTTP(w, req.WithContext(context.WithValue(ctx, proxyWriterKey{}, w)))
+			}()
 
 			duration := time.Since(start)
+			balancer.lb.RecordLatency(target.endpoint, duration)
 			contextLogger.Info("Proxy request completed", map[string]interface{}{
 				"method":     req.Method,
 				"path":       req.URL.Path,
@@ -521,20 +1040,37 @@ func (pr *ProxyRoute) registerProxies(r *mux.Router, hm *HealthManager, authMidd
 			})
 		}
 
+		bodyLimit := maxBodyBytes
+		if route.MaxBodyBytes > 0 {
+			bodyLimit = route.MaxBodyBytes
+		}
+
 		var currentHandler http.Handler = http.HandlerFunc(proxyHandler)
+		currentHandler = middleware.NewBodyLimitMiddleware(bodyLimit).Middleware(currentHandler)
 		currentHandler = authMiddleware.Middleware(route.AuthRequired)(currentHandler)
 
-		r.Handle(route.Path, currentHandler).Methods(route.Method)
+		r.Handle(route.Path, currentHandler).Methods(methods...)
 
 		proxyLogger.Info("Static route registered", map[string]interface{}{
-			"method":        route.Method,
+			"methods":       methods,
 			"path":          route.Path,
 			"target_url":    route.TargetUrl,
+			"strategy":      balancer.lb.GetStats().Strategy,
 			"auth_required": route.AuthRequired,
+			"forward_tls":   route.ForwardClientTLS,
+			"max_body":      bodyLimit,
 		})
 	}
 }
 
+// methods returns the methods a static route accepts, from methods or else method
+func (route StaticRoute) methods() ([]string, error) {
+	if len(route.Methods) > 0 {
+		return k8s.ParseMethods(strings.Join(route.Methods, ","))
+	}
+	return k8s.ParseMethods(route.Method)
+}
+
 func getProxyRoutes(structuredLogger *logger.Logger) ProxyRoute {
 	configLogger := structuredLogger.WithComponent("config")
 
@@ -543,12 +1079,7 @@ func getProxyRoutes(structuredLogger *logger.Logger) ProxyRoute {
 		configLogger.Warn("Could not read gateway.yaml, using empty configuration", map[string]interface{}{
 			"error": err,
 		})
-		return ProxyRoute{Routes: []struct {
-			Path         string `yaml:"path"`
-			Method       string `yaml:"method"`
-			TargetUrl    string `yaml:"target_url"`
-			AuthRequired bool   `yaml:"auth_required"`
-		}{}}
+		return ProxyRoute{Routes: []StaticRoute{}}
 	}
 
 	var pr ProxyRoute
@@ -556,12 +1087,7 @@ func getProxyRoutes(structuredLogger *logger.Logger) ProxyRoute {
 		configLogger.Error("Could not parse gateway.yaml", map[string]interface{}{
 			"error": err,
 		})
-		return ProxyRoute{Routes: []struct {
-			Path         string `yaml:"path"`
-			Method       string `yaml:"method"`
-			TargetUrl    string `yaml:"target_url"`
-			AuthRequired bool   `yaml:"auth_required"`
-		}{}}
+		return ProxyRoute{Routes: []StaticRoute{}}
 	}
 
 	configLogger.Info("Gateway configuration loaded", map[string]interface{}{
@@ -0,0 +1,601 @@
+package router
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/handlers"
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/internal/services"
+	"api-gateway/pkg/jwt"
+	"api-gateway/pkg/logger"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// newTestLogger returns a logger that only writes fatal entries
+func newTestLogger() *logger.Logger {
+	return logger.NewLogger(logger.Config{Level: "fatal"})
+}
+
+// refusedURL returns an http URL nothing listens on
+func refusedURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return "http://" + address
+}
+
+// newStaticRouter registers routes on a fresh router with every target in healthy
+// marked as passing health checks
+func newStaticRouter(t *testing.T, routes []StaticRoute, healthy ...string) (*mux.Router, *HealthManager, *gatewayproxy.ErrorCounter) {
+	t.Helper()
+	hm := NewHealthManager(time.Hour, time.Second, newTestLogger())
+	for _, target := range healthy {
+		hm.statuses[target] = true
+	}
+
+	r := mux.NewRouter()
+	upstreamErrors := gatewayproxy.NewErrorCounter()
+	pr := &ProxyRoute{Routes: routes}
+	pr.registerProxies(r, hm, services.NewLoadBalancerManager(), middleware.NewAuthMiddleware(jwt.NewService(config.Load().JWT)),
+		http.DefaultTransport, 0, upstreamErrors, newTestLogger())
+	return r, hm, upstreamErrors
+}
+
+func TestStaticRouteCountsUpstreamErrorsPerTarget(t *testing.T) {
+	first, second := refusedURL(t), refusedURL(t)
+	r, _, upstreamErrors := newStaticRouter(t, []StaticRoute{
+		{Path: "/first", Method: "GET", TargetUrl: first},
+		{Path: "/second", Method: "GET", TargetUrl: second},
+	}, first, second)
+
+	tests := []struct {
+		path     string
+		target   string
+		requests int
+	}{
+		{path: "/first", target: first, requests: 20},
+		{path: "/second", target: second, requests: 10},
+	}
+
+	var wg sync.WaitGroup
+	for _, tt := range tests {
+		for i := 0; i < tt.requests; i++ {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Code != http.StatusBadGateway {
+					t.Errorf("GET %s = %d, want %d", path, rec.Code, http.StatusBadGateway)
+				}
+			}(tt.path)
+		}
+	}
+	wg.Wait()
+
+	for _, tt := range tests {
+		host := tt.target[len("http://"):]
+		if got := upstreamErrors.Count(host, gatewayproxy.ErrorTypeDial); got != int64(tt.requests) {
+			t.Errorf("dial errors for %s = %d, want %d", tt.path, got, tt.requests)
+		}
+	}
+}
+
+func TestStaticRouteMethods(t *testing.T) {
+	tests := []struct {
+		name  string
+		route string // gateway.yaml route entry, minus target_url
+		want  map[string]int
+	}{
+		{
+			name:  "single method",
+			route: `{path: /orders, method: GET}`,
+			want:  map[string]int{http.MethodGet: http.StatusOK, http.MethodPost: http.StatusMethodNotAllowed},
+		},
+		{
+			name:  "wildcard",
+			route: `{path: /orders, method: "*"}`,
+			want:  map[string]int{http.MethodGet: http.StatusOK, http.MethodPost: http.StatusOK, http.MethodPatch: http.StatusOK, http.MethodOptions: http.StatusOK, http.MethodTrace: http.StatusMethodNotAllowed},
+		},
+		{
+			name:  "ANY",
+			route: `{path: /orders, method: ANY}`,
+			want:  map[string]int{http.MethodGet: http.StatusOK, http.MethodDelete: http.StatusOK},
+		},
+		{
+			name:  "comma-separated method",
+			route: `{path: /orders, method: "GET, OPTIONS"}`,
+			want:  map[string]int{http.MethodGet: http.StatusOK, http.MethodOptions: http.StatusOK, http.MethodPost: http.StatusMethodNotAllowed},
+		},
+		{
+			name:  "method list overrides method",
+			route: `{path: /orders, method: GET, methods: [POST, PATCH]}`,
+			want:  map[string]int{http.MethodPost: http.StatusOK, http.MethodPatch: http.StatusOK, http.MethodGet: http.StatusMethodNotAllowed},
+		},
+		{
+			name:  "unknown method skips the route",
+			route: `{path: /orders, methods: [GET, FETCH]}`,
+			want:  map[string]int{http.MethodGet: http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []string
+			var mu sync.Mutex
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				received = append(received, r.Method)
+				mu.Unlock()
+			}))
+			defer backend.Close()
+
+			var route StaticRoute
+			if err := yaml.Unmarshal([]byte(tt.route), &route); err != nil {
+				t.Fatalf("yaml: %v", err)
+			}
+			route.TargetUrl = backend.URL
+			r, _, _ := newStaticRouter(t, []StaticRoute{route}, backend.URL)
+
+			wantReceived := 0
+			for method, want := range tt.want {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(method, "/orders", nil))
+				if rec.Code != want {
+					t.Errorf("%s /orders = %d, want %d", method, rec.Code, want)
+				}
+				if want == http.StatusOK {
+					wantReceived++
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != wantReceived {
+				t.Errorf("target received %v, want %d requests", received, wantReceived)
+			}
+		})
+	}
+}
+
+func TestStaticRouteForwardsTracingIDs(t *testing.T) {
+	received := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(gatewayproxy.HeaderCorrelationID)
+	}))
+	defer backend.Close()
+
+	r, _, _ := newStaticRouter(t, []StaticRoute{{Path: "/orders", Method: "GET", TargetUrl: backend.URL}}, backend.URL)
+	handler := middleware.NewStructuredLoggingMiddleware(newTestLogger()).Middleware(r)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got, want := <-received, rec.Header().Get(gatewayproxy.HeaderCorrelationID); want == "" || got != want {
+		t.Errorf("upstream correlation ID = %q, client got %q", got, want)
+	}
+}
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, serial: 1}
+}
+
+// issue returns a certificate for commonName signed by the CA
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	ca.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writePEM writes a certificate, and its key when given, into dir and returns the paths
+func writePEM(t *testing.T, dir, name string, der []byte, key *ecdsa.PrivateKey) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", certFile, err)
+	}
+	if key == nil {
+		return certFile, ""
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", keyFile, err)
+	}
+	return certFile, keyFile
+}
+
+func TestGatewayServesTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile, _ := writePEM(t, dir, "ca", ca.cert.Raw, nil)
+	serverCert := ca.issue(t, "gateway", x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := writePEM(t, dir, "server", serverCert.Certificate[0], serverCert.PrivateKey.(*ecdsa.PrivateKey))
+	clientCert := ca.issue(t, "orders-client", x509.ExtKeyUsageClientAuth)
+	strangerCert := newTestCA(t).issue(t, "stranger", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name          string
+		mtls          bool
+		clientCert    *tls.Certificate
+		wantHandshake bool
+		wantSubject   string
+	}{
+		{name: "TLS without client certificates", wantHandshake: true},
+		{name: "mTLS with a trusted client certificate", mtls: true, clientCert: &clientCert, wantHandshake: true, wantSubject: "CN=orders-client"},
+		{name: "mTLS without a client certificate", mtls: true},
+		{name: "mTLS with an untrusted client certificate", mtls: true, clientCert: &strangerCert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.ServerConfig{TLSCertFile: certFile, TLSKeyFile: keyFile}
+			if tt.mtls {
+				cfg.TLSClientCAFile = caFile
+				cfg.TLSRequireClientCert = true
+			}
+			tlsConfig, err := buildServerTLSConfig(cfg)
+			if err != nil {
+				t.Fatalf("buildServerTLSConfig: %v", err)
+			}
+
+			handler := middleware.NewClientCertMiddleware().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, middleware.ClientCertSubject(r.Context()))
+			}))
+			server := newHTTPServer(cfg, handler)
+			server.TLSConfig = tlsConfig
+			server.ErrorLog = log.New(io.Discard, "", 0) // Rejected handshakes are expected
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			go server.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+			defer server.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.cert)
+			clientTLS := &tls.Config{RootCAs: roots}
+			if tt.clientCert != nil {
+				clientTLS.Certificates = []tls.Certificate{*tt.clientCert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+			resp, err := client.Get("https://" + listener.Addr().String() + "/")
+			if !tt.wantHandshake {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("request succeeded with status %d, want the handshake rejected", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET over TLS: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.wantSubject {
+				t.Errorf("client certificate subject = %q, want %q", body, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestBuildServerTLSConfigRejectsBadClientCA(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+
+	for _, caFile := range []string{notPEM, filepath.Join(dir, "missing.pem")} {
+		if _, err := buildServerTLSConfig(config.ServerConfig{TLSClientCAFile: caFile}); err == nil {
+			t.Errorf("buildServerTLSConfig(%s) succeeded, want an error", filepath.Base(caFile))
+		}
+	}
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	cfg := config.ServerConfig{
+		Port:              ":9090",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+	}
+	server := newHTTPServer(cfg, http.NotFoundHandler())
+
+	tests := []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{name: "ReadTimeout", got: server.ReadTimeout, want: cfg.ReadTimeout},
+		{name: "ReadHeaderTimeout", got: server.ReadHeaderTimeout, want: cfg.ReadHeaderTimeout},
+		{name: "WriteTimeout", got: server.WriteTimeout, want: cfg.WriteTimeout},
+		{name: "IdleTimeout", got: server.IdleTimeout, want: cfg.IdleTimeout},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if server.Addr != cfg.Port || server.MaxHeaderBytes != cfg.MaxHeaderBytes {
+		t.Errorf("Addr, MaxHeaderBytes = %q, %d, want %q, %d", server.Addr, server.MaxHeaderBytes, cfg.Port, cfg.MaxHeaderBytes)
+	}
+}
+
+func TestEventStreamOutlivesWriteTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		for i := 0; i < 4; i++ {
+			io.WriteString(w, "data: tick\n\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer backend.Close()
+
+	r, _, _ := newStaticRouter(t, []StaticRoute{{Path: "/events", Method: "GET", TargetUrl: backend.URL}}, backend.URL)
+	server := newHTTPServer(config.ServerConfig{WriteTimeout: 75 * time.Millisecond}, r)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		wantAll     bool
+	}{
+		{name: "upstream event stream", contentType: "text/event-stream", wantAll: true},
+		{name: "plain response is cut off", contentType: "application/json"},
+		{name: "client accept header is ignored", contentType: "application/json", accept: "text/event-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "http://" + listener.Addr().String() + "/events?type=" + tt.contentType
+			req, _ := http.NewRequest(http.MethodGet, target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET /events: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if events := strings.Count(string(body), "data: tick"); (events == 4) != tt.wantAll {
+				t.Errorf("got %d of 4 events, want all of them %v", events, tt.wantAll)
+			}
+		})
+	}
+}
+
+func TestHealthCheckProbe(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		healthy     string
+		routePath   string // Per-route HealthCheckPath override
+		handler     http.HandlerFunc
+		wantPath    string
+		wantHealthy bool
+	}{
+		{
+			name:        "HEAD check",
+			method:      http.MethodHead,
+			healthy:     "200-399",
+			handler:     func(w http.ResponseWriter, r *http.Request) {},
+			wantPath:    "/health",
+			wantHealthy: true,
+		},
+		{
+			name:        "204 counted as healthy",
+			method:      http.MethodGet,
+			healthy:     "204",
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantPath:    "/health",
+			wantHealthy: true,
+		},
+		{
+			name:     "200 outside a 204-only range",
+			method:   http.MethodGet,
+			healthy:  "204",
+			handler:  func(w http.ResponseWriter, r *http.Request) {},
+			wantPath: "/health",
+		},
+		{
+			name:     "500 outside the default range",
+			method:   http.MethodGet,
+			healthy:  "200-399",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			wantPath: "/health",
+		},
+		{
+			name:        "route path override",
+			method:      http.MethodHead,
+			healthy:     "204",
+			routePath:   "/healthz",
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantPath:    "/healthz",
+			wantHealthy: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type probe struct{ method, path string }
+			probes := make(chan probe, 1)
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				probes <- probe{r.Method, r.URL.Path}
+				tt.handler(w, r)
+			}))
+			defer backend.Close()
+
+			healthy, err := gatewayproxy.ParseStatusRange(tt.healthy)
+			if err != nil {
+				t.Fatalf("ParseStatusRange: %v", err)
+			}
+			hm := NewHealthManager(time.Hour, time.Second, newTestLogger())
+			hm.SetProbe("/health", tt.method, healthy)
+			hm.StartHealthChecks([]StaticRoute{{Path: "/orders", Method: "GET", TargetUrl: backend.URL, HealthCheckPath: tt.routePath}})
+			defer hm.StopHealthChecks()
+
+			hm.performCheck(backend.URL, hm.targets[backend.URL])
+			got := <-probes
+			if got.method != tt.method || got.path != tt.wantPath {
+				t.Errorf("probe = %s %s, want %s %s", got.method, got.path, tt.method, tt.wantPath)
+			}
+			if hm.IsHealthy(backend.URL) != tt.wantHealthy {
+				t.Errorf("healthy = %v, want %v", hm.IsHealthy(backend.URL), tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestHealthProbeConfigFallbacks(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.HealthConfig
+		wantMethod  string
+		wantHealthy gatewayproxy.StatusRange
+	}{
+		{
+			name:        "configured",
+			cfg:         config.HealthConfig{Method: "head", ExpectedStatus: "204"},
+			wantMethod:  http.MethodHead,
+			wantHealthy: gatewayproxy.StatusRange{Min: 204, Max: 204},
+		},
+		{
+			name:        "invalid values fall back to the defaults",
+			cfg:         config.HealthConfig{Method: "POST", ExpectedStatus: "2xx"},
+			wantMethod:  http.MethodGet,
+			wantHealthy: gatewayproxy.DefaultHealthyStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := healthCheckMethod(tt.cfg); got != tt.wantMethod {
+			t.Errorf("%s: method = %q, want %q", tt.name, got, tt.wantMethod)
+		}
+		if got := healthyStatus(tt.cfg); got != tt.wantHealthy {
+			t.Errorf("%s: healthy status = %v, want %v", tt.name, got, tt.wantHealthy)
+		}
+	}
+}
+
+// healthCheckGoroutines counts the goroutines StartHealthChecks spawned that are still alive
+func healthCheckGoroutines() int {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	return strings.Count(stacks, "created by api-gateway/internal/router.(*HealthManager).StartHealthChecks")
+}
+
+func TestStaticHealthChecksStopOnShutdown(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	// setupStaticRoutes reads configs/gateway.yaml from the working directory
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "configs"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	routes := "routes:\n" +
+		"  - path: /orders\n    method: GET\n    target_url: " + backend.URL + "\n" +
+		"  - path: /billing\n    method: GET\n    target_url: " + refusedURL(t) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "configs", "gateway.yaml"), []byte(routes), 0o600); err != nil {
+		t.Fatalf("write gateway.yaml: %v", err)
+	}
+	t.Chdir(dir)
+
+	before := healthCheckGoroutines()
+	cfg := config.Load()
+	cfg.Health.CheckInterval = 10 * time.Millisecond
+	hm := setupStaticRoutes(mux.NewRouter(), cfg, middleware.NewAuthMiddleware(jwt.NewService(cfg.JWT)),
+		handlers.NewReadiness(), gatewayproxy.NewErrorCounter(), newTestLogger())
+	if got := healthCheckGoroutines() - before; got != 2 {
+		t.Fatalf("health check goroutines = %d, want one per target", got)
+	}
+
+	hm.StopHealthChecks()
+	if got := healthCheckGoroutines() - before; got != 0 {
+		t.Errorf("%d health check goroutines still running after shutdown", got)
+	}
+	hm.StopHealthChecks() // A second stop is a no-op
+}
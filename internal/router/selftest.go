@@ -0,0 +1,132 @@
+package router
+
+import (
+	"api-gateway/internal/services"
+	"api-gateway/pkg/logger"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// selfTestSyncWait bounds how long the startup self-test waits for discovery to sync
+// before probing the endpoints known so far
+const selfTestSyncWait = time.Minute
+
+// selfTestConcurrency bounds how many targets the startup self-test probes at once
+const selfTestConcurrency = 16
+
+// selfTestResult is the outcome of probing one upstream at startup; err is nil when it is reachable
+type selfTestResult struct {
+	target string
+	err    error
+}
+
+// runStartupSelfTest probes each static target with its health check and dials each discovered
+// endpoint once, logging a summary so unreachable backends show up before the first request.
+// healthManager is nil when routes are discovered.
+func runStartupSelfTest(healthManager *HealthManager, discoveryManager *services.DiscoveryManager,
+	timeout time.Duration, structuredLogger *logger.Logger) {
+	selfTestLogger := structuredLogger.WithComponent("self_test")
+
+	if healthManager != nil {
+		logSelfTest(selfTestLogger, "static", healthManager.SelfTest())
+	}
+
+	if !discoveryManager.IsKubernetesEnabled() {
+		return
+	}
+	select {
+	case <-discoveryManager.Synced():
+	case <-time.After(selfTestSyncWait):
+		selfTestLogger.Warn("Discovery hasn't synced, probing the endpoints known so far", map[string]interface{}{
+			"waited": selfTestSyncWait,
+		})
+	}
+	logSelfTest(selfTestLogger, "discovered", probeTargets(discoveredTargets(discoveryManager), func(target string) error {
+		conn, err := net.DialTimeout("tcp", target, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}))
+}
+
+// SelfTest runs one health check of every target passed to StartHealthChecks
+func (hm *HealthManager) SelfTest() []selfTestResult {
+	hm.mu.RLock()
+	targets := make([]string, 0, len(hm.targets))
+	healthCheckURLs := make(map[string]string, len(hm.targets))
+	for targetURL, healthCheckURL := range hm.targets {
+		targets = append(targets, targetURL)
+		healthCheckURLs[targetURL] = healthCheckURL
+	}
+	hm.mu.RUnlock()
+
+	return probeTargets(targets, func(targetURL string) error {
+		return hm.performCheck(targetURL, healthCheckURLs[targetURL])
+	})
+}
+
+// discoveredTargets lists the unique host:port of every endpoint of the discovered services
+func discoveredTargets(discoveryManager *services.DiscoveryManager) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, service := range discoveryManager.GetDiscoveredServices() {
+		for _, endpoint := range service.Endpoints {
+			target := net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// probeTargets probes every target with bounded concurrency, returning the results sorted by target
+func probeTargets(targets []string, probe func(target string) error) []selfTestResult {
+	results := make([]selfTestResult, len(targets))
+	slots := make(chan struct{}, selfTestConcurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = selfTestResult{target: target, err: probe(target)}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].target < results[j].target
+	})
+	return results
+}
+
+// logSelfTest logs how many targets of a kind were reachable, warning with the failures when any weren't
+func logSelfTest(selfTestLogger *logger.Logger, kind string, results []selfTestResult) {
+	unreachable := make(map[string]string)
+	for _, result := range results {
+		if result.err != nil {
+			unreachable[result.target] = result.err.Error()
+		}
+	}
+
+	fields := map[string]interface{}{
+		"targets":     kind,
+		"total":       len(results),
+		"reachable":   len(results) - len(unreachable),
+		"unreachable": len(unreachable),
+	}
+	if len(unreachable) == 0 {
+		selfTestLogger.Info("Startup self-test passed", fields)
+		return
+	}
+	fields["failures"] = unreachable
+	selfTestLogger.Warn("Startup self-test found unreachable upstreams", fields)
+}
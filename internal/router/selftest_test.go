@@ -0,0 +1,113 @@
+package router
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/services"
+	"api-gateway/pkg/logger"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// entryHook keeps the entries it is fired for
+type entryHook struct {
+	mu      sync.Mutex
+	entries []*logger.LogEntry
+}
+
+func (h *entryHook) Fire(entry *logger.LogEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+	return nil
+}
+func (h *entryHook) Levels() []logger.LogLevel { return nil }
+func (h *entryHook) Synchronous() bool         { return true }
+
+func TestStartupSelfTestReportsUnreachableTargets(t *testing.T) {
+	reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer reachable.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	refused := refusedURL(t)
+
+	hm := NewHealthManager(time.Hour, time.Second, newTestLogger())
+	hm.StartHealthChecks([]StaticRoute{
+		{Path: "/orders", Method: "GET", TargetUrl: reachable.URL},
+		{Path: "/users", Method: "GET", TargetUrl: failing.URL},
+		{Path: "/billing", Method: "GET", TargetUrl: refused},
+		{Path: "/orders/{id}", Method: "GET", TargetUrl: reachable.URL},
+	})
+	defer hm.StopHealthChecks()
+
+	results := hm.SelfTest()
+	if len(results) != 3 {
+		t.Fatalf("results = %v, want one per unique target", results)
+	}
+	for _, result := range results {
+		if want := result.target == reachable.URL; (result.err == nil) != want {
+			t.Errorf("%s: err = %v, want reachable = %v", result.target, result.err, want)
+		}
+	}
+
+	hook := &entryHook{}
+	l := logger.NewLogger(logger.Config{Level: "info"})
+	l.AddHook(hook)
+	runStartupSelfTest(hm, services.NewDiscoveryManager(&config.Config{}, newTestLogger()), time.Second, l)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.entries) != 1 {
+		t.Fatalf("logged %d entries, want one summary", len(hook.entries))
+	}
+	entry := hook.entries[0]
+	if entry.Message != "Startup self-test found unreachable upstreams" {
+		t.Errorf("message = %q", entry.Message)
+	}
+	for field, want := range map[string]interface{}{"targets": "static", "total": 3, "reachable": 1, "unreachable": 2} {
+		if got := entry.Fields[field]; got != want {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+	failures, _ := entry.Fields["failures"].(map[string]string)
+	if _, exists := failures[reachable.URL]; exists || len(failures) != 2 {
+		t.Errorf("failures = %v, want the two unreachable targets", failures)
+	}
+}
+
+func TestProbeTargets(t *testing.T) {
+	unreachable := errors.New("connection refused")
+	targets := []string{"10.0.0.3:80", "10.0.0.1:80", "10.0.0.2:80"}
+	var mu sync.Mutex
+	probed := make(map[string]int)
+
+	results := probeTargets(targets, func(target string) error {
+		mu.Lock()
+		probed[target]++
+		mu.Unlock()
+		if target == "10.0.0.2:80" {
+			return unreachable
+		}
+		return nil
+	})
+
+	want := []selfTestResult{{target: "10.0.0.1:80"}, {target: "10.0.0.2:80", err: unreachable}, {target: "10.0.0.3:80"}}
+	if len(results) != len(want) {
+		t.Fatalf("results = %v, want %v", results, want)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %v, want %v", i, results[i], want[i])
+		}
+	}
+	for _, target := range targets {
+		if probed[target] != 1 {
+			t.Errorf("%s probed %d times, want once", target, probed[target])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/internal/services"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// staticTarget is one upstream of a static route
+type staticTarget struct {
+	raw      string // As written in target_url, the key health checks report it under
+	url      *url.URL
+	endpoint k8s.ServiceEndpoint
+	proxy    *httputil.ReverseProxy
+}
+
+// staticBalancer spreads a static route's requests over the targets passing health checks
+type staticBalancer struct {
+	lb      *services.LoadBalancer
+	targets map[string]*staticTarget // By endpoint host:port
+}
+
+// targets returns the upstream URLs of a static route, listed comma-separated in target_url
+func (route StaticRoute) targets() []string {
+	var targets []string
+	for _, target := range strings.Split(route.TargetUrl, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// strategy returns the route's load balancing strategy, empty for the manager's default
+func (route StaticRoute) strategy() (string, error) {
+	if route.Strategy != "" && !services.IsLoadBalancingStrategy(route.Strategy) {
+		return "", fmt.Errorf("unknown load balancing strategy %q", route.Strategy)
+	}
+	return route.Strategy, nil
+}
+
+// newStaticBalancer builds a balancer named name over the route's targets, each proxied
+// through newProxy. Targets start unhealthy and follow hm's checks from then on.
+func newStaticBalancer(name string, route StaticRoute, lbm *services.LoadBalancerManager, hm *HealthManager,
+	newProxy func(*url.URL) *httputil.ReverseProxy) (*staticBalancer, error) {
+	strategy, err := route.strategy()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := route.targets()
+	if len(targets) == 0 {
+		return nil, errors.New("no target_url")
+	}
+
+	sb := &staticBalancer{
+		lb:      lbm.GetOrCreateLoadBalancer(name, strategy),
+		targets: make(map[string]*staticTarget, len(targets)),
+	}
+	byURL := make(map[string]*staticTarget, len(targets))
+	endpoints := make([]k8s.ServiceEndpoint, 0, len(targets))
+
+	for _, raw := range targets {
+		targetURL, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target URL %q: %w", raw, err)
+		}
+		endpoint, err := targetEndpoint(targetURL)
+		if err != nil {
+			return nil, err
+		}
+		address := net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))
+		if _, duplicate := sb.targets[address]; duplicate {
+			return nil, fmt.Errorf("target %q repeats %s", raw, address)
+		}
+
+		target := &staticTarget{raw: raw, url: targetURL, endpoint: endpoint, proxy: newProxy(targetURL)}
+		sb.targets[address] = target
+		byURL[raw] = target
+		endpoints = append(endpoints, endpoint)
+	}
+
+	sb.lb.UpdateEndpoints(endpoints)
+	for _, target := range sb.targets {
+		sb.lb.SetEndpointHealth(target.endpoint, hm.IsHealthy(target.raw))
+	}
+	hm.OnCheck(func(targetURL string, healthy bool) {
+		if target, exists := byURL[targetURL]; exists {
+			sb.lb.SetEndpointHealth(target.endpoint, healthy)
+		}
+	})
+
+	return sb, nil
+}
+
+// next picks a healthy target, nil when none is
+func (sb *staticBalancer) next() *staticTarget {
+	endpoint := sb.lb.SelectEndpoint()
+	if endpoint.IP == "" {
+		return nil
+	}
+	return sb.targets[net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))]
+}
+
+// targetEndpoint returns the host and port a target URL is dialed at
+func targetEndpoint(targetURL *url.URL) (k8s.ServiceEndpoint, error) {
+	host := targetURL.Hostname()
+	if host == "" {
+		return k8s.ServiceEndpoint{}, fmt.Errorf("target URL %q has no host", targetURL)
+	}
+
+	port := 80
+	if gatewayproxy.NormalizeScheme(targetURL.Scheme) == gatewayproxy.SchemeHTTPS {
+		port = 443
+	}
+	if value := targetURL.Port(); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 65535 {
+			return k8s.ServiceEndpoint{}, fmt.Errorf("target URL %q has an invalid port", targetURL)
+		}
+		port = parsed
+	}
+
+	return k8s.ServiceEndpoint{IP: host, Port: int32(port), Ready: true, Weight: k8s.DefaultEndpointWeight}, nil
+}
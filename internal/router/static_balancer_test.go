@@ -0,0 +1,178 @@
+package router
+
+import (
+	"api-gateway/internal/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStaticBalancerTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       StaticRoute
+		wantTargets []string
+		wantErr     string
+	}{
+		{name: "single target", route: StaticRoute{TargetUrl: "http://orders:8080"}, wantTargets: []string{"http://orders:8080"}},
+		{
+			name:        "comma-separated targets",
+			route:       StaticRoute{TargetUrl: "http://orders-1:8080, http://orders-2:8080,,https://orders-3", Strategy: "weighted-round-robin"},
+			wantTargets: []string{"http://orders-1:8080", "http://orders-2:8080", "https://orders-3"},
+		},
+		{name: "no target", route: StaticRoute{TargetUrl: " , "}, wantErr: "no target_url"},
+		{name: "unknown strategy", route: StaticRoute{TargetUrl: "http://orders:8080", Strategy: "fastest"}, wantErr: "fastest"},
+		{name: "target without a host", route: StaticRoute{TargetUrl: "http://:8080"}, wantErr: "no host"},
+		{name: "invalid port", route: StaticRoute{TargetUrl: "http://orders:99999"}, wantErr: "invalid port"},
+		{name: "repeated address", route: StaticRoute{TargetUrl: "http://orders:80,http://orders"}, wantErr: "repeats orders:80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hm := NewHealthManager(time.Hour, time.Second, newTestLogger())
+			newProxy := func(target *url.URL) *httputil.ReverseProxy { return httputil.NewSingleHostReverseProxy(target) }
+			sb, err := newStaticBalancer(tt.name, tt.route, services.NewLoadBalancerManager(), hm, newProxy)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newStaticBalancer: %v", err)
+			}
+
+			var got []string
+			for _, raw := range tt.wantTargets {
+				for _, target := range sb.targets {
+					if target.raw == raw {
+						got = append(got, raw)
+					}
+				}
+			}
+			if len(sb.targets) != len(tt.wantTargets) || !reflect.DeepEqual(got, tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", got, tt.wantTargets)
+			}
+			// Targets are unhealthy until a health check passes
+			if target := sb.next(); target != nil {
+				t.Errorf("next = %s before any health check, want none", target.raw)
+			}
+		})
+	}
+}
+
+func TestStaticRouteBalancesTargets(t *testing.T) {
+	const backends = 3
+	var targets []string
+	served := make([]atomic.Int64, backends)
+	down := make([]atomic.Bool, backends)
+	for i := 0; i < backends; i++ {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				if down[i].Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+				return
+			}
+			served[i].Add(1)
+			fmt.Fprintf(w, "backend %d", i)
+		}))
+		t.Cleanup(backend.Close)
+		targets = append(targets, backend.URL)
+	}
+
+	r, hm, _ := newStaticRouter(t, []StaticRoute{
+		{Path: "/orders", Method: "GET", TargetUrl: strings.Join(targets, ","), Strategy: "round-robin"},
+	}, targets...)
+	check := func() {
+		for _, target := range targets {
+			hm.performCheck(target, target+"/health")
+		}
+	}
+	check()
+
+	send := func(requests int) []int64 {
+		t.Helper()
+		before := make([]int64, backends)
+		for i := range served {
+			before[i] = served[i].Load()
+		}
+		for i := 0; i < requests; i++ {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /orders = %d, want 200", rec.Code)
+			}
+		}
+		counts := make([]int64, backends)
+		for i := range served {
+			counts[i] = served[i].Load() - before[i]
+		}
+		return counts
+	}
+
+	steps := []struct {
+		name     string
+		down     []bool
+		requests int
+		want     []int64
+	}{
+		{name: "all healthy", down: []bool{false, false, false}, requests: 9, want: []int64{3, 3, 3}},
+		{name: "unhealthy target skipped", down: []bool{false, true, false}, requests: 8, want: []int64{4, 0, 4}},
+		{name: "recovered target rejoins", down: []bool{false, false, false}, requests: 6, want: []int64{2, 2, 2}},
+	}
+	for _, step := range steps {
+		for i, isDown := range step.down {
+			down[i].Store(isDown)
+		}
+		check()
+		if got := send(step.requests); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: requests per backend = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	for i := range down {
+		down[i].Store(true)
+	}
+	check()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /orders with every target down = %d, want 503", rec.Code)
+	}
+}
+
+func TestStaticBalancerStrategy(t *testing.T) {
+	tests := []struct {
+		name            string
+		strategy        string
+		defaultStrategy string
+		want            string
+	}{
+		{name: "route strategy", strategy: "random", defaultStrategy: "least-connections", want: "random"},
+		{name: "configured default", defaultStrategy: "least-connections", want: "least-connections"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lbm := services.NewLoadBalancerManager()
+			lbm.SetDefaultStrategy(tt.defaultStrategy)
+			hm := NewHealthManager(time.Hour, time.Second, newTestLogger())
+			newProxy := func(target *url.URL) *httputil.ReverseProxy { return httputil.NewSingleHostReverseProxy(target) }
+			sb, err := newStaticBalancer(tt.name, StaticRoute{TargetUrl: "http://orders:8080", Strategy: tt.strategy}, lbm, hm, newProxy)
+			if err != nil {
+				t.Fatalf("newStaticBalancer: %v", err)
+			}
+			if got := sb.lb.GetStats().Strategy; got != tt.want {
+				t.Errorf("strategy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
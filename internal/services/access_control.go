@@ -0,0 +1,24 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"net/netip"
+)
+
+// clientAllowed applies the service's gateway.io/deny-cidrs and gateway.io/allow-cidrs
+// rules to the trusted client IP. Deny wins over allow; with no allow list every
+// client not denied gets through. An unparseable IP only passes when no rules are set.
+func clientAllowed(service *k8s.DiscoveredService, clientIP string) bool {
+	if service == nil || (len(service.AllowCIDRs) == 0 && len(service.DenyCIDRs) == 0) {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	if service.DenyCIDRs.Contains(addr) {
+		return false
+	}
+	return len(service.AllowCIDRs) == 0 || service.AllowCIDRs.Contains(addr)
+}
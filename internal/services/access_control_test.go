@@ -0,0 +1,77 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPAccessControl(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("admin", map[string]string{
+			k8s.AnnotationAllowCIDRs: "10.0.0.0/8, 192.168.1.10",
+			k8s.AnnotationDenyCIDRs:  "10.0.5.0/24",
+		}),
+		testEndpoints(t, "admin", backend.URL),
+		testService("blocklist", map[string]string{k8s.AnnotationDenyCIDRs: "203.0.113.0/24"}),
+		testEndpoints(t, "blocklist", backend.URL),
+		testService("orders", nil),
+		testEndpoints(t, "orders", backend.URL),
+	)
+	for _, path := range []string{"/admin", "/blocklist", "/orders"} {
+		g.waitForEndpoints(t, http.MethodGet, path, 1)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		clientIP   string
+		wantStatus int
+	}{
+		{name: "allowed network", path: "/admin", clientIP: "10.1.2.3", wantStatus: http.StatusOK},
+		{name: "allowed single IP", path: "/admin", clientIP: "192.168.1.10", wantStatus: http.StatusOK},
+		{name: "deny wins over allow", path: "/admin", clientIP: "10.0.5.7", wantStatus: http.StatusForbidden},
+		{name: "outside the allow list", path: "/admin", clientIP: "198.51.100.4", wantStatus: http.StatusForbidden},
+		{name: "denied with no allow list", path: "/blocklist", clientIP: "203.0.113.9", wantStatus: http.StatusForbidden},
+		{name: "not denied with no allow list", path: "/blocklist", clientIP: "198.51.100.4", wantStatus: http.StatusOK},
+		{name: "no rules allow everyone", path: "/orders", clientIP: "198.51.100.4", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.clientIP + ":40000"
+			if rec := g.serve(req); rec.Code != tt.wantStatus {
+				t.Errorf("GET %s from %s = %d, want %d", tt.path, tt.clientIP, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClientAllowed(t *testing.T) {
+	allow, err := gatewayproxy.ParseCIDRList("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDRList: %v", err)
+	}
+	restricted := &k8s.DiscoveredService{AllowCIDRs: allow}
+
+	tests := []struct {
+		name     string
+		service  *k8s.DiscoveredService
+		clientIP string
+		want     bool
+	}{
+		{name: "unparseable IP with rules", service: restricted, clientIP: "unknown"},
+		{name: "unparseable IP without rules", service: &k8s.DiscoveredService{}, clientIP: "unknown", want: true},
+		{name: "no service", clientIP: "10.0.0.1", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := clientAllowed(tt.service, tt.clientIP); got != tt.want {
+			t.Errorf("%s: clientAllowed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/internal/middleware"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdminEndpointsRequireToken(t *testing.T) {
+	g := newTestGateway(t, newTestConfig(), testService("orders", nil), testEndpoints(t, "orders", refusedURL(t)))
+	g.drm.SetupAdminEndpoints(g.router)
+	handler := middleware.NewAdminAuthMiddleware(true, "s3cret").Middleware(g.router)
+
+	paths := []string{
+		"/admin/routes/stats",
+		"/admin/load-balancers",
+		"/admin/circuit-breakers",
+		"/admin/health-overview",
+		"/admin/connections",
+		"/admin/discovery/warnings",
+	}
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "unauthenticated", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", want: http.StatusForbidden},
+		{name: "authorized", token: "s3cret", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range paths {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.token != "" {
+					req.Header.Set(middleware.HeaderAdminToken, tt.token)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != tt.want {
+					t.Errorf("GET %s status = %d, want %d", path, rec.Code, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminConnectionsTracksActiveRequests(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 4)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	})
+	g := newServiceGateway(t, newTestConfig(), "orders", nil, backend.URL)
+	g.drm.SetupAdminEndpoints(g.router)
+
+	active := func() int64 {
+		var stats map[string]*ServiceConnectionStats
+		rec := g.serve(httptest.NewRequest(http.MethodGet, "/admin/connections", nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("decode /admin/connections: %v", err)
+		}
+		if stats["orders"] == nil {
+			return 0
+		}
+		return stats["orders"].Active
+	}
+
+	const concurrent = 3
+	var wg sync.WaitGroup
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+		}()
+	}
+	for i := 0; i < concurrent; i++ {
+		<-arrived
+	}
+	if got := active(); got != concurrent {
+		t.Errorf("active while in flight = %d, want %d", got, concurrent)
+	}
+
+	close(release)
+	wg.Wait()
+	if got := active(); got != 0 {
+		t.Errorf("active after completion = %d, want 0", got)
+	}
+
+	var metrics bytes.Buffer
+	g.drm.WriteMetrics(&metrics)
+	if !strings.Contains(metrics.String(), `gateway_upstream_connections{service="orders",`) {
+		t.Errorf("connection gauges missing from metrics:\n%s", metrics.String())
+	}
+}
+
+func TestAdminLoadBalancersReportEndpoints(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 1)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	})
+	refused := refusedURL(t)
+	endpoints := testEndpoints(t, "orders", backend.URL)
+	endpoints.Subsets = append(endpoints.Subsets, notReadyEndpoints(t, "orders", refused).Subsets...)
+	g := newTestGateway(t, newTestConfig(), testService("orders", nil), endpoints)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.drm.SetupAdminEndpoints(g.router)
+
+	loadBalancer := func() LoadBalancerStats {
+		var stats map[string]LoadBalancerStats
+		rec := g.serve(httptest.NewRequest(http.MethodGet, "/admin/load-balancers", nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("decode /admin/load-balancers: %v", err)
+		}
+		return stats["orders"]
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+	}()
+	<-arrived
+	inFlight := loadBalancer()
+	close(release)
+	<-done
+
+	if inFlight.Strategy != "round-robin" {
+		t.Errorf("strategy = %q, want round-robin", inFlight.Strategy)
+	}
+	ready, notReady := backend.URL[len("http://"):], refused[len("http://"):]
+	want := map[string]EndpointStats{
+		ready:    {Address: ready, Ready: true, Healthy: true, Weight: 1, Requests: 1, ActiveConnections: 1},
+		notReady: {Address: notReady, Weight: 1},
+	}
+	if len(inFlight.Endpoints) != len(want) {
+		t.Fatalf("endpoints = %+v, want %d", inFlight.Endpoints, len(want))
+	}
+	for _, got := range inFlight.Endpoints {
+		selected := got.LastSelected
+		got.LastSelected = time.Time{}
+		if got != want[got.Address] {
+			t.Errorf("endpoint = %+v, want %+v", got, want[got.Address])
+		}
+		if selected.IsZero() != (got.Address == notReady) {
+			t.Errorf("%s last selected = %v", got.Address, selected)
+		}
+	}
+
+	for _, endpoint := range loadBalancer().Endpoints {
+		if endpoint.ActiveConnections != 0 {
+			t.Errorf("%s active connections after completion = %d, want 0", endpoint.Address, endpoint.ActiveConnections)
+		}
+	}
+}
+
+func TestAdminFlushClearsStats(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", nil),
+		testEndpoints(t, "orders", backend.URL),
+		testService("billing", nil),
+		testEndpoints(t, "billing", refusedURL(t)),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/billing", 1)
+	g.drm.SetupAdminEndpoints(g.router)
+
+	for _, path := range []string{"/orders", "/orders", "/orders", "/billing"} {
+		g.serve(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	billingFailures := func() uint32 {
+		return g.drm.circuitBreakerManager.GetCircuitBreaker("billing").Counts().TotalFailures
+	}
+	if g.drm.GetStats().TotalRequests != 4 || billingFailures() == 0 {
+		t.Fatalf("stats before the flush = %+v, billing failures = %d", g.drm.GetStats(), billingFailures())
+	}
+
+	flush := func(target string) FlushSummary {
+		t.Helper()
+		rec := g.serve(httptest.NewRequest(http.MethodPost, target, nil))
+		var summary FlushSummary
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("POST %s = %d %q", target, rec.Code, rec.Body.String())
+		}
+		return summary
+	}
+
+	if got, want := flush("/admin/flush"), (FlushSummary{Routes: 2, LoadBalancers: 2}); got != want {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+	stats := g.drm.GetStats()
+	if stats.TotalRequests != 0 || stats.SuccessRequests != 0 || stats.ErrorRequests != 0 || len(stats.RouteStats) != 0 {
+		t.Errorf("route stats after the flush = %+v, want zero", stats)
+	}
+	for key, route := range g.drm.GetRouteInfo() {
+		if route.RequestCount != 0 {
+			t.Errorf("%s request count = %d, want 0", key, route.RequestCount)
+		}
+	}
+	for service, lbStats := range g.drm.loadBalancerStats() {
+		if lbStats.TotalRequests != 0 || len(lbStats.EndpointRequests) != 0 || lbStats.LastSelected != "" {
+			t.Errorf("%s load balancer stats = %+v, want zero", service, lbStats)
+		}
+		for _, endpoint := range lbStats.Endpoints {
+			if endpoint.Requests != 0 || !endpoint.LastSelected.IsZero() {
+				t.Errorf("%s endpoint %+v, want no requests", service, endpoint)
+			}
+		}
+	}
+	if detail, _ := g.drm.GetRouteDetail(http.MethodGet, "/orders"); detail.Latency != nil {
+		t.Errorf("latency after the flush = %+v, want none", detail.Latency)
+	}
+	if billingFailures() == 0 {
+		t.Error("breaker counts cleared without circuit_breakers=true")
+	}
+
+	if got := flush("/admin/flush?circuit_breakers=true"); got.CircuitBreakers != 2 || !got.BreakersReset {
+		t.Errorf("summary = %+v, want both breakers reset", got)
+	}
+	if billingFailures() != 0 {
+		t.Errorf("billing failures after resetting breakers = %d, want 0", billingFailures())
+	}
+
+	if rec := g.serve(httptest.NewRequest(http.MethodGet, "/admin/flush", nil)); rec.Code == http.StatusOK {
+		t.Error("GET /admin/flush succeeded, want POST only")
+	}
+	rec := httptest.NewRecorder()
+	middleware.NewAdminAuthMiddleware(true, "s3cret").Middleware(g.router).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/flush", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated POST /admin/flush = %d, want 401", rec.Code)
+	}
+}
+
+func TestAdminRouteDetail(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	service := testService("orders", map[string]string{k8s.AnnotationPaths: "/api/orders"})
+	g := newTestGateway(t, newTestConfig(), service, testEndpoints(t, "orders", backend.URL))
+	g.waitForEndpoints(t, http.MethodGet, "/api/orders", 1)
+	g.drm.SetupAdminEndpoints(g.router)
+	for i := 0; i < 3; i++ {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, "/api/orders", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/orders = %d, want 200", rec.Code)
+		}
+	}
+
+	// A real server, since the router redirects a fully encoded path to its cleaned form
+	server := httptest.NewServer(g.router)
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{name: "existing route", target: "/admin/routes/GET/api/orders", wantStatus: http.StatusOK},
+		{name: "URL-encoded segment", target: "/admin/routes/get/api%2Forders", wantStatus: http.StatusOK},
+		{name: "URL-encoded path", target: "/admin/routes/GET/%2Fapi%2Forders", wantStatus: http.StatusOK},
+		{name: "unknown path", target: "/admin/routes/GET/api/missing", wantStatus: http.StatusNotFound},
+		{name: "method without a route", target: "/admin/routes/DELETE/api/orders", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.target)
+			if err != nil {
+				t.Fatalf("GET %s: %v", tt.target, err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d", tt.target, resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var detail RouteDetail
+			if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+				t.Fatalf("decode detail: %v", err)
+			}
+			if detail.Route.Method != http.MethodGet || detail.Route.Path != "/api/orders" || detail.Route.ServiceName != "orders" {
+				t.Errorf("route = %s %s (%s), want GET /api/orders (orders)", detail.Route.Method, detail.Route.Path, detail.Route.ServiceName)
+			}
+			if detail.LoadBalancer == nil || detail.LoadBalancer.TotalRequests != 3 || detail.LoadBalancer.HealthyEndpoints != 1 {
+				t.Errorf("load balancer = %+v, want 3 requests to 1 healthy endpoint", detail.LoadBalancer)
+			}
+			if detail.CircuitBreaker == nil || detail.CircuitBreaker.State != middleware.StateClosed || detail.CircuitBreaker.Counts.Requests != 3 {
+				t.Errorf("circuit breaker = %+v, want closed after 3 requests", detail.CircuitBreaker)
+			}
+		})
+	}
+}
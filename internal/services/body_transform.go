@@ -0,0 +1,36 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// transformRequestBody applies the backend's request transform to a JSON body,
+// reading it whole; the caller has already capped it at the body size limit.
+// A body that isn't a JSON object yields gatewayproxy.ErrInvalidJSONBody and is
+// left in place, so it can still be forwarded untouched.
+func (drm *DynamicRouteManager) transformRequestBody(r *http.Request, backend *k8s.DiscoveredService) error {
+	transform := backend.RequestTransform
+	if transform == nil || r.Body == nil || r.Body == http.NoBody || !gatewayproxy.IsJSONContentType(r.Header.Get("Content-Type")) {
+		return nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	transformed, err := transform.Apply(body)
+	if err != nil {
+		transformed = body
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(transformed))
+	r.ContentLength = int64(len(transformed))
+	r.TransferEncoding = nil // The length is known now, so the body is no longer sent chunked
+	return err
+}
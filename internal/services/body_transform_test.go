@@ -0,0 +1,108 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyTransform(t *testing.T) {
+	received := make(chan string, 1)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.ContentLength != int64(len(body)) {
+			t.Errorf("Content-Length = %d for a %d byte body", r.ContentLength, len(body))
+		}
+		received <- string(body)
+	})
+
+	rename := `{"rename": {"userName": "user_name"}, "defaults": {"source": "gateway"}}`
+	g := newTestGateway(t, newTestConfig(),
+		testService("legacy", map[string]string{k8s.AnnotationMethod: "POST", k8s.AnnotationTransform: rename}),
+		testEndpoints(t, "legacy", backend.URL),
+		testService("strict", map[string]string{
+			k8s.AnnotationMethod:    "POST",
+			k8s.AnnotationTransform: `{"defaults": {"source": "gateway"}, "reject_invalid": true}`,
+		}),
+		testEndpoints(t, "strict", backend.URL),
+	)
+	g.waitForEndpoints(t, http.MethodPost, "/legacy", 1)
+	g.waitForEndpoints(t, http.MethodPost, "/strict", 1)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantJSON    string // Compared as decoded JSON
+		wantRaw     string // Compared byte for byte when wantJSON is empty
+	}{
+		{
+			name:        "field renamed and default injected",
+			path:        "/legacy",
+			contentType: "application/json",
+			body:        `{"userName": "ada"}`,
+			wantStatus:  http.StatusOK,
+			wantJSON:    `{"user_name": "ada", "source": "gateway"}`,
+		},
+		{
+			name:        "non-JSON content type untouched",
+			path:        "/legacy",
+			contentType: "text/plain",
+			body:        `{"userName": "ada"}`,
+			wantStatus:  http.StatusOK,
+			wantRaw:     `{"userName": "ada"}`,
+		},
+		{
+			name:        "malformed JSON forwarded unchanged",
+			path:        "/legacy",
+			contentType: "application/json",
+			body:        `{"userName": `,
+			wantStatus:  http.StatusOK,
+			wantRaw:     `{"userName": `,
+		},
+		{
+			name:        "malformed JSON rejected when configured",
+			path:        "/strict",
+			contentType: "application/json",
+			body:        `{"userName": `,
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := g.serve(req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			upstream := <-received
+			if tt.wantJSON == "" {
+				if upstream != tt.wantRaw {
+					t.Errorf("upstream body = %s, want %s", upstream, tt.wantRaw)
+				}
+				return
+			}
+			var got, want map[string]interface{}
+			if err := json.Unmarshal([]byte(upstream), &got); err != nil {
+				t.Fatalf("upstream body %q is not JSON: %v", upstream, err)
+			}
+			json.Unmarshal([]byte(tt.wantJSON), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("upstream body = %s, want %s", upstream, tt.wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"net/http"
+	"sync"
+)
+
+// bulkheadRetryAfter is the Retry-After, in seconds, sent when a service is saturated
+const bulkheadRetryAfter = "1"
+
+// bulkheads cap the requests in flight to each service configured with
+// gateway.io/max-concurrent, so one slow backend can't tie up every gateway goroutine
+type bulkheads struct {
+	inFlight map[string]int
+	mutex    sync.Mutex
+}
+
+func newBulkheads() *bulkheads {
+	return &bulkheads{inFlight: make(map[string]int)}
+}
+
+// acquire takes a slot for service, reporting false when limit requests are already
+// in flight. A limit <= 0 is unlimited, but the request is still counted.
+func (b *bulkheads) acquire(service string, limit int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if limit > 0 && b.inFlight[service] >= limit {
+		return false
+	}
+	b.inFlight[service]++
+	return true
+}
+
+// release gives back a slot taken by acquire
+func (b *bulkheads) release(service string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.inFlight[service] <= 1 {
+		delete(b.inFlight, service)
+		return
+	}
+	b.inFlight[service]--
+}
+
+// snapshot returns the requests in flight per service, omitting idle ones
+func (b *bulkheads) snapshot() map[string]int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	inFlight := make(map[string]int, len(b.inFlight))
+	for service, count := range b.inFlight {
+		inFlight[service] = count
+	}
+	return inFlight
+}
+
+// writeBulkheadFull writes the 503 for a request rejected by a saturated service
+func writeBulkheadFull(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", bulkheadRetryAfter)
+	http.Error(w, "Service Unavailable - Too Many Concurrent Requests", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBulkheadAcquire(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		acquires  int
+		wantTaken int
+	}{
+		{name: "under the limit", limit: 3, acquires: 2, wantTaken: 2},
+		{name: "at the limit", limit: 3, acquires: 3, wantTaken: 3},
+		{name: "over the limit", limit: 3, acquires: 5, wantTaken: 3},
+		{name: "unlimited", limit: 0, acquires: 5, wantTaken: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBulkheads()
+			taken := 0
+			for i := 0; i < tt.acquires; i++ {
+				if b.acquire("orders", tt.limit) {
+					taken++
+				}
+			}
+			if taken != tt.wantTaken || b.snapshot()["orders"] != tt.wantTaken {
+				t.Fatalf("took %d slots with %d in flight, want %d", taken, b.snapshot()["orders"], tt.wantTaken)
+			}
+			if tt.limit > 0 && !b.acquire("billing", tt.limit) {
+				t.Error("a saturated service blocked another service")
+			}
+
+			b.release("orders")
+			if tt.limit > 0 && !b.acquire("orders", tt.limit) {
+				t.Error("released slot could not be taken again")
+			}
+		})
+	}
+}
+
+func TestBulkheadRejectsRequestOverLimit(t *testing.T) {
+	const limit = 2
+	release := make(chan struct{})
+	arrived := make(chan struct{}, limit)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	})
+	// POST so the concurrent requests aren't coalesced into one upstream call
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{k8s.AnnotationMethod: "POST", k8s.AnnotationMaxConcurrent: "2"}),
+		testEndpoints(t, "orders", backend.URL),
+	)
+	g.waitForEndpoints(t, http.MethodPost, "/orders", 1)
+	g.drm.SetupAdminEndpoints(g.router)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- g.serve(httptest.NewRequest(http.MethodPost, "/orders", nil)).Code
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		select {
+		case <-arrived:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d requests reached the backend", i, limit)
+		}
+	}
+
+	rec := g.serve(httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("request %d status = %d, want 503", limit+1, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != bulkheadRetryAfter {
+		t.Errorf("Retry-After = %q, want %q", got, bulkheadRetryAfter)
+	}
+
+	var overview struct {
+		Summary struct {
+			InFlight map[string]int `json:"in_flight_requests"`
+		} `json:"summary"`
+	}
+	json.Unmarshal(g.serve(httptest.NewRequest(http.MethodGet, "/admin/health-overview", nil)).Body.Bytes(), &overview)
+	if got := overview.Summary.InFlight["orders"]; got != limit {
+		t.Errorf("health overview in-flight requests = %d, want %d", got, limit)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("admitted request status = %d, want 200", code)
+		}
+	}
+	if rec := g.serve(httptest.NewRequest(http.MethodPost, "/orders", nil)); rec.Code != http.StatusOK {
+		t.Errorf("request after the others finished = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+)
+
+// selectBackend picks the service that serves a request: the route's canary for
+// CanaryWeight percent of requests, the route's own service otherwise. Clients
+// sending the canary sticky header are hashed so they always land on the same side.
+func (drm *DynamicRouteManager) selectBackend(r *http.Request, route *DynamicRouteInfo) *k8s.DiscoveredService {
+	primary := route.Service
+	if primary.CanaryService == "" || primary.CanaryWeight <= 0 {
+		return primary
+	}
+
+	if !inCanary(r.Header.Get(primary.CanaryStickyHeader), primary.CanaryWeight) {
+		return primary
+	}
+
+	canary, exists := drm.discoveryManager.GetDiscoveredService(primary.CanaryService)
+	if !exists {
+		drm.logger.Debug("Canary service not discovered, using primary", map[string]interface{}{
+			"service": primary.Name,
+			"canary":  primary.CanaryService,
+		})
+		return primary
+	}
+	return canary
+}
+
+// inCanary reports whether a request falls in the canary's share of traffic
+func inCanary(stickyKey string, weight int) bool {
+	if weight >= 100 {
+		return true
+	}
+	if stickyKey == "" {
+		return rand.IntN(100) < weight
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(stickyKey))
+	return int(h.Sum32()%100) < weight
+}
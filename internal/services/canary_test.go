@@ -0,0 +1,96 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestInCanarySplit(t *testing.T) {
+	const requests = 10000
+	tests := []struct {
+		name   string
+		weight int
+		sticky bool // Each request carries a distinct sticky key
+	}{
+		{name: "10 percent", weight: 10},
+		{name: "50 percent", weight: 50},
+		{name: "100 percent", weight: 100},
+		{name: "10 percent of sticky clients", weight: 10, sticky: true},
+		{name: "35 percent of sticky clients", weight: 35, sticky: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary := 0
+			for i := 0; i < requests; i++ {
+				key := ""
+				if tt.sticky {
+					key = "client-" + strconv.Itoa(i)
+				}
+				if inCanary(key, tt.weight) {
+					canary++
+				}
+			}
+			share := float64(canary) / requests * 100
+			if math.Abs(share-float64(tt.weight)) > 2 {
+				t.Errorf("canary share = %.1f%%, want about %d%%", share, tt.weight)
+			}
+		})
+	}
+}
+
+func TestInCanaryIsStickyPerClient(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := "client-" + strconv.Itoa(i)
+		first := inCanary(key, 30)
+		for j := 0; j < 10; j++ {
+			if inCanary(key, 30) != first {
+				t.Fatalf("client %s switched sides", key)
+			}
+		}
+	}
+}
+
+func TestCanaryTrafficSplit(t *testing.T) {
+	primary := newBackend(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("v1")) })
+	canary := newBackend(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("v2")) })
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{
+			k8s.AnnotationCanaryService: "orders-v2",
+			k8s.AnnotationCanaryWeight:  "20",
+		}),
+		testEndpoints(t, "orders", primary.URL),
+		testService("orders-v2", nil),
+		testEndpoints(t, "orders-v2", canary.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/orders-v2", 1)
+
+	const requests = 1000
+	served := map[string]int{}
+	for i := 0; i < requests; i++ {
+		rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, rec.Code)
+		}
+		served[rec.Body.String()]++
+	}
+	if share := float64(served["v2"]) / requests * 100; share < 15 || share > 25 {
+		t.Errorf("canary served %.1f%% of requests, want about 20%%", share)
+	}
+
+	// A client sending the sticky header stays on one version
+	versions := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(k8s.DefaultCanaryStickyHeader, "customer-42")
+		versions[g.serve(req).Body.String()] = true
+	}
+	if len(versions) != 1 {
+		t.Errorf("sticky client was served by %v, want one version", versions)
+	}
+}
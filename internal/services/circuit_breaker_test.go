@@ -0,0 +1,96 @@
+package services
+
+import (
+	"api-gateway/internal/middleware"
+	"api-gateway/pkg/metrics"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHalfOpenRequestCountsOnce(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	cfg := newTestConfig()
+	cfg.Proxy.CircuitBreakerSuccessThreshold = 3
+	cfg.Proxy.CircuitBreakerTimeout = 20 * time.Millisecond
+	g := newServiceGateway(t, cfg, "orders", nil, backend.URL)
+
+	tests := []struct {
+		requests int
+		want     middleware.CircuitBreakerState
+	}{
+		{requests: 1, want: middleware.StateHalfOpen},
+		{requests: 2, want: middleware.StateHalfOpen},
+		{requests: 3, want: middleware.StateClosed},
+	}
+
+	cb := g.drm.circuitBreakerManager.GetCircuitBreaker("orders")
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.requests)+" requests", func(t *testing.T) {
+			for cb.State() != middleware.StateOpen {
+				cb.Execute(func() (interface{}, error) { return nil, errors.New("connection refused") })
+			}
+			eventually(t, func() bool { return cb.State() == middleware.StateHalfOpen })
+
+			for i := 0; i < tt.requests; i++ {
+				if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+					t.Fatalf("request %d got %d, want 200", i+1, rec.Code)
+				}
+			}
+			if got := cb.State(); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+			if tt.want == middleware.StateHalfOpen && cb.Counts().TotalSuccesses != uint32(tt.requests) {
+				t.Errorf("breaker recorded %d successes, want %d", cb.Counts().TotalSuccesses, tt.requests)
+			}
+		})
+	}
+}
+
+func TestOpenCircuitBreakerReturnsRetryAfter(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Proxy.CircuitBreakerTimeout = 10 * time.Second
+	g := newTestGateway(t, cfg, testService("orders", nil), testEndpoints(t, "orders", refusedURL(t)))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+
+	// Consecutive upstream failures trip the breaker; until then each one is a 502
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 20; i++ {
+		rec = g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+		if rec.Code != http.StatusBadGateway {
+			break
+		}
+	}
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503 once the breaker opened", rec.Code)
+	}
+	if state := g.drm.circuitBreakerManager.GetCircuitBreaker("orders").State(); state != middleware.StateOpen {
+		t.Errorf("breaker state = %s, want OPEN", state)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 10 {
+		t.Errorf("Retry-After = %q, want 1 to 10 seconds from the breaker timeout", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestCircuitBreakerStateIsRecorded(t *testing.T) {
+	registry := metrics.NewRegistry()
+	g := newTestGatewayWith(t, newTestConfig(), newTestLogger(), registry, testService("orders", nil), testEndpoints(t, "orders", refusedURL(t)))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+
+	for i := 0; i < 20 && g.drm.circuitBreakerManager.GetCircuitBreaker("orders").State() != middleware.StateOpen; i++ {
+		g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+	}
+
+	var buf bytes.Buffer
+	registry.WriteMetrics(&buf)
+	if want := `gateway_circuit_breaker_state{service="orders"} 2`; !strings.Contains(buf.String(), want+"\n") {
+		t.Errorf("metrics missing %q:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalesceMaxWait bounds how long a request waits on an identical one in flight
+// before going to the upstream itself
+const coalesceMaxWait = 5 * time.Second
+
+// coalesceKeyHeaders are the request headers that can change the upstream response,
+// so requests differing in any of them are never coalesced
+var coalesceKeyHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie"}
+
+// coalescer lets concurrent identical GETs to a gateway.io/coalesce service share one
+// upstream call: the first request fetches the response and the others replay it
+type coalescer struct {
+	calls map[string]*coalescedCall
+	mutex sync.Mutex
+}
+
+// coalescedCall is an upstream call in flight; response is set before done closes
+// and stays nil when the response can't be shared
+type coalescedCall struct {
+	done     chan struct{}
+	response *sharedResponse
+	waiters  int // Requests that joined the leader, guarded by the coalescer's mutex
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// begin returns the call in flight for key, reporting true when the caller is
+// the leader that must fetch the response and pass it to finish
+func (c *coalescer) begin(key string) (*coalescedCall, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if call, exists := c.calls[key]; exists {
+		call.waiters++
+		return call, false
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	return call, true
+}
+
+// finish publishes the leader's response, nil when it can't be shared, and
+// releases the waiting requests
+func (c *coalescer) finish(key string, call *coalescedCall, response *sharedResponse) {
+	c.mutex.Lock()
+	delete(c.calls, key)
+	c.mutex.Unlock()
+
+	call.response = response
+	close(call.done)
+}
+
+// wait returns the leader's response, or nil when it wasn't shareable, took longer
+// than coalesceMaxWait or the client went away
+func (call *coalescedCall) wait(ctx context.Context) *sharedResponse {
+	timer := time.NewTimer(coalesceMaxWait)
+	defer timer.Stop()
+
+	select {
+	case <-call.done:
+		return call.response
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// coalescable reports whether a request to service may share an identical request's
+// response: plain GETs the client didn't ask to revalidate, on a coalescing service
+func coalescable(r *http.Request, service *k8s.DiscoveredService, streaming bool) bool {
+	if !service.Coalesce || streaming || r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return false
+	}
+	cacheControl := strings.ToLower(r.Header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-cache") && !strings.Contains(cacheControl, "no-store")
+}
+
+// coalesceKey identifies requests that would get the same upstream response
+func coalesceKey(r *http.Request, service *k8s.DiscoveredService) string {
+	var b strings.Builder
+	b.WriteString(serviceKey(service))
+	b.WriteString("\n")
+	b.WriteString(r.Host)
+	b.WriteString("\n")
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range coalesceKeyHeaders {
+		b.WriteString("\n")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCoalescable(t *testing.T) {
+	coalescing := &k8s.DiscoveredService{Name: "orders", Coalesce: true}
+	tests := []struct {
+		name      string
+		method    string
+		headers   map[string]string
+		service   *k8s.DiscoveredService
+		streaming bool
+		want      bool
+	}{
+		{name: "plain GET", method: http.MethodGet, service: coalescing, want: true},
+		{name: "service not opted in", method: http.MethodGet, service: &k8s.DiscoveredService{Name: "orders"}},
+		{name: "POST", method: http.MethodPost, service: coalescing},
+		{name: "streaming", method: http.MethodGet, service: coalescing, streaming: true},
+		{name: "range request", method: http.MethodGet, headers: map[string]string{"Range": "bytes=0-99"}, service: coalescing},
+		{name: "client revalidates", method: http.MethodGet, headers: map[string]string{"Cache-Control": "no-cache"}, service: coalescing},
+		{name: "client forbids storing", method: http.MethodGet, headers: map[string]string{"Cache-Control": "No-Store"}, service: coalescing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/orders", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := coalescable(req, tt.service, tt.streaming); got != tt.want {
+				t.Errorf("coalescable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// coalesceWaiters counts the requests waiting on a leader's upstream call
+func coalesceWaiters(c *coalescer) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	waiters := 0
+	for _, call := range c.calls {
+		waiters += call.waiters
+	}
+	return waiters
+}
+
+func TestConcurrentIdenticalRequestsShareUpstreamCall(t *testing.T) {
+	const requests = 8
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		accept      func(i int) string
+		wantCalls   int64
+	}{
+		{
+			name:        "coalescing service",
+			annotations: map[string]string{k8s.AnnotationCoalesce: "true"},
+			accept:      func(i int) string { return "application/json" },
+			wantCalls:   1,
+		},
+		{
+			name:        "requests differing in a key header",
+			annotations: map[string]string{k8s.AnnotationCoalesce: "true"},
+			accept:      func(i int) string { return []string{"application/json", "text/plain"}[i%2] },
+			wantCalls:   2,
+		},
+		{
+			name:      "service not opted in",
+			accept:    func(i int) string { return "application/json" },
+			wantCalls: requests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			release := make(chan struct{})
+			backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				call := calls.Add(1)
+				<-release
+				fmt.Fprintf(w, "%s call %d", r.Header.Get("Accept"), call)
+			})
+			g := newServiceGateway(t, newTestConfig(), "orders", tt.annotations, backend.URL)
+
+			var wg sync.WaitGroup
+			recs := make([]*httptest.ResponseRecorder, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+					req.Header.Set("Accept", tt.accept(i))
+					recs[i] = g.serve(req)
+				}()
+			}
+			// Released only once every request is either upstream or waiting on one that is
+			eventually(t, func() bool { return calls.Load()+int64(coalesceWaiters(g.drm.coalescer)) == requests })
+			close(release)
+			wg.Wait()
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+			bodies := make(map[string]bool)
+			for i, rec := range recs {
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d status = %d, want 200", i, rec.Code)
+				}
+				body, _ := io.ReadAll(rec.Body)
+				bodies[string(body)] = true
+			}
+			if int64(len(bodies)) != tt.wantCalls {
+				t.Errorf("clients got %d distinct responses, want one per upstream call: %v", len(bodies), bodies)
+			}
+		})
+	}
+}
+
+// blockingWriter holds the response in WriteHeader until release closes
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (bw *blockingWriter) WriteHeader(code int) {
+	close(bw.writing)
+	<-bw.release
+	bw.ResponseRecorder.WriteHeader(code)
+}
+
+func TestCoalescedWaitersRetryGatewayResponses(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("orders"))
+	})
+	g := newServiceGateway(t, newTestConfig(), "orders", map[string]string{k8s.AnnotationCoalesce: "true", k8s.AnnotationMaxConcurrent: "1"}, backend.URL)
+
+	// The leader finds the bulkhead full and is held while writing its 503
+	if !g.drm.bulkheads.acquire("orders", 1) {
+		t.Fatal("bulkhead slot not acquired")
+	}
+	leader := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), release: make(chan struct{})}
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		g.router.ServeHTTP(leader, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	}()
+	<-leader.writing
+
+	waiter := make(chan *httptest.ResponseRecorder)
+	go func() { waiter <- g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)) }()
+	eventually(t, func() bool { return coalesceWaiters(g.drm.coalescer) == 1 })
+
+	g.drm.bulkheads.release("orders")
+	close(leader.release)
+	<-leaderDone
+	rec := <-waiter
+
+	if leader.Code != http.StatusServiceUnavailable {
+		t.Errorf("leader status = %d, want 503", leader.Code)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "orders" {
+		t.Errorf("waiter response = %d %q, want the upstream's 200 \"orders\"", rec.Code, rec.Body.String())
+	}
+}
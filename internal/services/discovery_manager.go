@@ -3,9 +3,12 @@ package services
 import (
 	"api-gateway/internal/config"
 	"api-gateway/internal/k8s"
+	"api-gateway/pkg/logger"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -16,10 +19,14 @@ type DiscoveryManager struct {
 	k8sClient        *k8s.Client
 	serviceDiscovery *k8s.ServiceDiscovery
 	routes           map[string]*DynamicRoute
+	claimants        map[string][]*k8s.DiscoveredService // Services waiting on a route another service holds, oldest first
 	routesMutex      sync.RWMutex
 	eventProcessors  []EventProcessor
 	stopCh           chan struct{}
+	syncedCh         chan struct{} // Closed once the informer caches have synced
 	started          bool
+	stateMutex       sync.RWMutex
+	logger           *logger.Logger
 }
 
 // DynamicRoute represents a dynamically discovered route
@@ -40,22 +47,27 @@ type EventProcessor interface {
 }
 
 // NewDiscoveryManager creates a new discovery manager
-func NewDiscoveryManager(cfg *config.Config) *DiscoveryManager {
+func NewDiscoveryManager(cfg *config.Config, structuredLogger *logger.Logger) *DiscoveryManager {
 	return &DiscoveryManager{
 		config:          cfg,
+		logger:          structuredLogger.WithComponent("discovery_manager"),
 		routes:          make(map[string]*DynamicRoute),
+		claimants:       make(map[string][]*k8s.DiscoveredService),
 		eventProcessors: make([]EventProcessor, 0),
 		stopCh:          make(chan struct{}),
+		syncedCh:        make(chan struct{}),
 	}
 }
 
-// Start initializes and starts the discovery manager
+// Start initializes and starts the discovery manager. It returns without waiting for
+// the informer caches, so the server can listen while they warm up; readiness fails
+// and unmatched requests get a 503 until they have synced.
 func (dm *DiscoveryManager) Start(ctx context.Context) error {
-	if dm.started {
+	if dm.IsStarted() {
 		return fmt.Errorf("discovery manager already started")
 	}
 
-	log.Println("Starting Discovery Manager...")
+	dm.logger.Info("Starting Discovery Manager")
 
 	if dm.config.Kubernetes.Enabled {
 		if err := dm.initializeKubernetes(); err != nil {
@@ -63,35 +75,137 @@ func (dm *DiscoveryManager) Start(ctx context.Context) error {
 		}
 
 		if dm.config.Kubernetes.ServiceDiscovery {
-			if err := dm.startServiceDiscovery(ctx); err != nil {
-				return fmt.Errorf("failed to start service discovery: %w", err)
+			dm.serviceDiscovery = k8s.NewServiceDiscovery(dm.k8sClient, dm.logger)
+			if err := dm.serviceDiscovery.SetRouteDefaults(dm.config.Kubernetes.DefaultMethod, dm.config.Kubernetes.DefaultPathTemplate); err != nil {
+				return err
+			}
+			dm.serviceDiscovery.SetDefaultLoadBalancing(dm.config.Proxy.LoadBalancing)
+			if dm.config.Kubernetes.WatchNodeZones {
+				dm.serviceDiscovery.WatchNodeZones()
 			}
 		}
 	}
 
 	go dm.processEvents()
+	if dm.serviceDiscovery != nil {
+		go dm.startServiceDiscovery(ctx)
+	} else {
+		close(dm.syncedCh)
+	}
 
+	dm.stateMutex.Lock()
 	dm.started = true
-	log.Println("Discovery Manager started successfully")
+	dm.stateMutex.Unlock()
+	dm.logger.Info("Discovery Manager started successfully")
 	return nil
 }
 
 // Stop stops the discovery manager
 func (dm *DiscoveryManager) Stop() {
-	if !dm.started {
+	if !dm.IsStarted() {
 		return
 	}
 
-	log.Println("Stopping Discovery Manager...")
+	dm.logger.Info("Stopping Discovery Manager")
 
 	if dm.serviceDiscovery != nil {
 		dm.serviceDiscovery.Stop()
 	}
 
 	close(dm.stopCh)
+
+	dm.stateMutex.Lock()
 	dm.started = false
+	dm.stateMutex.Unlock()
+
+	dm.logger.Info("Discovery Manager stopped")
+}
+
+// IsStarted reports whether the discovery manager is running
+func (dm *DiscoveryManager) IsStarted() bool {
+	dm.stateMutex.RLock()
+	defer dm.stateMutex.RUnlock()
+	return dm.started
+}
+
+// CheckStarted is a readiness check that fails until the manager has started
+func (dm *DiscoveryManager) CheckStarted(ctx context.Context) error {
+	if !dm.IsStarted() {
+		return errors.New("discovery manager not started")
+	}
+	return nil
+}
+
+// CheckCacheSynced is a readiness check that fails until the Kubernetes
+// informer caches have synced. It passes when service discovery is disabled.
+func (dm *DiscoveryManager) CheckCacheSynced(ctx context.Context) error {
+	if !dm.config.Kubernetes.Enabled || !dm.config.Kubernetes.ServiceDiscovery {
+		return nil
+	}
+	if dm.serviceDiscovery == nil || !dm.serviceDiscovery.HasSynced() {
+		return errors.New("service discovery cache not synced")
+	}
+	return nil
+}
+
+// CheckLive is a liveness check that fails when the manager's locks cannot be
+// acquired before ctx expires, which indicates a deadlocked discovery goroutine.
+// It polls instead of blocking, so a stuck lock doesn't strand a goroutine per probe.
+func (dm *DiscoveryManager) CheckLive(ctx context.Context) error {
+	for _, mutex := range []*sync.RWMutex{&dm.stateMutex, &dm.routesMutex} {
+		if !tryReadLock(ctx, mutex) {
+			return errors.New("discovery manager locks unavailable, possible deadlock")
+		}
+	}
+	return nil
+}
+
+// liveLockPoll is how often CheckLive retries a lock that is held
+const liveLockPoll = 10 * time.Millisecond
+
+// tryReadLock reports whether mutex could be read-locked, and releases it, before ctx expires
+func tryReadLock(ctx context.Context, mutex *sync.RWMutex) bool {
+	ticker := time.NewTicker(liveLockPoll)
+	defer ticker.Stop()
+	for {
+		if mutex.TryRLock() {
+			mutex.RUnlock()
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// Synced returns a channel closed once discovery has synced; it closes right
+// away when service discovery is disabled and never when the sync fails
+func (dm *DiscoveryManager) Synced() <-chan struct{} {
+	return dm.syncedCh
+}
+
+// IsSynced reports whether discovery has synced, so an unmatched route is genuinely unknown
+func (dm *DiscoveryManager) IsSynced() bool {
+	return dm.IsStarted() && dm.CheckCacheSynced(context.Background()) == nil
+}
+
+// RespondIfSyncing writes a 503 with Retry-After while discovery is still syncing
+// and reports whether it did, letting callers tell "not ready yet" from "unknown route"
+func (dm *DiscoveryManager) RespondIfSyncing(w http.ResponseWriter) bool {
+	if !dm.config.Kubernetes.StartupUnavailable || dm.IsSynced() {
+		return false
+	}
+
+	retryAfter := int(dm.config.Kubernetes.StartupRetryAfter.Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
 
-	log.Println("Discovery Manager stopped")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "Service Unavailable - Gateway Starting Up", http.StatusServiceUnavailable)
+	return true
 }
 
 // GetRoutes returns all current dynamic routes
@@ -114,8 +228,14 @@ func (dm *DiscoveryManager) GetRoute(path string) (*DynamicRoute, bool) {
 	return route, exists
 }
 
-// AddEventProcessor adds an event processor
+// AddEventProcessor adds an event processor; registering the same processor twice is a no-op
 func (dm *DiscoveryManager) AddEventProcessor(processor EventProcessor) {
+	for _, existing := range dm.eventProcessors {
+		if existing == processor {
+			dm.logger.Warn("Event processor already registered, ignoring")
+			return
+		}
+	}
 	dm.eventProcessors = append(dm.eventProcessors, processor)
 }
 
@@ -127,6 +247,41 @@ func (dm *DiscoveryManager) GetDiscoveredServices() map[string]*k8s.DiscoveredSe
 	return dm.serviceDiscovery.GetServices()
 }
 
+// GetDiscoveredService returns a single discovered service by name
+func (dm *DiscoveryManager) GetDiscoveredService(name string) (*k8s.DiscoveredService, bool) {
+	if dm.serviceDiscovery == nil {
+		return nil, false
+	}
+	return dm.serviceDiscovery.GetService(name)
+}
+
+// GetWarnings returns services skipped because of invalid annotations, with the reason
+func (dm *DiscoveryManager) GetWarnings() map[string]string {
+	if dm.serviceDiscovery == nil {
+		return make(map[string]string)
+	}
+	return dm.serviceDiscovery.GetWarnings()
+}
+
+// GetBasicAuthSecret reads upstream Basic credentials from a Kubernetes secret
+func (dm *DiscoveryManager) GetBasicAuthSecret(ctx context.Context, namespace, name string) (string, string, error) {
+	if dm.k8sClient == nil {
+		return "", "", errors.New("kubernetes client not initialized")
+	}
+	return dm.k8sClient.GetBasicAuthSecret(ctx, namespace, name)
+}
+
+// LocalZone returns the gateway's own zone: GATEWAY_ZONE, or the zone label of its node
+func (dm *DiscoveryManager) LocalZone() string {
+	if dm.config.Kubernetes.Zone != "" {
+		return dm.config.Kubernetes.Zone
+	}
+	if dm.serviceDiscovery == nil || dm.config.Kubernetes.NodeName == "" {
+		return ""
+	}
+	return dm.serviceDiscovery.NodeZone(dm.config.Kubernetes.NodeName)
+}
+
 // IsKubernetesEnabled returns whether Kubernetes integration is enabled
 func (dm *DiscoveryManager) IsKubernetesEnabled() bool {
 	return dm.config.Kubernetes.Enabled
@@ -153,7 +308,7 @@ func (dm *DiscoveryManager) GetServiceEndpoints(serviceName string) []k8s.Servic
 
 // initializeKubernetes sets up the Kubernetes client
 func (dm *DiscoveryManager) initializeKubernetes() error {
-	log.Println("Initializing Kubernetes client...")
+	dm.logger.Info("Initializing Kubernetes client")
 
 	clientConfig := k8s.ClientConfig{
 		InCluster:  dm.config.Kubernetes.InCluster,
@@ -165,28 +320,32 @@ func (dm *DiscoveryManager) initializeKubernetes() error {
 		clientConfig = k8s.AutoDetectConfig(dm.config.Kubernetes.Namespace)
 	}
 
-	client, err := k8s.NewClient(clientConfig)
+	client, err := k8s.NewClient(clientConfig, dm.logger)
 	if err != nil {
 		return fmt.Errorf("failed to create Kubernetes client: %w", err)
 	}
 
 	dm.k8sClient = client
-	log.Printf("Kubernetes client initialized successfully (namespace: %s)", client.GetNamespace())
+	dm.logger.Info("Kubernetes client initialized successfully", map[string]interface{}{
+		"namespace": client.GetNamespace(),
+	})
 	return nil
 }
 
-// startServiceDiscovery initializes and starts service discovery
-func (dm *DiscoveryManager) startServiceDiscovery(ctx context.Context) error {
-	log.Println("Starting Kubernetes service discovery...")
-
-	dm.serviceDiscovery = k8s.NewServiceDiscovery(dm.k8sClient)
+// startServiceDiscovery starts service discovery and waits for its caches to sync,
+// closing syncedCh once they have. A failed sync leaves the gateway not ready.
+func (dm *DiscoveryManager) startServiceDiscovery(ctx context.Context) {
+	dm.logger.Info("Starting Kubernetes service discovery")
 
 	if err := dm.serviceDiscovery.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start service discovery: %w", err)
+		dm.logger.Error("Failed to start service discovery", map[string]interface{}{
+			"error": err,
+		})
+		return
 	}
 
-	log.Println("Service discovery started successfully")
-	return nil
+	dm.logger.Info("Service discovery started successfully")
+	close(dm.syncedCh)
 }
 
 // processEvents processes service discovery events
@@ -195,14 +354,24 @@ func (dm *DiscoveryManager) processEvents() {
 		return
 	}
 
-	log.Println("Starting event processing...")
+	dm.logger.Info("Starting event processing")
+
+	// Resync runs on this goroutine so it never interleaves with event handling
+	var resync <-chan time.Time
+	if interval := dm.config.Kubernetes.ResyncInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
 
 	for {
 		select {
 		case event := <-dm.serviceDiscovery.GetEventChannel():
 			dm.handleServiceEvent(event)
+		case <-resync:
+			dm.resync()
 		case <-dm.stopCh:
-			log.Println("Stopping event processing")
+			dm.logger.Info("Stopping event processing")
 			return
 		}
 	}
@@ -210,18 +379,85 @@ func (dm *DiscoveryManager) processEvents() {
 
 // handleServiceEvent handles a service discovery event
 func (dm *DiscoveryManager) handleServiceEvent(event k8s.ServiceEvent) {
-	log.Printf("Processing service event: %s for service %s", event.Type, event.Service.Name)
+	dm.logger.Debug("Processing service event", map[string]interface{}{
+		"event":   string(event.Type),
+		"service": event.Service.Name,
+	})
 
 	dm.updateRoutes(event)
 
 	for _, processor := range dm.eventProcessors {
 		if err := processor.ProcessServiceEvent(event); err != nil {
-			log.Printf("Error processing event with processor: %v", err)
+			dm.logger.Error("Error processing service event", map[string]interface{}{
+				"event":   string(event.Type),
+				"service": event.Service.Name,
+				"error":   err,
+			})
+		}
+	}
+}
+
+// resync reconciles the route table against the discovered services, replaying
+// events that were dropped while the event channel was full
+func (dm *DiscoveryManager) resync() {
+	services := dm.serviceDiscovery.GetServices()
+
+	var events []k8s.ServiceEvent
+	dm.routesMutex.RLock()
+	for _, service := range services {
+		if dm.isStale(service) {
+			events = append(events, k8s.ServiceEvent{Type: k8s.ServiceModified, Service: service, Timestamp: time.Now()})
+		}
+	}
+	removed := make(map[string]bool)
+	for _, route := range dm.routes {
+		if _, exists := services[route.ServiceName]; exists || removed[route.ServiceName] {
+			continue
 		}
+		removed[route.ServiceName] = true
+		events = append(events, k8s.ServiceEvent{Type: k8s.ServiceDeleted, Service: route.Service, Timestamp: time.Now()})
 	}
+	dm.routesMutex.RUnlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	dm.logger.Warn("Route table out of sync with discovered services, resyncing", map[string]interface{}{
+		"events":         len(events),
+		"dropped_events": dm.serviceDiscovery.GetDroppedEvents(),
+	})
+	for _, event := range events {
+		dm.handleServiceEvent(event)
+	}
+}
+
+// isStale reports whether a service's routes are missing or older than the service.
+// Routes held by another service are skipped; the first registered service keeps
+// them, so a rejected claim is not out of sync. Callers hold routesMutex.
+func (dm *DiscoveryManager) isStale(service *k8s.DiscoveredService) bool {
+	for _, serviceRoute := range service.Routes() {
+		route, exists := dm.routes[serviceRoute.Key()]
+		if !exists {
+			return true
+		}
+		if !ownsRoute(route, service) {
+			continue
+		}
+		if route.Service != service || route.LastUpdated.Before(service.LastUpdated) {
+			return true
+		}
+	}
+	return false
 }
 
-// updateRoutes updates internal route table based on service events
+// ownsRoute reports whether route belongs to service
+func ownsRoute(route *DynamicRoute, service *k8s.DiscoveredService) bool {
+	return route.ServiceName == service.Name && route.Namespace == service.Namespace
+}
+
+// updateRoutes updates internal route table based on service events. The first
+// registered service keeps a route; later claimants wait until it is released.
 func (dm *DiscoveryManager) updateRoutes(event k8s.ServiceEvent) {
 	dm.routesMutex.Lock()
 	defer dm.routesMutex.Unlock()
@@ -231,13 +467,59 @@ func (dm *DiscoveryManager) updateRoutes(event k8s.ServiceEvent) {
 		return
 	}
 
-	routeKey := fmt.Sprintf("%s:%s", service.Method, service.Path)
+	// Drop the service's previous routes and claims so methods removed on update disappear too
+	var released []string
+	for key, route := range dm.routes {
+		if ownsRoute(route, service) {
+			delete(dm.routes, key)
+			released = append(released, key)
+		}
+	}
+	claimed := make(map[string]bool)
+	if event.Type != k8s.ServiceDeleted {
+		for _, serviceRoute := range service.Routes() {
+			claimed[serviceRoute.Key()] = true
+		}
+	}
+	dm.dropClaims(service, claimed)
 
 	switch event.Type {
 	case k8s.ServiceAdded, k8s.ServiceModified:
+		dm.addRoutes(service)
+
+	case k8s.ServiceDeleted:
+		dm.logger.Info("Routes removed", map[string]interface{}{
+			"service": service.Name,
+			"methods": service.Methods,
+			"paths":   service.Paths,
+		})
+	}
+
+	// Routes the service no longer serves go to the longest waiting claimant
+	for _, key := range released {
+		if _, exists := dm.routes[key]; exists {
+			continue
+		}
+		if waiting := dm.claimants[key]; len(waiting) > 0 {
+			dm.addRoutes(waiting[0])
+		}
+	}
+}
+
+// addRoutes installs a service's routes, queuing it behind the owner of any route
+// another service already holds. Callers hold routesMutex.
+func (dm *DiscoveryManager) addRoutes(service *k8s.DiscoveredService) {
+	for _, serviceRoute := range service.Routes() {
+		key := serviceRoute.Key()
+		if owner, exists := dm.routes[key]; exists && !ownsRoute(owner, service) {
+			dm.queueClaimant(key, service)
+			continue
+		}
+		dm.dequeueClaimant(key, service)
+
 		route := &DynamicRoute{
-			Path:         service.Path,
-			Method:       service.Method,
+			Path:         serviceRoute.Path,
+			Method:       serviceRoute.Method,
 			ServiceName:  service.Name,
 			Namespace:    service.Namespace,
 			AuthRequired: service.AuthRequired,
@@ -245,13 +527,52 @@ func (dm *DiscoveryManager) updateRoutes(event k8s.ServiceEvent) {
 			Service:      service,
 			LastUpdated:  time.Now(),
 		}
-		dm.routes[routeKey] = route
-		log.Printf("Route updated: %s %s -> %s (%d endpoints)",
-			route.Method, route.Path, route.ServiceName, len(route.Endpoints))
+		dm.routes[key] = route
+		dm.logger.Info("Route updated", map[string]interface{}{
+			"method":    route.Method,
+			"path":      route.Path,
+			"service":   route.ServiceName,
+			"endpoints": len(route.Endpoints),
+		})
+	}
+}
 
-	case k8s.ServiceDeleted:
-		delete(dm.routes, routeKey)
-		log.Printf("Route removed: %s %s", service.Method, service.Path)
+// queueClaimant adds a service to a route's waiting list, keeping its place if it
+// is already waiting. Callers hold routesMutex.
+func (dm *DiscoveryManager) queueClaimant(key string, service *k8s.DiscoveredService) {
+	waiting := dm.claimants[key]
+	for i, claimant := range waiting {
+		if claimant.Name == service.Name && claimant.Namespace == service.Namespace {
+			waiting[i] = service
+			return
+		}
+	}
+	dm.claimants[key] = append(waiting, service)
+}
+
+// dequeueClaimant removes a service from a route's waiting list. Callers hold routesMutex.
+func (dm *DiscoveryManager) dequeueClaimant(key string, service *k8s.DiscoveredService) {
+	waiting := dm.claimants[key]
+	for i, claimant := range waiting {
+		if claimant.Name == service.Name && claimant.Namespace == service.Namespace {
+			waiting = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(waiting) == 0 {
+		delete(dm.claimants, key)
+	} else {
+		dm.claimants[key] = waiting
+	}
+}
+
+// dropClaims removes a service from the waiting lists of routes it no longer claims.
+// Callers hold routesMutex.
+func (dm *DiscoveryManager) dropClaims(service *k8s.DiscoveredService, claimed map[string]bool) {
+	for key := range dm.claimants {
+		if !claimed[key] {
+			dm.dequeueClaimant(key, service)
+		}
 	}
 }
 
@@ -265,12 +586,15 @@ func (dm *DiscoveryManager) GetStats() map[string]interface{} {
 		"service_discovery":  dm.config.Kubernetes.ServiceDiscovery,
 		"namespace":          dm.config.Kubernetes.Namespace,
 		"total_routes":       len(dm.routes),
-		"started":            dm.started,
+		"started":            dm.IsStarted(),
 	}
 
 	if dm.serviceDiscovery != nil {
 		services := dm.serviceDiscovery.GetServices()
 		stats["discovered_services"] = len(services)
+		stats["warnings"] = dm.serviceDiscovery.GetWarnings()
+		stats["dropped_events"] = dm.serviceDiscovery.GetDroppedEvents()
+		stats["errors"] = dm.serviceDiscovery.GetErrorStats()
 
 		totalEndpoints := 0
 		healthyEndpoints := 0
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"api-gateway/internal/handlers"
+	"api-gateway/internal/k8s"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRespondIfSyncing(t *testing.T) {
+	tests := []struct {
+		name               string
+		startupUnavailable bool
+		synced             bool
+		wantResponded      bool
+	}{
+		{name: "before sync", startupUnavailable: true, wantResponded: true},
+		{name: "after sync", startupUnavailable: true, synced: true},
+		{name: "disabled before sync"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Kubernetes.StartupUnavailable = tt.startupUnavailable
+			cfg.Kubernetes.StartupRetryAfter = 7 * time.Second
+
+			dm := NewDiscoveryManager(cfg, newTestLogger())
+			if tt.synced {
+				dm = newTestGateway(t, cfg).discovery
+			}
+
+			rec := httptest.NewRecorder()
+			if got := dm.RespondIfSyncing(rec); got != tt.wantResponded {
+				t.Fatalf("RespondIfSyncing = %v, want %v", got, tt.wantResponded)
+			}
+			if !tt.wantResponded {
+				return
+			}
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want 503", rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != "7" {
+				t.Errorf("Retry-After = %q, want 7", got)
+			}
+		})
+	}
+}
+
+func TestResyncRepairsDroppedEvents(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name string
+		// drop puts the route table out of step with discovery, as a dropped event would
+		drop     func(g *testGateway)
+		path     string
+		wantBack bool
+	}{
+		{
+			name: "dropped add restores the route",
+			drop: func(g *testGateway) {
+				service, _ := g.discovery.serviceDiscovery.GetService("orders")
+				g.discovery.handleServiceEvent(k8s.ServiceEvent{Type: k8s.ServiceDeleted, Service: service, Timestamp: time.Now()})
+			},
+			path:     "/orders",
+			wantBack: true,
+		},
+		{
+			name: "dropped delete withdraws the route",
+			drop: func(g *testGateway) {
+				ghost := &k8s.DiscoveredService{
+					Name: "ghost", Namespace: testNamespace, Path: "/ghost", Paths: []string{"/ghost"},
+					Method: http.MethodGet, Methods: []string{http.MethodGet}, LastUpdated: time.Now(),
+				}
+				g.discovery.handleServiceEvent(k8s.ServiceEvent{Type: k8s.ServiceAdded, Service: ghost, Timestamp: time.Now()})
+			},
+			path: "/ghost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newServiceGateway(t, newTestConfig(), "orders", nil, backend.URL)
+
+			tt.drop(g)
+			if present := g.readyEndpoints(http.MethodGet, tt.path) != -1; present == tt.wantBack {
+				t.Fatalf("route %s present = %v before resync", tt.path, present)
+			}
+
+			g.discovery.resync()
+			if present := g.readyEndpoints(http.MethodGet, tt.path) != -1; present != tt.wantBack {
+				t.Errorf("route %s present = %v after resync, want %v", tt.path, present, tt.wantBack)
+			}
+			if tt.wantBack {
+				if rec := g.serve(httptest.NewRequest(http.MethodGet, tt.path, nil)); rec.Code != http.StatusOK {
+					t.Errorf("GET %s = %d after resync, want 200", tt.path, rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestPeriodicResync(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	cfg := newTestConfig()
+	cfg.Kubernetes.ResyncInterval = 20 * time.Millisecond
+	g := newServiceGateway(t, cfg, "orders", nil, backend.URL)
+
+	service, _ := g.discovery.serviceDiscovery.GetService("orders")
+	g.discovery.handleServiceEvent(k8s.ServiceEvent{Type: k8s.ServiceDeleted, Service: service, Timestamp: time.Now()})
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+}
+
+// countingProcessor counts the service events it is given, by type
+type countingProcessor struct {
+	mu     sync.Mutex
+	events map[k8s.ServiceEventType]int
+}
+
+func (p *countingProcessor) ProcessServiceEvent(event k8s.ServiceEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events[event.Type]++
+	return nil
+}
+
+func (p *countingProcessor) count(eventType k8s.ServiceEventType) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.events[eventType]
+}
+
+func TestServiceAddedEventProducesOneRoute(t *testing.T) {
+	g := newTestGateway(t, newTestConfig())
+	processor := &countingProcessor{events: make(map[k8s.ServiceEventType]int)}
+	g.discovery.AddEventProcessor(processor)
+	g.discovery.AddEventProcessor(g.drm) // Already registered by NewDynamicRouteManager, so ignored
+	if got := len(g.discovery.eventProcessors); got != 2 {
+		t.Fatalf("%d event processors registered, want the route manager once and the counter", got)
+	}
+
+	service := testService("orders", nil)
+	if _, err := g.clientset.CoreV1().Services(testNamespace).Create(context.Background(), service, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	eventually(t, func() bool { return processor.count(k8s.ServiceAdded) == 1 })
+
+	g.drm.routesMutex.RLock()
+	dynamicRoutes := len(g.drm.dynamicRoutes)
+	g.drm.routesMutex.RUnlock()
+	g.discovery.routesMutex.RLock()
+	discoveryRoutes := len(g.discovery.routes)
+	g.discovery.routesMutex.RUnlock()
+
+	if dynamicRoutes != 1 || discoveryRoutes != 1 {
+		t.Errorf("routes = %d in the route manager and %d in discovery, want 1 each", dynamicRoutes, discoveryRoutes)
+	}
+}
+
+func TestReadinessWaitsForDiscoverySync(t *testing.T) {
+	// Listing services blocks until released, holding the informer caches unsynced
+	release := make(chan struct{})
+	clientset := fake.NewSimpleClientset(testService("orders", nil))
+	clientset.PrependReactor("list", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+	dm := newTestDiscovery(t, newTestConfig(), clientset)
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	readiness := handlers.NewReadiness()
+	readiness.Register(handlers.NewCheck("discovery_started", dm.CheckStarted))
+	readiness.Register(handlers.NewCheck("discovery_cache_synced", dm.CheckCacheSynced))
+	r := mux.NewRouter()
+	r.HandleFunc("/health", handlers.HealthHandler)
+	r.HandleFunc("/livez", handlers.NewLiveness(dm.CheckLive).Handle)
+	r.HandleFunc("/ready", readiness.Handle)
+	status := func(path string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if got := status("/ready"); got != http.StatusServiceUnavailable {
+		t.Errorf("/ready before start = %d, want 503", got)
+	}
+	startTestDiscovery(t, dm)
+
+	// Warming up: alive but not ready
+	for i := 0; i < 3; i++ {
+		for path, want := range map[string]int{"/health": http.StatusOK, "/livez": http.StatusOK, "/ready": http.StatusServiceUnavailable} {
+			if got := status(path); got != want {
+				t.Fatalf("%s while syncing = %d, want %d", path, got, want)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case <-dm.Synced():
+		t.Fatal("discovery synced before the service list was released")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-dm.Synced():
+	case <-time.After(5 * time.Second):
+		t.Fatal("discovery did not sync")
+	}
+	if got := status("/ready"); got != http.StatusOK {
+		t.Errorf("/ready after sync = %d, want 200", got)
+	}
+}
+
+func TestCheckLiveDoesNotStrandGoroutines(t *testing.T) {
+	dm := NewDiscoveryManager(newTestConfig(), newTestLogger())
+	dm.routesMutex.Lock() // A deadlocked writer
+
+	before := goruntime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		if err := dm.CheckLive(ctx); err == nil {
+			t.Fatal("CheckLive passed while the routes lock was held")
+		}
+		cancel()
+	}
+	if grown := goruntime.NumGoroutine() - before; grown >= 10 {
+		t.Errorf("%d goroutines left behind by failed liveness checks", grown)
+	}
+
+	// A lock released within the deadline passes
+	time.AfterFunc(20*time.Millisecond, dm.routesMutex.Unlock)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := dm.CheckLive(ctx); err != nil {
+		t.Errorf("CheckLive after the lock was released = %v", err)
+	}
+}
+
+func TestConfiguredRouteDefaults(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name         string
+		method       string
+		pathTemplate string
+		annotations  map[string]string
+		wantRoutes   []string // "METHOD /path" routes the service should get
+		wantMissing  []string
+	}{
+		{
+			name:         "built-in defaults",
+			method:       "GET",
+			pathTemplate: "/{name}",
+			wantRoutes:   []string{"GET /orders"},
+			wantMissing:  []string{"POST /orders"},
+		},
+		{
+			name:         "configured method and path prefix",
+			method:       "ANY",
+			pathTemplate: "/api/{namespace}/{name}",
+			wantRoutes:   []string{"GET /api/default/orders", "POST /api/default/orders", "DELETE /api/default/orders"},
+			wantMissing:  []string{"GET /orders"},
+		},
+		{
+			name:         "annotations win over the defaults",
+			method:       "ANY",
+			pathTemplate: "/api/{name}",
+			annotations:  map[string]string{k8s.AnnotationPath: "/purchases", k8s.AnnotationMethod: "POST"},
+			wantRoutes:   []string{"POST /purchases"},
+			wantMissing:  []string{"GET /purchases", "POST /api/orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Kubernetes.DefaultMethod = tt.method
+			cfg.Kubernetes.DefaultPathTemplate = tt.pathTemplate
+			g := newTestGateway(t, cfg, testService("orders", tt.annotations), testEndpoints(t, "orders", backend.URL))
+
+			for _, route := range tt.wantRoutes {
+				method, path, _ := strings.Cut(route, " ")
+				g.waitForEndpoints(t, method, path, 1)
+				if rec := g.serve(httptest.NewRequest(method, path, nil)); rec.Code != http.StatusOK {
+					t.Errorf("%s = %d, want 200", route, rec.Code)
+				}
+			}
+			for _, route := range tt.wantMissing {
+				method, path, _ := strings.Cut(route, " ")
+				if got := g.readyEndpoints(method, path); got != -1 {
+					t.Errorf("%s registered with %d endpoints, want no route", route, got)
+				}
+			}
+		})
+	}
+}
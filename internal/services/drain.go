@@ -0,0 +1,56 @@
+package services
+
+import (
+	"net/http"
+)
+
+// drainRetryAfter is the Retry-After, in seconds, sent for requests to a draining service
+const drainRetryAfter = "30"
+
+// DrainService stops new requests reaching a service's routes while requests already
+// in flight complete, returning how many routes it serves. Draining outlasts route
+// updates from discovery until UndrainService.
+func (drm *DynamicRouteManager) DrainService(name string) int {
+	return drm.setDrained(name, true)
+}
+
+// UndrainService lets a drained service take requests again, returning how many routes it serves
+func (drm *DynamicRouteManager) UndrainService(name string) int {
+	return drm.setDrained(name, false)
+}
+
+// IsDrained reports whether a service is draining
+func (drm *DynamicRouteManager) IsDrained(name string) bool {
+	drm.routesMutex.RLock()
+	defer drm.routesMutex.RUnlock()
+	return drm.drained[name]
+}
+
+func (drm *DynamicRouteManager) setDrained(name string, drained bool) int {
+	drm.routesMutex.Lock()
+	defer drm.routesMutex.Unlock()
+
+	routes := 0
+	for routeKey, route := range drm.dynamicRoutes {
+		if route.ServiceName == name {
+			// Replaced rather than updated in place, like discovery updates
+			updated := *route
+			updated.Drained = drained
+			drm.dynamicRoutes[routeKey] = &updated
+			routes++
+		}
+	}
+
+	if !drained {
+		delete(drm.drained, name)
+	} else if routes > 0 {
+		drm.drained[name] = true
+	}
+	return routes
+}
+
+// writeServiceDraining writes the 503 for a request to a draining service
+func writeServiceDraining(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", drainRetryAfter)
+	http.Error(w, "Service Unavailable - Draining", http.StatusServiceUnavailable)
+}
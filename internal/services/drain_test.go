@@ -0,0 +1,131 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// routeDrained reports the drained flag of a route, false if it is not registered
+func routeDrained(drm *DynamicRouteManager, routeKey string) bool {
+	drm.routesMutex.RLock()
+	defer drm.routesMutex.RUnlock()
+	route, exists := drm.dynamicRoutes[routeKey]
+	return exists && route.Drained
+}
+
+func TestDrainService(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			close(started)
+			<-release
+		}
+	})
+	service := testService("orders", map[string]string{k8s.AnnotationPaths: "/orders"})
+	g := newTestGateway(t, newTestConfig(), service, testEndpoints(t, "orders", backend.URL))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.drm.SetupAdminEndpoints(g.router)
+
+	// A request in flight when draining starts still completes
+	inFlight := make(chan *httptest.ResponseRecorder)
+	go func() { inFlight <- g.serve(httptest.NewRequest(http.MethodGet, "/orders?slow=1", nil)) }()
+	<-started
+
+	steps := []struct {
+		name        string
+		method      string
+		target      string
+		wantStatus  int
+		wantDrained bool
+	}{
+		{name: "drain", method: http.MethodPost, target: "/admin/services/orders/drain", wantStatus: http.StatusOK, wantDrained: true},
+		{name: "new request while draining", method: http.MethodGet, target: "/orders", wantStatus: http.StatusServiceUnavailable, wantDrained: true},
+		{name: "drain is POST only", method: http.MethodGet, target: "/admin/services/orders/drain", wantStatus: http.StatusMethodNotAllowed, wantDrained: true},
+		{name: "unknown service", method: http.MethodPost, target: "/admin/services/payments/drain", wantStatus: http.StatusNotFound, wantDrained: true},
+	}
+	for _, step := range steps {
+		rec := g.serve(httptest.NewRequest(step.method, step.target, nil))
+		if rec.Code != step.wantStatus {
+			t.Errorf("%s: %s %s = %d, want %d", step.name, step.method, step.target, rec.Code, step.wantStatus)
+		}
+		if got := routeDrained(g.drm, "GET:/orders"); got != step.wantDrained || g.drm.IsDrained("orders") != step.wantDrained {
+			t.Errorf("%s: drained = %v, want %v", step.name, got, step.wantDrained)
+		}
+	}
+
+	rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if got := rec.Header().Get("Retry-After"); got != drainRetryAfter {
+		t.Errorf("Retry-After = %q, want %q", got, drainRetryAfter)
+	}
+	close(release)
+	if rec := <-inFlight; rec.Code != http.StatusOK {
+		t.Errorf("in-flight request = %d, want 200", rec.Code)
+	}
+
+	// Routes added by discovery while draining start out drained
+	service = service.DeepCopy()
+	service.Annotations[k8s.AnnotationPaths] = "/orders,/purchases"
+	if _, err := g.clientset.CoreV1().Services(testNamespace).Update(context.Background(), service, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update service: %v", err)
+	}
+	g.waitForEndpoints(t, http.MethodGet, "/purchases", 1)
+	if rec := g.serve(httptest.NewRequest(http.MethodGet, "/purchases", nil)); rec.Code != http.StatusServiceUnavailable || !routeDrained(g.drm, "GET:/purchases") {
+		t.Errorf("GET /purchases on a draining service = %d, want 503", rec.Code)
+	}
+
+	rec = g.serve(httptest.NewRequest(http.MethodPost, "/admin/services/orders/undrain", nil))
+	var body struct {
+		Service string `json:"service"`
+		Drained bool   `json:"drained"`
+		Routes  int    `json:"routes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("undrain = %d %q, %v", rec.Code, rec.Body.String(), err)
+	}
+	if body.Service != "orders" || body.Drained || body.Routes != 2 {
+		t.Errorf("undrain response = %+v, want orders undrained on 2 routes", body)
+	}
+	for _, path := range []string{"/orders", "/purchases"} {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, path, nil)); rec.Code != http.StatusOK {
+			t.Errorf("GET %s after undraining = %d, want 200", path, rec.Code)
+		}
+	}
+	if g.drm.IsDrained("orders") || routeDrained(g.drm, "GET:/orders") {
+		t.Error("orders still drained after undraining")
+	}
+}
+
+func TestDrainDoesNotMutatePublishedRoutes(t *testing.T) {
+	g := newServiceGateway(t, newTestConfig(), "orders", nil, refusedURL(t))
+
+	// Routes handed out by GetRouteInfo are read without the route lock; run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			for _, route := range g.drm.GetRouteInfo() {
+				_ = route.Drained
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		g.drm.DrainService("orders")
+		g.drm.UndrainService("orders")
+	}
+	<-done
+
+	before := g.drm.GetRouteInfo()["GET:/orders"]
+	g.drm.DrainService("orders")
+	if before.Drained {
+		t.Error("draining changed a route already handed out")
+	}
+	if !g.drm.GetRouteInfo()["GET:/orders"].Drained {
+		t.Error("route not drained")
+	}
+}
@@ -1,15 +1,22 @@
 package services
 
 import (
+	"api-gateway/internal/config"
 	"api-gateway/internal/k8s"
 	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/metrics"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -25,15 +32,38 @@ type DynamicRouteManager struct {
 
 	// Route storage
 	dynamicRoutes map[string]*DynamicRouteInfo
+	serviceRoutes map[string][]string               // Route keys registered per service
+	conflicts     map[string]*RouteConflict         // Rejected claims on routes another service serves
+	registered    map[string]*k8s.DiscoveredService // Services added through RegisterRoute, by name
+	drained       map[string]bool                   // Services refusing new requests, by name
 	routesMutex   sync.RWMutex
 
 	// Enhanced load balancing and circuit breaking
 	loadBalancerManager   *LoadBalancerManager
 	circuitBreakerManager *middleware.CircuitBreakerManager
+	endpointHealth        *EndpointHealthChecker
+
+	// Gateway configuration shared with the discovery manager
+	config         *config.Config
+	transport      http.RoundTripper
+	connections    *gatewayproxy.ConnectionTracker
+	proxies        *proxyCache
+	credentials    *upstreamCredentials // Basic credentials injected for gateway.io/upstream-auth
+	bulkheads      *bulkheads           // In-flight requests per service, capped by gateway.io/max-concurrent
+	coalescer      *coalescer           // Identical GETs in flight to gateway.io/coalesce services
+	responseCache  *responseCache       // GET responses of gateway.io/cache-ttl services
+	defaultBackend *url.URL             // Unmatched requests are proxied here when set
+	mirrors        *mirrorSlots         // Mirrored requests in flight to gateway.io/mirror-service services
+	events         *routeEventNotifier  // Route table changes for the webhook and /admin/events
+	logger         *logger.Logger
 
 	// Statistics
-	stats      *RouteStats
-	statsMutex sync.RWMutex
+	stats          *RouteStats
+	latency        map[string]*latencyWindow
+	overallLatency *latencyWindow
+	statsMutex     sync.RWMutex
+	upstreamErrors *gatewayproxy.ErrorCounter
+	metrics        metrics.Recorder
 }
 
 // DynamicRouteInfo holds information about a dynamic route
@@ -49,6 +79,7 @@ type DynamicRouteInfo struct {
 	CreatedAt     time.Time              `json:"created_at"`
 	LastUsed      time.Time              `json:"last_used"`
 	RequestCount  int64                  `json:"request_count"`
+	Drained       bool                   `json:"drained"` // New requests get a 503 while in-flight ones finish
 }
 
 // RouteStats holds routing statistics
@@ -62,26 +93,42 @@ type RouteStats struct {
 }
 
 // NewDynamicRouteManager creates a new enhanced dynamic route manager
-func NewDynamicRouteManager(router *mux.Router, discoveryManager *DiscoveryManager, authMiddleware *middleware.AuthMiddleware) *DynamicRouteManager {
-	// Circuit breaker configuration
+func NewDynamicRouteManager(router *mux.Router, discoveryManager *DiscoveryManager, authMiddleware *middleware.AuthMiddleware,
+	upstreamErrors *gatewayproxy.ErrorCounter, recorder metrics.Recorder, structuredLogger *logger.Logger) *DynamicRouteManager {
+	drmLogger := structuredLogger.WithComponent("dynamic_routes")
+	if recorder == nil {
+		recorder = metrics.Discard
+	}
+
+	// Circuit breaker configuration; requiring several half-open successes keeps a single
+	// lucky probe from closing the circuit on a flapping backend
+	proxyConfig := discoveryManager.config.Proxy
 	cbConfig := middleware.CircuitBreakerConfig{
-		MaxRequests: 5,
-		Interval:    60 * time.Second,
-		Timeout:     30 * time.Second,
+		MaxRequests:      uint32(proxyConfig.CircuitBreakerMaxRequests),
+		SuccessThreshold: uint32(proxyConfig.CircuitBreakerSuccessThreshold),
+		Interval:         proxyConfig.CircuitBreakerInterval,
+		Timeout:          proxyConfig.CircuitBreakerTimeout,
 		ReadyToTrip: func(counts middleware.Counts) bool {
 			// Trip if we have more than 5 consecutive failures or error rate > 50%
 			return counts.ConsecutiveFailures > 5 ||
 				(counts.Requests > 10 && counts.ErrorRate() > 0.5)
 		},
 		OnStateChange: func(name string, from middleware.CircuitBreakerState, to middleware.CircuitBreakerState) {
-			log.Printf("Circuit breaker for service '%s' changed from %s to %s", name, from, to)
+			drmLogger.Warn("Circuit breaker state changed", map[string]interface{}{
+				"service": name,
+				"from":    from.String(),
+				"to":      to.String(),
+			})
+			// 0 closed, 1 half-open, 2 open
+			recorder.SetGauge("gateway_circuit_breaker_state", metrics.Labels{"service": name}, float64(to))
 		},
 		IsSuccessful: func(err error) bool {
-			// Consider network errors as failures, but not circuit breaker errors
+			// Consider network errors and server error responses as failures, but not circuit breaker errors
 			if err == nil {
 				return true
 			}
-			return !isNetworkError(err)
+			var statusErr *upstreamStatusError
+			return !errors.As(err, &statusErr) && !isNetworkError(err)
 		},
 	}
 
@@ -90,13 +137,71 @@ func NewDynamicRouteManager(router *mux.Router, discoveryManager *DiscoveryManag
 		discoveryManager:      discoveryManager,
 		authMiddleware:        authMiddleware,
 		dynamicRoutes:         make(map[string]*DynamicRouteInfo),
+		serviceRoutes:         make(map[string][]string),
+		conflicts:             make(map[string]*RouteConflict),
+		registered:            make(map[string]*k8s.DiscoveredService),
+		drained:               make(map[string]bool),
 		loadBalancerManager:   NewLoadBalancerManager(),
 		circuitBreakerManager: middleware.NewCircuitBreakerManager(cbConfig),
 		stats: &RouteStats{
 			RouteStats: make(map[string]int64),
 		},
+		latency:        make(map[string]*latencyWindow),
+		overallLatency: newLatencyWindow(),
+		upstreamErrors: upstreamErrors,
+		metrics:        recorder,
+		config:         discoveryManager.config,
+		connections:    gatewayproxy.NewConnectionTracker(),
+		credentials:    newUpstreamCredentials(discoveryManager.GetBasicAuthSecret),
+		bulkheads:      newBulkheads(),
+		coalescer:      newCoalescer(),
+		responseCache:  newResponseCache(),
+		mirrors:        newMirrorSlots(maxMirrorsInFlight),
+		logger:         drmLogger,
+	}
+
+	drm.events = newRouteEventNotifier(drm.config.Kubernetes.RouteEventsWebhookURL, drm.config.Kubernetes.RouteEventsDebounce,
+		drm.config.Kubernetes.RouteEventsMaxDelay, drmLogger)
+	// HTTPS backends are verified against their service's name rather than the pod IP dialed
+	upstreamTransport := newUpstreamTransport(drm.config, drm.connections, drmLogger)
+	drm.transport = gatewayproxy.NewServerNameTransport(upstreamTransport)
+	drm.loadBalancerManager.SetDefaultStrategy(drm.config.Proxy.LoadBalancing)
+
+	// A zone read from the gateway's node is only known once discovery has synced
+	go func() {
+		<-discoveryManager.Synced()
+		if zone := discoveryManager.LocalZone(); zone != "" {
+			drm.loadBalancerManager.SetLocalZone(zone)
+			drmLogger.Info("Zone-aware load balancing prefers local zone", map[string]interface{}{
+				"zone": zone,
+			})
+		}
+	}()
+
+	grpcTransport := gatewayproxy.NewServerNameTransport(gatewayproxy.NewGRPCTransport(upstreamTransport))
+	drm.proxies = newProxyCache(drm.transport, grpcTransport, upstreamErrors,
+		gatewayproxy.NewBufferPool(drm.config.Proxy.BufferSize), drm.logUpstreamError)
+
+	if backend := drm.config.Proxy.DefaultBackend; backend != "" {
+		target, err := parseBackendURL(backend)
+		if err != nil {
+			drmLogger.Error("Ignoring invalid default backend", map[string]interface{}{
+				"error": err,
+			})
+		} else {
+			drm.defaultBackend = target
+		}
 	}
 
+	healthMethod, healthyStatus := http.MethodGet, gatewayproxy.DefaultHealthyStatus
+	if method, err := gatewayproxy.ParseHealthCheckMethod(drm.config.Health.Method); err == nil {
+		healthMethod = method
+	}
+	if status, err := gatewayproxy.ParseStatusRange(drm.config.Health.ExpectedStatus); err == nil {
+		healthyStatus = status
+	}
+	drm.endpointHealth = NewEndpointHealthChecker(drm.loadBalancerManager, drm.transport, healthMethod, healthyStatus, structuredLogger)
+
 	discoveryManager.AddEventProcessor(drm)
 	drm.registerDynamicHandler()
 
@@ -104,230 +209,655 @@ func NewDynamicRouteManager(router *mux.Router, discoveryManager *DiscoveryManag
 }
 
 // registerDynamicHandler registers the catch-all dynamic route handler
+// The handler only matches requests with a dynamic route, so routes registered
+// later and the router's NotFoundHandler aren't shadowed by a catch-all.
 func (drm *DynamicRouteManager) registerDynamicHandler() {
-	drm.router.PathPrefix("/").HandlerFunc(drm.handleDynamicRoute)
-	log.Println("Enhanced dynamic route handler registered with PathPrefix")
+	drm.router.MatcherFunc(drm.matchDynamicRoute).HandlerFunc(drm.handleDynamicRoute)
+	drm.logger.Info("Enhanced dynamic route handler registered")
+}
+
+// matchDynamicRoute is a mux matcher reporting whether a dynamic route exists for the request
+func (drm *DynamicRouteManager) matchDynamicRoute(r *http.Request, _ *mux.RouteMatch) bool {
+	drm.routesMutex.RLock()
+	defer drm.routesMutex.RUnlock()
+
+	return drm.lookupRoute(r.Method, r.URL.Path) != nil
+}
+
+// RequestTimeout returns the deadline override for a request to a dynamic route:
+// none for streams, which outlive any fixed budget, else the route's annotation.
+// The route event stream has no deadline whatever the client accepts.
+func (drm *DynamicRouteManager) RequestTimeout(r *http.Request) (time.Duration, bool) {
+	if r.URL.Path == routeEventsPath {
+		return 0, true
+	}
+
+	drm.routesMutex.RLock()
+	route := drm.lookupRoute(r.Method, r.URL.Path)
+	drm.routesMutex.RUnlock()
+	if route == nil || route.Service == nil {
+		return 0, false
+	}
+
+	if streaming, _ := streamingMode(r, route.Service); streaming {
+		return 0, true
+	}
+	if route.Service.RequestTimeout > 0 {
+		return route.Service.RequestTimeout, true
+	}
+	return 0, false
 }
 
 // handleDynamicRoute handles all dynamic routes with enhanced load balancing and circuit breaking
 func (drm *DynamicRouteManager) handleDynamicRoute(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
+	contextLogger := drm.logger.WithContext(r.Context()).WithComponent("proxy")
+	requestFields := map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	}
 
 	route := drm.findMatchingRoute(r.Method, r.URL.Path)
 	if route == nil {
-		log.Printf("No dynamic route found for %s %s", r.Method, r.URL.Path)
+		if drm.discoveryManager.RespondIfSyncing(w) {
+			contextLogger.Info("Discovery still syncing, deferring request", requestFields)
+			return
+		}
+		if drm.ServeDefaultBackend(w, r) {
+			return
+		}
+		// The route was removed between matching and handling
+		contextLogger.Warn("No dynamic route found", requestFields)
+		WriteNotFound(w, r)
 		return
 	}
 
-	log.Printf("Dynamic route matched: %s %s -> service: %s", r.Method, r.URL.Path, route.ServiceName)
+	requestFields["service"] = route.ServiceName
+	contextLogger.Debug("Dynamic route matched", requestFields)
+
+	if drm.IsDrained(route.ServiceName) {
+		contextLogger.Info("Service draining, rejecting request", requestFields)
+		writeServiceDraining(w)
+		drm.incrementErrorStats()
+		return
+	}
 
 	drm.updateRouteStats(route, startTime)
 
+	if clientIP := middleware.ClientIP(r); !clientAllowed(route.Service, clientIP) {
+		contextLogger.Warn("Client IP not allowed", requestFields, map[string]interface{}{
+			"client_ip": clientIP,
+		})
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		drm.incrementErrorStats()
+		return
+	}
+
+	// Authenticate before touching load balancer or breaker state, so anonymous
+	// requests can neither skew it nor tell an unhealthy backend from a bad token
+	var authenticated bool
+	if r, authenticated = drm.checkAuthentication(w, r, route); !authenticated {
+		contextLogger.Warn("Authentication failed", requestFields)
+		drm.incrementErrorStats()
+		return
+	}
+	contextLogger = drm.logger.WithContext(r.Context()).WithComponent("proxy") // Picks up an optional token's user
+
+	backend := drm.selectBackend(r, route)
+	if backend != route.Service {
+		requestFields["canary"] = backend.Name
+	}
+
+	streaming, grpc := streamingMode(r, route.Service)
+
+	// Fresh cached responses are served without contacting the upstream or
+	// touching load balancer and breaker state
+	caching := cacheableRequest(r, backend, streaming)
+	if caching {
+		if entry, hit := drm.responseCache.lookup(r, backend); hit {
+			contextLogger.Debug("Served cached response", requestFields)
+			entry.write(w, drm.responseCache.clock.Now())
+			drm.incrementSuccessStats()
+			drm.recordLatency(route, time.Since(startTime), false)
+			return
+		}
+		w.Header().Set("X-Cache", "MISS")
+	}
+
 	// Enhanced endpoint selection with load balancing and circuit breaking
-	endpoint := drm.selectHealthyEndpointEnhanced(route.ServiceName, route.Service.Endpoints)
+	endpoint, selectErr := drm.selectHealthyEndpointEnhanced(backend.Name, backend.LoadBalancing, backend.Endpoints)
 	if endpoint.IP == "" {
-		log.Printf("No healthy endpoint available for service: %s", route.ServiceName)
+		contextLogger.Warn("No healthy endpoint available", requestFields)
+		if drm.serveFallback(w, r, route) {
+			return
+		}
+		if middleware.IsCircuitBreakerRejection(selectErr) {
+			drm.writeCircuitBreakerOpen(w, backend.Name)
+			drm.incrementErrorStats()
+			return
+		}
 		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 		drm.incrementErrorStats()
 		return
 	}
 
-	log.Printf("Selected endpoint: %s:%d for service: %s", endpoint.IP, endpoint.Port, route.ServiceName)
+	contextLogger.Debug("Selected endpoint", requestFields, map[string]interface{}{
+		"endpoint": endpointKey(endpoint),
+	})
+
+	// Identical GETs in flight share one upstream call; the waiters take no bulkhead slot
+	var call *coalescedCall
+	var callKey string
+	if coalescable(r, backend, streaming) {
+		key := coalesceKey(r, backend)
+		if inFlight, leader := drm.coalescer.begin(key); leader {
+			call, callKey = inFlight, key
+		} else if response := inFlight.wait(r.Context()); response != nil {
+			contextLogger.Debug("Served coalesced response", requestFields)
+			response.write(w)
+			drm.incrementSuccessStats()
+			drm.recordLatency(route, time.Since(startTime), false)
+			return
+		}
+	}
+
+	// Only a response from the upstream is shared. When the gateway answers itself, for
+	// a full bulkhead or a rejected body, waiters go to the upstream on their own.
+	proxied := false
+	if caching || call != nil {
+		// The cache is keyed on the headers as the client sent them, before upstream auth replaces any
+		cacheRequest := r.Clone(r.Context())
+		cw := newCaptureWriter(w)
+		w = cw
+		defer func() {
+			var response *sharedResponse
+			if proxied {
+				response = cw.response(r.Context())
+			}
+			if call != nil {
+				drm.coalescer.finish(callKey, call, response)
+			}
+			if caching {
+				drm.responseCache.store(cacheRequest, backend, response, backend.CacheTTL)
+			}
+		}()
+	}
+
+	// The slot is held for the whole exchange, body upload and retries included
+	if !drm.bulkheads.acquire(backend.Name, backend.MaxConcurrent) {
+		contextLogger.Warn("Service at its concurrent request limit", requestFields, map[string]interface{}{
+			"max_concurrent": backend.MaxConcurrent,
+		})
+		writeBulkheadFull(w)
+		drm.incrementErrorStats()
+		return
+	}
+	defer drm.bulkheads.release(backend.Name)
+
+	// gRPC streams are full duplex, so their bodies are neither capped nor buffered for retries
+	if grpc {
+		requestFields["protocol"] = k8s.ProtocolGRPC
+	}
 
-	if route.AuthRequired {
-		if !drm.checkAuthentication(w, r) {
-			log.Printf("Authentication failed for %s %s", r.Method, r.URL.Path)
+	var body []byte
+	var err error
+	replayable := false
+	if !grpc {
+		// Cap the body before buffering so retries can never hold more than the limit
+		if !middleware.LimitRequestBody(w, r, drm.maxBodyBytes(route)) {
 			drm.incrementErrorStats()
 			return
 		}
+
+		if err := drm.transformRequestBody(r, backend); err != nil {
+			switch {
+			case middleware.IsRequestBodyTooLarge(err):
+				contextLogger.Warn("Request body too large", requestFields, map[string]interface{}{
+					"error": err,
+				})
+				middleware.WriteRequestBodyTooLarge(w)
+				drm.incrementErrorStats()
+				return
+			case errors.Is(err, gatewayproxy.ErrInvalidJSONBody) && !backend.RequestTransform.RejectInvalid:
+				contextLogger.Warn("Request body not transformed, forwarding it unchanged", requestFields, map[string]interface{}{
+					"error": err,
+				})
+			default:
+				contextLogger.Warn("Request body rejected by transform", requestFields, map[string]interface{}{
+					"error": err,
+				})
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				drm.incrementErrorStats()
+				return
+			}
+		}
+
+		// Buffer the body up front so it can be replayed if the first endpoint can't be reached
+		body, replayable, err = drm.bufferRequestBody(r)
+		if err != nil {
+			contextLogger.Warn("Request body too large", requestFields, map[string]interface{}{
+				"error": err,
+			})
+			middleware.WriteRequestBodyTooLarge(w)
+			drm.incrementErrorStats()
+			return
+		}
+
+		drm.mirrorRequest(r, route, body, replayable)
 	}
 
-	if err := drm.proxyRequestEnhanced(w, r, route, endpoint); err != nil {
-		log.Printf("Proxy error for route %s %s: %v", route.Method, route.Path, err)
-		if !strings.Contains(err.Error(), "circuit breaker") {
-			http.Error(w, "Service Temporarily Unavailable", http.StatusServiceUnavailable)
+	if backend.UpstreamAuth != "" {
+		authorization, err := drm.credentials.authorization(r.Context(), backend)
+		if err != nil {
+			contextLogger.Error("Upstream credentials unavailable", requestFields, map[string]interface{}{
+				"upstream_auth": backend.UpstreamAuth,
+				"error":         err,
+			})
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			drm.incrementErrorStats()
+			return
+		}
+		// The client's own Authorization was meant for the gateway, so it is replaced
+		r.Header.Set("Authorization", authorization)
+	}
+
+	if streaming {
+		// Lift the server's write timeout so long-lived responses aren't cut off
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			contextLogger.Debug("Could not clear write deadline for streaming route", requestFields, map[string]interface{}{
+				"error": err,
+			})
+		}
+	}
+
+	maxAttempts := drm.config.Proxy.MaxAttempts
+	if maxAttempts < 1 || !replayable {
+		maxAttempts = 1
+	}
+
+	attempt := 0
+	for attempt < maxAttempts {
+		attempt++
+
+		if attempt > 1 {
+			// A breaker tripping now still reports the upstream failure that tripped it
+			endpoint, _ = drm.selectHealthyEndpointEnhanced(backend.Name, backend.LoadBalancing, backend.Endpoints)
+			if endpoint.IP == "" {
+				break
+			}
+			contextLogger.Info("Retrying request on another endpoint", requestFields, map[string]interface{}{
+				"endpoint":     endpointKey(endpoint),
+				"attempt":      attempt,
+				"max_attempts": maxAttempts,
+			})
+		}
+
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+		}
+
+		err = drm.proxyRequestEnhanced(w, r, route, backend, endpoint)
+		if err == nil || !isRetryableUpstreamError(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		contextLogger.Error("Proxy request failed", requestFields, map[string]interface{}{
+			"route":    route.ID,
+			"attempts": attempt,
+			"error":    err,
+		})
+		var upstreamErr *upstreamError
+		switch {
+		case middleware.IsRequestBodyTooLarge(err):
+			middleware.WriteRequestBodyTooLarge(w)
+		case middleware.IsCircuitBreakerRejection(err):
+			// Rejected by the circuit breaker before reaching the upstream
+			drm.writeCircuitBreakerOpen(w, backend.Name)
+		case attempt > 1 && errors.As(err, &upstreamErr):
+			writeRetriesExhausted(w, backend.Name, attempt, upstreamErr)
+		case errors.Is(err, errResponseHeaderTimeout):
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+		default:
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
 		}
 		drm.incrementErrorStats()
+		drm.recordLatency(route, time.Since(startTime), true)
 		return
 	}
 
+	proxied = true
 	drm.incrementSuccessStats()
-	log.Printf("Successfully proxied %s %s to %s:%d", r.Method, r.URL.Path, endpoint.IP, endpoint.Port)
+	drm.recordLatency(route, time.Since(startTime), false)
+	contextLogger.Info("Successfully proxied request", requestFields, map[string]interface{}{
+		"endpoint": endpointKey(endpoint),
+		"duration": time.Since(startTime),
+	})
 }
 
-// selectHealthyEndpointEnhanced uses load balancing and circuit breaking
-func (drm *DynamicRouteManager) selectHealthyEndpointEnhanced(serviceName string, endpoints []k8s.ServiceEndpoint) k8s.ServiceEndpoint {
-	// Get or create load balancer for this service with configured strategy
-	strategy := "round-robin" // Default, could be read from service annotations
+// maxBodyBytes returns the body limit for a route, preferring its annotation override
+func (drm *DynamicRouteManager) maxBodyBytes(route *DynamicRouteInfo) int64 {
+	if route.Service != nil && route.Service.MaxBodyBytes > 0 {
+		return route.Service.MaxBodyBytes
+	}
+	return drm.config.Proxy.MaxBodyBytes
+}
+
+// writeCircuitBreakerOpen responds 503 with a Retry-After taken from the named breaker
+func (drm *DynamicRouteManager) writeCircuitBreakerOpen(w http.ResponseWriter, breakerName string) {
+	middleware.WriteCircuitBreakerOpen(w, drm.circuitBreakerManager.GetCircuitBreaker(breakerName).RetryAfter())
+}
 
+// selectHealthyEndpointEnhanced uses load balancing and circuit breaking. The error is
+// the breaker's rejection, if any; an empty endpoint without one means none is healthy.
+func (drm *DynamicRouteManager) selectHealthyEndpointEnhanced(serviceName, strategy string, endpoints []k8s.ServiceEndpoint) (k8s.ServiceEndpoint, error) {
+	// Get or create load balancer for this service with configured strategy
 	lb := drm.loadBalancerManager.GetOrCreateLoadBalancer(serviceName, strategy)
 
 	// Update endpoints in load balancer
 	lb.UpdateEndpoints(endpoints)
 
-	// Get circuit breaker for this service
-	cb := drm.circuitBreakerManager.GetCircuitBreaker(serviceName)
-
-	// Try to get an endpoint through circuit breaker
-	result, err := cb.Execute(func() (interface{}, error) {
-		endpoint := lb.SelectEndpoint()
-		if endpoint.IP == "" {
-			return nil, errors.New("no healthy endpoints available")
-		}
-		return endpoint, nil
-	})
-
-	if err != nil {
-		log.Printf("Circuit breaker blocked request to service %s: %v", serviceName, err)
-		return k8s.ServiceEndpoint{}
+	// The breaker only decides whether to fail fast here; the request itself is
+	// recorded once, by the Execute in proxyRequestEnhanced
+	if drm.circuitBreakerManager.GetCircuitBreaker(serviceName).State() == middleware.StateOpen {
+		drm.logger.Warn("Circuit breaker blocked request", map[string]interface{}{
+			"service": serviceName,
+		})
+		return k8s.ServiceEndpoint{}, middleware.ErrOpenState
 	}
 
-	return result.(k8s.ServiceEndpoint)
+	return lb.SelectEndpoint(), nil
 }
 
-// proxyRequestEnhanced handles request proxying with circuit breaker protection
-func (drm *DynamicRouteManager) proxyRequestEnhanced(w http.ResponseWriter, r *http.Request, route *DynamicRouteInfo, endpoint k8s.ServiceEndpoint) error {
-	startTime := time.Now()
-
+// proxyRequestEnhanced handles request proxying with circuit breaker protection; backend is
+// the service actually serving the request, which differs from the route's for canary traffic
+func (drm *DynamicRouteManager) proxyRequestEnhanced(w http.ResponseWriter, r *http.Request, route *DynamicRouteInfo, backend *k8s.DiscoveredService, endpoint k8s.ServiceEndpoint) error {
 	// Get circuit breaker for this service
-	cb := drm.circuitBreakerManager.GetCircuitBreaker(route.ServiceName)
+	cb := drm.circuitBreakerManager.GetCircuitBreaker(backend.Name)
 
 	// Execute request through circuit breaker
 	_, err := cb.Execute(func() (interface{}, error) {
 		targetURL := &url.URL{
-			Scheme: "http",
-			Host:   fmt.Sprintf("%s:%d", endpoint.IP, endpoint.Port),
+			Scheme: gatewayproxy.NormalizeScheme(backend.Scheme),
+			Host:   endpointKey(endpoint),
 		}
 
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
-		// Enhanced proxy director with better error handling
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.URL.Host = targetURL.Host
-			req.URL.Scheme = targetURL.Scheme
-			req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
-			req.Header.Set("X-Gateway-Service", route.ServiceName)
-			req.Header.Set("X-Gateway-Endpoint", endpoint.IP)
-			req.Header.Set("X-Request-Start", startTime.Format(time.RFC3339Nano))
-			req.Host = targetURL.Host
+		attempt := &proxyAttempt{
+			service:    backend.Name,
+			endpoint:   endpoint,
+			forwardTLS: route.Service.ForwardTLS,
+			statusMap:  backend.StatusMap,
+			startTime:  time.Now(),
+			writer:     w,
 		}
 
-		// Enhanced error handler
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			duration := time.Since(startTime)
-			log.Printf("Proxy error for service %s (endpoint %s:%d) after %v: %v",
-				route.ServiceName, endpoint.IP, endpoint.Port, duration, err)
+		attemptRequest := r
+		if backend.ServerName != "" {
+			attemptRequest = r.WithContext(gatewayproxy.WithServerName(r.Context(), backend.ServerName))
+		}
+		if backend.HeaderTimeout > 0 {
+			ctx, cancel := context.WithCancelCause(attemptRequest.Context())
+			defer cancel(nil)
+			attempt.headerTimer = time.AfterFunc(backend.HeaderTimeout, func() { cancel(errResponseHeaderTimeout) })
+			defer attempt.headerTimer.Stop()
+			attemptRequest = attemptRequest.WithContext(ctx)
+		}
 
-			// Return error to circuit breaker for evaluation
-			return
+		streaming, grpc := streamingMode(r, backend)
+		defer drm.connections.Begin(backend.Name, targetURL.Host)()
+		defer drm.loadBalancerManager.BeginRequest(backend.Name, endpoint)()
+		flushInterval := drm.config.Proxy.FlushInterval
+		if backend.FlushInterval > 0 {
+			flushInterval = backend.FlushInterval
+		}
+		drm.proxies.get(targetURL, streaming, grpc, flushInterval).ServeHTTP(w, withProxyAttempt(attemptRequest, attempt))
+		if attempt.err == nil && attempt.latency > 0 {
+			drm.loadBalancerManager.RecordLatency(backend.Name, endpoint, attempt.latency)
 		}
 
-		// Execute proxy
-		proxy.ServeHTTP(w, r)
-		return nil, nil
+		// Return the error to the circuit breaker for evaluation. Server errors, judged
+		// after status remapping, count as failures though the response was already sent.
+		if attempt.err == nil && attempt.status >= http.StatusInternalServerError {
+			return nil, &upstreamStatusError{status: attempt.status}
+		}
+		return nil, attempt.err
 	})
 
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return nil
+	}
 	return err
 }
 
+// logUpstreamError logs a failed attempt reported by a cached proxy
+func (drm *DynamicRouteManager) logUpstreamError(r *http.Request, attempt *proxyAttempt, errorType string, err error) {
+	fields := map[string]interface{}{
+		"service":    attempt.service,
+		"error_type": errorType,
+		"duration":   time.Since(attempt.startTime),
+		"error":      err,
+	}
+	if attempt.endpoint.IP != "" {
+		fields["endpoint"] = endpointKey(attempt.endpoint)
+	}
+	drm.logger.WithContext(r.Context()).WithComponent("proxy").Error("Upstream attempt failed", fields)
+}
+
+// pruneProxies drops cached proxies for endpoints no discovered service has anymore,
+// keeping those for the default backend and the services' fallback backends
+func (drm *DynamicRouteManager) pruneProxies() {
+	live := make(map[string]bool)
+	if drm.defaultBackend != nil {
+		live[drm.defaultBackend.Host] = true
+	}
+	for _, service := range drm.discoveryManager.GetDiscoveredServices() {
+		for _, endpoint := range service.Endpoints {
+			live[endpointKey(endpoint)] = true
+		}
+		if target, err := parseBackendURL(service.FallbackBackend); service.FallbackBackend != "" && err == nil {
+			live[target.Host] = true
+		}
+	}
+
+	if removed := drm.proxies.prune(live); removed > 0 {
+		drm.logger.Debug("Dropped cached proxies for removed endpoints", map[string]interface{}{
+			"removed": removed,
+			"cached":  drm.proxies.size(),
+		})
+	}
+}
+
 // ProcessServiceEvent implements EventProcessor interface
 func (drm *DynamicRouteManager) ProcessServiceEvent(event k8s.ServiceEvent) error {
+	var err error
 	switch event.Type {
 	case k8s.ServiceAdded:
-		return drm.addRoute(event.Service)
+		err = drm.addRoute(event.Service)
 	case k8s.ServiceModified:
-		return drm.updateRoute(event.Service)
+		err = drm.updateRoute(event.Service)
 	case k8s.ServiceDeleted:
-		return drm.removeRoute(event.Service)
+		err = drm.removeRoute(event.Service)
 	}
-	return nil
+
+	drm.pruneProxies()
+	return err
 }
 
-// addRoute adds a new dynamic route
+// addRoute adds the dynamic routes for a new service
 func (drm *DynamicRouteManager) addRoute(service *k8s.DiscoveredService) error {
-	drm.routesMutex.Lock()
-	defer drm.routesMutex.Unlock()
-
-	routeKey := fmt.Sprintf("%s:%s", service.Method, service.Path)
+	drm.syncServiceRoutes(service)
+
+	// Create the load balancer up front so endpoint metrics exist before the first request
+	lb := drm.loadBalancerManager.GetOrCreateLoadBalancer(service.Name, service.LoadBalancing)
+	lb.UpdateEndpoints(service.Endpoints)
+	drm.endpointHealth.Watch(service)
+
+	drm.events.publish(serviceEvent(RouteEventAdded, service))
+	drm.logger.Info("Dynamic route added", map[string]interface{}{
+		"methods":        service.Methods,
+		"paths":          service.Paths,
+		"service":        service.Name,
+		"namespace":      service.Namespace,
+		"auth_required":  service.AuthRequired,
+		"load_balancing": service.LoadBalancing,
+		"held":           service.Held(),
+	})
 
-	route := &DynamicRouteInfo{
-		ID:            routeKey,
-		Path:          service.Path,
-		Method:        service.Method,
-		ServiceName:   service.Name,
-		Namespace:     service.Namespace,
-		AuthRequired:  service.AuthRequired,
-		LoadBalancing: service.LoadBalancing,
-		Service:       service,
-		CreatedAt:     time.Now(),
-		LastUsed:      time.Now(),
+	if service.AuthProvider != "" && !drm.authMiddleware.HasProvider(service.AuthProvider) {
+		drm.logger.Warn("Unknown auth provider, authenticated requests to the service will be rejected", map[string]interface{}{
+			"service":       service.Name,
+			"namespace":     service.Namespace,
+			"auth_provider": service.AuthProvider,
+		})
 	}
 
-	drm.dynamicRoutes[routeKey] = route
+	return nil
+}
 
-	// Update load balancer with new endpoints
-	drm.loadBalancerManager.UpdateServiceEndpoints(service.Name, service.Endpoints)
+// updateRoute updates the dynamic routes for an existing service
+func (drm *DynamicRouteManager) updateRoute(service *k8s.DiscoveredService) error {
+	drm.routesMutex.RLock()
+	_, exists := drm.serviceRoutes[serviceKey(service)]
+	drm.routesMutex.RUnlock()
 
-	drm.statsMutex.Lock()
-	drm.stats.TotalRoutes++
-	drm.statsMutex.Unlock()
+	if !exists {
+		return drm.addRoute(service)
+	}
 
-	log.Printf("Dynamic route added: %s %s -> %s.%s (auth: %v, lb: %s)",
-		route.Method, route.Path, route.ServiceName, route.Namespace,
-		route.AuthRequired, route.LoadBalancing)
+	drm.syncServiceRoutes(service)
+
+	// Update load balancer with new endpoints, and a new strategy if the annotation changed
+	drm.loadBalancerManager.GetOrCreateLoadBalancer(service.Name, service.LoadBalancing).UpdateEndpoints(service.Endpoints)
+	drm.endpointHealth.Watch(service)
+
+	drm.events.publish(serviceEvent(RouteEventUpdated, service))
+	drm.logger.Info("Dynamic route updated", map[string]interface{}{
+		"methods":        service.Methods,
+		"paths":          service.Paths,
+		"service":        service.Name,
+		"namespace":      service.Namespace,
+		"load_balancing": service.LoadBalancing,
+	})
 
 	return nil
 }
 
-// updateRoute updates an existing dynamic route
-func (drm *DynamicRouteManager) updateRoute(service *k8s.DiscoveredService) error {
-	routeKey := fmt.Sprintf("%s:%s", service.Method, service.Path)
-
+// removeRoute removes every dynamic route registered for a service
+func (drm *DynamicRouteManager) removeRoute(service *k8s.DiscoveredService) error {
 	drm.routesMutex.Lock()
-	_, exists := drm.dynamicRoutes[routeKey]
-	drm.routesMutex.Unlock()
+	defer drm.routesMutex.Unlock()
 
+	key := serviceKey(service)
+	routeKeys, exists := drm.serviceRoutes[key]
 	if !exists {
-		return drm.addRoute(service)
+		return nil
 	}
 
-	// Update load balancer with new endpoints
-	drm.loadBalancerManager.UpdateServiceEndpoints(service.Name, service.Endpoints)
-
-	drm.routesMutex.Lock()
-	if route, exists := drm.dynamicRoutes[routeKey]; exists {
-		route.Service = service
-		route.LastUsed = time.Now()
-		route.LoadBalancing = service.LoadBalancing
+	for _, routeKey := range routeKeys {
+		delete(drm.dynamicRoutes, routeKey)
 	}
-	drm.routesMutex.Unlock()
+	delete(drm.serviceRoutes, key)
+	drm.clearConflicts(key)
+	drm.endpointHealth.Unwatch(service.Name)
 
-	log.Printf("Dynamic route updated: %s %s -> %s.%s (lb: %s)",
-		service.Method, service.Path, service.Name, service.Namespace, service.LoadBalancing)
+	drm.statsMutex.Lock()
+	drm.stats.TotalRoutes -= int64(len(routeKeys))
+	drm.statsMutex.Unlock()
 
+	drm.events.publish(serviceEvent(RouteEventRemoved, service))
+	drm.logger.Info("Dynamic route removed", map[string]interface{}{
+		"service": service.Name,
+		"routes":  routeKeys,
+	})
+
+	drm.promoteClaimants(routeKeys)
 	return nil
 }
 
-// removeRoute removes a dynamic route
-func (drm *DynamicRouteManager) removeRoute(service *k8s.DiscoveredService) error {
+// syncServiceRoutes makes the route table match the routes a service declares,
+// keeping counters for routes that still exist and dropping ones that don't
+func (drm *DynamicRouteManager) syncServiceRoutes(service *k8s.DiscoveredService) {
 	drm.routesMutex.Lock()
 	defer drm.routesMutex.Unlock()
 
-	routeKey := fmt.Sprintf("%s:%s", service.Method, service.Path)
+	drm.syncServiceRoutesLocked(service)
+}
 
-	if _, exists := drm.dynamicRoutes[routeKey]; exists {
-		delete(drm.dynamicRoutes, routeKey)
+// syncServiceRoutesLocked is syncServiceRoutes with routesMutex held. A route already
+// served by another service stays with it and the claim is recorded as a conflict.
+func (drm *DynamicRouteManager) syncServiceRoutesLocked(service *k8s.DiscoveredService) {
+	key := serviceKey(service)
+	now := time.Now()
+	previousConflicts := drm.clearConflicts(key)
+
+	wanted := make(map[string]bool)
+	routeKeys := make([]string, 0)
+	added := 0
+
+	for _, serviceRoute := range service.Routes() {
+		routeKey := serviceRoute.Key()
+		if wanted[routeKey] {
+			continue
+		}
+		wanted[routeKey] = true
+
+		if route, exists := drm.dynamicRoutes[routeKey]; exists {
+			if owner := route.Namespace + "/" + route.ServiceName; owner != key {
+				drm.recordConflict(routeKey, owner, service, previousConflicts[routeKey])
+				continue
+			}
+
+			// Replaced rather than updated in place: in-flight requests read the
+			// route they matched without holding routesMutex
+			routeKeys = append(routeKeys, routeKey)
+			updated := *route
+			updated.Service = service
+			updated.AuthRequired = service.AuthRequired
+			updated.LoadBalancing = service.LoadBalancing
+			updated.LastUsed = now
+			drm.dynamicRoutes[routeKey] = &updated
+			continue
+		}
 
-		drm.statsMutex.Lock()
-		drm.stats.TotalRoutes--
-		drm.statsMutex.Unlock()
+		routeKeys = append(routeKeys, routeKey)
+		drm.dynamicRoutes[routeKey] = &DynamicRouteInfo{
+			ID:            routeKey,
+			Path:          serviceRoute.Path,
+			Method:        serviceRoute.Method,
+			ServiceName:   service.Name,
+			Namespace:     service.Namespace,
+			AuthRequired:  service.AuthRequired,
+			LoadBalancing: service.LoadBalancing,
+			Service:       service,
+			CreatedAt:     now,
+			LastUsed:      now,
+			Drained:       drm.drained[service.Name],
+		}
+		added++
+	}
 
-		log.Printf("Dynamic route removed: %s %s", service.Method, service.Path)
+	released := make([]string, 0)
+	for _, routeKey := range drm.serviceRoutes[key] {
+		if !wanted[routeKey] {
+			delete(drm.dynamicRoutes, routeKey)
+			released = append(released, routeKey)
+		}
 	}
+	drm.serviceRoutes[key] = routeKeys
 
-	return nil
+	drm.statsMutex.Lock()
+	drm.stats.TotalRoutes += int64(added - len(released))
+	drm.statsMutex.Unlock()
+
+	drm.promoteClaimants(released)
+}
+
+// serviceKey identifies a discovered service across namespaces
+func serviceKey(service *k8s.DiscoveredService) string {
+	return service.Namespace + "/" + service.Name
 }
 
 // findMatchingRoute finds a matching route for the given method and path
@@ -335,40 +865,104 @@ func (drm *DynamicRouteManager) findMatchingRoute(method, path string) *DynamicR
 	drm.routesMutex.RLock()
 	defer drm.routesMutex.RUnlock()
 
-	routeKey := fmt.Sprintf("%s:%s", method, path)
-
-	if route, exists := drm.dynamicRoutes[routeKey]; exists {
-		log.Printf("Exact route match found: %s -> %s", routeKey, route.ServiceName)
+	if route := drm.lookupRoute(method, path); route != nil {
 		return route
 	}
 
-	log.Printf("No route found for: %s", routeKey)
-	log.Printf("Available routes: %v", drm.getRouteKeys())
+	drm.logger.Debug("No route found", map[string]interface{}{
+		"route":            fmt.Sprintf("%s:%s", method, path),
+		"available_routes": drm.getRouteKeys(),
+	})
 	return nil
 }
 
+// lookupRoute returns the highest-precedence route for the request (see routeMatchKind),
+// skipping routes held until their service has a ready endpoint; callers hold routesMutex
+func (drm *DynamicRouteManager) lookupRoute(method, path string) *DynamicRouteInfo {
+	if route, exists := drm.dynamicRoutes[fmt.Sprintf("%s:%s", method, path)]; exists && !routeHeld(route) {
+		return route
+	}
+
+	var candidates []routeCandidate
+	for _, route := range drm.dynamicRoutes {
+		if route.Method != method || routeHeld(route) {
+			continue
+		}
+		if kind := matchRoutePath(route.Path, path); kind != noMatch {
+			candidates = append(candidates, routeCandidate{route: route, kind: kind})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sortCandidates(candidates)
+	return candidates[0].route
+}
+
+// allowedMethods lists the methods of the routes matching path, sorted, or nil when none do
+func (drm *DynamicRouteManager) allowedMethods(path string) []string {
+	drm.routesMutex.RLock()
+	defer drm.routesMutex.RUnlock()
+
+	seen := make(map[string]bool)
+	var methods []string
+	for _, route := range drm.dynamicRoutes {
+		if seen[route.Method] || routeHeld(route) || matchRoutePath(route.Path, path) == noMatch {
+			continue
+		}
+		seen[route.Method] = true
+		methods = append(methods, route.Method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// RespondIfMethodNotAllowed writes a 405 with an Allow header when the request's path has
+// dynamic routes but none for its method, and reports whether it did
+func (drm *DynamicRouteManager) RespondIfMethodNotAllowed(w http.ResponseWriter, r *http.Request) bool {
+	methods := drm.allowedMethods(r.URL.Path)
+	if len(methods) == 0 {
+		return false
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	return true
+}
+
+// routeHeld reports whether a route waits for its service's first ready endpoint
+func routeHeld(route *DynamicRouteInfo) bool {
+	return route.Service != nil && route.Service.Held()
+}
+
 // Helper methods
 func (drm *DynamicRouteManager) getRouteKeys() []string {
-	var keys []string
+	keys := make([]string, 0, len(drm.dynamicRoutes))
 	for k := range drm.dynamicRoutes {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
-func (drm *DynamicRouteManager) checkAuthentication(w http.ResponseWriter, r *http.Request) bool {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header required", http.StatusUnauthorized)
-		return false
+// checkAuthentication applies the route's auth mode, writing a 401 and returning false on
+// failure. With optional auth the returned request carries the identity of a valid token.
+// Tokens are verified against the service's auth provider.
+func (drm *DynamicRouteManager) checkAuthentication(w http.ResponseWriter, r *http.Request, route *DynamicRouteInfo) (*http.Request, bool) {
+	var provider string
+	if route.Service != nil {
+		provider = route.Service.AuthProvider
 	}
 
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		http.Error(w, "Invalid token format", http.StatusUnauthorized)
-		return false
+	switch {
+	case route.AuthRequired:
+		return r, drm.authMiddleware.AuthenticateWith(w, r, provider)
+	case route.Service != nil && route.Service.AuthOptional:
+		return drm.authMiddleware.AuthenticateOptional(w, r, provider)
+	default:
+		return r, true
 	}
-
-	return true
 }
 
 func (drm *DynamicRouteManager) updateRouteStats(route *DynamicRouteInfo, startTime time.Time) {
@@ -419,6 +1013,100 @@ func isNetworkError(err error) bool {
 	return false
 }
 
+// RouteDetail is one route with the live state of its backend
+type RouteDetail struct {
+	Route          DynamicRouteInfo                `json:"route"`
+	LoadBalancer   *LoadBalancerStats              `json:"load_balancer"`   // Nil until the service gets traffic
+	CircuitBreaker *middleware.CircuitBreakerStats `json:"circuit_breaker"` // Nil until the service gets traffic
+	Latency        *RouteLatencyStats              `json:"latency"`         // Nil until the route gets traffic
+}
+
+// GetRouteDetail returns the route registered for method and path, together with its
+// service's load balancer and circuit breaker stats, or false when there is none
+func (drm *DynamicRouteManager) GetRouteDetail(method, path string) (RouteDetail, bool) {
+	drm.routesMutex.RLock()
+	route, exists := drm.dynamicRoutes[fmt.Sprintf("%s:%s", method, path)]
+	var detail RouteDetail
+	if exists {
+		detail.Route = *route
+	}
+	drm.routesMutex.RUnlock()
+	if !exists {
+		return RouteDetail{}, false
+	}
+
+	if stats, exists := drm.loadBalancerManager.GetLoadBalancerStats(detail.Route.ServiceName); exists {
+		setActiveConnections(&stats, drm.connections.Stats())
+		detail.LoadBalancer = &stats
+	}
+	if stats, exists := drm.circuitBreakerManager.GetStats()[detail.Route.ServiceName]; exists {
+		detail.CircuitBreaker = &stats
+	}
+
+	drm.statsMutex.RLock()
+	if window, exists := drm.latency[detail.Route.ID]; exists {
+		latency := window.snapshot(detail.Route.ID)
+		detail.Latency = &latency
+	}
+	drm.statsMutex.RUnlock()
+
+	return detail, true
+}
+
+// FlushSummary reports what an /admin/flush cleared
+type FlushSummary struct {
+	Routes          int  `json:"routes"`           // Routes whose request counts and latencies were cleared
+	LoadBalancers   int  `json:"load_balancers"`   // Load balancers whose endpoint counters were zeroed
+	CircuitBreakers int  `json:"circuit_breakers"` // Breakers closed and cleared, 0 unless requested
+	BreakersReset   bool `json:"breakers_reset"`
+}
+
+// Flush zeroes the request statistics of every route and load balancer, and closes
+// and clears the circuit breakers too when resetBreakers is set
+func (drm *DynamicRouteManager) Flush(resetBreakers bool) FlushSummary {
+	var summary FlushSummary
+
+	drm.routesMutex.Lock()
+	for _, route := range drm.dynamicRoutes {
+		route.RequestCount = 0
+	}
+	summary.Routes = len(drm.dynamicRoutes)
+	drm.routesMutex.Unlock()
+
+	drm.statsMutex.Lock()
+	drm.stats.TotalRequests = 0
+	drm.stats.SuccessRequests = 0
+	drm.stats.ErrorRequests = 0
+	drm.stats.AvgResponseTime = 0
+	drm.stats.RouteStats = make(map[string]int64)
+	drm.latency = make(map[string]*latencyWindow)
+	drm.statsMutex.Unlock()
+
+	summary.LoadBalancers = drm.loadBalancerManager.ResetStats()
+	if resetBreakers {
+		summary.CircuitBreakers = drm.circuitBreakerManager.ResetAll()
+		summary.BreakersReset = true
+	}
+	return summary
+}
+
+// loadBalancerStats returns every service's load balancer stats with the requests in flight per endpoint
+func (drm *DynamicRouteManager) loadBalancerStats() map[string]LoadBalancerStats {
+	stats := drm.loadBalancerManager.GetAllStats()
+	connections := drm.connections.Stats()
+	for _, serviceStats := range stats {
+		setActiveConnections(&serviceStats, connections)
+	}
+	return stats
+}
+
+// setActiveConnections fills in the requests in flight to each of the stats' endpoints
+func setActiveConnections(stats *LoadBalancerStats, connections map[string]gatewayproxy.ConnectionStats) {
+	for i := range stats.Endpoints {
+		stats.Endpoints[i].ActiveConnections = connections[stats.Endpoints[i].Address].Active
+	}
+}
+
 // GetRouteInfo returns information about all dynamic routes
 func (drm *DynamicRouteManager) GetRouteInfo() map[string]*DynamicRouteInfo {
 	drm.routesMutex.RLock()
@@ -452,15 +1140,196 @@ func (drm *DynamicRouteManager) GetStats() *RouteStats {
 	return stats
 }
 
+// WriteMetrics implements handlers.MetricsCollector for per-service endpoint and connection gauges
+func (drm *DynamicRouteManager) WriteMetrics(w io.Writer) {
+	drm.loadBalancerManager.WriteMetrics(w)
+	fmt.Fprintln(w)
+	drm.connections.WriteMetrics(w)
+	fmt.Fprintln(w)
+	drm.responseCache.WriteMetrics(w)
+	fmt.Fprintln(w)
+	drm.mirrors.WriteMetrics(w)
+}
+
+// ServiceConnectionStats sums a service's upstream connections across its endpoints
+type ServiceConnectionStats struct {
+	Open      int64                                   `json:"open"`
+	Active    int64                                   `json:"active"`
+	Idle      int64                                   `json:"idle"`
+	Endpoints map[string]gatewayproxy.ConnectionStats `json:"endpoints"`
+}
+
+// GetConnectionStats returns upstream connection counts grouped by service
+func (drm *DynamicRouteManager) GetConnectionStats() map[string]*ServiceConnectionStats {
+	stats := make(map[string]*ServiceConnectionStats)
+	for addr, endpoint := range drm.connections.Stats() {
+		service, exists := stats[endpoint.Service]
+		if !exists {
+			service = &ServiceConnectionStats{Endpoints: make(map[string]gatewayproxy.ConnectionStats)}
+			stats[endpoint.Service] = service
+		}
+		service.Open += endpoint.Open
+		service.Active += endpoint.Active
+		service.Idle += endpoint.Idle
+		service.Endpoints[addr] = endpoint
+	}
+	return stats
+}
+
 // Enhanced admin endpoints
 func (drm *DynamicRouteManager) SetupAdminEndpoints(router *mux.Router) {
 	// Load balancer statistics endpoint
 	router.HandleFunc("/admin/load-balancers", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		stats := drm.loadBalancerManager.GetAllStats()
+		stats := drm.loadBalancerStats()
 		json.NewEncoder(w).Encode(stats)
 	}).Methods("GET")
 
+	// Per-route request and latency statistics endpoint
+	router.HandleFunc("/admin/routes/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		response := struct {
+			Overall *RouteStats         `json:"overall"`
+			Routes  []RouteLatencyStats `json:"routes"`
+		}{
+			Overall: drm.GetStats(),
+			Routes:  drm.GetRouteLatencyStats(),
+		}
+		json.NewEncoder(w).Encode(response)
+	}).Methods("GET")
+
+	// Single route detail endpoint; the path may be sent URL-encoded, e.g. GET/%2Fapi%2Fusers
+	router.HandleFunc("/admin/routes/{method}/{path:.+}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		path, err := url.PathUnescape(vars["path"])
+		if err != nil {
+			http.Error(w, "Invalid route path", http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		detail, exists := drm.GetRouteDetail(strings.ToUpper(vars["method"]), path)
+		if !exists {
+			WriteNotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detail)
+	}).Methods("GET")
+
+	// Route registration without Kubernetes; re-posting a name replaces its routes
+	router.HandleFunc("/admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		var spec RouteSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "Invalid route spec", http.StatusBadRequest)
+			return
+		}
+
+		if err := drm.RegisterRoute(spec); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrRouteConflict) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		drm.logger.WithContext(r.Context()).Info("Route registered through the admin API", map[string]interface{}{
+			"name":      spec.Name,
+			"paths":     spec.Paths,
+			"endpoints": spec.Endpoints,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(drm.RegisteredRoutes()[strings.TrimSpace(spec.Name)])
+	}).Methods("POST")
+
+	router.HandleFunc("/admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if err := drm.UnregisterRoute(name); err != nil {
+			WriteNotFound(w, r)
+			return
+		}
+
+		drm.logger.WithContext(r.Context()).Info("Route unregistered through the admin API", map[string]interface{}{
+			"name": name,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+
+	// Service draining for maintenance: new requests get a 503 while in-flight ones finish
+	router.HandleFunc("/admin/services/{name}/{action:drain|undrain}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		name, drain := vars["name"], vars["action"] == "drain"
+
+		var routes int
+		if drain {
+			routes = drm.DrainService(name)
+		} else {
+			routes = drm.UndrainService(name)
+		}
+		if routes == 0 {
+			WriteNotFound(w, r)
+			return
+		}
+
+		drm.logger.WithContext(r.Context()).Info("Service drain state changed", map[string]interface{}{
+			"service": name,
+			"drained": drain,
+			"routes":  routes,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"service": name,
+			"drained": drain,
+			"routes":  routes,
+		})
+	}).Methods("POST")
+
+	// Route table changes as server-sent events, batched like the route events webhook
+	router.HandleFunc(routeEventsPath, drm.events.serveEvents).Methods("GET")
+
+	// Upstream connection pool statistics endpoint
+	router.HandleFunc("/admin/connections", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(drm.GetConnectionStats())
+	}).Methods("GET")
+
+	// Statistics reset for load tests; ?circuit_breakers=true also closes every breaker
+	router.HandleFunc("/admin/flush", func(w http.ResponseWriter, r *http.Request) {
+		resetBreakers, _ := strconv.ParseBool(r.URL.Query().Get("circuit_breakers"))
+		summary := drm.Flush(resetBreakers)
+
+		drm.logger.WithContext(r.Context()).Info("Statistics flushed", map[string]interface{}{
+			"routes":           summary.Routes,
+			"load_balancers":   summary.LoadBalancers,
+			"circuit_breakers": summary.CircuitBreakers,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}).Methods("POST")
+
+	// Annotation problems and rejected route claims, for debugging discovery
+	router.HandleFunc("/admin/discovery/warnings", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		response := struct {
+			Annotations    map[string]string `json:"annotations"`
+			RouteConflicts []RouteConflict   `json:"route_conflicts"`
+		}{
+			Annotations:    drm.discoveryManager.GetWarnings(),
+			RouteConflicts: drm.RouteConflicts(),
+		}
+		json.NewEncoder(w).Encode(response)
+	}).Methods("GET")
+
 	// Circuit breaker statistics endpoint
 	router.HandleFunc("/admin/circuit-breakers", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -479,7 +1348,7 @@ func (drm *DynamicRouteManager) SetupAdminEndpoints(router *mux.Router) {
 			Summary         map[string]interface{}                    `json:"summary"`
 		}{
 			Services:        drm.GetRouteInfo(),
-			LoadBalancers:   drm.loadBalancerManager.GetAllStats(),
+			LoadBalancers:   drm.loadBalancerStats(),
 			CircuitBreakers: drm.circuitBreakerManager.GetStats(),
 		}
 
@@ -512,6 +1381,7 @@ func (drm *DynamicRouteManager) SetupAdminEndpoints(router *mux.Router) {
 			"healthy_services":   healthyServices,
 			"unhealthy_services": totalServices - healthyServices,
 			"open_circuits":      openCircuits,
+			"in_flight_requests": drm.bulkheads.snapshot(),
 			"service_health_rate": func() float64 {
 				if totalServices == 0 {
 					return 100.0
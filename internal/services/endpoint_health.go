@@ -0,0 +1,173 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/clock"
+	"api-gateway/pkg/logger"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// EndpointHealthChecker actively probes discovered endpoints and reports the
+// results to the load balancer. It catches pods that pass Kubernetes readiness
+// but fail their application-level health check.
+type EndpointHealthChecker struct {
+	loadBalancers *LoadBalancerManager
+	client        *http.Client
+	method        string                   // Used when a service's health check sets none
+	healthy       gatewayproxy.StatusRange // Used when a service's health check sets none
+	clock         clock.Clock
+	logger        *logger.Logger
+
+	probes map[string]*endpointProbe // Keyed by service name
+	mutex  sync.Mutex
+}
+
+// endpointProbe is the probing loop for a single service
+type endpointProbe struct {
+	service *k8s.DiscoveredService
+	stopCh  chan struct{}
+}
+
+// NewEndpointHealthChecker creates a checker that probes through the given transport,
+// with method and healthy as the defaults for services that don't set their own
+func NewEndpointHealthChecker(loadBalancers *LoadBalancerManager, transport http.RoundTripper, method string,
+	healthy gatewayproxy.StatusRange, structuredLogger *logger.Logger) *EndpointHealthChecker {
+	return NewEndpointHealthCheckerWithClock(loadBalancers, transport, method, healthy, structuredLogger, clock.Real{})
+}
+
+// NewEndpointHealthCheckerWithClock creates a checker driven by the given clock
+func NewEndpointHealthCheckerWithClock(loadBalancers *LoadBalancerManager, transport http.RoundTripper, method string,
+	healthy gatewayproxy.StatusRange, structuredLogger *logger.Logger, clk clock.Clock) *EndpointHealthChecker {
+	return &EndpointHealthChecker{
+		loadBalancers: loadBalancers,
+		client:        &http.Client{Transport: transport},
+		method:        method,
+		healthy:       healthy,
+		clock:         clk,
+		logger:        structuredLogger.WithComponent("endpoint_health"),
+		probes:        make(map[string]*endpointProbe),
+	}
+}
+
+// Watch starts, restarts or stops probing for a service based on its health check config
+func (hc *EndpointHealthChecker) Watch(service *k8s.DiscoveredService) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	if existing, exists := hc.probes[service.Name]; exists {
+		close(existing.stopCh)
+		delete(hc.probes, service.Name)
+	}
+
+	if service.HealthCheck == nil {
+		return
+	}
+
+	probe := &endpointProbe{
+		service: service,
+		stopCh:  make(chan struct{}),
+	}
+	hc.probes[service.Name] = probe
+
+	go hc.run(probe)
+	hc.logger.Info("Active health checks started", map[string]interface{}{
+		"service":  service.Name,
+		"path":     service.HealthCheck.Path,
+		"interval": service.HealthCheck.Interval.String(),
+	})
+}
+
+// Unwatch stops probing a service and clears its probe results
+func (hc *EndpointHealthChecker) Unwatch(serviceName string) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	if existing, exists := hc.probes[serviceName]; exists {
+		close(existing.stopCh)
+		delete(hc.probes, serviceName)
+		hc.logger.Info("Active health checks stopped", map[string]interface{}{
+			"service": serviceName,
+		})
+	}
+}
+
+// Stop stops probing every service
+func (hc *EndpointHealthChecker) Stop() {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	for name, probe := range hc.probes {
+		close(probe.stopCh)
+		delete(hc.probes, name)
+	}
+}
+
+func (hc *EndpointHealthChecker) run(probe *endpointProbe) {
+	ticker := hc.clock.NewTicker(probe.service.HealthCheck.Interval)
+	defer ticker.Stop()
+
+	hc.CheckService(probe.service)
+
+	for {
+		select {
+		case <-ticker.C():
+			hc.CheckService(probe.service)
+		case <-probe.stopCh:
+			return
+		}
+	}
+}
+
+// CheckService probes every ready endpoint of a service once
+func (hc *EndpointHealthChecker) CheckService(service *k8s.DiscoveredService) {
+	for _, endpoint := range service.Endpoints {
+		if !endpoint.Ready {
+			continue
+		}
+
+		err := hc.probe(service, endpoint)
+		if err != nil {
+			hc.logger.Warn("Endpoint health check failed", map[string]interface{}{
+				"service":  service.Name,
+				"endpoint": endpointKey(endpoint),
+				"error":    err,
+			})
+		}
+		hc.loadBalancers.SetEndpointHealth(service.Name, endpoint, err == nil)
+	}
+}
+
+// probe issues a single health check request and checks the status against the expected range
+func (hc *EndpointHealthChecker) probe(service *k8s.DiscoveredService, endpoint k8s.ServiceEndpoint) error {
+	ctx, cancel := context.WithTimeout(gatewayproxy.WithServerName(context.Background(), service.ServerName), service.HealthCheck.Timeout)
+	defer cancel()
+
+	method, healthy := hc.method, hc.healthy
+	if service.HealthCheck.Method != "" {
+		method = service.HealthCheck.Method
+	}
+	if service.HealthCheck.ExpectedStatus != (gatewayproxy.StatusRange{}) {
+		healthy = service.HealthCheck.ExpectedStatus
+	}
+
+	target := fmt.Sprintf("%s://%s%s", gatewayproxy.NormalizeScheme(service.Scheme), endpointKey(endpoint), service.HealthCheck.Path)
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if !healthy.Contains(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d, want %s", resp.StatusCode, healthy)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serviceEndpoint returns a ready endpoint at the host of an http URL
+func serviceEndpoint(t *testing.T, rawURL string) k8s.ServiceEndpoint {
+	t.Helper()
+	host, portValue, err := net.SplitHostPort(strings.TrimPrefix(rawURL, "http://"))
+	if err != nil {
+		t.Fatalf("endpoint URL %q: %v", rawURL, err)
+	}
+	port, _ := strconv.Atoi(portValue)
+	return k8s.ServiceEndpoint{IP: host, Port: int32(port), Ready: true}
+}
+
+func TestEndpointHealthCheckExcludesFailingEndpoint(t *testing.T) {
+	healthy := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name        string
+		healthCheck k8s.HealthCheck
+		probe       http.HandlerFunc
+		wantHealthy bool
+	}{
+		{
+			name:        "passing probe",
+			probe:       func(w http.ResponseWriter, r *http.Request) {},
+			wantHealthy: true,
+		},
+		{
+			name:  "failing probe",
+			probe: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+		},
+		{
+			name:        "probe path not served",
+			healthCheck: k8s.HealthCheck{Path: "/missing"},
+			probe:       func(w http.ResponseWriter, r *http.Request) {},
+		},
+		{
+			name:        "status outside the service's expected range",
+			healthCheck: k8s.HealthCheck{ExpectedStatus: gatewayproxy.StatusRange{Min: 200, Max: 200}},
+			probe:       func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+		},
+		{
+			name:        "service method override",
+			healthCheck: k8s.HealthCheck{Method: http.MethodHead},
+			probe: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			},
+			wantHealthy: true,
+		},
+		{
+			name:  "probe times out",
+			probe: func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probed := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/healthz" {
+					http.NotFound(w, r)
+					return
+				}
+				tt.probe(w, r)
+			})
+			healthCheck := tt.healthCheck
+			if healthCheck.Path == "" {
+				healthCheck.Path = "/healthz"
+			}
+			healthCheck.Timeout = 50 * time.Millisecond
+			service := &k8s.DiscoveredService{
+				Name:        "orders",
+				Endpoints:   []k8s.ServiceEndpoint{serviceEndpoint(t, healthy.URL), serviceEndpoint(t, probed.URL)},
+				HealthCheck: &healthCheck,
+			}
+
+			lbm := NewLoadBalancerManager()
+			lb := lbm.GetOrCreateLoadBalancer("orders", "round-robin")
+			lb.UpdateEndpoints(service.Endpoints)
+			checker := NewEndpointHealthChecker(lbm, http.DefaultTransport, http.MethodGet, gatewayproxy.DefaultHealthyStatus, newTestLogger())
+			checker.CheckService(service)
+
+			probedAddress := endpointKey(service.Endpoints[1])
+			selected := false
+			for i := 0; i < 10; i++ {
+				if endpointKey(lb.SelectEndpoint()) == probedAddress {
+					selected = true
+				}
+			}
+			if selected != tt.wantHealthy {
+				t.Errorf("probed endpoint selected = %v, want %v", selected, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestEndpointHealthCheckRecovery(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	service := &k8s.DiscoveredService{
+		Name:        "orders",
+		Endpoints:   []k8s.ServiceEndpoint{serviceEndpoint(t, backend.URL)},
+		HealthCheck: &k8s.HealthCheck{Path: "/healthz", Timeout: time.Second},
+	}
+
+	lbm := NewLoadBalancerManager()
+	lb := lbm.GetOrCreateLoadBalancer("orders", "round-robin")
+	lb.UpdateEndpoints(service.Endpoints)
+	checker := NewEndpointHealthChecker(lbm, http.DefaultTransport, http.MethodGet, gatewayproxy.DefaultHealthyStatus, newTestLogger())
+
+	checker.CheckService(service)
+	if stats := lb.GetStats(); stats.HealthyEndpoints != 0 {
+		t.Fatalf("healthy endpoints = %d after a failed probe, want 0", stats.HealthyEndpoints)
+	}
+
+	failing.Store(false)
+	checker.CheckService(service)
+	if stats := lb.GetStats(); stats.HealthyEndpoints != 1 {
+		t.Errorf("healthy endpoints = %d after a passing probe, want 1", stats.HealthyEndpoints)
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultBackendBreaker names the circuit breaker guarding the gateway-wide default backend
+const defaultBackendBreaker = "default-backend"
+
+// ServeDefaultBackend proxies an unmatched request to the configured default backend.
+// It reports false, writing nothing, when no default backend is configured.
+func (drm *DynamicRouteManager) ServeDefaultBackend(w http.ResponseWriter, r *http.Request) bool {
+	if drm.defaultBackend == nil {
+		return false
+	}
+
+	drm.serveBackend(w, r, defaultBackendBreaker, drm.defaultBackend, drm.config.Proxy.MaxBodyBytes)
+	return true
+}
+
+// serveFallback proxies a request for a route without healthy endpoints to the
+// route's fallback backend. It reports false when the route has none.
+func (drm *DynamicRouteManager) serveFallback(w http.ResponseWriter, r *http.Request, route *DynamicRouteInfo) bool {
+	if route.Service == nil || route.Service.FallbackBackend == "" {
+		return false
+	}
+
+	target, err := parseBackendURL(route.Service.FallbackBackend)
+	if err != nil {
+		return false
+	}
+
+	drm.serveBackend(w, r, "fallback:"+route.ServiceName, target, drm.maxBodyBytes(route))
+	return true
+}
+
+// serveBackend proxies a request to a fixed backend through the named circuit breaker,
+// capping the request body at maxBodyBytes
+func (drm *DynamicRouteManager) serveBackend(w http.ResponseWriter, r *http.Request, breakerName string, target *url.URL, maxBodyBytes int64) {
+	startTime := time.Now()
+	contextLogger := drm.logger.WithContext(r.Context()).WithComponent("proxy")
+	fields := map[string]interface{}{
+		"method":  r.Method,
+		"path":    r.URL.Path,
+		"backend": target.String(),
+	}
+
+	if !middleware.LimitRequestBody(w, r, maxBodyBytes) {
+		drm.incrementErrorStats()
+		return
+	}
+
+	cb := drm.circuitBreakerManager.GetCircuitBreaker(breakerName)
+	_, err := cb.Execute(func() (interface{}, error) {
+		attempt := &proxyAttempt{service: breakerName, startTime: time.Now(), writer: w}
+		drm.proxies.get(target, false, false, drm.config.Proxy.FlushInterval).ServeHTTP(w, withProxyAttempt(r, attempt))
+
+		// Server errors count against the breaker though the response was already sent
+		if attempt.err == nil && attempt.status >= http.StatusInternalServerError {
+			return nil, &upstreamStatusError{status: attempt.status}
+		}
+		return nil, attempt.err
+	})
+
+	var statusErr *upstreamStatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		contextLogger.Error("Fallback backend request failed", fields, map[string]interface{}{
+			"error": err,
+		})
+		switch {
+		case middleware.IsRequestBodyTooLarge(err):
+			middleware.WriteRequestBodyTooLarge(w)
+		case middleware.IsCircuitBreakerRejection(err):
+			drm.writeCircuitBreakerOpen(w, breakerName)
+		default:
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		}
+		drm.incrementErrorStats()
+		return
+	}
+
+	drm.incrementSuccessStats()
+	contextLogger.Info("Request served by fallback backend", fields, map[string]interface{}{
+		"duration": time.Since(startTime),
+	})
+}
+
+// parseBackendURL parses a fallback backend URL, requiring an absolute http(s) URL
+func parseBackendURL(raw string) (*url.URL, error) {
+	target, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if (target.Scheme != gatewayproxy.SchemeHTTP && target.Scheme != gatewayproxy.SchemeHTTPS) || target.Host == "" {
+		return nil, fmt.Errorf("backend %q must be an absolute http or https URL", raw)
+	}
+	return target, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDefaultBackendServesUnmatchedPaths(t *testing.T) {
+	seen := make(chan *http.Request, 1)
+	defaultBackend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r
+		w.Write([]byte("default"))
+	})
+	failingBackend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		name        string
+		backend     string
+		wantServed  bool
+		wantStatus  int
+		wantFailure bool
+	}{
+		{name: "no default backend"},
+		{name: "default backend", backend: defaultBackend.URL, wantServed: true, wantStatus: http.StatusOK},
+		{name: "unreachable default backend", backend: refusedURL(t), wantServed: true, wantStatus: http.StatusBadGateway, wantFailure: true},
+		{name: "default backend server error", backend: failingBackend.URL, wantServed: true, wantStatus: http.StatusInternalServerError, wantFailure: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Proxy.DefaultBackend = tt.backend
+			g := newTestGateway(t, cfg)
+
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				served := g.drm.ServeDefaultBackend(rec, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+				if served != tt.wantServed {
+					t.Fatalf("ServeDefaultBackend = %v, want %v", served, tt.wantServed)
+				}
+				if !served {
+					return
+				}
+				if rec.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+				}
+				if tt.wantStatus == http.StatusOK {
+					r := <-seen
+					if r.URL.Path != "/unknown" || r.Header.Get("X-Gateway-Service") != defaultBackendBreaker {
+						t.Errorf("default backend got %s with service %q", r.URL.Path, r.Header.Get("X-Gateway-Service"))
+					}
+				}
+			}
+
+			// The default backend has its own breaker, like any upstream
+			counts := g.drm.circuitBreakerManager.GetCircuitBreaker(defaultBackendBreaker).Counts()
+			if counts.Requests != 2 || (counts.TotalFailures == 2) != tt.wantFailure {
+				t.Errorf("breaker counts = %+v, want 2 requests, failures %v", counts, tt.wantFailure)
+			}
+			// Both requests went through one cached proxy
+			if got := g.drm.proxies.size(); got != 1 {
+				t.Errorf("cached proxies = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestFallbackServesRouteWithoutHealthyEndpoints(t *testing.T) {
+	fallback := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback"))
+	})
+	primary := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("primary"))
+	})
+
+	tests := []struct {
+		name        string
+		endpoints   []string
+		fallback    string
+		wantStatus  int
+		wantBody    string
+		wantBreaker bool // Whether the fallback's breaker saw the request
+	}{
+		{name: "healthy primary", endpoints: []string{primary.URL}, fallback: fallback.URL, wantStatus: http.StatusOK, wantBody: "primary"},
+		{name: "no endpoints uses the fallback", fallback: fallback.URL, wantStatus: http.StatusOK, wantBody: "fallback", wantBreaker: true},
+		{name: "unreachable fallback", fallback: refusedURL(t), wantStatus: http.StatusBadGateway, wantBreaker: true},
+		{name: "no fallback", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tt.fallback != "" {
+				annotations[k8s.AnnotationFallback] = tt.fallback
+			}
+			g := newServiceGateway(t, newTestConfig(), "orders", annotations, tt.endpoints...)
+
+			rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := g.drm.circuitBreakerManager.GetCircuitBreaker("fallback:orders").Counts().Requests == 1; got != tt.wantBreaker {
+				t.Errorf("fallback breaker saw the request = %v, want %v", got, tt.wantBreaker)
+			}
+		})
+	}
+}
+
+func TestFallbackUsesRouteBodyLimit(t *testing.T) {
+	fallback := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("fallback"))
+	})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{
+			k8s.AnnotationMethod:       http.MethodPost,
+			k8s.AnnotationFallback:     fallback.URL,
+			k8s.AnnotationMaxBodyBytes: "8",
+		}),
+		testEndpoints(t, "orders"),
+	)
+	g.waitForEndpoints(t, http.MethodPost, "/orders", 0)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "body within the route limit", body: "small", wantStatus: http.StatusOK},
+		{name: "body over the route limit", body: strings.Repeat("x", 64), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := g.serve(httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// grpcFrame encodes message as an uncompressed gRPC length-prefixed message
+func grpcFrame(message string) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// readGRPCFrame reads one length-prefixed message
+func readGRPCFrame(r io.Reader) (string, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	message := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, message); err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// newH2CServer starts a server that only speaks HTTP/2 without TLS, as gRPC servers do
+func newH2CServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newGRPCEchoBackend starts a gRPC echo service: every message is sent back as
+// soon as it arrives and the status travels in the trailers
+func newGRPCEchoBackend(t *testing.T) *httptest.Server {
+	return newH2CServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "gRPC needs HTTP/2", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		echoed := 0
+		for {
+			message, err := readGRPCFrame(r.Body)
+			if err != nil {
+				break
+			}
+			w.Write(grpcFrame(message))
+			w.(http.Flusher).Flush()
+			echoed++
+		}
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "echoed "+strconv.Itoa(echoed))
+	}))
+}
+
+func TestGRPCEchoThroughGateway(t *testing.T) {
+	backend := newGRPCEchoBackend(t)
+	g := newTestGateway(t, newTestConfig(),
+		testService("echo", map[string]string{
+			k8s.AnnotationProtocol: "grpc",
+			k8s.AnnotationPath:     "/echo.Echo",
+			k8s.AnnotationMethod:   "POST",
+		}),
+		testEndpoints(t, "echo", backend.URL))
+	g.waitForEndpoints(t, http.MethodPost, "/echo.Echo", 1)
+	gateway := newH2CServer(t, g.router)
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	tests := []struct {
+		name     string
+		messages []string
+	}{
+		{name: "unary", messages: []string{"ping"}},
+		{name: "bidirectional stream", messages: []string{"one", "two", "three"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, send := io.Pipe()
+			req, _ := http.NewRequest(http.MethodPost, gateway.URL+"/echo.Echo/Stream", body)
+			req.Header.Set("Content-Type", "application/grpc")
+			req.Header.Set("TE", "trailers")
+
+			// The first message must go out before the response can start
+			go send.Write(grpcFrame(tt.messages[0]))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request through the gateway: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+				t.Fatalf("response = %d over HTTP/%d, want 200 over HTTP/2", resp.StatusCode, resp.ProtoMajor)
+			}
+
+			// Each reply arrives before the next message is sent, so the stream is
+			// carried both ways while it is open rather than buffered
+			for i, message := range tt.messages {
+				if i > 0 {
+					if _, err := send.Write(grpcFrame(message)); err != nil {
+						t.Fatalf("send %q: %v", message, err)
+					}
+				}
+				got, err := readGRPCFrame(resp.Body)
+				if err != nil || got != message {
+					t.Fatalf("reply %d = %q, %v, want %q", i+1, got, err, message)
+				}
+			}
+			send.Close()
+
+			if rest, _ := io.ReadAll(resp.Body); len(rest) > 0 {
+				t.Errorf("unexpected data after the replies: %q", rest)
+			}
+			if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+				t.Errorf("Grpc-Status trailer = %q, want 0", got)
+			}
+			if got, want := resp.Trailer.Get("Grpc-Message"), "echoed "+strconv.Itoa(len(tt.messages)); got != want {
+				t.Errorf("Grpc-Message trailer = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGRPCNeedsProtocolAnnotation(t *testing.T) {
+	backend := newGRPCEchoBackend(t)
+	g := newTestGateway(t, newTestConfig(),
+		testService("echo", map[string]string{k8s.AnnotationPath: "/echo.Echo", k8s.AnnotationMethod: "POST"}),
+		testEndpoints(t, "echo", backend.URL))
+	g.waitForEndpoints(t, http.MethodPost, "/echo.Echo", 1)
+
+	// Without the annotation the call goes over HTTP/1.1, which an h2c-only server can't answer
+	req := httptest.NewRequest(http.MethodPost, "/echo.Echo/Stream", bytes.NewReader(grpcFrame("ping")))
+	req.Header.Set("Content-Type", "application/grpc")
+	if rec := g.serve(req); rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502 without gateway.io/protocol: grpc", rec.Code)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/k8s"
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/jwt"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/metrics"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testNamespace is the namespace test services are discovered in
+const testNamespace = "default"
+
+// newTestLogger returns a logger that only writes fatal entries
+func newTestLogger() *logger.Logger {
+	return logger.NewLogger(logger.Config{Level: "fatal"})
+}
+
+// newTestConfig returns the configuration tests start from
+func newTestConfig() *config.Config {
+	cfg := config.Load()
+	cfg.Kubernetes.StartupUnavailable = false
+	cfg.Kubernetes.ResyncInterval = 0
+	return cfg
+}
+
+// testGateway is a route manager fed by discovery over a fake clientset
+type testGateway struct {
+	router         *mux.Router
+	drm            *DynamicRouteManager
+	discovery      *DiscoveryManager
+	clientset      *fake.Clientset
+	jwt            *jwt.Service
+	upstreamErrors *gatewayproxy.ErrorCounter
+}
+
+// newTestGateway starts discovery of the given Kubernetes objects and waits for it to sync
+func newTestGateway(t *testing.T, cfg *config.Config, objects ...runtime.Object) *testGateway {
+	t.Helper()
+	return newTestGatewayWith(t, cfg, newTestLogger(), nil, objects...)
+}
+
+// newServiceGateway starts a gateway discovering one service with the given annotations,
+// ready at the hosts of urls, and waits for its GET route to have every endpoint
+func newServiceGateway(t *testing.T, cfg *config.Config, name string, annotations map[string]string, urls ...string) *testGateway {
+	t.Helper()
+	g := newTestGateway(t, cfg, testService(name, annotations), testEndpoints(t, name, urls...))
+	g.waitForEndpoints(t, http.MethodGet, "/"+name, len(urls))
+	return g
+}
+
+// newTestGatewayWith is newTestGateway with the route manager logging to routeLogger
+// and reporting metrics to recorder
+func newTestGatewayWith(t *testing.T, cfg *config.Config, routeLogger *logger.Logger, recorder metrics.Recorder, objects ...runtime.Object) *testGateway {
+	t.Helper()
+
+	clientset := fake.NewSimpleClientset(objects...)
+	dm := newTestDiscovery(t, cfg, clientset)
+	g := &testGateway{
+		router:         mux.NewRouter(),
+		discovery:      dm,
+		clientset:      clientset,
+		jwt:            jwt.NewService(cfg.JWT),
+		upstreamErrors: gatewayproxy.NewErrorCounter(),
+	}
+	g.drm = NewDynamicRouteManager(g.router, dm, middleware.NewAuthMiddleware(g.jwt), g.upstreamErrors, recorder, routeLogger)
+	startTestDiscovery(t, dm)
+
+	select {
+	case <-dm.Synced():
+	case <-time.After(5 * time.Second):
+		t.Fatal("discovery did not sync")
+	}
+	return g
+}
+
+// newTestDiscovery returns a discovery manager watching clientset, not yet started
+func newTestDiscovery(t *testing.T, cfg *config.Config, clientset *fake.Clientset) *DiscoveryManager {
+	t.Helper()
+	dm := NewDiscoveryManager(cfg, newTestLogger())
+	dm.k8sClient = &k8s.Client{Clientset: clientset, Namespace: testNamespace}
+	dm.serviceDiscovery = k8s.NewServiceDiscovery(dm.k8sClient, newTestLogger())
+	if err := dm.serviceDiscovery.SetRouteDefaults(cfg.Kubernetes.DefaultMethod, cfg.Kubernetes.DefaultPathTemplate); err != nil {
+		t.Fatalf("SetRouteDefaults: %v", err)
+	}
+	dm.serviceDiscovery.SetDefaultLoadBalancing(cfg.Proxy.LoadBalancing)
+	return dm
+}
+
+// startTestDiscovery starts dm as DiscoveryManager.Start does, without connecting to a
+// cluster, and returns without waiting for it to sync
+func startTestDiscovery(t *testing.T, dm *DiscoveryManager) {
+	t.Helper()
+	go dm.processEvents()
+	go dm.startServiceDiscovery(context.Background())
+	dm.stateMutex.Lock()
+	dm.started = true
+	dm.stateMutex.Unlock()
+	t.Cleanup(dm.Stop)
+}
+
+// captureHook keeps the entries it is fired for
+type captureHook struct {
+	mu      sync.Mutex
+	entries []*logger.LogEntry
+}
+
+func (h *captureHook) Fire(entry *logger.LogEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+	return nil
+}
+func (h *captureHook) Levels() []logger.LogLevel { return nil }
+func (h *captureHook) Synchronous() bool         { return true }
+
+// find returns the first entry with the message, or nil
+func (h *captureHook) find(message string) *logger.LogEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, entry := range h.entries {
+		if entry.Message == message {
+			return entry
+		}
+	}
+	return nil
+}
+
+// serve sends a request through the gateway's router
+func (g *testGateway) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	g.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// readyEndpoints returns how many ready endpoints the route for method and path has
+func (g *testGateway) readyEndpoints(method, path string) int {
+	g.drm.routesMutex.RLock()
+	defer g.drm.routesMutex.RUnlock()
+
+	route := g.drm.lookupRoute(method, path)
+	if route == nil || route.Service == nil {
+		return -1
+	}
+	ready := 0
+	for _, endpoint := range route.Service.Endpoints {
+		if endpoint.Ready {
+			ready++
+		}
+	}
+	return ready
+}
+
+// waitForEndpoints waits until the route for method and path has ready ready endpoints
+func (g *testGateway) waitForEndpoints(t *testing.T, method, path string, ready int) {
+	t.Helper()
+	eventually(t, func() bool { return g.readyEndpoints(method, path) == ready })
+}
+
+// eventually fails the test unless condition becomes true within a few seconds
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// testService returns a gateway-enabled service with the given annotations
+func testService(name string, annotations map[string]string) *corev1.Service {
+	all := map[string]string{k8s.AnnotationEnabled: "true"}
+	for key, value := range annotations {
+		all[key] = value
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Annotations: all},
+	}
+}
+
+// testEndpoints returns the endpoints of a service, ready at the hosts of the given URLs
+func testEndpoints(t *testing.T, name string, urls ...string) *corev1.Endpoints {
+	t.Helper()
+	endpoints := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+	for _, rawURL := range urls {
+		host, portValue, err := net.SplitHostPort(rawURL[len("http://"):])
+		if err != nil {
+			t.Fatalf("endpoint URL %q: %v", rawURL, err)
+		}
+		port, _ := strconv.Atoi(portValue)
+		endpoints.Subsets = append(endpoints.Subsets, corev1.EndpointSubset{
+			Addresses: []corev1.EndpointAddress{{IP: host}},
+			Ports:     []corev1.EndpointPort{{Port: int32(port)}},
+		})
+	}
+	return endpoints
+}
+
+// refusedURL returns an http URL nothing listens on
+func refusedURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return "http://" + address
+}
+
+// newBackend starts an upstream answering every request with handler
+func newBackend(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+	return backend
+}
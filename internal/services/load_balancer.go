@@ -3,8 +3,14 @@ package services
 import (
 	"api-gateway/internal/k8s"
 	"crypto/rand"
+	"fmt"
+	"io"
 	"math/big"
+	"net"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,13 +20,38 @@ type LoadBalancerStrategy interface {
 	Name() string
 }
 
+// weightedStrategy is implemented by strategies that take per-endpoint weights
+type weightedStrategy interface {
+	SetWeights(weights map[string]int)
+}
+
+// latencyAwareStrategy is implemented by strategies that learn from response times
+type latencyAwareStrategy interface {
+	ObserveLatency(endpoint k8s.ServiceEndpoint, latency time.Duration)
+}
+
+// endpointAwareStrategy is implemented by strategies keeping per-endpoint state, so
+// they can forget endpoints that are gone
+type endpointAwareStrategy interface {
+	RetainEndpoints(current map[string]bool)
+}
+
+// connectionAwareStrategy is implemented by strategies that balance on requests in flight
+type connectionAwareStrategy interface {
+	IncrementConnections(endpoint k8s.ServiceEndpoint)
+	DecrementConnections(endpoint k8s.ServiceEndpoint)
+}
+
 // LoadBalancer manages load balancing for services
 type LoadBalancer struct {
-	strategy    LoadBalancerStrategy
-	serviceName string
-	endpoints   []k8s.ServiceEndpoint
-	stats       *LoadBalancerStats
-	mutex       sync.RWMutex
+	strategy     LoadBalancerStrategy
+	strategyName string // Strategy the load balancer was last asked for, after defaulting
+	serviceName  string
+	endpoints    []k8s.ServiceEndpoint
+	probeFailed  map[string]bool // Endpoints failing active health checks, by address
+	selectedAt   map[string]time.Time
+	stats        *LoadBalancerStats
+	mutex        sync.RWMutex
 }
 
 // LoadBalancerStats tracks load balancer statistics
@@ -31,6 +62,19 @@ type LoadBalancerStats struct {
 	LastSelectedTime   time.Time        `json:"last_selected_time"`
 	HealthyEndpoints   int              `json:"healthy_endpoints"`
 	UnhealthyEndpoints int              `json:"unhealthy_endpoints"`
+	Strategy           string           `json:"strategy"`
+	Endpoints          []EndpointStats  `json:"endpoints"`
+}
+
+// EndpointStats is the load balancer's view of one endpoint
+type EndpointStats struct {
+	Address           string    `json:"address"` // host:port, bracketed for IPv6
+	Ready             bool      `json:"ready"`   // Kubernetes reports it ready
+	Healthy           bool      `json:"healthy"` // Ready and passing active health checks, so selectable
+	Weight            int       `json:"weight"`
+	Requests          int64     `json:"requests"`
+	ActiveConnections int64     `json:"active_connections"` // Requests in flight, filled in by the route manager
+	LastSelected      time.Time `json:"last_selected,omitempty"`
 }
 
 // NewLoadBalancer creates a new load balancer with the specified strategy
@@ -39,6 +83,8 @@ func NewLoadBalancer(serviceName string, strategy LoadBalancerStrategy) *LoadBal
 		strategy:    strategy,
 		serviceName: serviceName,
 		endpoints:   make([]k8s.ServiceEndpoint, 0),
+		probeFailed: make(map[string]bool),
+		selectedAt:  make(map[string]time.Time),
 		stats: &LoadBalancerStats{
 			EndpointRequests: make(map[string]int64),
 		},
@@ -51,6 +97,74 @@ func (lb *LoadBalancer) UpdateEndpoints(endpoints []k8s.ServiceEndpoint) {
 	defer lb.mutex.Unlock()
 
 	lb.endpoints = endpoints
+	lb.applyWeights()
+
+	// Forget probe results and selection times for endpoints that no longer exist
+	current := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		current[endpointKey(endpoint)] = true
+	}
+	for address := range lb.probeFailed {
+		if !current[address] {
+			delete(lb.probeFailed, address)
+		}
+	}
+	for address := range lb.selectedAt {
+		if !current[address] {
+			delete(lb.selectedAt, address)
+		}
+	}
+	if endpointAware, ok := lb.strategy.(endpointAwareStrategy); ok {
+		endpointAware.RetainEndpoints(current)
+	}
+
+	lb.updateStats()
+}
+
+// setStrategy replaces the strategy, handing it the current endpoint weights
+func (lb *LoadBalancer) setStrategy(name string, strategy LoadBalancerStrategy) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	lb.strategy = strategy
+	lb.strategyName = name
+	lb.applyWeights()
+}
+
+// applyWeights passes the endpoint weights to strategies that use them; callers hold the mutex
+func (lb *LoadBalancer) applyWeights() {
+	weighted, ok := lb.strategy.(weightedStrategy)
+	if !ok {
+		return
+	}
+	weights := make(map[string]int, len(lb.endpoints))
+	for _, endpoint := range lb.endpoints {
+		if endpoint.Weight > 0 {
+			weights[endpointKey(endpoint)] = endpoint.Weight
+		}
+	}
+	weighted.SetWeights(weights)
+}
+
+// currentStrategy returns the strategy, which GetOrCreateLoadBalancer may swap
+func (lb *LoadBalancer) currentStrategy() LoadBalancerStrategy {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+	return lb.strategy
+}
+
+// SetEndpointHealth records an active health check result. Endpoints marked
+// unhealthy are skipped even when Kubernetes reports them ready.
+func (lb *LoadBalancer) SetEndpointHealth(endpoint k8s.ServiceEndpoint, healthy bool) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	address := endpointKey(endpoint)
+	if healthy {
+		delete(lb.probeFailed, address)
+	} else {
+		lb.probeFailed[address] = true
+	}
 	lb.updateStats()
 }
 
@@ -68,14 +182,45 @@ func (lb *LoadBalancer) SelectEndpoint() k8s.ServiceEndpoint {
 
 	// Update statistics
 	lb.stats.TotalRequests++
-	endpointKey := selected.IP + ":" + string(rune(selected.Port))
-	lb.stats.EndpointRequests[endpointKey]++
-	lb.stats.LastSelected = endpointKey
+	key := endpointKey(selected)
+	lb.stats.EndpointRequests[key]++
+	lb.stats.LastSelected = key
 	lb.stats.LastSelectedTime = time.Now()
+	lb.selectedAt[key] = lb.stats.LastSelectedTime
 
 	return selected
 }
 
+// RecordLatency feeds the time an endpoint took to respond back to strategies that use it
+func (lb *LoadBalancer) RecordLatency(endpoint k8s.ServiceEndpoint, latency time.Duration) {
+	if latencyAware, ok := lb.currentStrategy().(latencyAwareStrategy); ok {
+		latencyAware.ObserveLatency(endpoint, latency)
+	}
+}
+
+// BeginRequest counts a request in flight to endpoint for strategies that use it; call
+// the returned function once the request completes
+func (lb *LoadBalancer) BeginRequest(endpoint k8s.ServiceEndpoint) func() {
+	connectionAware, ok := lb.currentStrategy().(connectionAwareStrategy)
+	if !ok {
+		return func() {}
+	}
+	connectionAware.IncrementConnections(endpoint)
+	return func() { connectionAware.DecrementConnections(endpoint) }
+}
+
+// ResetStats zeroes the request counts and forgets the last selections
+func (lb *LoadBalancer) ResetStats() {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	lb.stats.TotalRequests = 0
+	lb.stats.EndpointRequests = make(map[string]int64)
+	lb.stats.LastSelected = ""
+	lb.stats.LastSelectedTime = time.Time{}
+	lb.selectedAt = make(map[string]time.Time)
+}
+
 // GetStats returns current load balancer statistics
 func (lb *LoadBalancer) GetStats() LoadBalancerStats {
 	lb.mutex.RLock()
@@ -89,31 +234,52 @@ func (lb *LoadBalancer) GetStats() LoadBalancerStats {
 		LastSelectedTime:   lb.stats.LastSelectedTime,
 		HealthyEndpoints:   lb.stats.HealthyEndpoints,
 		UnhealthyEndpoints: lb.stats.UnhealthyEndpoints,
+		Strategy:           lb.strategy.Name(),
+		Endpoints:          make([]EndpointStats, 0, len(lb.endpoints)),
 	}
 
 	for k, v := range lb.stats.EndpointRequests {
 		stats.EndpointRequests[k] = v
 	}
 
+	for _, endpoint := range lb.endpoints {
+		key := endpointKey(endpoint)
+		stats.Endpoints = append(stats.Endpoints, EndpointStats{
+			Address:      key,
+			Ready:        endpoint.Ready,
+			Healthy:      lb.isHealthy(endpoint),
+			Weight:       endpoint.Weight,
+			Requests:     lb.stats.EndpointRequests[key],
+			LastSelected: lb.selectedAt[key],
+		})
+	}
+	sort.Slice(stats.Endpoints, func(i, j int) bool {
+		return stats.Endpoints[i].Address < stats.Endpoints[j].Address
+	})
+
 	return stats
 }
 
 func (lb *LoadBalancer) getHealthyEndpoints() []k8s.ServiceEndpoint {
 	var healthy []k8s.ServiceEndpoint
 	for _, endpoint := range lb.endpoints {
-		if endpoint.Ready {
+		if lb.isHealthy(endpoint) {
 			healthy = append(healthy, endpoint)
 		}
 	}
 	return healthy
 }
 
+func (lb *LoadBalancer) isHealthy(endpoint k8s.ServiceEndpoint) bool {
+	return endpoint.Ready && !lb.probeFailed[endpointKey(endpoint)]
+}
+
 func (lb *LoadBalancer) updateStats() {
 	healthy := 0
 	unhealthy := 0
 
 	for _, endpoint := range lb.endpoints {
-		if endpoint.Ready {
+		if lb.isHealthy(endpoint) {
 			healthy++
 		} else {
 			unhealthy++
@@ -124,14 +290,20 @@ func (lb *LoadBalancer) updateStats() {
 	lb.stats.UnhealthyEndpoints = unhealthy
 }
 
-// RoundRobinStrategy implements round-robin load balancing
+// endpointKey returns the host:port key for an endpoint, bracketing IPv6 addresses
+// so the key is also a valid URL host and matches the transport's dial address
+func endpointKey(endpoint k8s.ServiceEndpoint) string {
+	return net.JoinHostPort(endpoint.IP, strconv.Itoa(int(endpoint.Port)))
+}
+
+// RoundRobinStrategy implements round-robin load balancing. The counter is
+// atomic, so it needs no lock of its own and wraps instead of overflowing.
 type RoundRobinStrategy struct {
-	current int
-	mutex   sync.Mutex
+	current atomic.Uint64
 }
 
 func NewRoundRobinStrategy() *RoundRobinStrategy {
-	return &RoundRobinStrategy{current: 0}
+	return &RoundRobinStrategy{}
 }
 
 func (rr *RoundRobinStrategy) SelectEndpoint(endpoints []k8s.ServiceEndpoint) k8s.ServiceEndpoint {
@@ -139,13 +311,8 @@ func (rr *RoundRobinStrategy) SelectEndpoint(endpoints []k8s.ServiceEndpoint) k8
 		return k8s.ServiceEndpoint{}
 	}
 
-	rr.mutex.Lock()
-	defer rr.mutex.Unlock()
-
-	endpoint := endpoints[rr.current%len(endpoints)]
-	rr.current++
-
-	return endpoint
+	next := rr.current.Add(1) - 1
+	return endpoints[next%uint64(len(endpoints))]
 }
 
 func (rr *RoundRobinStrategy) Name() string {
@@ -166,6 +333,14 @@ func NewWeightedRoundRobinStrategy(weights map[string]int) *WeightedRoundRobinSt
 	}
 }
 
+// SetWeights replaces the endpoint weights, keyed by endpointKey
+func (wrr *WeightedRoundRobinStrategy) SetWeights(weights map[string]int) {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
+	wrr.weights = weights
+}
+
 func (wrr *WeightedRoundRobinStrategy) SelectEndpoint(endpoints []k8s.ServiceEndpoint) k8s.ServiceEndpoint {
 	if len(endpoints) == 0 {
 		return k8s.ServiceEndpoint{}
@@ -178,7 +353,7 @@ func (wrr *WeightedRoundRobinStrategy) SelectEndpoint(endpoints []k8s.ServiceEnd
 	// In production, you might want a more sophisticated algorithm
 	totalWeight := 0
 	for _, endpoint := range endpoints {
-		key := endpoint.IP + ":" + string(rune(endpoint.Port))
+		key := endpointKey(endpoint)
 		if weight, exists := wrr.weights[key]; exists {
 			totalWeight += weight
 		} else {
@@ -194,7 +369,7 @@ func (wrr *WeightedRoundRobinStrategy) SelectEndpoint(endpoints []k8s.ServiceEnd
 	currentWeight := 0
 
 	for _, endpoint := range endpoints {
-		key := endpoint.IP + ":" + string(rune(endpoint.Port))
+		key := endpointKey(endpoint)
 		weight := 1
 		if w, exists := wrr.weights[key]; exists {
 			weight = w
@@ -263,7 +438,7 @@ func (lc *LeastConnectionsStrategy) SelectEndpoint(endpoints []k8s.ServiceEndpoi
 	minConnections := int64(-1)
 
 	for _, endpoint := range endpoints {
-		key := endpoint.IP + ":" + string(rune(endpoint.Port))
+		key := endpointKey(endpoint)
 		connections := lc.connections[key]
 
 		if minConnections == -1 || connections < minConnections {
@@ -279,7 +454,7 @@ func (lc *LeastConnectionsStrategy) IncrementConnections(endpoint k8s.ServiceEnd
 	lc.mutex.Lock()
 	defer lc.mutex.Unlock()
 
-	key := endpoint.IP + ":" + string(rune(endpoint.Port))
+	key := endpointKey(endpoint)
 	lc.connections[key]++
 }
 
@@ -287,7 +462,7 @@ func (lc *LeastConnectionsStrategy) DecrementConnections(endpoint k8s.ServiceEnd
 	lc.mutex.Lock()
 	defer lc.mutex.Unlock()
 
-	key := endpoint.IP + ":" + string(rune(endpoint.Port))
+	key := endpointKey(endpoint)
 	if lc.connections[key] > 0 {
 		lc.connections[key]--
 	}
@@ -297,42 +472,259 @@ func (lc *LeastConnectionsStrategy) Name() string {
 	return "least-connections"
 }
 
+// ewmaDecay is the weight of the newest sample in an endpoint's latency average
+const ewmaDecay = 0.3
+
+// exploreEvery sends one request in this many round-robin, so endpoints that were
+// slow once keep getting sampled and can win traffic back after recovering
+const exploreEvery = 20
+
+// LeastResponseTimeStrategy picks the endpoint with the lowest exponentially weighted
+// moving average of response latency. An endpoint without a sample yet is sent one
+// request to measure it and is then taken to be average until that request completes,
+// so a new endpoint isn't sent every request in the meantime.
+type LeastResponseTimeStrategy struct {
+	averages   map[string]float64 // Average latency in nanoseconds, by address
+	probing    map[string]bool    // Unsampled endpoints already sent their first request
+	selections uint64
+	explore    RoundRobinStrategy
+	mutex      sync.Mutex
+}
+
+func NewLeastResponseTimeStrategy() *LeastResponseTimeStrategy {
+	return &LeastResponseTimeStrategy{
+		averages: make(map[string]float64),
+		probing:  make(map[string]bool),
+	}
+}
+
+func (lrt *LeastResponseTimeStrategy) SelectEndpoint(endpoints []k8s.ServiceEndpoint) k8s.ServiceEndpoint {
+	if len(endpoints) == 0 {
+		return k8s.ServiceEndpoint{}
+	}
+
+	lrt.mutex.Lock()
+	defer lrt.mutex.Unlock()
+
+	lrt.selections++
+	if lrt.selections%exploreEvery == 0 {
+		return lrt.explore.SelectEndpoint(endpoints)
+	}
+
+	for _, endpoint := range endpoints {
+		key := endpointKey(endpoint)
+		if _, sampled := lrt.averages[key]; !sampled && !lrt.probing[key] {
+			lrt.probing[key] = true
+			return endpoint
+		}
+	}
+	if len(lrt.averages) == 0 {
+		return lrt.explore.SelectEndpoint(endpoints)
+	}
+	mean := 0.0
+	for _, average := range lrt.averages {
+		mean += average
+	}
+	mean /= float64(len(lrt.averages))
+
+	var selected k8s.ServiceEndpoint
+	lowest := -1.0
+	for _, endpoint := range endpoints {
+		average, sampled := lrt.averages[endpointKey(endpoint)]
+		if !sampled {
+			average = mean
+		}
+		if lowest < 0 || average < lowest {
+			lowest = average
+			selected = endpoint
+		}
+	}
+	return selected
+}
+
+// ObserveLatency folds a response time into the endpoint's average
+func (lrt *LeastResponseTimeStrategy) ObserveLatency(endpoint k8s.ServiceEndpoint, latency time.Duration) {
+	lrt.mutex.Lock()
+	defer lrt.mutex.Unlock()
+
+	key := endpointKey(endpoint)
+	delete(lrt.probing, key)
+	sample := float64(latency)
+	if average, sampled := lrt.averages[key]; sampled {
+		sample = ewmaDecay*sample + (1-ewmaDecay)*average
+	}
+	lrt.averages[key] = sample
+}
+
+// RetainEndpoints forgets endpoints not in current, keyed by address
+func (lrt *LeastResponseTimeStrategy) RetainEndpoints(current map[string]bool) {
+	lrt.mutex.Lock()
+	defer lrt.mutex.Unlock()
+
+	for address := range lrt.averages {
+		if !current[address] {
+			delete(lrt.averages, address)
+		}
+	}
+	for address := range lrt.probing {
+		if !current[address] {
+			delete(lrt.probing, address)
+		}
+	}
+}
+
+func (lrt *LeastResponseTimeStrategy) Name() string {
+	return "least-response-time"
+}
+
+// ZoneAwareStrategy prefers endpoints in the gateway's zone, round-robin among them,
+// and spills over to the other zones only when the local zone has no healthy endpoint.
+// Endpoints with an unknown zone count as remote.
+type ZoneAwareStrategy struct {
+	localZone string
+	local     RoundRobinStrategy
+	remote    RoundRobinStrategy
+	mutex     sync.RWMutex
+}
+
+func NewZoneAwareStrategy(localZone string) *ZoneAwareStrategy {
+	return &ZoneAwareStrategy{localZone: localZone}
+}
+
+// SetLocalZone changes the preferred zone
+func (za *ZoneAwareStrategy) SetLocalZone(zone string) {
+	za.mutex.Lock()
+	defer za.mutex.Unlock()
+
+	za.localZone = zone
+}
+
+func (za *ZoneAwareStrategy) SelectEndpoint(endpoints []k8s.ServiceEndpoint) k8s.ServiceEndpoint {
+	if len(endpoints) == 0 {
+		return k8s.ServiceEndpoint{}
+	}
+
+	za.mutex.RLock()
+	localZone := za.localZone
+	za.mutex.RUnlock()
+
+	var local []k8s.ServiceEndpoint
+	if localZone != "" {
+		for _, endpoint := range endpoints {
+			if endpoint.Zone == localZone {
+				local = append(local, endpoint)
+			}
+		}
+	}
+
+	if len(local) > 0 {
+		return za.local.SelectEndpoint(local)
+	}
+	return za.remote.SelectEndpoint(endpoints)
+}
+
+func (za *ZoneAwareStrategy) Name() string {
+	return "zone-aware"
+}
+
 // LoadBalancerManager manages load balancers for multiple services
 type LoadBalancerManager struct {
-	loadBalancers map[string]*LoadBalancer
-	mutex         sync.RWMutex
+	loadBalancers   map[string]*LoadBalancer
+	localZone       string // Zone preferred by zone-aware load balancers
+	defaultStrategy string // Strategy of services naming none or an unknown one
+	mutex           sync.RWMutex
 }
 
 func NewLoadBalancerManager() *LoadBalancerManager {
 	return &LoadBalancerManager{
-		loadBalancers: make(map[string]*LoadBalancer),
+		loadBalancers:   make(map[string]*LoadBalancer),
+		defaultStrategy: "round-robin",
+	}
+}
+
+// SetDefaultStrategy sets the strategy of load balancers asked for no known one.
+// Existing load balancers switch to it the next time they are looked up.
+func (lbm *LoadBalancerManager) SetDefaultStrategy(strategyName string) {
+	lbm.mutex.Lock()
+	defer lbm.mutex.Unlock()
+
+	if IsLoadBalancingStrategy(strategyName) {
+		lbm.defaultStrategy = strategyName
+	}
+}
+
+// SetLocalZone sets the zone preferred by zone-aware load balancers, existing ones included
+func (lbm *LoadBalancerManager) SetLocalZone(zone string) {
+	lbm.mutex.Lock()
+	defer lbm.mutex.Unlock()
+
+	lbm.localZone = zone
+	for _, lb := range lbm.loadBalancers {
+		if zoneAware, ok := lb.currentStrategy().(*ZoneAwareStrategy); ok {
+			zoneAware.SetLocalZone(zone)
+		}
 	}
 }
 
+// loadBalancingStrategies are the strategies GetOrCreateLoadBalancer knows
+var loadBalancingStrategies = map[string]bool{
+	"round-robin":          true,
+	"weighted-round-robin": true,
+	"random":               true,
+	"least-connections":    true,
+	"zone-aware":           true,
+	"least-response-time":  true,
+}
+
+// IsLoadBalancingStrategy reports whether name is a known load balancing strategy
+func IsLoadBalancingStrategy(name string) bool {
+	return loadBalancingStrategies[name]
+}
+
+// GetOrCreateLoadBalancer returns the service's load balancer with the named strategy, or
+// the default one when the name is empty or unknown. An existing load balancer on another
+// strategy is switched to it, so annotation changes take effect.
 func (lbm *LoadBalancerManager) GetOrCreateLoadBalancer(serviceName, strategyName string) *LoadBalancer {
 	lbm.mutex.Lock()
 	defer lbm.mutex.Unlock()
 
+	if !IsLoadBalancingStrategy(strategyName) {
+		strategyName = lbm.defaultStrategy
+	}
+
 	if lb, exists := lbm.loadBalancers[serviceName]; exists {
+		lb.mutex.RLock()
+		current := lb.strategyName
+		lb.mutex.RUnlock()
+		if current != strategyName {
+			lb.setStrategy(strategyName, lbm.newStrategy(strategyName))
+		}
 		return lb
 	}
 
-	var strategy LoadBalancerStrategy
+	lb := NewLoadBalancer(serviceName, lbm.newStrategy(strategyName))
+	lb.strategyName = strategyName
+	lbm.loadBalancers[serviceName] = lb
+
+	return lb
+}
+
+// newStrategy builds the named strategy; callers hold the mutex
+func (lbm *LoadBalancerManager) newStrategy(strategyName string) LoadBalancerStrategy {
 	switch strategyName {
 	case "weighted-round-robin":
-		strategy = NewWeightedRoundRobinStrategy(nil)
+		return NewWeightedRoundRobinStrategy(nil)
 	case "random":
-		strategy = NewRandomStrategy()
+		return NewRandomStrategy()
 	case "least-connections":
-		strategy = NewLeastConnectionsStrategy()
+		return NewLeastConnectionsStrategy()
+	case "zone-aware":
+		return NewZoneAwareStrategy(lbm.localZone)
+	case "least-response-time":
+		return NewLeastResponseTimeStrategy()
 	default:
-		strategy = NewRoundRobinStrategy()
+		return NewRoundRobinStrategy()
 	}
-
-	lb := NewLoadBalancer(serviceName, strategy)
-	lbm.loadBalancers[serviceName] = lb
-
-	return lb
 }
 
 func (lbm *LoadBalancerManager) UpdateServiceEndpoints(serviceName string, endpoints []k8s.ServiceEndpoint) {
@@ -345,6 +737,41 @@ func (lbm *LoadBalancerManager) UpdateServiceEndpoints(serviceName string, endpo
 	}
 }
 
+// SetEndpointHealth records an active health check result for a service's endpoint
+func (lbm *LoadBalancerManager) SetEndpointHealth(serviceName string, endpoint k8s.ServiceEndpoint, healthy bool) {
+	lbm.mutex.RLock()
+	lb, exists := lbm.loadBalancers[serviceName]
+	lbm.mutex.RUnlock()
+
+	if exists {
+		lb.SetEndpointHealth(endpoint, healthy)
+	}
+}
+
+// RecordLatency reports how long a service's endpoint took to respond
+func (lbm *LoadBalancerManager) RecordLatency(serviceName string, endpoint k8s.ServiceEndpoint, latency time.Duration) {
+	lbm.mutex.RLock()
+	lb, exists := lbm.loadBalancers[serviceName]
+	lbm.mutex.RUnlock()
+
+	if exists {
+		lb.RecordLatency(endpoint, latency)
+	}
+}
+
+// BeginRequest counts a request in flight to a service's endpoint; call the returned
+// function once it completes
+func (lbm *LoadBalancerManager) BeginRequest(serviceName string, endpoint k8s.ServiceEndpoint) func() {
+	lbm.mutex.RLock()
+	lb, exists := lbm.loadBalancers[serviceName]
+	lbm.mutex.RUnlock()
+
+	if !exists {
+		return func() {}
+	}
+	return lb.BeginRequest(endpoint)
+}
+
 func (lbm *LoadBalancerManager) GetLoadBalancerStats(serviceName string) (LoadBalancerStats, bool) {
 	lbm.mutex.RLock()
 	defer lbm.mutex.RUnlock()
@@ -356,6 +783,17 @@ func (lbm *LoadBalancerManager) GetLoadBalancerStats(serviceName string) (LoadBa
 	return LoadBalancerStats{}, false
 }
 
+// ResetStats zeroes every load balancer's request counts, returning how many were reset
+func (lbm *LoadBalancerManager) ResetStats() int {
+	lbm.mutex.RLock()
+	defer lbm.mutex.RUnlock()
+
+	for _, lb := range lbm.loadBalancers {
+		lb.ResetStats()
+	}
+	return len(lbm.loadBalancers)
+}
+
 func (lbm *LoadBalancerManager) GetAllStats() map[string]LoadBalancerStats {
 	lbm.mutex.RLock()
 	defer lbm.mutex.RUnlock()
@@ -367,3 +805,30 @@ func (lbm *LoadBalancerManager) GetAllStats() map[string]LoadBalancerStats {
 
 	return stats
 }
+
+// WriteMetrics writes per-service endpoint gauges in the Prometheus text format
+func (lbm *LoadBalancerManager) WriteMetrics(w io.Writer) {
+	stats := lbm.GetAllStats()
+
+	serviceNames := make([]string, 0, len(stats))
+	for serviceName := range stats {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	fmt.Fprintln(w, "# HELP gateway_service_endpoints Number of endpoints per service by readiness state")
+	fmt.Fprintln(w, "# TYPE gateway_service_endpoints gauge")
+	for _, serviceName := range serviceNames {
+		s := stats[serviceName]
+		fmt.Fprintf(w, "gateway_service_endpoints{service=%q,state=\"ready\"} %d\n", serviceName, s.HealthyEndpoints)
+		fmt.Fprintf(w, "gateway_service_endpoints{service=%q,state=\"not_ready\"} %d\n", serviceName, s.UnhealthyEndpoints)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "# HELP gateway_service_endpoint_count Total number of endpoints per service")
+	fmt.Fprintln(w, "# TYPE gateway_service_endpoint_count gauge")
+	for _, serviceName := range serviceNames {
+		s := stats[serviceName]
+		fmt.Fprintf(w, "gateway_service_endpoint_count{service=%q} %d\n", serviceName, s.HealthyEndpoints+s.UnhealthyEndpoints)
+	}
+}
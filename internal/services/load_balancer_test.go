@@ -0,0 +1,507 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWeightedRoundRobinDistribution(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints []k8s.ServiceEndpoint
+		requests  int
+		want      map[string]int64
+	}{
+		{
+			name: "weight 3 against weight 1",
+			endpoints: []k8s.ServiceEndpoint{
+				{IP: "10.0.0.1", Port: 8080, Ready: true, Weight: 3},
+				{IP: "10.0.0.2", Port: 8080, Ready: true, Weight: 1},
+			},
+			requests: 40,
+			want:     map[string]int64{"10.0.0.1:8080": 30, "10.0.0.2:8080": 10},
+		},
+		{
+			name: "unset weight counts as 1",
+			endpoints: []k8s.ServiceEndpoint{
+				{IP: "10.0.0.1", Port: 8080, Ready: true, Weight: 2},
+				{IP: "10.0.0.2", Port: 8080, Ready: true},
+			},
+			requests: 30,
+			want:     map[string]int64{"10.0.0.1:8080": 20, "10.0.0.2:8080": 10},
+		},
+		{
+			name: "IPv6 endpoints",
+			endpoints: []k8s.ServiceEndpoint{
+				{IP: "fd00::1", Port: 8080, Ready: true, Weight: 3},
+				{IP: "fd00::2", Port: 8080, Ready: true, Weight: 1},
+			},
+			requests: 40,
+			want:     map[string]int64{"[fd00::1]:8080": 30, "[fd00::2]:8080": 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLoadBalancer("orders", NewWeightedRoundRobinStrategy(nil))
+			lb.UpdateEndpoints(tt.endpoints)
+			for i := 0; i < tt.requests; i++ {
+				lb.SelectEndpoint()
+			}
+
+			stats := lb.GetStats()
+			for address, want := range tt.want {
+				if got := stats.EndpointRequests[address]; got != want {
+					t.Errorf("%s got %d requests, want %d", address, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobinConcurrentSelection(t *testing.T) {
+	endpoints := []k8s.ServiceEndpoint{
+		{IP: "10.0.0.1", Port: 8080, Ready: true},
+		{IP: "10.0.0.2", Port: 8080, Ready: true},
+		{IP: "10.0.0.3", Port: 8080, Ready: true},
+	}
+	tests := []struct {
+		name       string
+		goroutines int
+		perWorker  int
+	}{
+		{name: "one goroutine", goroutines: 1, perWorker: 300},
+		{name: "many goroutines", goroutines: 16, perWorker: 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLoadBalancer("orders", NewRoundRobinStrategy())
+			lb.UpdateEndpoints(endpoints)
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.goroutines; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < tt.perWorker; j++ {
+						lb.SelectEndpoint()
+					}
+				}()
+			}
+			wg.Wait()
+
+			// Every selection takes the next counter value, so the split stays exact
+			want := int64(tt.goroutines * tt.perWorker / len(endpoints))
+			stats := lb.GetStats()
+			for _, endpoint := range endpoints {
+				if got := stats.EndpointRequests[endpointKey(endpoint)]; got != want {
+					t.Errorf("%s got %d requests, want %d", endpointKey(endpoint), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobinCounterWraps(t *testing.T) {
+	endpoints := []k8s.ServiceEndpoint{{IP: "10.0.0.1", Port: 8080}, {IP: "10.0.0.2", Port: 8080}}
+	rr := NewRoundRobinStrategy()
+	rr.current.Store(math.MaxUint64 - 1)
+
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.2"}
+	for i, ip := range want {
+		if got := rr.SelectEndpoint(endpoints).IP; got != ip {
+			t.Errorf("selection %d = %s, want %s", i+1, got, ip)
+		}
+	}
+}
+
+func TestZoneAwareFailover(t *testing.T) {
+	endpoints := []k8s.ServiceEndpoint{
+		{IP: "10.0.0.1", Port: 8080, Ready: true, Zone: "eu-west-1a"},
+		{IP: "10.0.0.2", Port: 8080, Ready: true, Zone: "eu-west-1a"},
+		{IP: "10.0.1.1", Port: 8080, Ready: true, Zone: "eu-west-1b"},
+		{IP: "10.0.2.1", Port: 8080, Ready: true}, // Unknown zone counts as remote
+	}
+
+	tests := []struct {
+		name      string
+		localZone string
+		unhealthy []int // Indexes of endpoints failing their probe
+		want      map[string]int
+	}{
+		{
+			name:      "local zone preferred",
+			localZone: "eu-west-1a",
+			want:      map[string]int{"10.0.0.1:8080": 4, "10.0.0.2:8080": 4},
+		},
+		{
+			name:      "healthy local endpoint takes all traffic",
+			localZone: "eu-west-1a",
+			unhealthy: []int{0},
+			want:      map[string]int{"10.0.0.2:8080": 8},
+		},
+		{
+			name:      "spills over when the local zone is down",
+			localZone: "eu-west-1a",
+			unhealthy: []int{0, 1},
+			want:      map[string]int{"10.0.1.1:8080": 4, "10.0.2.1:8080": 4},
+		},
+		{
+			name:      "zone with no endpoints",
+			localZone: "eu-west-1c",
+			want:      map[string]int{"10.0.0.1:8080": 2, "10.0.0.2:8080": 2, "10.0.1.1:8080": 2, "10.0.2.1:8080": 2},
+		},
+		{
+			name: "local zone unknown",
+			want: map[string]int{"10.0.0.1:8080": 2, "10.0.0.2:8080": 2, "10.0.1.1:8080": 2, "10.0.2.1:8080": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lbm := NewLoadBalancerManager()
+			lbm.SetLocalZone(tt.localZone)
+			lb := lbm.GetOrCreateLoadBalancer("orders", "zone-aware")
+			lb.UpdateEndpoints(endpoints)
+			for _, i := range tt.unhealthy {
+				lb.SetEndpointHealth(endpoints[i], false)
+			}
+
+			got := make(map[string]int)
+			for i := 0; i < 8; i++ {
+				got[endpointKey(lb.SelectEndpoint())]++
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("selections = %v, want %v", got, tt.want)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s selected %d times, want %d", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetLocalZoneUpdatesExistingBalancers(t *testing.T) {
+	lbm := NewLoadBalancerManager()
+	lb := lbm.GetOrCreateLoadBalancer("orders", "zone-aware")
+	lb.UpdateEndpoints([]k8s.ServiceEndpoint{
+		{IP: "10.0.0.1", Port: 8080, Ready: true, Zone: "eu-west-1a"},
+		{IP: "10.0.1.1", Port: 8080, Ready: true, Zone: "eu-west-1b"},
+	})
+
+	// The zone read from the gateway's node arrives after services were registered
+	lbm.SetLocalZone("eu-west-1b")
+	for i := 0; i < 4; i++ {
+		if got := lb.SelectEndpoint(); got.Zone != "eu-west-1b" {
+			t.Fatalf("selected %s in zone %q, want eu-west-1b", endpointKey(got), got.Zone)
+		}
+	}
+}
+
+func TestLeastResponseTimeFavorsFasterEndpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies map[string]time.Duration // By IP
+		fastest   string
+	}{
+		{
+			name:      "one fast of two",
+			latencies: map[string]time.Duration{"10.0.0.1": 80 * time.Millisecond, "10.0.0.2": 10 * time.Millisecond},
+			fastest:   "10.0.0.2",
+		},
+		{
+			name:      "one fast of three",
+			latencies: map[string]time.Duration{"10.0.0.1": 50 * time.Millisecond, "10.0.0.2": 60 * time.Millisecond, "10.0.0.3": 5 * time.Millisecond},
+			fastest:   "10.0.0.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var endpoints []k8s.ServiceEndpoint
+			for ip := range tt.latencies {
+				endpoints = append(endpoints, k8s.ServiceEndpoint{IP: ip, Port: 8080, Ready: true})
+			}
+			lb := NewLoadBalancer("orders", NewLeastResponseTimeStrategy())
+			lb.UpdateEndpoints(endpoints)
+
+			const requests = 200
+			for i := 0; i < requests; i++ {
+				endpoint := lb.SelectEndpoint()
+				lb.RecordLatency(endpoint, tt.latencies[endpoint.IP])
+			}
+
+			stats := lb.GetStats()
+			if got := stats.EndpointRequests[tt.fastest+":8080"]; got < requests*8/10 {
+				t.Errorf("fastest endpoint got %d of %d requests, want at least 80%%", got, requests)
+			}
+			for ip := range tt.latencies {
+				if got := stats.EndpointRequests[ip+":8080"]; got == 0 {
+					t.Errorf("%s was never sampled", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestLeastResponseTimeRecoveredEndpointWinsTrafficBack(t *testing.T) {
+	slow := k8s.ServiceEndpoint{IP: "10.0.0.1", Port: 8080, Ready: true}
+	steady := k8s.ServiceEndpoint{IP: "10.0.0.2", Port: 8080, Ready: true}
+	lrt := NewLeastResponseTimeStrategy()
+	lrt.ObserveLatency(slow, 500*time.Millisecond)
+	lrt.ObserveLatency(steady, 50*time.Millisecond)
+
+	// Only exploration reaches the slow endpoint, which now answers quickly
+	latencies := map[string]time.Duration{slow.IP: 5 * time.Millisecond, steady.IP: 50 * time.Millisecond}
+	counts := make(map[string]int)
+	for i := 0; i < 600; i++ {
+		endpoint := lrt.SelectEndpoint([]k8s.ServiceEndpoint{slow, steady})
+		lrt.ObserveLatency(endpoint, latencies[endpoint.IP])
+		if i >= 500 {
+			counts[endpoint.IP]++
+		}
+	}
+	if counts[slow.IP] < 80 {
+		t.Errorf("recovered endpoint got %d of the last 100 requests, want most", counts[slow.IP])
+	}
+}
+
+func TestLeastResponseTimeProbesNewEndpointOnce(t *testing.T) {
+	fast := k8s.ServiceEndpoint{IP: "10.0.0.1", Port: 8080, Ready: true}
+	slow := k8s.ServiceEndpoint{IP: "10.0.0.2", Port: 8080, Ready: true}
+	added := k8s.ServiceEndpoint{IP: "10.0.0.3", Port: 8080, Ready: true}
+	lrt := NewLeastResponseTimeStrategy()
+	lrt.ObserveLatency(fast, 10*time.Millisecond)
+	lrt.ObserveLatency(slow, 90*time.Millisecond)
+
+	// Until its first request completes, the new endpoint counts as average
+	tests := []struct {
+		name      string
+		endpoints []k8s.ServiceEndpoint
+		want      string
+	}{
+		{name: "new endpoint gets one request", endpoints: []k8s.ServiceEndpoint{fast, slow, added}, want: added.IP},
+		{name: "then loses to one faster than the mean", endpoints: []k8s.ServiceEndpoint{added, fast, slow}, want: fast.IP},
+		{name: "and beats one slower than the mean", endpoints: []k8s.ServiceEndpoint{slow, added}, want: added.IP},
+		{name: "no endpoints", want: ""},
+	}
+	for _, tt := range tests {
+		if got := lrt.SelectEndpoint(tt.endpoints); got.IP != tt.want {
+			t.Errorf("%s: selected %q, want %q", tt.name, got.IP, tt.want)
+		}
+	}
+}
+
+func TestLeastResponseTimeForgetsRemovedEndpoints(t *testing.T) {
+	kept := k8s.ServiceEndpoint{IP: "10.0.0.1", Port: 8080, Ready: true}
+	removed := k8s.ServiceEndpoint{IP: "10.0.0.2", Port: 8080, Ready: true}
+	lrt := NewLeastResponseTimeStrategy()
+	lb := NewLoadBalancer("orders", lrt)
+	lb.UpdateEndpoints([]k8s.ServiceEndpoint{kept, removed})
+	lb.RecordLatency(kept, 10*time.Millisecond)
+	lb.RecordLatency(removed, 20*time.Millisecond)
+
+	lb.UpdateEndpoints([]k8s.ServiceEndpoint{kept})
+
+	lrt.mutex.Lock()
+	defer lrt.mutex.Unlock()
+	if _, exists := lrt.averages[endpointKey(removed)]; exists || len(lrt.averages) != 1 {
+		t.Errorf("averages = %v, want only %s", lrt.averages, endpointKey(kept))
+	}
+}
+
+func TestLoadBalancerStatsEndpoints(t *testing.T) {
+	lb := NewLoadBalancer("orders", NewWeightedRoundRobinStrategy(nil))
+	lb.UpdateEndpoints([]k8s.ServiceEndpoint{
+		{IP: "10.0.0.2", Port: 8080, Ready: true, Weight: 3},
+		{IP: "10.0.0.1", Port: 8080, Ready: false, Weight: 1},
+		{IP: "fd00::1", Port: 8080, Ready: true, Weight: 1},
+		{IP: "10.0.0.3", Port: 8080, Ready: true, Weight: 2},
+	})
+	lb.SetEndpointHealth(k8s.ServiceEndpoint{IP: "10.0.0.3", Port: 8080}, false)
+	before := time.Now()
+	for i := 0; i < 8; i++ {
+		lb.SelectEndpoint()
+	}
+
+	stats := lb.GetStats()
+	if stats.Strategy != "weighted-round-robin" {
+		t.Errorf("strategy = %q, want weighted-round-robin", stats.Strategy)
+	}
+	want := []EndpointStats{
+		{Address: "10.0.0.1:8080", Weight: 1},
+		{Address: "10.0.0.2:8080", Ready: true, Healthy: true, Weight: 3, Requests: 6},
+		{Address: "10.0.0.3:8080", Ready: true, Weight: 2},
+		{Address: "[fd00::1]:8080", Ready: true, Healthy: true, Weight: 1, Requests: 2},
+	}
+	if len(stats.Endpoints) != len(want) {
+		t.Fatalf("endpoints = %+v, want %d", stats.Endpoints, len(want))
+	}
+	for i, got := range stats.Endpoints {
+		selected := got.LastSelected
+		got.LastSelected = time.Time{}
+		if got != want[i] {
+			t.Errorf("endpoint %d = %+v, want %+v", i, got, want[i])
+		}
+		if wasSelected := !selected.IsZero(); wasSelected != (want[i].Requests > 0) || (wasSelected && selected.Before(before)) {
+			t.Errorf("%s last selected = %v", got.Address, selected)
+		}
+	}
+	if stats.HealthyEndpoints != 2 || stats.UnhealthyEndpoints != 2 {
+		t.Errorf("healthy/unhealthy = %d/%d, want 2/2", stats.HealthyEndpoints, stats.UnhealthyEndpoints)
+	}
+}
+
+func TestLoadBalancingStrategyFromAnnotation(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		defaultStrategy string
+		want            string
+	}{
+		{name: "annotated least-connections", annotations: map[string]string{k8s.AnnotationLoadBalancing: "least-connections"}, defaultStrategy: "round-robin", want: "least-connections"},
+		{name: "no annotation uses the default", defaultStrategy: "random", want: "random"},
+		{name: "unknown annotation uses the default", annotations: map[string]string{k8s.AnnotationLoadBalancing: "fastest"}, defaultStrategy: "least-response-time", want: "least-response-time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+			cfg := newTestConfig()
+			cfg.Proxy.LoadBalancing = tt.defaultStrategy
+			g := newServiceGateway(t, cfg, "orders", tt.annotations, backend.URL)
+
+			if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			stats, exists := g.drm.loadBalancerManager.GetLoadBalancerStats("orders")
+			if !exists || stats.Strategy != tt.want {
+				t.Errorf("strategy = %q, want %q", stats.Strategy, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBalancingAnnotationChangeSwitchesStrategy(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	service := testService("orders", map[string]string{k8s.AnnotationLoadBalancing: "least-connections"})
+	g := newTestGateway(t, newTestConfig(), service, testEndpoints(t, "orders", backend.URL))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+
+	strategy := func() string {
+		stats, _ := g.drm.loadBalancerManager.GetLoadBalancerStats("orders")
+		return stats.Strategy
+	}
+	if got := strategy(); got != "least-connections" {
+		t.Fatalf("strategy = %q, want least-connections", got)
+	}
+
+	steps := []struct {
+		annotation string
+		want       string
+	}{
+		{annotation: "random", want: "random"},
+		{annotation: "", want: "round-robin"}, // Removed, so back to the default
+	}
+	for _, step := range steps {
+		service = service.DeepCopy()
+		if step.annotation == "" {
+			delete(service.Annotations, k8s.AnnotationLoadBalancing)
+		} else {
+			service.Annotations[k8s.AnnotationLoadBalancing] = step.annotation
+		}
+		if _, err := g.clientset.CoreV1().Services(testNamespace).Update(context.Background(), service, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("update service: %v", err)
+		}
+		eventually(t, func() bool { return strategy() == step.want })
+
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("with %s, status = %d, want 200", step.want, rec.Code)
+		}
+		if got := strategy(); got != step.want {
+			t.Errorf("after a request, strategy = %q, want %q", got, step.want)
+		}
+	}
+}
+
+func TestLeastConnectionsAvoidsBusyEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	var first atomic.Bool
+	var hits [2]atomic.Int64
+	backends := make([]string, 2)
+	for i := range backends {
+		backends[i] = newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			if first.CompareAndSwap(false, true) {
+				<-release
+			}
+		}).URL
+	}
+
+	g := newServiceGateway(t, newTestConfig(), "orders", map[string]string{k8s.AnnotationLoadBalancing: "least-connections"}, backends...)
+
+	// The first request holds its endpoint busy; the rest go to the idle one
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+	}()
+	eventually(t, func() bool { return hits[0].Load()+hits[1].Load() == 1 })
+	busy := 0
+	if hits[1].Load() == 1 {
+		busy = 1
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("request %d got %d, want 200", i+1, rec.Code)
+		}
+	}
+	if got := hits[1-busy].Load(); got != 3 {
+		t.Errorf("idle endpoint got %d requests, want 3", got)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestEndpointWeightsAnnotation(t *testing.T) {
+	var hits [2]atomic.Int64
+	backends := make([]string, 2)
+	for i := range backends {
+		backends[i] = newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+		}).URL
+	}
+
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{
+			k8s.AnnotationLoadBalancing: "weighted-round-robin",
+			k8s.AnnotationWeights:       strings.TrimPrefix(backends[0], "http://") + "=3",
+		}),
+		testEndpoints(t, "orders", backends...),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 2)
+
+	for i := 0; i < 40; i++ {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i+1, rec.Code)
+		}
+	}
+	if heavy, light := hits[0].Load(), hits[1].Load(); heavy != 30 || light != 10 {
+		t.Errorf("weight 3 endpoint got %d requests and weight 1 got %d, want 30 and 10", heavy, light)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEndpointGaugesReflectReadiness(t *testing.T) {
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: testNamespace},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.3"}},
+			Ports:             []corev1.EndpointPort{{Port: 8080}},
+		}},
+	}
+	g := newTestGateway(t, newTestConfig(), testService("users", nil), endpoints)
+	g.waitForEndpoints(t, http.MethodGet, "/users", 2)
+
+	want := []string{
+		`gateway_service_endpoints{service="users",state="ready"} 2`,
+		`gateway_service_endpoints{service="users",state="not_ready"} 1`,
+		`gateway_service_endpoint_count{service="users"} 3`,
+	}
+	eventually(t, func() bool {
+		var buf bytes.Buffer
+		g.drm.WriteMetrics(&buf)
+		for _, line := range want {
+			if !strings.Contains(buf.String(), line+"\n") {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestRouteMetricsParse(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", nil),
+		testEndpoints(t, "orders", backend.URL),
+		testService("catalog", map[string]string{k8s.AnnotationCacheTTL: "30s"}),
+		testEndpoints(t, "catalog", backend.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/catalog", 1)
+	for _, path := range []string{"/orders", "/catalog", "/catalog"} {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, path, nil)); rec.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want 200", path, rec.Code)
+		}
+	}
+
+	var buf bytes.Buffer
+	g.drm.WriteMetrics(&buf)
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(&buf)
+	if err != nil {
+		t.Fatalf("route metrics do not parse: %v", err)
+	}
+	for _, name := range []string{"gateway_service_endpoints", "gateway_response_cache_requests_total"} {
+		if _, exists := families[name]; !exists {
+			t.Errorf("family %s missing", name)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	gatewayproxy "api-gateway/internal/proxy"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderMirrored marks requests the gateway sent as a mirror copy
+const HeaderMirrored = "X-Gateway-Mirrored"
+
+// mirrorTimeout bounds a mirrored request; it is detached from the client's context
+const mirrorTimeout = 10 * time.Second
+
+// maxMirrorsInFlight caps the mirrored requests in flight, so a slow mirror service
+// can't pile up goroutines and buffered bodies; copies beyond it are dropped
+const maxMirrorsInFlight = 64
+
+// mirrorSlots bounds the mirrored requests in flight and counts the ones dropped
+type mirrorSlots struct {
+	slots   chan struct{}
+	dropped atomic.Int64
+}
+
+func newMirrorSlots(size int) *mirrorSlots {
+	return &mirrorSlots{slots: make(chan struct{}, size)}
+}
+
+// acquire takes a slot, reporting false and counting a drop when all are taken
+func (m *mirrorSlots) acquire() bool {
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	default:
+		m.dropped.Add(1)
+		return false
+	}
+}
+
+// release frees a slot taken by acquire
+func (m *mirrorSlots) release() {
+	<-m.slots
+}
+
+// WriteMetrics writes the mirror counters in the Prometheus text format
+func (m *mirrorSlots) WriteMetrics(w io.Writer) {
+	fmt.Fprintf(w, `# HELP gateway_mirror_requests_in_flight Mirrored requests waiting on the mirror service
+# TYPE gateway_mirror_requests_in_flight gauge
+gateway_mirror_requests_in_flight %d
+
+# HELP gateway_mirror_requests_dropped_total Mirror copies dropped because too many were in flight
+# TYPE gateway_mirror_requests_dropped_total counter
+gateway_mirror_requests_dropped_total %d
+`, len(m.slots), m.dropped.Load())
+}
+
+// mirrorRequest sends a copy of the request to the route's mirror service in the
+// background. The mirror's response is discarded and its failures are only logged.
+// Requests whose body wasn't buffered can't be copied and are not mirrored, nor are
+// requests arriving while maxMirrorsInFlight copies are still in flight.
+func (drm *DynamicRouteManager) mirrorRequest(r *http.Request, route *DynamicRouteInfo, body []byte, replayable bool) {
+	if route.Service == nil || route.Service.MirrorService == "" {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"method":         r.Method,
+		"path":           r.URL.Path,
+		"service":        route.ServiceName,
+		"mirror_service": route.Service.MirrorService,
+	}
+	contextLogger := drm.logger.WithContext(r.Context()).WithComponent("mirror")
+
+	if !replayable {
+		contextLogger.Debug("Skipping mirror, request body was not buffered", fields)
+		return
+	}
+
+	mirror, exists := drm.discoveryManager.GetDiscoveredService(route.Service.MirrorService)
+	if !exists {
+		contextLogger.Debug("Skipping mirror, mirror service not discovered", fields)
+		return
+	}
+
+	lb := drm.loadBalancerManager.GetOrCreateLoadBalancer(mirror.Name, mirror.LoadBalancing)
+	lb.UpdateEndpoints(mirror.Endpoints)
+	endpoint := lb.SelectEndpoint()
+	if endpoint.IP == "" {
+		contextLogger.Debug("Skipping mirror, no healthy mirror endpoint", fields)
+		return
+	}
+
+	// Copy everything the goroutine needs; r must not be touched once the handler returns
+	target := *r.URL
+	target.Scheme = gatewayproxy.NormalizeScheme(mirror.Scheme)
+	target.Host = endpointKey(endpoint)
+	header := r.Header.Clone()
+	header.Set(HeaderMirrored, "true")
+	header.Set("X-Gateway-Service", mirror.Name)
+	method := r.Method
+	fields["endpoint"] = endpointKey(endpoint)
+
+	if !drm.mirrors.acquire() {
+		contextLogger.Debug("Skipping mirror, too many mirrored requests in flight", fields)
+		return
+	}
+
+	go func() {
+		defer drm.mirrors.release()
+
+		ctx, cancel := context.WithTimeout(gatewayproxy.WithServerName(context.Background(), mirror.ServerName), mirrorTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			contextLogger.Warn("Mirror request failed", fields, map[string]interface{}{
+				"error": err,
+			})
+			return
+		}
+		req.Header = header
+
+		resp, err := (&http.Client{Transport: drm.transport}).Do(req)
+		if err != nil {
+			contextLogger.Warn("Mirror request failed", fields, map[string]interface{}{
+				"error": err,
+			})
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		contextLogger.Debug("Mirror request completed", fields, map[string]interface{}{
+			"status": resp.StatusCode,
+		})
+	}()
+}
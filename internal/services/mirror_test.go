@@ -0,0 +1,95 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMirrorReceivesCopyClientSeesPrimary(t *testing.T) {
+	primary := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "primary")
+	})
+	mirrored := make(chan *http.Request, 1)
+	mirroredBody := make(chan string, 1)
+	mirror := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirroredBody <- string(body)
+		mirrored <- r
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "mirror")
+	})
+
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{
+			k8s.AnnotationPath:          "/orders",
+			k8s.AnnotationMethod:        "POST",
+			k8s.AnnotationMirrorService: "orders-next",
+		}),
+		testEndpoints(t, "orders", primary.URL),
+		testService("orders-next", map[string]string{k8s.AnnotationPath: "/orders-next"}),
+		testEndpoints(t, "orders-next", mirror.URL),
+	)
+	g.waitForEndpoints(t, http.MethodPost, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/orders-next", 1)
+
+	rec := g.serve(httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id":1}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "primary" {
+		t.Fatalf("client got %d %q, want 200 \"primary\"", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case r := <-mirrored:
+		if r.Header.Get(HeaderMirrored) != "true" {
+			t.Errorf("%s = %q, want true", HeaderMirrored, r.Header.Get(HeaderMirrored))
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/orders" {
+			t.Errorf("mirror got %s %s, want POST /orders", r.Method, r.URL.Path)
+		}
+		if body := <-mirroredBody; body != `{"id":1}` {
+			t.Errorf("mirror body = %q, want the client's body", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirror service never received the request")
+	}
+}
+
+func TestMirrorDropsCopiesBeyondLimit(t *testing.T) {
+	primary := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	release := make(chan struct{})
+	var received atomic.Int64
+	mirror := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		<-release
+	})
+	defer close(release)
+
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{
+			k8s.AnnotationPath:          "/orders",
+			k8s.AnnotationMirrorService: "orders-next",
+		}),
+		testEndpoints(t, "orders", primary.URL),
+		testService("orders-next", map[string]string{k8s.AnnotationPath: "/orders-next"}),
+		testEndpoints(t, "orders-next", mirror.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/orders-next", 1)
+
+	const extra = 5
+	for i := 0; i < maxMirrorsInFlight+extra; i++ {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("request %d got %d, want 200", i, rec.Code)
+		}
+	}
+
+	if got := g.drm.mirrors.dropped.Load(); got != extra {
+		t.Errorf("dropped mirrors = %d, want %d", got, extra)
+	}
+	eventually(t, func() bool { return received.Load() == maxMirrorsInFlight })
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// NotFoundResponse is the body returned when no route matches a request
+type NotFoundResponse struct {
+	Error  string `json:"error"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// WriteNotFound writes a JSON 404 for a request that matched no route
+func WriteNotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+
+	json.NewEncoder(w).Encode(NotFoundResponse{
+		Error:  "not found",
+		Method: r.Method,
+		Path:   r.URL.Path,
+	})
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUnmatchedDynamicRouteIsNotFound(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newServiceGateway(t, newTestConfig(), "orders", nil, backend.URL)
+
+	// Routes registered after the dynamic handler and the router's own 404 must still be reachable
+	g.router.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	g.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handled-By", "router")
+		WriteNotFound(w, r)
+	})
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		method, path string
+		wantStatus   int
+		wantRouter   bool
+	}{
+		{name: "dynamic handler, unknown path", handler: g.drm.handleDynamicRoute, method: http.MethodGet, path: "/unknown", wantStatus: http.StatusNotFound},
+		{name: "dynamic handler, unrouted method", handler: g.drm.handleDynamicRoute, method: http.MethodDelete, path: "/orders", wantStatus: http.StatusNotFound},
+		{name: "router, unknown path", handler: g.router.ServeHTTP, method: http.MethodGet, path: "/unknown", wantStatus: http.StatusNotFound, wantRouter: true},
+		{name: "router, later static route", handler: g.router.ServeHTTP, method: http.MethodGet, path: "/login", wantStatus: http.StatusAccepted},
+		{name: "router, dynamic route", handler: g.router.ServeHTTP, method: http.MethodGet, path: "/orders", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Handled-By") == "router"; got != tt.wantRouter {
+				t.Errorf("handled by the router's NotFoundHandler = %v, want %v", got, tt.wantRouter)
+			}
+			if tt.wantStatus != http.StatusNotFound {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			var body NotFoundResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			want := NotFoundResponse{Error: "not found", Method: tt.method, Path: tt.path}
+			if body != want {
+				t.Errorf("body = %+v, want %+v", body, want)
+			}
+		})
+	}
+}
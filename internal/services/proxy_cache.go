@@ -0,0 +1,219 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// proxyAttempt carries the per-request details a cached reverse proxy needs.
+// It travels in the request context because the proxy itself is shared.
+type proxyAttempt struct {
+	service    string
+	endpoint   k8s.ServiceEndpoint
+	forwardTLS bool
+	startTime  time.Time
+	statusMap  map[int]int   // Upstream status codes rewritten for the client
+	latency    time.Duration // Time until the upstream's response headers arrived
+	status     int           // Status sent to the client, after remapping
+	err        error         // Set by the error handler when the attempt fails
+	writer     http.ResponseWriter
+
+	// headerTimer cancels the attempt when response headers take longer than
+	// gateway.io/response-header-timeout; it is stopped once they arrive
+	headerTimer *time.Timer
+}
+
+type proxyAttemptKey struct{}
+
+// withProxyAttempt returns a request carrying the attempt for the cached proxy
+func withProxyAttempt(r *http.Request, attempt *proxyAttempt) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), proxyAttemptKey{}, attempt))
+}
+
+// attemptFrom returns the attempt stored in the request context
+func attemptFrom(ctx context.Context) *proxyAttempt {
+	attempt, _ := ctx.Value(proxyAttemptKey{}).(*proxyAttempt)
+	return attempt
+}
+
+// errResponseHeaderTimeout fails an attempt whose upstream didn't send response
+// headers within gateway.io/response-header-timeout; it classifies as a timeout
+var errResponseHeaderTimeout = fmt.Errorf("upstream response headers not received in time: %w", context.DeadlineExceeded)
+
+// proxyCache holds one reverse proxy per upstream endpoint so requests reuse
+// it, and the shared transport's connection pool, instead of building a proxy each time
+type proxyCache struct {
+	proxies       map[string]*cachedProxy
+	transport     http.RoundTripper
+	grpcTransport http.RoundTripper // HTTP/2-only transport for gRPC backends
+	recorder      *gatewayproxy.ErrorCounter
+	bufferPool    httputil.BufferPool
+	onError       func(r *http.Request, attempt *proxyAttempt, errorType string, err error)
+	mutex         sync.RWMutex
+}
+
+// cachedProxy is a reverse proxy for one endpoint
+type cachedProxy struct {
+	host  string
+	proxy *httputil.ReverseProxy
+}
+
+// newProxyCache creates an empty cache whose proxies use the given transport
+func newProxyCache(transport, grpcTransport http.RoundTripper, recorder *gatewayproxy.ErrorCounter, bufferPool httputil.BufferPool,
+	onError func(r *http.Request, attempt *proxyAttempt, errorType string, err error)) *proxyCache {
+	return &proxyCache{
+		proxies:       make(map[string]*cachedProxy),
+		transport:     transport,
+		grpcTransport: grpcTransport,
+		recorder:      recorder,
+		bufferPool:    bufferPool,
+		onError:       onError,
+	}
+}
+
+// get returns the cached proxy for the target, building it on first use. Streaming
+// proxies flush every write; others flush buffered data every flushInterval.
+func (pc *proxyCache) get(target *url.URL, streaming, grpc bool, flushInterval time.Duration) *httputil.ReverseProxy {
+	key := target.String()
+	if streaming {
+		key += "#streaming"
+		flushInterval = -1
+	}
+	if grpc {
+		key += "#grpc"
+	}
+	key += "#flush=" + flushInterval.String()
+
+	pc.mutex.RLock()
+	cached, exists := pc.proxies[key]
+	pc.mutex.RUnlock()
+	if exists {
+		return cached.proxy
+	}
+
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if cached, exists := pc.proxies[key]; exists {
+		return cached.proxy
+	}
+	proxy := pc.build(target, grpc, flushInterval)
+	pc.proxies[key] = &cachedProxy{host: target.Host, proxy: proxy}
+	return proxy
+}
+
+// build creates a reverse proxy for one endpoint
+func (pc *proxyCache) build(target *url.URL, grpc bool, flushInterval time.Duration) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = pc.transport
+	if grpc {
+		proxy.Transport = pc.grpcTransport
+	}
+	proxy.FlushInterval = flushInterval
+	proxy.BufferPool = pc.bufferPool
+
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		req.URL.Host = target.Host
+		req.URL.Scheme = target.Scheme
+		gatewayproxy.ApplyForwardedHeaders(req, middleware.FromTrustedProxy(req))
+		req.Host = target.Host
+		if attempt := attemptFrom(req.Context()); attempt != nil {
+			req.Header.Set("X-Gateway-Service", attempt.service)
+			if attempt.endpoint.IP != "" { // Fixed fallback backends have no endpoint
+				req.Header.Set("X-Gateway-Endpoint", attempt.endpoint.IP)
+			}
+			req.Header.Set("X-Request-Start", attempt.startTime.Format(time.RFC3339Nano))
+			gatewayproxy.ApplyClientTLSHeaders(req, attempt.forwardTLS)
+		}
+		gatewayproxy.ApplyTracingHeaders(req)
+	}
+
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		attempt := attemptFrom(resp.Request.Context())
+		if attempt == nil {
+			return nil
+		}
+		if attempt.headerTimer != nil {
+			attempt.headerTimer.Stop()
+		}
+		attempt.latency = time.Since(attempt.startTime)
+		if mapped, exists := attempt.statusMap[resp.StatusCode]; exists {
+			resp.StatusCode = mapped
+			resp.Status = fmt.Sprintf("%d %s", mapped, http.StatusText(mapped))
+		}
+		attempt.status = resp.StatusCode
+		if attempt.writer != nil && gatewayproxy.IsEventStreamResponse(resp) {
+			// The upstream chose to stream, so lift the server's write timeout;
+			// the route's request timeout still bounds the response
+			http.NewResponseController(attempt.writer).SetWriteDeadline(time.Time{})
+		}
+		return nil
+	}
+
+	// The caller writes the response so it can retry first
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		attempt := attemptFrom(r.Context())
+		if attempt == nil {
+			return
+		}
+		if middleware.IsRequestBodyTooLarge(err) {
+			// The client sent too much, the upstream did nothing wrong
+			attempt.err = err
+			return
+		}
+		if errors.Is(context.Cause(r.Context()), errResponseHeaderTimeout) {
+			err = errResponseHeaderTimeout
+		}
+
+		errorType := pc.recorder.Record(attempt.service, err)
+		if pc.onError != nil {
+			pc.onError(r, attempt, errorType, err)
+		}
+		attempt.err = &upstreamError{errorType: errorType, err: err}
+	}
+
+	return proxy
+}
+
+// prune drops cached proxies for endpoints no longer in live, keyed by host:port
+func (pc *proxyCache) prune(live map[string]bool) int {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	removed := 0
+	for key, cached := range pc.proxies {
+		if !live[cached.host] {
+			delete(pc.proxies, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// size returns the number of cached proxies
+func (pc *proxyCache) size() int {
+	pc.mutex.RLock()
+	defer pc.mutex.RUnlock()
+	return len(pc.proxies)
+}
+
+// streamingMode reports how a request to service must be proxied: streaming
+// responses are flushed on every write and exempt from the write timeout, and
+// gRPC calls go over the HTTP/2 transport. Only the service's configuration
+// decides this, never the client's headers.
+func streamingMode(r *http.Request, service *k8s.DiscoveredService) (streaming, grpc bool) {
+	grpc = service.Protocol == k8s.ProtocolGRPC && gatewayproxy.IsGRPCRequest(r)
+	streaming = service.Streaming || grpc
+	return streaming, grpc
+}
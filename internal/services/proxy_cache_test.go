@@ -0,0 +1,322 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpstreamReceivesTracingIDs(t *testing.T) {
+	type seen struct{ correlationID, requestID string }
+	received := make(chan seen, 1)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- seen{r.Header.Get("X-Correlation-ID"), r.Header.Get("X-Request-ID")}
+	})
+
+	g := newServiceGateway(t, newTestConfig(), "orders", nil, backend.URL)
+	handler := middleware.NewStructuredLoggingMiddleware(newTestLogger()).Middleware(g.router)
+
+	tests := []struct {
+		name              string
+		clientCorrelation string
+		wantCorrelation   string // Empty means any generated ID
+	}{
+		{name: "generated by the gateway"},
+		{name: "sent by the client", clientCorrelation: "checkout-7f3a", wantCorrelation: "checkout-7f3a"},
+		{name: "invalid client value is replaced", clientCorrelation: "bad id<script>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.clientCorrelation != "" {
+				req.Header.Set("X-Correlation-ID", tt.clientCorrelation)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			upstream := <-received
+
+			clientCorrelation := rec.Header().Get("X-Correlation-ID")
+			if clientCorrelation == "" || upstream.correlationID != clientCorrelation {
+				t.Errorf("upstream correlation ID = %q, client got %q", upstream.correlationID, clientCorrelation)
+			}
+			if tt.wantCorrelation != "" && clientCorrelation != tt.wantCorrelation {
+				t.Errorf("correlation ID = %q, want %q", clientCorrelation, tt.wantCorrelation)
+			}
+			if tt.clientCorrelation != "" && tt.wantCorrelation == "" && clientCorrelation == tt.clientCorrelation {
+				t.Errorf("invalid correlation ID %q was forwarded", clientCorrelation)
+			}
+			if clientRequest := rec.Header().Get("X-Request-ID"); clientRequest == "" || upstream.requestID != clientRequest {
+				t.Errorf("upstream request ID = %q, client got %q", upstream.requestID, clientRequest)
+			}
+		})
+	}
+}
+
+func TestProxyCacheReusesAndPrunes(t *testing.T) {
+	orders, _ := url.Parse("http://10.0.0.1:8080")
+	billing, _ := url.Parse("http://10.0.0.2:8080")
+
+	tests := []struct {
+		name        string
+		live        map[string]bool
+		wantRemoved int
+	}{
+		{name: "all endpoints live", live: map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:8080": true}},
+		{name: "removed endpoint drops every variant", live: map[string]bool{"10.0.0.2:8080": true}, wantRemoved: 2},
+		{name: "no endpoints left", live: map[string]bool{}, wantRemoved: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := newProxyCache(http.DefaultTransport, http.DefaultTransport, gatewayproxy.NewErrorCounter(), nil, nil)
+			first := pc.get(orders, false, false, 0)
+			if again := pc.get(orders, false, false, 0); again != first {
+				t.Fatal("second get built a new proxy for the same endpoint")
+			}
+			if streaming := pc.get(orders, true, false, 0); streaming == first {
+				t.Fatal("streaming proxy shares the buffered one")
+			}
+			pc.get(billing, false, false, 0)
+			if got := pc.size(); got != 3 {
+				t.Fatalf("size = %d, want 3", got)
+			}
+
+			if removed := pc.prune(tt.live); removed != tt.wantRemoved {
+				t.Errorf("prune removed %d, want %d", removed, tt.wantRemoved)
+			}
+			if got := pc.size(); got != 3-tt.wantRemoved {
+				t.Errorf("size after prune = %d, want %d", got, 3-tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestRemovedEndpointDropsCachedProxy(t *testing.T) {
+	first := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	second := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newServiceGateway(t, newTestConfig(), "orders", nil, first.URL, second.URL)
+
+	for i := 0; i < 4; i++ {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+	}
+	if got := g.drm.proxies.size(); got != 2 {
+		t.Fatalf("cached proxies = %d, want one per endpoint", got)
+	}
+
+	endpoints := testEndpoints(t, "orders", first.URL)
+	if _, err := g.clientset.CoreV1().Endpoints(testNamespace).Update(context.Background(), endpoints, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update endpoints: %v", err)
+	}
+	eventually(t, func() bool { return g.drm.proxies.size() == 1 })
+}
+
+func BenchmarkProxyCache(b *testing.B) {
+	target, _ := url.Parse("http://10.0.0.1:8080")
+	pc := newProxyCache(http.DefaultTransport, http.DefaultTransport, gatewayproxy.NewErrorCounter(), nil, nil)
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pc.get(target, false, false, 0)
+		}
+	})
+	b.Run("built per request", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pc.build(target, false, 0)
+		}
+	})
+}
+
+func TestEventStreamReachesClientIncrementally(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		contentType string
+	}{
+		{name: "upstream event stream", contentType: "text/event-stream"},
+		{name: "streaming service", annotations: map[string]string{k8s.AnnotationStreaming: "true"}, contentType: "application/x-ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The backend holds each event until the client has read the previous one,
+			// so a buffered response never completes
+			received := make(chan struct{})
+			backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				for i := 1; i <= 3; i++ {
+					fmt.Fprintf(w, "data: event %d\n\n", i)
+					w.(http.Flusher).Flush()
+					select {
+					case <-received:
+					case <-time.After(2 * time.Second):
+						return
+					}
+				}
+			})
+			g := newServiceGateway(t, newTestConfig(), "events", tt.annotations, backend.URL)
+			gateway := httptest.NewServer(middleware.NewStructuredLoggingMiddleware(newTestLogger()).Middleware(g.router))
+			defer gateway.Close()
+
+			resp, err := http.Get(gateway.URL + "/events")
+			if err != nil {
+				t.Fatalf("GET /events: %v", err)
+			}
+			defer resp.Body.Close()
+
+			reader := bufio.NewReader(resp.Body)
+			for i := 1; i <= 3; i++ {
+				line, err := reader.ReadString('\n')
+				if want := fmt.Sprintf("data: event %d\n", i); err != nil || line != want {
+					t.Fatalf("event %d = %q, %v, want %q", i, line, err, want)
+				}
+				reader.ReadString('\n')
+				received <- struct{}{}
+			}
+		})
+	}
+}
+
+func TestFlushIntervalDeliversBufferedChunks(t *testing.T) {
+	const hold = 500 * time.Millisecond // How long the backend withholds the rest of the body
+	tests := []struct {
+		name          string
+		flushInterval time.Duration
+		annotations   map[string]string
+		wantEarly     bool // The first chunk reaches the client while the backend holds the rest
+	}{
+		{name: "configured interval", flushInterval: 20 * time.Millisecond, wantEarly: true},
+		{name: "service override", annotations: map[string]string{k8s.AnnotationFlushInterval: "20ms"}, wantEarly: true},
+		{name: "flushed only at the end", flushInterval: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan struct{}, 1)
+			backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				// A known length keeps the proxy from treating the response as a stream
+				w.Header().Set("Content-Length", "12")
+				w.Write([]byte("first\n"))
+				w.(http.Flusher).Flush()
+				select {
+				case <-received:
+				case <-time.After(hold):
+				}
+				w.Write([]byte("rest.\n"))
+			})
+			cfg := newTestConfig()
+			cfg.Proxy.FlushInterval = tt.flushInterval
+			g := newServiceGateway(t, cfg, "orders", tt.annotations, backend.URL)
+			gateway := httptest.NewServer(g.router)
+			defer gateway.Close()
+
+			start := time.Now()
+			resp, err := http.Get(gateway.URL + "/orders")
+			if err != nil {
+				t.Fatalf("GET /orders: %v", err)
+			}
+			defer resp.Body.Close()
+
+			reader := bufio.NewReader(resp.Body)
+			if line, err := reader.ReadString('\n'); err != nil || line != "first\n" {
+				t.Fatalf("first chunk = %q, %v", line, err)
+			}
+			if early := time.Since(start) < hold; early != tt.wantEarly {
+				t.Errorf("first chunk arrived after %v, want early = %v", time.Since(start), tt.wantEarly)
+			}
+			received <- struct{}{}
+			if line, err := reader.ReadString('\n'); err != nil || line != "rest.\n" {
+				t.Errorf("second chunk = %q, %v", line, err)
+			}
+		})
+	}
+}
+
+func TestUpstreamReceivesForwardedChain(t *testing.T) {
+	received := make(chan http.Header, 1)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	})
+	g := newServiceGateway(t, newTestConfig(), "orders", nil, backend.URL)
+	clientIP, err := middleware.NewClientIPMiddleware([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("NewClientIPMiddleware: %v", err)
+	}
+	handler := clientIP.Middleware(g.router)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		incoming   map[string]string
+		wantFor    string
+		wantProto  string
+		wantHost   string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:40000",
+			wantFor:    "203.0.113.7",
+			wantProto:  "http",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "direct client spoofing a chain",
+			remoteAddr: "203.0.113.7:40000",
+			incoming:   map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Forwarded-Proto": "https"},
+			wantFor:    "203.0.113.7",
+			wantProto:  "http",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "client behind a trusted proxy",
+			remoteAddr: "10.0.0.5:40000",
+			incoming:   map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "shop.example.com"},
+			wantFor:    "198.51.100.1, 10.0.0.5",
+			wantProto:  "https",
+			wantHost:   "shop.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://api.example.com/orders", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.incoming {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			upstream := <-received
+
+			if got := upstream.Get("X-Forwarded-For"); got != tt.wantFor {
+				t.Errorf("X-Forwarded-For = %q, want %q", got, tt.wantFor)
+			}
+			if got := upstream.Get("X-Forwarded-Proto"); got != tt.wantProto {
+				t.Errorf("X-Forwarded-Proto = %q, want %q", got, tt.wantProto)
+			}
+			if got := upstream.Get("X-Forwarded-Host"); got != tt.wantHost {
+				t.Errorf("X-Forwarded-Host = %q, want %q", got, tt.wantHost)
+			}
+		})
+	}
+}
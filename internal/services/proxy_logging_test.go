@@ -0,0 +1,39 @@
+package services
+
+import (
+	"api-gateway/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxiedRequestLogsStructuredEntry(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	routeLogger := logger.NewLogger(logger.Config{Level: "debug", Format: "json"})
+	t.Cleanup(routeLogger.Close)
+	hook := &captureHook{}
+	routeLogger.AddHook(hook)
+
+	g := newTestGatewayWith(t, newTestConfig(), routeLogger, nil, testService("orders", nil), testEndpoints(t, "orders", backend.URL))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req = req.WithContext(logger.WithCorrelationID(req.Context(), "checkout-7f3a"))
+	if rec := g.serve(req); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	entry := hook.find("Successfully proxied request")
+	if entry == nil {
+		t.Fatal("no entry for the proxied request")
+	}
+	if entry.Component != "proxy" {
+		t.Errorf("component = %q, want proxy", entry.Component)
+	}
+	if entry.Fields["service"] != "orders" {
+		t.Errorf("service = %v, want orders", entry.Fields["service"])
+	}
+	if entry.CorrelationID != "checkout-7f3a" {
+		t.Errorf("correlation ID = %q, want the request's", entry.CorrelationID)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutCancelsUpstream(t *testing.T) {
+	tests := []struct {
+		name        string
+		global      time.Duration
+		annotations map[string]string
+		accept      string
+		wantStatus  int
+	}{
+		{name: "global deadline", global: 50 * time.Millisecond, wantStatus: http.StatusGatewayTimeout},
+		{name: "route annotation overrides the global deadline", global: time.Minute, annotations: map[string]string{k8s.AnnotationTimeout: "50ms"}, wantStatus: http.StatusGatewayTimeout},
+		{name: "within the deadline", global: time.Minute, wantStatus: http.StatusOK},
+		{name: "client accept header doesn't lift the deadline", global: 50 * time.Millisecond, accept: "text/event-stream", wantStatus: http.StatusGatewayTimeout},
+		{name: "streaming route has no deadline", global: 50 * time.Millisecond, annotations: map[string]string{k8s.AnnotationStreaming: "true"}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamCancelled := make(chan bool, 1)
+			backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					upstreamCancelled <- true
+				case <-time.After(200 * time.Millisecond):
+					upstreamCancelled <- false
+				}
+			})
+			g := newServiceGateway(t, newTestConfig(), "orders", tt.annotations, backend.URL)
+			timeout := middleware.NewTimeoutMiddleware(tt.global)
+			timeout.SetRouteTimeouts(g.drm.RequestTimeout)
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			timeout.Middleware(g.router).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if cancelled := <-upstreamCancelled; cancelled != (tt.wantStatus == http.StatusGatewayTimeout) {
+				t.Errorf("upstream request cancelled = %v", cancelled)
+			}
+		})
+	}
+}
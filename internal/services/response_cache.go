@@ -0,0 +1,225 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/pkg/clock"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCacheMaxBytes caps the body bytes held by the response cache
+const responseCacheMaxBytes = 64 << 20
+
+// cacheableStatus are the statuses cached for gateway.io/cache-ttl routes
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// responseCache keeps upstream responses to GETs for services with gateway.io/cache-ttl,
+// keyed by path and query plus the request headers the upstream named in Vary
+type responseCache struct {
+	entries map[string]*cachedResponse
+	vary    map[string][]string // Vary header names by path key, from the last stored response
+	size    int
+	hits    map[string]int64 // By service
+	misses  map[string]int64
+	clock   clock.Clock
+	mutex   sync.Mutex
+}
+
+type cachedResponse struct {
+	response *sharedResponse
+	stored   time.Time
+	expires  time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]*cachedResponse),
+		vary:    make(map[string][]string),
+		hits:    make(map[string]int64),
+		misses:  make(map[string]int64),
+		clock:   clock.Real{},
+	}
+}
+
+// cacheableRequest reports whether a request may be answered from, or stored in, the cache
+func cacheableRequest(r *http.Request, service *k8s.DiscoveredService, streaming bool) bool {
+	return service.CacheTTL > 0 && !streaming && r.Method == http.MethodGet && r.Header.Get("Range") == "" &&
+		!hasCacheDirective(r.Header, "no-store")
+}
+
+// hasCacheDirective reports whether the Cache-Control header holds directive
+func hasCacheDirective(header http.Header, directive string) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if strings.EqualFold(name, directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookup returns the fresh response stored for the request, counting a hit or miss.
+// Clients sending no-cache always miss.
+func (rc *responseCache) lookup(r *http.Request, service *k8s.DiscoveredService) (*cachedResponse, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	base := cacheBaseKey(r, service)
+	entry, exists := rc.entries[cacheVaryKey(base, rc.vary[base], r)]
+	if !exists || rc.clock.Now().After(entry.expires) || hasCacheDirective(r.Header, "no-cache") {
+		rc.misses[service.Name]++
+		return nil, false
+	}
+	rc.hits[service.Name]++
+	return entry, true
+}
+
+// cacheCredentialHeaders are the request headers the auth middleware accepts a token
+// from. Responses to requests carrying them are only stored when marked public, and
+// their values are part of the cache key.
+var cacheCredentialHeaders = []string{"Authorization", "Cookie"}
+
+// store caches an upstream response for ttl unless the upstream forbade it. Responses
+// to requests carrying credentials are only stored when the upstream marks them public.
+func (rc *responseCache) store(r *http.Request, service *k8s.DiscoveredService, response *sharedResponse, ttl time.Duration) {
+	if response == nil || !cacheableStatus[response.status] {
+		return
+	}
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if hasCacheDirective(response.header, directive) {
+			return
+		}
+	}
+	if hasCredentials(r) && !hasCacheDirective(response.header, "public") {
+		return
+	}
+
+	var varyNames []string
+	for _, value := range response.header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "*" {
+				return
+			} else if name != "" {
+				varyNames = append(varyNames, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(varyNames)
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	now := rc.clock.Now()
+	if rc.size+len(response.body) > responseCacheMaxBytes {
+		rc.evictExpired(now)
+		if rc.size+len(response.body) > responseCacheMaxBytes {
+			return
+		}
+	}
+
+	base := cacheBaseKey(r, service)
+	rc.vary[base] = varyNames
+	key := cacheVaryKey(base, varyNames, r)
+	if previous, exists := rc.entries[key]; exists {
+		rc.size -= len(previous.response.body)
+	}
+	rc.entries[key] = &cachedResponse{response: response, stored: now, expires: now.Add(ttl)}
+	rc.size += len(response.body)
+}
+
+// evictExpired drops expired entries; callers hold the mutex
+func (rc *responseCache) evictExpired(now time.Time) {
+	for key, entry := range rc.entries {
+		if now.After(entry.expires) {
+			rc.size -= len(entry.response.body)
+			delete(rc.entries, key)
+		}
+	}
+}
+
+// write serves a cached response with its age at now
+func (cr *cachedResponse) write(w http.ResponseWriter, now time.Time) {
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(cr.stored).Seconds())))
+	w.Header().Set("X-Cache", "HIT")
+	cr.response.write(w)
+}
+
+// hasCredentials reports whether the request carries any of cacheCredentialHeaders
+func hasCredentials(r *http.Request) bool {
+	for _, name := range cacheCredentialHeaders {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheBaseKey identifies the requested resource and the credentials it was requested
+// with, so a response cached for one user is never replayed to another
+func cacheBaseKey(r *http.Request, service *k8s.DiscoveredService) string {
+	var b strings.Builder
+	b.WriteString(serviceKey(service) + "\n" + r.Host + "\n" + r.URL.RequestURI())
+	for _, name := range cacheCredentialHeaders {
+		b.WriteString("\n")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// cacheVaryKey extends the resource key with the request's values of the Vary headers
+func cacheVaryKey(base string, varyNames []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range varyNames {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// WriteMetrics writes per-service hit and miss counters in the Prometheus text format
+func (rc *responseCache) WriteMetrics(w io.Writer) {
+	rc.mutex.Lock()
+	services := make([]string, 0, len(rc.hits)+len(rc.misses))
+	seen := make(map[string]bool)
+	for _, counts := range []map[string]int64{rc.hits, rc.misses} {
+		for service := range counts {
+			if !seen[service] {
+				seen[service] = true
+				services = append(services, service)
+			}
+		}
+	}
+	hits := make(map[string]int64, len(rc.hits))
+	misses := make(map[string]int64, len(rc.misses))
+	for _, service := range services {
+		hits[service] = rc.hits[service]
+		misses[service] = rc.misses[service]
+	}
+	rc.mutex.Unlock()
+	sort.Strings(services)
+
+	fmt.Fprintln(w, "# HELP gateway_response_cache_requests_total Cacheable requests per service by cache result")
+	fmt.Fprintln(w, "# TYPE gateway_response_cache_requests_total counter")
+	for _, service := range services {
+		fmt.Fprintf(w, "gateway_response_cache_requests_total{service=%q,result=\"hit\"} %d\n", service, hits[service])
+		fmt.Fprintf(w, "gateway_response_cache_requests_total{service=%q,result=\"miss\"} %d\n", service, misses[service])
+	}
+}
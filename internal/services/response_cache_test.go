@@ -0,0 +1,149 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/pkg/clock"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHasCacheDirective(t *testing.T) {
+	tests := []struct {
+		values    []string
+		directive string
+		want      bool
+	}{
+		{values: []string{"no-store"}, directive: "no-store", want: true},
+		{values: []string{"max-age=60, No-Store"}, directive: "no-store", want: true},
+		{values: []string{"public", "no-cache"}, directive: "no-cache", want: true},
+		{values: []string{"max-age=60"}, directive: "no-store"},
+		{values: []string{"private=\"no-store\""}, directive: "no-store"},
+		{directive: "no-store"},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		for _, value := range tt.values {
+			header.Add("Cache-Control", value)
+		}
+		if got := hasCacheDirective(header, tt.directive); got != tt.want {
+			t.Errorf("hasCacheDirective(%q, %q) = %v, want %v", tt.values, tt.directive, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCacheHitAndExpiry(t *testing.T) {
+	var calls atomic.Int64
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("upstream") == "no-store" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte("catalog"))
+	})
+	g := newServiceGateway(t, newTestConfig(), "catalog", map[string]string{k8s.AnnotationCacheTTL: "30s"}, backend.URL)
+	fake := clock.NewFake(time.Now())
+	g.drm.responseCache.clock = fake
+
+	steps := []struct {
+		name        string
+		advance     time.Duration
+		target      string
+		noStore     bool // Client sends Cache-Control: no-store
+		wantXCache  string
+		wantAge     string
+		wantUpcalls int64
+	}{
+		{name: "first request misses", target: "/catalog", wantXCache: "MISS", wantUpcalls: 1},
+		{name: "hit within the TTL", advance: 10 * time.Second, target: "/catalog", wantXCache: "HIT", wantAge: "10", wantUpcalls: 1},
+		{name: "client no-store bypasses the cache", target: "/catalog", noStore: true, wantUpcalls: 2},
+		{name: "still cached at the TTL", advance: 20 * time.Second, target: "/catalog", wantXCache: "HIT", wantAge: "30", wantUpcalls: 2},
+		{name: "miss after expiry", advance: time.Second, target: "/catalog", wantXCache: "MISS", wantUpcalls: 3},
+		{name: "refetched response is cached again", advance: time.Second, target: "/catalog", wantXCache: "HIT", wantAge: "1", wantUpcalls: 3},
+		{name: "upstream no-store is not cached", target: "/catalog?upstream=no-store", wantXCache: "MISS", wantUpcalls: 4},
+		{name: "upstream no-store misses again", target: "/catalog?upstream=no-store", wantXCache: "MISS", wantUpcalls: 5},
+	}
+
+	for _, step := range steps {
+		fake.Advance(step.advance)
+		req := httptest.NewRequest(http.MethodGet, step.target, nil)
+		if step.noStore {
+			req.Header.Set("Cache-Control", "no-store")
+		}
+		rec := g.serve(req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "catalog" {
+			t.Fatalf("%s: response = %d %q, want 200 \"catalog\"", step.name, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-Cache"); got != step.wantXCache {
+			t.Errorf("%s: X-Cache = %q, want %q", step.name, got, step.wantXCache)
+		}
+		if got := rec.Header().Get("Age"); got != step.wantAge {
+			t.Errorf("%s: Age = %q, want %q", step.name, got, step.wantAge)
+		}
+		if got := calls.Load(); got != step.wantUpcalls {
+			t.Errorf("%s: upstream calls = %d, want %d", step.name, got, step.wantUpcalls)
+		}
+	}
+
+	var buf bytes.Buffer
+	g.drm.responseCache.WriteMetrics(&buf)
+	for _, line := range []string{
+		`gateway_response_cache_requests_total{service="catalog",result="hit"} 3`,
+		`gateway_response_cache_requests_total{service="catalog",result="miss"} 4`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestResponseCacheSeparatesCredentials(t *testing.T) {
+	var calls atomic.Int64
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("upstream") == "public" {
+			w.Header().Set("Cache-Control", "public")
+		}
+		w.Write([]byte("profile of " + r.Header.Get("Cookie")))
+	})
+	g := newServiceGateway(t, newTestConfig(), "profile", map[string]string{k8s.AnnotationCacheTTL: "30s"}, backend.URL)
+
+	steps := []struct {
+		name        string
+		target      string
+		cookie      string
+		wantXCache  string
+		wantUpcalls int64
+	}{
+		{name: "cookie response is not stored", target: "/profile", cookie: "token=alice", wantXCache: "MISS", wantUpcalls: 1},
+		{name: "same cookie misses again", target: "/profile", cookie: "token=alice", wantXCache: "MISS", wantUpcalls: 2},
+		{name: "public response is stored", target: "/profile?upstream=public", cookie: "token=alice", wantXCache: "MISS", wantUpcalls: 3},
+		{name: "same cookie hits", target: "/profile?upstream=public", cookie: "token=alice", wantXCache: "HIT", wantUpcalls: 3},
+		{name: "another cookie misses", target: "/profile?upstream=public", cookie: "token=bob", wantXCache: "MISS", wantUpcalls: 4},
+		{name: "no cookie misses", target: "/profile?upstream=public", wantXCache: "MISS", wantUpcalls: 5},
+	}
+
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodGet, step.target, nil)
+		if step.cookie != "" {
+			req.Header.Set("Cookie", step.cookie)
+		}
+		rec := g.serve(req)
+
+		if want := "profile of " + step.cookie; rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("%s: response = %d %q, want 200 %q", step.name, rec.Code, rec.Body.String(), want)
+		}
+		if got := rec.Header().Get("X-Cache"); got != step.wantXCache {
+			t.Errorf("%s: X-Cache = %q, want %q", step.name, got, step.wantXCache)
+		}
+		if got := calls.Load(); got != step.wantUpcalls {
+			t.Errorf("%s: upstream calls = %d, want %d", step.name, got, step.wantUpcalls)
+		}
+	}
+}
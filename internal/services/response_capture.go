@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// maxSharedBody is the largest response captured for other requests; bigger ones
+// are only sent to the request that fetched them
+const maxSharedBody = 1 << 20
+
+// sharedResponse is an upstream response replayed for other requests
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// write replays the shared response
+func (sr *sharedResponse) write(w http.ResponseWriter) {
+	for name, values := range sr.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.WriteHeader(sr.status)
+	w.Write(sr.body)
+}
+
+// captureWriter passes a response through while capturing a copy to replay for other
+// requests. Headers the gateway set before proxying, such as the request ID, belong
+// to this request and are left out of the copy.
+type captureWriter struct {
+	http.ResponseWriter
+	before   http.Header
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func newCaptureWriter(w http.ResponseWriter) *captureWriter {
+	return &captureWriter{ResponseWriter: w, before: w.Header().Clone()}
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+		cw.header = make(http.Header)
+		for name, values := range cw.ResponseWriter.Header() {
+			if strings.Join(cw.before[name], ",") != strings.Join(values, ",") {
+				cw.header[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.overflow {
+		if cw.body.Len()+len(b) > maxSharedBody {
+			cw.overflow = true
+			cw.body = bytes.Buffer{}
+		} else {
+			cw.body.Write(b)
+		}
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// response returns the captured response, or nil when it is incomplete, too large,
+// sets cookies or the upstream forbade storing it
+func (cw *captureWriter) response(ctx context.Context) *sharedResponse {
+	if cw.status == 0 || cw.overflow || ctx.Err() != nil {
+		return nil
+	}
+	if cw.header.Get("Set-Cookie") != "" || strings.Contains(strings.ToLower(cw.header.Get("Cache-Control")), "no-store") {
+		return nil
+	}
+	return &sharedResponse{status: cw.status, header: cw.header, body: cw.body.Bytes()}
+}
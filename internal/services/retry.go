@@ -0,0 +1,95 @@
+package services
+
+import (
+	"api-gateway/internal/middleware"
+	gatewayproxy "api-gateway/internal/proxy"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// upstreamError is a failure reported by the reverse proxy for a single attempt
+type upstreamError struct {
+	errorType string
+	err       error
+}
+
+func (e *upstreamError) Error() string {
+	return e.errorType + ": " + e.err.Error()
+}
+
+func (e *upstreamError) Unwrap() error {
+	return e.err
+}
+
+// upstreamStatusError reports an upstream server error response to the circuit
+// breaker; the response itself has already been passed to the client
+type upstreamStatusError struct {
+	status int
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream responded %d", e.status)
+}
+
+// RetriesExhaustedResponse is the body returned when every attempt failed
+type RetriesExhaustedResponse struct {
+	Error     string `json:"error"`
+	Service   string `json:"service"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error"`
+}
+
+// isRetryableUpstreamError only allows retries when the request never reached
+// the upstream, which keeps retries safe for non-idempotent methods
+func isRetryableUpstreamError(err error) bool {
+	var upstreamErr *upstreamError
+	return errors.As(err, &upstreamErr) && upstreamErr.errorType == gatewayproxy.ErrorTypeDial
+}
+
+// bufferRequestBody reads the body into memory so it can be replayed on retry.
+// It reports false when the body is too large to buffer, in which case the
+// request is forwarded once as a stream. An error means the body exceeded the
+// request body limit while being read.
+func (drm *DynamicRouteManager) bufferRequestBody(r *http.Request) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	limit := drm.config.Proxy.RetryBufferBytes
+	if limit <= 0 || r.ContentLength > limit {
+		return nil, false, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if middleware.IsRequestBodyTooLarge(err) {
+		return nil, false, err
+	}
+	if err != nil || int64(len(body)) > limit {
+		// Stitch back what was read so the single attempt still sees the full body
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return nil, false, nil
+	}
+
+	r.Body.Close()
+	return body, true, nil
+}
+
+// writeRetriesExhausted writes the distinguishable "tried and gave up" response
+func writeRetriesExhausted(w http.ResponseWriter, serviceName string, attempts int, lastErr *upstreamError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Gateway-Attempts", strconv.Itoa(attempts))
+	w.Header().Set("X-Gateway-Retries-Exhausted", "true")
+	w.WriteHeader(http.StatusBadGateway)
+
+	json.NewEncoder(w).Encode(RetriesExhaustedResponse{
+		Error:     "upstream retries exhausted",
+		Service:   serviceName,
+		Attempts:  attempts,
+		LastError: lastErr.errorType,
+	})
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRetriesExhaustedDiffersFromUnavailable(t *testing.T) {
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", nil),
+		testEndpoints(t, "orders", refusedURL(t), refusedURL(t)),
+		testService("billing", nil),
+		testEndpoints(t, "billing"),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 2)
+	g.waitForEndpoints(t, http.MethodGet, "/billing", 0)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantStatus    int
+		wantAttempts  string
+		wantExhausted string
+		wantBody      *RetriesExhaustedResponse
+	}{
+		{
+			name:          "every attempt refused",
+			path:          "/orders",
+			wantStatus:    http.StatusBadGateway,
+			wantAttempts:  "3",
+			wantExhausted: "true",
+			wantBody:      &RetriesExhaustedResponse{Error: "upstream retries exhausted", Service: "orders", Attempts: 3, LastError: "dial"},
+		},
+		{
+			name:       "no endpoint available",
+			path:       "/billing",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := g.serve(httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Gateway-Attempts"); got != tt.wantAttempts {
+				t.Errorf("X-Gateway-Attempts = %q, want %q", got, tt.wantAttempts)
+			}
+			if got := rec.Header().Get("X-Gateway-Retries-Exhausted"); got != tt.wantExhausted {
+				t.Errorf("X-Gateway-Retries-Exhausted = %q, want %q", got, tt.wantExhausted)
+			}
+			if tt.wantBody == nil {
+				return
+			}
+			var body RetriesExhaustedResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+			}
+			if body != *tt.wantBody {
+				t.Errorf("body = %+v, want %+v", body, *tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMethodAnnotation(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{k8s.AnnotationMethod: "GET, post"}),
+		testEndpoints(t, "orders", backend.URL),
+		testService("inventory", map[string]string{k8s.AnnotationMethod: "GETT"}),
+		testEndpoints(t, "inventory", backend.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodPost, "/orders", 1)
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{method: http.MethodGet, path: "/orders", wantStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/orders", wantStatus: http.StatusOK},
+		{method: http.MethodDelete, path: "/orders", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/inventory", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := g.serve(httptest.NewRequest(tt.method, tt.path, nil)); rec.Code != tt.wantStatus {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+
+	warning := g.discovery.serviceDiscovery.GetWarnings()["inventory"]
+	if !strings.Contains(warning, `unsupported HTTP method "GETT"`) {
+		t.Errorf("inventory warning = %q, want the invalid method named", warning)
+	}
+}
+
+func TestMultiplePathsShareBackend(t *testing.T) {
+	var hits atomic.Int64
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	})
+	service := testService("orders", map[string]string{k8s.AnnotationPaths: "/orders, /purchases"})
+	g := newTestGateway(t, newTestConfig(), service, testEndpoints(t, "orders", backend.URL))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/purchases", 1)
+
+	for _, path := range []string{"/orders", "/purchases"} {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, path, nil)); rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, want 200", path, rec.Code)
+		}
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("backend got %d requests, want 2", got)
+	}
+	if stats, ok := g.drm.loadBalancerManager.GetLoadBalancerStats("orders"); !ok || stats.TotalRequests != 2 {
+		t.Errorf("orders load balancer counted %d requests, want both paths in one balancer", stats.TotalRequests)
+	}
+
+	// Dropping a path on update withdraws only that route
+	service = service.DeepCopy()
+	service.Annotations[k8s.AnnotationPaths] = "/orders"
+	if _, err := g.clientset.CoreV1().Services(testNamespace).Update(context.Background(), service, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update service: %v", err)
+	}
+	g.waitForEndpoints(t, http.MethodGet, "/purchases", -1)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/orders", wantStatus: http.StatusOK},
+		{path: "/purchases", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, tt.path, nil)); rec.Code != tt.wantStatus {
+			t.Errorf("after update, GET %s = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"api-gateway/internal/config"
+	"api-gateway/internal/k8s"
+	"api-gateway/pkg/jwt"
+	"api-gateway/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticationPrecedesEndpointSelection(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	required := map[string]string{k8s.AnnotationAuthRequired: "true"}
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", required),
+		testEndpoints(t, "orders", backend.URL),
+		testService("billing", required),
+		testEndpoints(t, "billing"),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/billing", 0)
+
+	token, err := g.jwt.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{name: "valid token", path: "/orders", authorization: "Bearer " + token, want: http.StatusOK},
+		{name: "invalid token", path: "/orders", authorization: "Bearer not-a-token", want: http.StatusUnauthorized},
+		{name: "missing token", path: "/orders", want: http.StatusUnauthorized},
+		{name: "missing token without endpoints", path: "/billing", want: http.StatusUnauthorized},
+		{name: "valid token without endpoints", path: "/billing", authorization: "Bearer " + token, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if rec := g.serve(req); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	// Rejected requests never reached the load balancer
+	stats, exists := g.drm.loadBalancerManager.GetLoadBalancerStats("orders")
+	if !exists || stats.TotalRequests != 1 {
+		t.Errorf("orders load balancer saw %d requests, want 1", stats.TotalRequests)
+	}
+}
+
+func TestTokenCookieOnDynamicRoute(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{k8s.AnnotationAuthRequired: "true"}),
+		testEndpoints(t, "orders", backend.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.drm.authMiddleware.SetTokenCookie("gateway_token")
+	token, err := g.jwt.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		cookie        string
+		want          int
+	}{
+		{name: "token in cookie", cookie: token, want: http.StatusOK},
+		{name: "header overrides cookie", authorization: "Bearer " + token, cookie: "not-a-token", want: http.StatusOK},
+		{name: "invalid token in cookie", cookie: "not-a-token", want: http.StatusUnauthorized},
+		{name: "neither present", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "gateway_token", Value: tt.cookie})
+			}
+			if rec := g.serve(req); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthProviderOnDynamicRoute(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{k8s.AnnotationAuthRequired: "true"}),
+		testEndpoints(t, "orders", backend.URL),
+		testService("partners", map[string]string{k8s.AnnotationAuthRequired: "true", k8s.AnnotationAuthProvider: "partner"}),
+		testEndpoints(t, "partners", backend.URL),
+	)
+	partnerConfig := config.JWTConfig{Secret: "partner-secret", Issuer: "partner.example.com", Expiration: time.Hour}
+	g.drm.authMiddleware.SetProviders(jwt.NewProviders(config.JWTConfig{
+		Expiration: time.Hour,
+		Providers:  []config.AuthProviderConfig{{Name: "partner", Secret: partnerConfig.Secret, Issuer: partnerConfig.Issuer}},
+	}, g.jwt))
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/partners", 1)
+
+	tokens := make(map[string]string)
+	for issuer, service := range map[string]*jwt.Service{"default": g.jwt, "partner": jwt.NewService(partnerConfig)} {
+		token, err := service.CreateToken("alice")
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		tokens[issuer] = token
+	}
+
+	tests := []struct {
+		path   string
+		issuer string
+		want   int
+	}{
+		{path: "/orders", issuer: "default", want: http.StatusOK},
+		{path: "/orders", issuer: "partner", want: http.StatusUnauthorized},
+		{path: "/partners", issuer: "partner", want: http.StatusOK},
+		{path: "/partners", issuer: "default", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tokens[tt.issuer])
+		if rec := g.serve(req); rec.Code != tt.want {
+			t.Errorf("GET %s with %s token: status = %d, want %d", tt.path, tt.issuer, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestOptionalAuthOnDynamicRoute(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	routeLogger := logger.NewLogger(logger.Config{Level: "info", Format: "json"})
+	t.Cleanup(routeLogger.Close)
+	hook := &captureHook{}
+	routeLogger.AddHook(hook)
+	g := newTestGatewayWith(t, newTestConfig(), routeLogger, nil,
+		testService("feed", map[string]string{k8s.AnnotationAuth: k8s.AuthModeOptional}),
+		testEndpoints(t, "feed", backend.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/feed", 1)
+	token, err := g.jwt.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+		wantUser      string
+	}{
+		{name: "absent token proceeds anonymously", want: http.StatusOK},
+		{name: "valid token attaches the user", authorization: "Bearer " + token, want: http.StatusOK, wantUser: "alice"},
+		{name: "invalid token is rejected", authorization: "Bearer not-a-token", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook.mu.Lock()
+			hook.entries = nil
+			hook.mu.Unlock()
+
+			req := httptest.NewRequest(http.MethodGet, "/feed", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if rec := g.serve(req); rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			entry := hook.find("Successfully proxied request")
+			if entry == nil {
+				t.Fatal("no entry for the proxied request")
+			}
+			if entry.UserID != tt.wantUser {
+				t.Errorf("logged user = %q, want %q", entry.UserID, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"sort"
+	"time"
+)
+
+// RouteConflict is a service's claim on a METHOD:path route another service already
+// serves. The first registered service keeps the route; the claim is retried when
+// the route is released.
+type RouteConflict struct {
+	Route      string    `json:"route"`
+	Owner      string    `json:"owner"`
+	Rejected   string    `json:"rejected"`
+	DetectedAt time.Time `json:"detected_at"`
+
+	service *k8s.DiscoveredService // Rejected service, re-synced when the route frees up
+}
+
+// conflictKey identifies a rejected service's claim on a route
+func conflictKey(routeKey, claimant string) string {
+	return routeKey + " " + claimant
+}
+
+// recordConflict records that service claimed a route owned by another service,
+// logging only claims that weren't already known. Callers hold routesMutex.
+func (drm *DynamicRouteManager) recordConflict(routeKey, owner string, service *k8s.DiscoveredService, previous *RouteConflict) {
+	claimant := serviceKey(service)
+	conflict := &RouteConflict{
+		Route:      routeKey,
+		Owner:      owner,
+		Rejected:   claimant,
+		DetectedAt: time.Now(),
+		service:    service,
+	}
+
+	if previous != nil && previous.Owner == owner {
+		conflict.DetectedAt = previous.DetectedAt
+	} else {
+		drm.logger.Error("Route conflict, keeping the first registered service", map[string]interface{}{
+			"route":            routeKey,
+			"service":          owner,
+			"rejected_service": claimant,
+		})
+		drm.events.publish(RouteEvent{
+			Type:      RouteEventConflict,
+			Service:   service.Name,
+			Namespace: service.Namespace,
+			Routes:    []string{routeKey},
+			Owner:     owner,
+			Timestamp: conflict.DetectedAt,
+		})
+	}
+
+	drm.conflicts[conflictKey(routeKey, claimant)] = conflict
+}
+
+// clearConflicts drops every conflict recorded for a claimant, returning them by route.
+// Callers hold routesMutex.
+func (drm *DynamicRouteManager) clearConflicts(claimant string) map[string]*RouteConflict {
+	cleared := make(map[string]*RouteConflict)
+	for key, conflict := range drm.conflicts {
+		if conflict.Rejected == claimant {
+			cleared[conflict.Route] = conflict
+			delete(drm.conflicts, key)
+		}
+	}
+	return cleared
+}
+
+// promoteClaimants re-syncs services whose claims on the released routes were rejected,
+// oldest claim first, so the longest waiting service takes over. Callers hold routesMutex.
+func (drm *DynamicRouteManager) promoteClaimants(released []string) {
+	if len(released) == 0 || len(drm.conflicts) == 0 {
+		return
+	}
+
+	routes := make(map[string]bool, len(released))
+	for _, routeKey := range released {
+		routes[routeKey] = true
+	}
+
+	waiting := make([]*RouteConflict, 0)
+	for _, conflict := range drm.conflicts {
+		if routes[conflict.Route] {
+			waiting = append(waiting, conflict)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		if !waiting[i].DetectedAt.Equal(waiting[j].DetectedAt) {
+			return waiting[i].DetectedAt.Before(waiting[j].DetectedAt)
+		}
+		return waiting[i].Rejected < waiting[j].Rejected
+	})
+
+	for _, conflict := range waiting {
+		if route, exists := drm.dynamicRoutes[conflict.Route]; exists {
+			// An earlier claimant took the route; later ones now wait on it
+			conflict.Owner = route.Namespace + "/" + route.ServiceName
+			continue
+		}
+
+		drm.logger.Info("Released route handed to the waiting service", map[string]interface{}{
+			"route":            conflict.Route,
+			"previous_service": conflict.Owner,
+			"service":          conflict.Rejected,
+		})
+		drm.syncServiceRoutesLocked(conflict.service)
+	}
+}
+
+// RouteConflicts returns the rejected route claims, sorted by route and service
+func (drm *DynamicRouteManager) RouteConflicts() []RouteConflict {
+	drm.routesMutex.RLock()
+	defer drm.routesMutex.RUnlock()
+
+	conflicts := make([]RouteConflict, 0, len(drm.conflicts))
+	for _, conflict := range drm.conflicts {
+		conflicts = append(conflicts, *conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Route != conflicts[j].Route {
+			return conflicts[i].Route < conflicts[j].Route
+		}
+		return conflicts[i].Rejected < conflicts[j].Rejected
+	})
+	return conflicts
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/pkg/logger"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRouteConflictKeepsFirstRegisteredService(t *testing.T) {
+	v1 := newBackend(t, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "v1") })
+	v2 := newBackend(t, func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "v2") })
+	routeLogger := logger.NewLogger(logger.Config{Level: "info", Format: "json"})
+	t.Cleanup(routeLogger.Close)
+	hook := &captureHook{}
+	routeLogger.AddHook(hook)
+	g := newTestGatewayWith(t, newTestConfig(), routeLogger, nil,
+		testService("orders", nil),
+		testEndpoints(t, "orders", v1.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/orders", 1)
+	g.drm.SetupAdminEndpoints(g.router)
+
+	ctx := context.Background()
+	if _, err := g.clientset.CoreV1().Endpoints(testNamespace).Create(ctx, testEndpoints(t, "orders-v2", v2.URL), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create endpoints: %v", err)
+	}
+	claimant := testService("orders-v2", map[string]string{k8s.AnnotationPath: "/orders"})
+	if _, err := g.clientset.CoreV1().Services(testNamespace).Create(ctx, claimant, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	eventually(t, func() bool { return len(g.drm.RouteConflicts()) == 1 })
+
+	body := func() string {
+		rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+		return rec.Body.String()
+	}
+	if got := body(); got != "v1" {
+		t.Errorf("GET /orders served by %q, want the first registered service", got)
+	}
+
+	var warnings struct {
+		RouteConflicts []RouteConflict `json:"route_conflicts"`
+	}
+	rec := g.serve(httptest.NewRequest(http.MethodGet, "/admin/discovery/warnings", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &warnings); err != nil {
+		t.Fatalf("decode /admin/discovery/warnings: %v", err)
+	}
+	if len(warnings.RouteConflicts) != 1 {
+		t.Fatalf("route conflicts = %+v, want one", warnings.RouteConflicts)
+	}
+	conflict := warnings.RouteConflicts[0]
+	if conflict.Route != "GET:/orders" || conflict.Owner != "default/orders" || conflict.Rejected != "default/orders-v2" || conflict.DetectedAt.IsZero() {
+		t.Errorf("conflict = %+v", conflict)
+	}
+	entry := hook.find("Route conflict, keeping the first registered service")
+	if entry == nil {
+		t.Fatal("conflict not logged")
+	}
+	if entry.Fields["service"] != "default/orders" || entry.Fields["rejected_service"] != "default/orders-v2" {
+		t.Errorf("logged conflict fields = %v, want both service names", entry.Fields)
+	}
+
+	// Once the owner goes away the waiting service takes the route over
+	if err := g.clientset.CoreV1().Services(testNamespace).Delete(ctx, "orders", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete service: %v", err)
+	}
+	eventually(t, func() bool { return body() == "v2" })
+	if conflicts := g.drm.RouteConflicts(); len(conflicts) != 0 {
+		t.Errorf("conflicts after the owner was removed = %+v, want none", conflicts)
+	}
+}
+
+func TestRouteConflictPromotesOldestClaim(t *testing.T) {
+	g := newTestGateway(t, newTestConfig())
+	service := func(name string, paths ...string) *k8s.DiscoveredService {
+		return &k8s.DiscoveredService{Name: name, Namespace: testNamespace, Method: http.MethodGet, Paths: paths}
+	}
+	owner := func(routeKey string) string {
+		g.drm.routesMutex.RLock()
+		defer g.drm.routesMutex.RUnlock()
+		if route, exists := g.drm.dynamicRoutes[routeKey]; exists {
+			return route.ServiceName
+		}
+		return ""
+	}
+	conflicts := func() map[string]string {
+		rejected := make(map[string]string)
+		for _, conflict := range g.drm.RouteConflicts() {
+			rejected[conflict.Rejected] = conflict.Owner
+		}
+		return rejected
+	}
+
+	orders, ordersV2, ordersV3 := service("orders", "/orders"), service("orders-v2", "/orders", "/v2/orders"), service("orders-v3", "/orders")
+	for _, s := range []*k8s.DiscoveredService{orders, ordersV2, ordersV3} {
+		g.drm.syncServiceRoutes(s)
+	}
+	// A re-sync of a rejected service keeps its place in the queue
+	g.drm.syncServiceRoutes(ordersV2)
+
+	if got := owner("GET:/orders"); got != "orders" {
+		t.Fatalf("GET:/orders owner = %q, want the first registered service", got)
+	}
+	if got := owner("GET:/v2/orders"); got != "orders-v2" {
+		t.Errorf("GET:/v2/orders owner = %q, want orders-v2 to keep its unconflicted route", got)
+	}
+	if got, want := conflicts(), map[string]string{"default/orders-v2": "default/orders", "default/orders-v3": "default/orders"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("conflicts = %v, want %v", got, want)
+	}
+
+	g.drm.removeRoute(orders)
+	if got := owner("GET:/orders"); got != "orders-v2" {
+		t.Errorf("GET:/orders owner after removal = %q, want the oldest claimant orders-v2", got)
+	}
+	if got, want := conflicts(), map[string]string{"default/orders-v3": "default/orders-v2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("conflicts after removal = %v, want %v", got, want)
+	}
+
+	// Dropping the path from the owner also releases it
+	g.drm.syncServiceRoutes(service("orders-v2", "/v2/orders"))
+	if got := owner("GET:/orders"); got != "orders-v3" {
+		t.Errorf("GET:/orders owner after orders-v2 dropped it = %q, want orders-v3", got)
+	}
+	if got := conflicts(); len(got) != 0 {
+		t.Errorf("conflicts = %v, want none", got)
+	}
+}
+
+func TestResyncLeavesRejectedClaimAlone(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", nil),
+		testEndpoints(t, "orders", backend.URL),
+		testService("orders-v2", map[string]string{k8s.AnnotationPath: "/orders"}),
+		testEndpoints(t, "orders-v2", backend.URL),
+	)
+	eventually(t, func() bool { return len(g.drm.RouteConflicts()) == 1 })
+
+	owner := func() string {
+		route, exists := g.discovery.GetRoute("GET:/orders")
+		if !exists {
+			return ""
+		}
+		return route.ServiceName
+	}
+	want := g.drm.RouteConflicts()[0].Owner
+	if got := testNamespace + "/" + owner(); got != want {
+		t.Fatalf("discovery route owned by %q, route manager kept %q", got, want)
+	}
+
+	processor := &countingProcessor{events: make(map[k8s.ServiceEventType]int)}
+	g.discovery.AddEventProcessor(processor)
+	for i := 0; i < 3; i++ {
+		g.discovery.resync()
+	}
+	if replayed := processor.count(k8s.ServiceModified); replayed != 0 {
+		t.Errorf("resync replayed %d events for a rejected claim, want none", replayed)
+	}
+
+	// The waiting service takes the route over once the owner is gone, without a resync
+	rejected := "orders-v2"
+	if want == testNamespace+"/orders-v2" {
+		rejected = "orders"
+	}
+	if err := g.clientset.CoreV1().Services(testNamespace).Delete(context.Background(), owner(), metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete service: %v", err)
+	}
+	eventually(t, func() bool { return owner() == rejected })
+	g.discovery.resync()
+	if replayed := processor.count(k8s.ServiceModified); replayed != 0 {
+		t.Errorf("resync replayed %d events after the handover, want none", replayed)
+	}
+}
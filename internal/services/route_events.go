@@ -0,0 +1,225 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Route event types
+const (
+	RouteEventAdded    = "added"
+	RouteEventUpdated  = "updated"
+	RouteEventRemoved  = "removed"
+	RouteEventConflict = "conflict"
+)
+
+// routeEventsPath is the admin endpoint streaming route events
+const routeEventsPath = "/admin/events"
+
+// routeEventSubscriberBuffer is how many batches a slow /admin/events client may fall
+// behind before batches are dropped for it
+const routeEventSubscriberBuffer = 16
+
+// RouteEvent is a change to the route table. For conflicts, Service is the service
+// whose claim was rejected and Owner the one keeping the route.
+type RouteEvent struct {
+	Type      string    `json:"type"`
+	Service   string    `json:"service"`
+	Namespace string    `json:"namespace"`
+	Routes    []string  `json:"routes"`
+	Owner     string    `json:"owner,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RouteEventBatch is the payload posted to the webhook and streamed to subscribers
+type RouteEventBatch struct {
+	Events []RouteEvent `json:"events"`
+}
+
+// routeEventNotifier batches route events until none has arrived for the debounce
+// window, then posts the batch to the webhook and hands it to subscribers. Within a
+// batch a service's events collapse into its latest, so a rollout is one event. A
+// batch is flushed at the latest maxDelay after its first event, however busy it is.
+type routeEventNotifier struct {
+	webhookURL  string
+	debounce    time.Duration
+	maxDelay    time.Duration
+	client      *http.Client
+	pending     map[string]RouteEvent
+	order       []string  // Pending keys in arrival order
+	firstAt     time.Time // When the oldest pending event arrived
+	timer       *time.Timer
+	subscribers map[chan RouteEventBatch]struct{}
+	mutex       sync.Mutex
+	logger      *logger.Logger
+}
+
+func newRouteEventNotifier(webhookURL string, debounce, maxDelay time.Duration, structuredLogger *logger.Logger) *routeEventNotifier {
+	return &routeEventNotifier{
+		webhookURL:  webhookURL,
+		debounce:    debounce,
+		maxDelay:    maxDelay,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		pending:     make(map[string]RouteEvent),
+		subscribers: make(map[chan RouteEventBatch]struct{}),
+		logger:      structuredLogger,
+	}
+}
+
+// serviceEvent builds the event for a service's routes
+func serviceEvent(eventType string, service *k8s.DiscoveredService) RouteEvent {
+	routes := make([]string, 0)
+	for _, route := range service.Routes() {
+		routes = append(routes, route.Key())
+	}
+	return RouteEvent{
+		Type:      eventType,
+		Service:   service.Name,
+		Namespace: service.Namespace,
+		Routes:    routes,
+		Timestamp: time.Now(),
+	}
+}
+
+// publish queues an event and restarts the debounce window, cut short so the batch
+// is not held past maxDelay
+func (n *routeEventNotifier) publish(event RouteEvent) {
+	key := event.Namespace + "/" + event.Service
+	if event.Type == RouteEventConflict {
+		key = fmt.Sprintf("%s %s %v", RouteEventConflict, key, event.Routes)
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	if len(n.order) == 0 {
+		n.firstAt = now
+	}
+	if previous, exists := n.pending[key]; exists {
+		// A service added and then changed in the same batch is still new to subscribers
+		if previous.Type == RouteEventAdded && event.Type == RouteEventUpdated {
+			event.Type = RouteEventAdded
+		}
+	} else {
+		n.order = append(n.order, key)
+	}
+	n.pending[key] = event
+
+	wait := n.debounce
+	if untilMax := n.firstAt.Add(n.maxDelay).Sub(now); untilMax < wait {
+		wait = max(untilMax, 0)
+	}
+	if n.timer == nil {
+		n.timer = time.AfterFunc(wait, n.flush)
+	} else {
+		n.timer.Reset(wait)
+	}
+}
+
+// flush sends the pending events as one batch
+func (n *routeEventNotifier) flush() {
+	n.mutex.Lock()
+	if len(n.order) == 0 {
+		n.mutex.Unlock()
+		return
+	}
+	batch := RouteEventBatch{Events: make([]RouteEvent, 0, len(n.order))}
+	for _, key := range n.order {
+		batch.Events = append(batch.Events, n.pending[key])
+	}
+	n.pending = make(map[string]RouteEvent)
+	n.order = nil
+	n.timer = nil
+
+	for subscriber := range n.subscribers {
+		select {
+		case subscriber <- batch:
+		default:
+		}
+	}
+	n.mutex.Unlock()
+
+	if n.webhookURL != "" {
+		n.post(batch)
+	}
+}
+
+// post sends a batch to the webhook
+func (n *routeEventNotifier) post(batch RouteEventBatch) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
+
+	resp, err := n.client.Post(n.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		n.logger.Warn("Failed to send route events", map[string]interface{}{
+			"events": len(batch.Events),
+			"error":  err,
+		})
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		n.logger.Warn("Route events webhook rejected the batch", map[string]interface{}{
+			"events":      len(batch.Events),
+			"status_code": resp.StatusCode,
+		})
+	}
+}
+
+// subscribe returns a channel receiving every batch and a function ending the subscription
+func (n *routeEventNotifier) subscribe() (<-chan RouteEventBatch, func()) {
+	subscriber := make(chan RouteEventBatch, routeEventSubscriberBuffer)
+
+	n.mutex.Lock()
+	n.subscribers[subscriber] = struct{}{}
+	n.mutex.Unlock()
+
+	return subscriber, func() {
+		n.mutex.Lock()
+		delete(n.subscribers, subscriber)
+		n.mutex.Unlock()
+	}
+}
+
+// serveEvents streams batches to an /admin/events client as server-sent events
+func (n *routeEventNotifier) serveEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream stays open far longer than the server's write timeout
+	rc.SetWriteDeadline(time.Time{})
+
+	batches, unsubscribe := n.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case batch := <-batches:
+			data, err := json.Marshal(batch)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: routes\ndata: %s\n\n", data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
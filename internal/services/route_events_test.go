@@ -0,0 +1,147 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/internal/middleware"
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddedServiceEmitsOneEvent(t *testing.T) {
+	batches := make(chan RouteEventBatch, 4)
+	webhook := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		var batch RouteEventBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		batches <- batch
+	})
+
+	cfg := newTestConfig()
+	cfg.Kubernetes.RouteEventsWebhookURL = webhook.URL
+	cfg.Kubernetes.RouteEventsDebounce = 20 * time.Millisecond
+	g := newTestGateway(t, cfg)
+
+	service := testService("orders", map[string]string{k8s.AnnotationPath: "/orders"})
+	if _, err := g.clientset.CoreV1().Services(testNamespace).Create(context.Background(), service, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+
+	select {
+	case batch := <-batches:
+		if len(batch.Events) != 1 {
+			t.Fatalf("batch has %d events, want 1: %+v", len(batch.Events), batch.Events)
+		}
+		event := batch.Events[0]
+		want := RouteEvent{Type: RouteEventAdded, Service: "orders", Namespace: testNamespace, Routes: []string{"GET:/orders"}}
+		event.Timestamp = time.Time{}
+		if !reflect.DeepEqual(event, want) {
+			t.Errorf("event = %+v, want %+v", event, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook never received the event")
+	}
+}
+
+func TestRouteEventsCollapsePerService(t *testing.T) {
+	tests := []struct {
+		name      string
+		published []string
+		want      string
+	}{
+		{name: "added", published: []string{RouteEventAdded}, want: RouteEventAdded},
+		{name: "added then updated", published: []string{RouteEventAdded, RouteEventUpdated}, want: RouteEventAdded},
+		{name: "updated twice", published: []string{RouteEventUpdated, RouteEventUpdated}, want: RouteEventUpdated},
+		{name: "updated then removed", published: []string{RouteEventUpdated, RouteEventRemoved}, want: RouteEventRemoved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newRouteEventNotifier("", time.Hour, time.Hour, newTestLogger())
+			batches, unsubscribe := n.subscribe()
+			defer unsubscribe()
+
+			for _, eventType := range tt.published {
+				n.publish(RouteEvent{Type: eventType, Service: "orders", Namespace: testNamespace})
+			}
+			n.flush()
+
+			batch := <-batches
+			if len(batch.Events) != 1 || batch.Events[0].Type != tt.want {
+				t.Errorf("batch = %+v, want one %s event", batch.Events, tt.want)
+			}
+		})
+	}
+}
+
+func TestSteadyRouteEventsFlushByMaxDelay(t *testing.T) {
+	const maxDelay = 100 * time.Millisecond
+	n := newRouteEventNotifier("", 50*time.Millisecond, maxDelay, newTestLogger())
+	batches, unsubscribe := n.subscribe()
+	defer unsubscribe()
+
+	// Events keep arriving faster than the debounce window, so it never goes quiet
+	stop := make(chan struct{})
+	defer close(stop)
+	start := time.Now()
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n.publish(RouteEvent{Type: RouteEventUpdated, Service: "orders", Namespace: testNamespace})
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	select {
+	case batch := <-batches:
+		if elapsed := time.Since(start); elapsed > 5*maxDelay {
+			t.Errorf("first batch after %v, want about %v", elapsed, maxDelay)
+		}
+		if len(batch.Events) != 1 {
+			t.Errorf("batch has %d events, want the service's events collapsed into 1", len(batch.Events))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a steady stream of events was never flushed")
+	}
+}
+
+func TestRouteEventStreamHasNoRequestTimeout(t *testing.T) {
+	g := newTestGateway(t, newTestConfig())
+	const timeout = 20 * time.Millisecond
+	tm := middleware.NewTimeoutMiddleware(timeout)
+	tm.SetRouteTimeouts(g.drm.RequestTimeout)
+	server := httptest.NewServer(tm.Middleware(http.HandlerFunc(g.drm.events.serveEvents)))
+	t.Cleanup(server.Close)
+
+	// No Accept: text/event-stream, so only the path exempts the stream
+	resp, err := http.Get(server.URL + routeEventsPath)
+	if err != nil {
+		t.Fatalf("GET %s: %v", routeEventsPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	time.Sleep(5 * timeout)
+	g.drm.events.publish(RouteEvent{Type: RouteEventAdded, Service: "orders", Namespace: testNamespace})
+	g.drm.events.flush()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "event: routes\n" {
+		t.Errorf("stream read %q, %v after the timeout, want an event", line, err)
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"api-gateway/pkg/metrics"
+	"sort"
+	"time"
+)
+
+// latencyWindowSize is the number of recent samples kept per route
+const latencyWindowSize = 1000
+
+// RouteLatencyStats summarises request latency for a single route
+type RouteLatencyStats struct {
+	Route         string        `json:"route"`
+	Requests      int64         `json:"requests"`
+	Errors        int64         `json:"errors"`
+	AvgLatency    time.Duration `json:"avg_latency"`
+	P95Latency    time.Duration `json:"p95_latency"`
+	LastLatency   time.Duration `json:"last_latency"`
+	WindowSamples int           `json:"window_samples"`
+}
+
+// latencyWindow keeps a ring of recent latencies so averages follow current behaviour
+type latencyWindow struct {
+	samples  []time.Duration
+	next     int
+	total    time.Duration // Sum of the samples currently in the ring
+	requests int64
+	errors   int64
+	last     time.Duration
+}
+
+func newLatencyWindow() *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, 0, latencyWindowSize)}
+}
+
+func (lw *latencyWindow) record(duration time.Duration, failed bool) {
+	lw.requests++
+	if failed {
+		lw.errors++
+	}
+	lw.last = duration
+
+	if len(lw.samples) < latencyWindowSize {
+		lw.samples = append(lw.samples, duration)
+	} else {
+		lw.total -= lw.samples[lw.next]
+		lw.samples[lw.next] = duration
+		lw.next = (lw.next + 1) % latencyWindowSize
+	}
+	lw.total += duration
+}
+
+func (lw *latencyWindow) average() time.Duration {
+	if len(lw.samples) == 0 {
+		return 0
+	}
+	return lw.total / time.Duration(len(lw.samples))
+}
+
+func (lw *latencyWindow) percentile(p float64) time.Duration {
+	if len(lw.samples) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(lw.samples))
+	copy(sorted, lw.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	index := int(p*float64(len(sorted))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+func (lw *latencyWindow) snapshot(route string) RouteLatencyStats {
+	return RouteLatencyStats{
+		Route:         route,
+		Requests:      lw.requests,
+		Errors:        lw.errors,
+		AvgLatency:    lw.average(),
+		P95Latency:    lw.percentile(0.95),
+		LastLatency:   lw.last,
+		WindowSamples: len(lw.samples),
+	}
+}
+
+// recordLatency adds a completed request's duration to the route and overall averages
+func (drm *DynamicRouteManager) recordLatency(route *DynamicRouteInfo, duration time.Duration, failed bool) {
+	outcome := "success"
+	if failed {
+		outcome = "error"
+	}
+	drm.metrics.IncCounter("gateway_route_requests_total", metrics.Labels{
+		"route":   route.ID,
+		"service": route.ServiceName,
+		"outcome": outcome,
+	})
+	drm.metrics.ObserveHistogram("gateway_route_request_duration_seconds", metrics.Labels{
+		"route": route.ID,
+	}, duration.Seconds())
+
+	drm.statsMutex.Lock()
+	defer drm.statsMutex.Unlock()
+
+	window, exists := drm.latency[route.ID]
+	if !exists {
+		window = newLatencyWindow()
+		drm.latency[route.ID] = window
+	}
+	window.record(duration, failed)
+
+	drm.overallLatency.record(duration, failed)
+	drm.stats.AvgResponseTime = drm.overallLatency.average()
+}
+
+// GetRouteLatencyStats returns latency statistics for every route that has served traffic
+func (drm *DynamicRouteManager) GetRouteLatencyStats() []RouteLatencyStats {
+	drm.statsMutex.RLock()
+	defer drm.statsMutex.RUnlock()
+
+	stats := make([]RouteLatencyStats, 0, len(drm.latency))
+	for route, window := range drm.latency {
+		stats = append(stats, window.snapshot(route))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Route < stats[j].Route })
+
+	return stats
+}
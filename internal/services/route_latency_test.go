@@ -0,0 +1,109 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name        string
+		samples     []time.Duration
+		failed      int // Samples at the start recorded as failures
+		wantAvg     time.Duration
+		wantP95     time.Duration
+		wantSamples int
+	}{
+		{name: "no samples"},
+		{name: "one sample", samples: []time.Duration{40 * ms}, wantAvg: 40 * ms, wantP95: 40 * ms, wantSamples: 1},
+		{name: "average of samples", samples: []time.Duration{10 * ms, 20 * ms, 30 * ms}, failed: 1, wantAvg: 20 * ms, wantP95: 30 * ms, wantSamples: 3},
+		{name: "p95 of twenty samples", samples: sequence(20, ms), wantAvg: 10500 * time.Microsecond, wantP95: 19 * ms, wantSamples: 20},
+		{
+			name:        "old samples leave the window",
+			samples:     append(repeat(latencyWindowSize, 100*ms), repeat(latencyWindowSize, 2*ms)...),
+			wantAvg:     2 * ms,
+			wantP95:     2 * ms,
+			wantSamples: latencyWindowSize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := newLatencyWindow()
+			for i, sample := range tt.samples {
+				window.record(sample, i < tt.failed)
+			}
+
+			got := window.snapshot("GET:/orders")
+			if got.AvgLatency != tt.wantAvg || got.P95Latency != tt.wantP95 {
+				t.Errorf("avg, p95 = %v, %v, want %v, %v", got.AvgLatency, got.P95Latency, tt.wantAvg, tt.wantP95)
+			}
+			if got.Requests != int64(len(tt.samples)) || got.Errors != int64(tt.failed) || got.WindowSamples != tt.wantSamples {
+				t.Errorf("requests, errors, samples = %d, %d, %d, want %d, %d, %d",
+					got.Requests, got.Errors, got.WindowSamples, len(tt.samples), tt.failed, tt.wantSamples)
+			}
+		})
+	}
+}
+
+// sequence returns n durations of 1..n units
+func sequence(n int, unit time.Duration) []time.Duration {
+	samples := make([]time.Duration, n)
+	for i := range samples {
+		samples[i] = time.Duration(i+1) * unit
+	}
+	return samples
+}
+
+// repeat returns n copies of a duration
+func repeat(n int, duration time.Duration) []time.Duration {
+	samples := make([]time.Duration, n)
+	for i := range samples {
+		samples[i] = duration
+	}
+	return samples
+}
+
+func TestRouteStatsEndpointReportsLatency(t *testing.T) {
+	g := newTestGateway(t, newTestConfig())
+	g.drm.SetupAdminEndpoints(g.router)
+
+	orders := &DynamicRouteInfo{ID: "GET:/orders", ServiceName: "orders"}
+	billing := &DynamicRouteInfo{ID: "GET:/billing", ServiceName: "billing"}
+	g.drm.recordLatency(orders, 10*time.Millisecond, false)
+	g.drm.recordLatency(orders, 30*time.Millisecond, true)
+	g.drm.recordLatency(billing, 50*time.Millisecond, false)
+
+	rec := g.serve(httptest.NewRequest(http.MethodGet, "/admin/routes/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var response struct {
+		Overall RouteStats          `json:"overall"`
+		Routes  []RouteLatencyStats `json:"routes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	if response.Overall.AvgResponseTime != 30*time.Millisecond {
+		t.Errorf("overall average = %v, want 30ms", response.Overall.AvgResponseTime)
+	}
+	want := map[string]RouteLatencyStats{
+		"GET:/billing": {Requests: 1, AvgLatency: 50 * time.Millisecond, LastLatency: 50 * time.Millisecond},
+		"GET:/orders":  {Requests: 2, Errors: 1, AvgLatency: 20 * time.Millisecond, LastLatency: 30 * time.Millisecond},
+	}
+	if len(response.Routes) != len(want) {
+		t.Fatalf("routes = %+v, want %d", response.Routes, len(want))
+	}
+	for _, got := range response.Routes {
+		w := want[got.Route]
+		if got.Requests != w.Requests || got.Errors != w.Errors || got.AvgLatency != w.AvgLatency || got.LastLatency != w.LastLatency {
+			t.Errorf("%s = %+v, want %+v", got.Route, got, w)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"sort"
+	"strings"
+)
+
+// routeMatchKind ranks how a route path matched a request path. When several
+// routes match, the lowest kind wins:
+//
+//  1. exact: the route path equals the request path
+//  2. prefix: the route path is a whole-segment prefix of the request path,
+//     so /users matches /users/42 but not /usersettings; longer prefixes win
+//  3. parameterized: the route path has {name} segments and matches the
+//     request segment by segment
+//
+// Remaining ties are broken by route path so matching never depends on map order.
+type routeMatchKind int
+
+const (
+	matchExact routeMatchKind = iota
+	matchPrefix
+	matchParameterized
+	noMatch
+)
+
+// routeCandidate is a route that matched a request, with how it matched
+type routeCandidate struct {
+	route *DynamicRouteInfo
+	kind  routeMatchKind
+}
+
+// matchRoutePath reports how routePath matches the request path
+func matchRoutePath(routePath, path string) routeMatchKind {
+	if routePath == path {
+		return matchExact
+	}
+	if isParameterized(routePath) {
+		if matchSegments(routePath, path) {
+			return matchParameterized
+		}
+		return noMatch
+	}
+	prefix := strings.TrimSuffix(routePath, "/")
+	if strings.HasPrefix(path, prefix+"/") {
+		return matchPrefix
+	}
+	return noMatch
+}
+
+// isParameterized reports whether a route path has {name} segments
+func isParameterized(routePath string) bool {
+	return strings.Contains(routePath, "{") && strings.Contains(routePath, "}")
+}
+
+// matchSegments matches a parameterized route path against a request path segment by segment
+func matchSegments(routePath, path string) bool {
+	routeSegments := strings.Split(strings.Trim(routePath, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(routeSegments) != len(pathSegments) {
+		return false
+	}
+
+	for i, segment := range routeSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortCandidates orders matched routes by precedence, best first
+func sortCandidates(candidates []routeCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if len(a.route.Path) != len(b.route.Path) {
+			return len(a.route.Path) > len(b.route.Path)
+		}
+		return a.route.Path < b.route.Path
+	})
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchRoutePath(t *testing.T) {
+	tests := []struct {
+		routePath, path string
+		want            routeMatchKind
+	}{
+		{routePath: "/users", path: "/users", want: matchExact},
+		{routePath: "/users", path: "/users/42", want: matchPrefix},
+		{routePath: "/users/", path: "/users/42", want: matchPrefix},
+		{routePath: "/users", path: "/usersettings", want: noMatch},
+		{routePath: "/users/{id}", path: "/users/42", want: matchParameterized},
+		{routePath: "/users/{id}", path: "/users/42/orders", want: noMatch},
+		{routePath: "/users/{id}", path: "/users/", want: noMatch},
+		{routePath: "/orders", path: "/users", want: noMatch},
+	}
+
+	for _, tt := range tests {
+		if got := matchRoutePath(tt.routePath, tt.path); got != tt.want {
+			t.Errorf("matchRoutePath(%q, %q) = %d, want %d", tt.routePath, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLookupRoutePrecedence(t *testing.T) {
+	routes := []string{"/users", "/users/me", "/users/{id}", "/users/{id}/orders", "/users/admin", "/users/admin/reports"}
+	drm := &DynamicRouteManager{dynamicRoutes: make(map[string]*DynamicRouteInfo)}
+	for _, path := range routes {
+		drm.dynamicRoutes[http.MethodGet+":"+path] = &DynamicRouteInfo{ID: http.MethodGet + ":" + path, Path: path, Method: http.MethodGet}
+	}
+
+	tests := []struct {
+		path string
+		want string // Route path expected to win; empty for no match
+	}{
+		{path: "/users/me", want: "/users/me"},                            // Exact beats prefix and parameterized
+		{path: "/users/admin/reports/2024", want: "/users/admin/reports"}, // Longest prefix wins
+		{path: "/users/admin/settings", want: "/users/admin"},
+		{path: "/users/42/orders", want: "/users"}, // Any prefix beats a parameterized route
+		{path: "/users/42", want: "/users"},
+		{path: "/accounts", want: ""},
+	}
+
+	for _, tt := range tests {
+		// Map iteration order varies, so repeat to catch order-dependent matches
+		for i := 0; i < 20; i++ {
+			route := drm.lookupRoute(http.MethodGet, tt.path)
+			got := ""
+			if route != nil {
+				got = route.Path
+			}
+			if got != tt.want {
+				t.Fatalf("lookupRoute(%s) = %q, want %q", tt.path, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestExactRouteBeatsPrefixRoute(t *testing.T) {
+	users := newBackend(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("users")) })
+	me := newBackend(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("me")) })
+	g := newTestGateway(t, newTestConfig(),
+		testService("users", nil), testEndpoints(t, "users", users.URL),
+		testService("me", map[string]string{k8s.AnnotationPath: "/users/me"}), testEndpoints(t, "me", me.URL),
+	)
+	g.waitForEndpoints(t, http.MethodGet, "/users", 1)
+	g.waitForEndpoints(t, http.MethodGet, "/users/me", 1)
+
+	tests := []struct{ path, want string }{
+		{path: "/users/me", want: "me"},
+		{path: "/users/42", want: "users"},
+	}
+	for _, tt := range tests {
+		rec := g.serve(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+			t.Errorf("GET %s = %d %q, want 200 %q", tt.path, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RegisteredNamespace is the namespace routes registered through RegisterRoute are listed under
+const RegisteredNamespace = "registered"
+
+// ErrRouteNotRegistered is returned by UnregisterRoute for a name that was never registered
+var ErrRouteNotRegistered = errors.New("route not registered")
+
+// ErrRouteConflict is returned by RegisterRoute when another service already serves a route
+var ErrRouteConflict = errors.New("route already served by another service")
+
+// RouteSpec describes routes to a fixed set of endpoints, registered at runtime
+// without Kubernetes. Every method is served on every path.
+type RouteSpec struct {
+	Name          string   `json:"name"`                     // Identifies the registration, unique among services
+	Methods       []string `json:"methods,omitempty"`        // Empty or "*" accepts any method
+	Paths         []string `json:"paths"`                    // Matched like gateway.io/path: exact, {param} or prefix
+	Endpoints     []string `json:"endpoints"`                // Backend host:port addresses
+	Scheme        string   `json:"scheme,omitempty"`         // http or https, http by default
+	AuthRequired  bool     `json:"auth_required,omitempty"`  // Requests need a valid token
+	AuthOptional  bool     `json:"auth_optional,omitempty"`  // A token is verified when sent, but anonymous requests pass
+	AuthProvider  string   `json:"auth_provider,omitempty"`  // Token issuer the routes are verified against; empty is the default one
+	LoadBalancing string   `json:"load_balancing,omitempty"` // PROXY_LOAD_BALANCING by default
+}
+
+// service builds the discovered service the route manager serves the spec's routes from,
+// balanced with defaultStrategy unless the spec names a strategy
+func (spec RouteSpec) service(defaultStrategy string) (*k8s.DiscoveredService, error) {
+	name := strings.TrimSpace(spec.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	methodList := strings.Join(spec.Methods, ",")
+	if methodList == "" {
+		methodList = "*"
+	}
+	methods, err := k8s.ParseMethods(methodList)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(spec.Paths) == 0 {
+		return nil, errors.New("at least one path is required")
+	}
+	for _, path := range spec.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("path %q must start with /", path)
+		}
+	}
+
+	if len(spec.Endpoints) == 0 {
+		return nil, errors.New("at least one endpoint is required")
+	}
+	endpoints := make([]k8s.ServiceEndpoint, 0, len(spec.Endpoints))
+	for _, address := range spec.Endpoints {
+		host, portValue, err := net.SplitHostPort(strings.TrimSpace(address))
+		port, portErr := strconv.ParseUint(portValue, 10, 16)
+		if err != nil || portErr != nil || host == "" || port == 0 {
+			return nil, fmt.Errorf("endpoint %q must be host:port", address)
+		}
+		endpoints = append(endpoints, k8s.ServiceEndpoint{
+			IP:     host,
+			Port:   int32(port),
+			Ready:  true,
+			Weight: k8s.DefaultEndpointWeight,
+		})
+	}
+
+	scheme := strings.ToLower(strings.TrimSpace(spec.Scheme))
+	if scheme != "" && scheme != gatewayproxy.SchemeHTTP && scheme != gatewayproxy.SchemeHTTPS {
+		return nil, fmt.Errorf("scheme %q must be http or https", spec.Scheme)
+	}
+
+	loadBalancing := spec.LoadBalancing
+	if loadBalancing == "" {
+		loadBalancing = defaultStrategy
+	}
+	if !IsLoadBalancingStrategy(loadBalancing) {
+		return nil, fmt.Errorf("unknown load balancing strategy %q", spec.LoadBalancing)
+	}
+
+	if spec.AuthRequired && spec.AuthOptional {
+		return nil, errors.New("auth_required and auth_optional are exclusive")
+	}
+
+	return &k8s.DiscoveredService{
+		Name:          name,
+		Namespace:     RegisteredNamespace,
+		Path:          spec.Paths[0],
+		Paths:         append([]string(nil), spec.Paths...),
+		Method:        methods[0],
+		Methods:       methods,
+		AuthRequired:  spec.AuthRequired,
+		AuthOptional:  spec.AuthOptional,
+		AuthProvider:  spec.AuthProvider,
+		LoadBalancing: loadBalancing,
+		Scheme:        scheme,
+		Protocol:      k8s.ProtocolHTTP,
+		Ready:         true,
+		Endpoints:     endpoints,
+		LastUpdated:   time.Now(),
+	}, nil
+}
+
+// RegisterRoute serves a spec's routes, replacing an earlier registration of the same
+// name. Routes another service already serves are refused with ErrRouteConflict.
+func (drm *DynamicRouteManager) RegisterRoute(spec RouteSpec) error {
+	service, err := spec.service(drm.config.Proxy.LoadBalancing)
+	if err != nil {
+		return fmt.Errorf("invalid route spec: %w", err)
+	}
+	if _, discovered := drm.discoveryManager.GetDiscoveredService(service.Name); discovered {
+		return fmt.Errorf("invalid route spec: name %q is used by a discovered service", service.Name)
+	}
+
+	key := serviceKey(service)
+	drm.routesMutex.Lock()
+	for _, route := range service.Routes() {
+		if existing, exists := drm.dynamicRoutes[route.Key()]; exists && existing.Namespace+"/"+existing.ServiceName != key {
+			drm.routesMutex.Unlock()
+			return fmt.Errorf("%w: %s is served by %s/%s", ErrRouteConflict, route.Key(), existing.Namespace, existing.ServiceName)
+		}
+	}
+	drm.registered[service.Name] = service
+	drm.routesMutex.Unlock()
+
+	err = drm.updateRoute(service)
+	drm.pruneProxies()
+	return err
+}
+
+// UnregisterRoute removes the routes registered under name
+func (drm *DynamicRouteManager) UnregisterRoute(name string) error {
+	drm.routesMutex.Lock()
+	service, exists := drm.registered[name]
+	delete(drm.registered, name)
+	drm.routesMutex.Unlock()
+
+	if !exists {
+		return ErrRouteNotRegistered
+	}
+	err := drm.removeRoute(service)
+	drm.pruneProxies()
+	return err
+}
+
+// RegisteredRoutes returns the services registered through RegisterRoute, by name
+func (drm *DynamicRouteManager) RegisteredRoutes() map[string]*k8s.DiscoveredService {
+	drm.routesMutex.RLock()
+	defer drm.routesMutex.RUnlock()
+
+	registered := make(map[string]*k8s.DiscoveredService, len(drm.registered))
+	for name, service := range drm.registered {
+		registered[name] = service
+	}
+	return registered
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteSpecService(t *testing.T) {
+	valid := RouteSpec{Name: "orders", Paths: []string{"/orders"}, Endpoints: []string{"10.0.0.1:8080"}}
+	tests := []struct {
+		name    string
+		modify  func(spec *RouteSpec)
+		wantErr string
+	}{
+		{name: "valid", modify: func(spec *RouteSpec) {}},
+		{name: "missing name", modify: func(spec *RouteSpec) { spec.Name = " " }, wantErr: "name is required"},
+		{name: "unknown method", modify: func(spec *RouteSpec) { spec.Methods = []string{"GET", "FETCH"} }, wantErr: "FETCH"},
+		{name: "no paths", modify: func(spec *RouteSpec) { spec.Paths = nil }, wantErr: "at least one path"},
+		{name: "relative path", modify: func(spec *RouteSpec) { spec.Paths = []string{"orders"} }, wantErr: "must start with /"},
+		{name: "no endpoints", modify: func(spec *RouteSpec) { spec.Endpoints = nil }, wantErr: "at least one endpoint"},
+		{name: "endpoint without port", modify: func(spec *RouteSpec) { spec.Endpoints = []string{"10.0.0.1"} }, wantErr: "host:port"},
+		{name: "endpoint with port 0", modify: func(spec *RouteSpec) { spec.Endpoints = []string{"10.0.0.1:0"} }, wantErr: "host:port"},
+		{name: "unknown scheme", modify: func(spec *RouteSpec) { spec.Scheme = "ftp" }, wantErr: "http or https"},
+		{name: "unknown strategy", modify: func(spec *RouteSpec) { spec.LoadBalancing = "fastest" }, wantErr: "fastest"},
+		{name: "required and optional auth", modify: func(spec *RouteSpec) { spec.AuthRequired, spec.AuthOptional = true, true }, wantErr: "exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := valid
+			tt.modify(&spec)
+			service, err := spec.service("round-robin")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if service.Namespace != RegisteredNamespace || service.LoadBalancing != "round-robin" || len(service.Methods) < 7 {
+				t.Errorf("service = %+v, want registered namespace, default strategy and any method", service)
+			}
+			if len(service.Endpoints) != 1 || service.Endpoints[0] != (k8s.ServiceEndpoint{IP: "10.0.0.1", Port: 8080, Ready: true, Weight: k8s.DefaultEndpointWeight}) {
+				t.Errorf("endpoints = %+v", service.Endpoints)
+			}
+		})
+	}
+}
+
+func TestAdminRegisteredRouteProxies(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method+" "+r.URL.Path)
+	})
+	discovered := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newServiceGateway(t, newTestConfig(), "users", nil, discovered.URL)
+	g.drm.SetupAdminEndpoints(g.router)
+	address := backend.URL[len("http://"):]
+
+	register := func(spec RouteSpec) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(spec)
+		return g.serve(httptest.NewRequest(http.MethodPost, "/admin/routes", bytes.NewReader(body)))
+	}
+	get := func(method, path string) *httptest.ResponseRecorder {
+		return g.serve(httptest.NewRequest(method, path, nil))
+	}
+
+	rec := register(RouteSpec{Name: "orders", Methods: []string{"GET", "POST"}, Paths: []string{"/orders"}, Endpoints: []string{address}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /admin/routes = %d %q, want 201", rec.Code, rec.Body.String())
+	}
+	var registered k8s.DiscoveredService
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || registered.Name != "orders" {
+		t.Errorf("response = %q, %v, want the registered service", rec.Body.String(), err)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if rec := get(method, "/orders"); rec.Code != http.StatusOK || rec.Body.String() != method+" /orders" {
+			t.Errorf("%s /orders = %d %q, want it proxied", method, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Re-posting the name replaces its routes
+	if rec := register(RouteSpec{Name: "orders", Paths: []string{"/v2/orders"}, Endpoints: []string{address}, AuthRequired: true}); rec.Code != http.StatusCreated {
+		t.Fatalf("re-register = %d %q, want 201", rec.Code, rec.Body.String())
+	}
+	if rec := get(http.MethodGet, "/orders"); rec.Code == http.StatusOK {
+		t.Error("GET /orders still served after the registration was replaced")
+	}
+	if rec := get(http.MethodGet, "/v2/orders"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /v2/orders without a token = %d, want 401", rec.Code)
+	}
+	token, err := g.jwt.CreateToken("alice")
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v2/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if rec := g.serve(req); rec.Code != http.StatusOK || rec.Body.String() != "GET /v2/orders" {
+		t.Errorf("GET /v2/orders with a token = %d %q, want it proxied", rec.Code, rec.Body.String())
+	}
+
+	rejected := []struct {
+		name string
+		spec RouteSpec
+		want int
+	}{
+		{name: "route served by a discovered service", spec: RouteSpec{Name: "accounts", Methods: []string{"GET"}, Paths: []string{"/users"}, Endpoints: []string{address}}, want: http.StatusConflict},
+		{name: "name of a discovered service", spec: RouteSpec{Name: "users", Paths: []string{"/people"}, Endpoints: []string{address}}, want: http.StatusBadRequest},
+		{name: "invalid spec", spec: RouteSpec{Name: "catalog", Paths: []string{"/catalog"}}, want: http.StatusBadRequest},
+	}
+	for _, tt := range rejected {
+		if rec := register(tt.spec); rec.Code != tt.want {
+			t.Errorf("%s: POST /admin/routes = %d %q, want %d", tt.name, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+	if err := g.drm.RegisterRoute(rejected[0].spec); !errors.Is(err, ErrRouteConflict) {
+		t.Errorf("RegisterRoute = %v, want ErrRouteConflict", err)
+	}
+
+	if rec := get(http.MethodDelete, "/admin/routes?name=orders"); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /admin/routes = %d, want 204", rec.Code)
+	}
+	if rec := get(http.MethodGet, "/v2/orders"); rec.Code == http.StatusOK || rec.Code == http.StatusUnauthorized {
+		t.Errorf("GET /v2/orders after unregistering = %d, want it no longer routed", rec.Code)
+	}
+	if rec := get(http.MethodDelete, "/admin/routes?name=orders"); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE /admin/routes = %d, want 404", rec.Code)
+	}
+	if rec := get(http.MethodGet, "/users"); rec.Code != http.StatusOK {
+		t.Errorf("GET /users = %d, want the discovered route untouched", rec.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	gatewayproxy "api-gateway/internal/proxy"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// statusBackend responds with the status given in the request's status query parameter
+func statusBackend(t *testing.T) string {
+	t.Helper()
+	return newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		status, err := strconv.Atoi(r.URL.Query().Get("status"))
+		if err != nil {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+	}).URL
+}
+
+func TestStatusMapRemapsUpstreamStatus(t *testing.T) {
+	g := newServiceGateway(t, newTestConfig(), "orders", map[string]string{k8s.AnnotationStatusMap: "418=400,509=429,500=400,200=503"}, statusBackend(t))
+
+	tests := []struct {
+		upstream     int
+		want         int
+		wantFailures bool // Counted against the circuit breaker
+	}{
+		{upstream: http.StatusTeapot, want: http.StatusBadRequest},
+		{upstream: 509, want: http.StatusTooManyRequests},
+		{upstream: http.StatusNotFound, want: http.StatusNotFound},
+		{upstream: http.StatusInternalServerError, want: http.StatusBadRequest}, // Remapped away from a server error
+		{upstream: http.StatusOK, want: http.StatusServiceUnavailable, wantFailures: true},
+		{upstream: http.StatusBadGateway, want: http.StatusBadGateway, wantFailures: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.upstream), func(t *testing.T) {
+			g.drm.circuitBreakerManager.ResetAll()
+			rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders?status="+strconv.Itoa(tt.upstream), nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+
+			failures := g.drm.circuitBreakerManager.GetCircuitBreaker("orders").Counts().TotalFailures
+			if (failures > 0) != tt.wantFailures {
+				t.Errorf("breaker failures = %d, want failures %v", failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		if delay, err := time.ParseDuration(r.URL.Query().Get("delay")); err == nil {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write([]byte("ok"))
+	})
+	g := newServiceGateway(t, newTestConfig(), "orders", map[string]string{k8s.AnnotationHeaderTimeout: "100ms"}, backend.URL)
+
+	tests := []struct {
+		name         string
+		delay        time.Duration
+		want         int
+		wantTimeouts int64
+	}{
+		{name: "headers within the deadline", delay: 10 * time.Millisecond, want: http.StatusOK},
+		{name: "deadline breached", delay: 2 * time.Second, want: http.StatusGatewayTimeout, wantTimeouts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g.drm.circuitBreakerManager.ResetAll()
+			before := g.upstreamErrors.Count("orders", gatewayproxy.ErrorTypeTimeout)
+			start := time.Now()
+			rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders?delay="+tt.delay.String(), nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if elapsed := time.Since(start); tt.wantTimeouts > 0 && elapsed > time.Second {
+				t.Errorf("took %v, want the request cut off at the deadline", elapsed)
+			}
+			if got := g.upstreamErrors.Count("orders", gatewayproxy.ErrorTypeTimeout) - before; got < tt.wantTimeouts {
+				t.Errorf("timeout errors = %d, want %d", got, tt.wantTimeouts)
+			}
+			failures := g.drm.circuitBreakerManager.GetCircuitBreaker("orders").Counts().TotalFailures
+			if (failures > 0) != (tt.wantTimeouts > 0) {
+				t.Errorf("breaker failures = %d, want failures %v", failures, tt.wantTimeouts > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"api-gateway/internal/config"
+	gatewayproxy "api-gateway/internal/proxy"
+	"api-gateway/pkg/logger"
+	"net/http"
+)
+
+// newUpstreamTransport builds the transport for discovered backends. A broken CA
+// bundle is logged and the default transport is used, so HTTPS upstreams signed
+// by that CA fail verification rather than being trusted silently. Connections
+// are counted by the tracker.
+func newUpstreamTransport(cfg *config.Config, connections *gatewayproxy.ConnectionTracker, structuredLogger *logger.Logger) *http.Transport {
+	transport, err := gatewayproxy.NewUpstreamTransport(gatewayproxy.UpstreamTransportConfig{
+		InsecureSkipVerify:  cfg.Proxy.UpstreamInsecureSkipVerify,
+		CAFile:              cfg.Proxy.UpstreamCAFile,
+		MaxIdleConns:        cfg.Proxy.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.Proxy.MaxIdleConnsPerHost,
+	})
+	if err != nil {
+		structuredLogger.Error("Failed to configure upstream transport, using defaults", map[string]interface{}{
+			"error": err,
+		})
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	connections.Instrument(transport)
+	return transport
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSUpstreamWithCABundle(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure orders"))
+	}))
+	backend.Config.ErrorLog = log.New(io.Discard, "", 0) // Failed handshakes are expected
+	backend.StartTLS()
+	t.Cleanup(backend.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+	if err := os.WriteFile(caFile, block, 0o600); err != nil {
+		t.Fatalf("write CA bundle: %v", err)
+	}
+	// testEndpoints takes http URLs; the annotation decides the scheme
+	address := "http://" + strings.TrimPrefix(backend.URL, "https://")
+
+	tests := []struct {
+		name       string
+		scheme     string
+		serverName string // The test certificate is issued to example.com
+		caFile     string
+		wantStatus int
+	}{
+		{name: "https with the CA bundle", scheme: "https", serverName: "example.com", caFile: caFile, wantStatus: http.StatusOK},
+		{name: "scheme is case-insensitive", scheme: " HTTPS ", serverName: "example.com", caFile: caFile, wantStatus: http.StatusOK},
+		// The certificate covers the pod IP dialed, but not the service's DNS name
+		{name: "service name verified by default", scheme: "https", caFile: caFile, wantStatus: http.StatusBadGateway},
+		{name: "https without the CA bundle", scheme: "https", serverName: "example.com", wantStatus: http.StatusBadGateway},
+		// The TLS server answers plain HTTP with a 400 of its own, which is proxied
+		{name: "plain http to a TLS backend", caFile: caFile, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Proxy.UpstreamCAFile = tt.caFile
+			annotations := map[string]string{}
+			if tt.scheme != "" {
+				annotations[k8s.AnnotationScheme] = tt.scheme
+			}
+			if tt.serverName != "" {
+				annotations[k8s.AnnotationServerName] = tt.serverName
+			}
+			g := newServiceGateway(t, cfg, "orders", annotations, address)
+
+			rec := g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != "secure orders" {
+				t.Errorf("body = %q, want the backend's", rec.Body.String())
+			}
+		})
+	}
+}
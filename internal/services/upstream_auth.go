@@ -0,0 +1,82 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upstreamAuthTTL is how long resolved credentials are reused before the source is read again,
+// so rotated secrets are picked up without a Kubernetes API call per request
+const upstreamAuthTTL = time.Minute
+
+// upstreamCredentials resolves gateway.io/upstream-auth references into Authorization
+// header values. Credentials only live here and are never logged or exposed.
+type upstreamCredentials struct {
+	lookupSecret func(ctx context.Context, namespace, name string) (string, string, error)
+	cache        map[string]cachedCredential
+	mutex        sync.Mutex
+}
+
+type cachedCredential struct {
+	authorization string
+	expires       time.Time
+}
+
+// newUpstreamCredentials creates a resolver reading secrets through lookupSecret
+func newUpstreamCredentials(lookupSecret func(ctx context.Context, namespace, name string) (string, string, error)) *upstreamCredentials {
+	return &upstreamCredentials{
+		lookupSecret: lookupSecret,
+		cache:        make(map[string]cachedCredential),
+	}
+}
+
+// authorization returns the Basic Authorization header value for the service's backend
+func (uc *upstreamCredentials) authorization(ctx context.Context, service *k8s.DiscoveredService) (string, error) {
+	key := service.Namespace + "/" + service.UpstreamAuth
+
+	uc.mutex.Lock()
+	cached, exists := uc.cache[key]
+	uc.mutex.Unlock()
+	if exists && time.Now().Before(cached.expires) {
+		return cached.authorization, nil
+	}
+
+	username, password, err := uc.resolve(ctx, service)
+	if err != nil {
+		return "", err
+	}
+
+	authorization := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	uc.mutex.Lock()
+	uc.cache[key] = cachedCredential{authorization: authorization, expires: time.Now().Add(upstreamAuthTTL)}
+	uc.mutex.Unlock()
+	return authorization, nil
+}
+
+// resolve reads the username and password from the referenced source
+func (uc *upstreamCredentials) resolve(ctx context.Context, service *k8s.DiscoveredService) (string, string, error) {
+	source, name, err := k8s.ParseUpstreamAuth(service.UpstreamAuth)
+	if err != nil {
+		return "", "", err
+	}
+
+	if source == k8s.UpstreamAuthEnv {
+		username, password, found := strings.Cut(os.Getenv(name), ":")
+		if !found || username == "" || password == "" {
+			return "", "", fmt.Errorf("environment variable %s must hold username:password", name)
+		}
+		return username, password, nil
+	}
+
+	if uc.lookupSecret == nil {
+		return "", "", errors.New("secret credentials need a Kubernetes client")
+	}
+	return uc.lookupSecret(ctx, service.Namespace, name)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"api-gateway/pkg/logger"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// basic returns the Basic Authorization header value for the credentials
+func basic(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestUpstreamAuthInjection(t *testing.T) {
+	t.Setenv("GATEWAY_UPSTREAM_AUTH_LEGACY", "env-user:env-pass")
+	t.Setenv("JWT_SECRET", "gateway-user:signing-key")
+	received := make(chan string, 1)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Authorization")
+	})
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy-credentials", Namespace: testNamespace},
+		Data:       map[string][]byte{"username": []byte("secret-user"), "password": []byte("secret-pass")},
+	}
+
+	tests := []struct {
+		name         string
+		upstreamAuth string // Empty leaves the annotation off
+		clientAuth   string
+		wantStatus   int
+		wantUpstream string
+	}{
+		{name: "from a secret", upstreamAuth: "secret:legacy-credentials", clientAuth: "Bearer client-token", wantStatus: http.StatusOK, wantUpstream: basic("secret-user", "secret-pass")},
+		{name: "from the environment", upstreamAuth: "env:GATEWAY_UPSTREAM_AUTH_LEGACY", wantStatus: http.StatusOK, wantUpstream: basic("env-user", "env-pass")},
+		{name: "client's Basic header is replaced", upstreamAuth: "env:GATEWAY_UPSTREAM_AUTH_LEGACY", clientAuth: basic("mallory", "guess"), wantStatus: http.StatusOK, wantUpstream: basic("env-user", "env-pass")},
+		{name: "variable outside the prefix is never read", upstreamAuth: "env:JWT_SECRET", clientAuth: "Bearer client-token", wantStatus: http.StatusOK, wantUpstream: "Bearer client-token"},
+		{name: "missing secret fails closed", upstreamAuth: "secret:missing", clientAuth: "Bearer client-token", wantStatus: http.StatusBadGateway},
+		{name: "no annotation passes the client header", clientAuth: "Bearer client-token", wantStatus: http.StatusOK, wantUpstream: "Bearer client-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tt.upstreamAuth != "" {
+				annotations[k8s.AnnotationUpstreamAuth] = tt.upstreamAuth
+			}
+			g := newTestGateway(t, newTestConfig(), testService("legacy", annotations), testEndpoints(t, "legacy", backend.URL), secret)
+			g.waitForEndpoints(t, http.MethodGet, "/legacy", 1)
+
+			req := httptest.NewRequest(http.MethodGet, "/legacy", nil)
+			if tt.clientAuth != "" {
+				req.Header.Set("Authorization", tt.clientAuth)
+			}
+			rec := g.serve(req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := <-received; got != tt.wantUpstream {
+				t.Errorf("upstream Authorization = %q, want %q", got, tt.wantUpstream)
+			}
+		})
+	}
+}
+
+func TestUpstreamCredentialsAreNotLogged(t *testing.T) {
+	t.Setenv("GATEWAY_UPSTREAM_AUTH_LEGACY", "env-user:env-pass")
+	routeLogger := logger.NewLogger(logger.Config{Level: "debug", Format: "json"})
+	t.Cleanup(routeLogger.Close)
+	hook := &captureHook{}
+	routeLogger.AddHook(hook)
+
+	g := newTestGatewayWith(t, newTestConfig(), routeLogger, nil,
+		testService("legacy", map[string]string{k8s.AnnotationUpstreamAuth: "env:GATEWAY_UPSTREAM_AUTH_LEGACY"}),
+		testEndpoints(t, "legacy", refusedURL(t)))
+	g.waitForEndpoints(t, http.MethodGet, "/legacy", 1)
+
+	g.serve(httptest.NewRequest(http.MethodGet, "/legacy", nil))
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.entries) == 0 {
+		t.Fatal("nothing was logged for the failed request")
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte("env-user:env-pass"))
+	for _, entry := range hook.entries {
+		if line := entry.Message + " " + fmt.Sprint(entry.Fields); strings.Contains(line, "env-pass") || strings.Contains(line, encoded) {
+			t.Errorf("credentials logged: %s", line)
+		}
+	}
+}
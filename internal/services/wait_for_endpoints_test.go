@@ -0,0 +1,73 @@
+package services
+
+import (
+	"api-gateway/internal/k8s"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWaitForEndpointsDefersRoute(t *testing.T) {
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	g := newTestGateway(t, newTestConfig(),
+		testService("orders", map[string]string{k8s.AnnotationWaitEndpoints: "true"}),
+		testService("billing", nil),
+	)
+	eventually(t, func() bool {
+		routes := g.discovery.GetRoutes()
+		return routes["GET:/orders"] != nil && routes["GET:/billing"] != nil
+	})
+
+	// Held out of matching until its first ready endpoint, unlike a route that serves 503s
+	heldStatus := map[string]int{"/orders": http.StatusNotFound, "/billing": http.StatusServiceUnavailable}
+	for path, want := range heldStatus {
+		if rec := g.serve(httptest.NewRequest(http.MethodGet, path, nil)); rec.Code != want {
+			t.Errorf("GET %s without endpoints = %d, want %d", path, rec.Code, want)
+		}
+	}
+	if route := g.discovery.GetRoutes()["GET:/orders"]; !route.Service.Held() || route.Service.Ready {
+		t.Errorf("orders route held, ready = %v, %v, want true, false", route.Service.Held(), route.Service.Ready)
+	}
+
+	steps := []struct {
+		name      string
+		endpoints *corev1.Endpoints
+		want      int
+	}{
+		{name: "only not-ready endpoints", endpoints: notReadyEndpoints(t, "orders", backend.URL), want: http.StatusNotFound},
+		{name: "first ready endpoint releases the route", endpoints: testEndpoints(t, "orders", backend.URL), want: http.StatusOK},
+		{name: "losing every endpoint later doesn't hold it again", endpoints: testEndpoints(t, "orders"), want: http.StatusServiceUnavailable},
+	}
+	for i, step := range steps {
+		var err error
+		if i == 0 {
+			_, err = g.clientset.CoreV1().Endpoints(testNamespace).Create(context.Background(), step.endpoints, metav1.CreateOptions{})
+		} else {
+			_, err = g.clientset.CoreV1().Endpoints(testNamespace).Update(context.Background(), step.endpoints, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			t.Fatalf("%s: write endpoints: %v", step.name, err)
+		}
+		eventually(t, func() bool {
+			return g.serve(httptest.NewRequest(http.MethodGet, "/orders", nil)).Code == step.want
+		})
+	}
+	if route := g.discovery.GetRoutes()["GET:/orders"]; route.Service.Held() || !route.Service.Ready {
+		t.Errorf("orders route held, ready = %v, %v after release, want false, true", route.Service.Held(), route.Service.Ready)
+	}
+}
+
+// notReadyEndpoints returns endpoints listing every URL as not ready
+func notReadyEndpoints(t *testing.T, name string, urls ...string) *corev1.Endpoints {
+	t.Helper()
+	endpoints := testEndpoints(t, name, urls...)
+	for i := range endpoints.Subsets {
+		endpoints.Subsets[i].NotReadyAddresses = endpoints.Subsets[i].Addresses
+		endpoints.Subsets[i].Addresses = nil
+	}
+	return endpoints
+}
@@ -0,0 +1,11 @@
+// Package version holds the build information stamped into the binary with -ldflags, e.g.
+//
+//	go build -ldflags "-X api-gateway/internal/version.Version=v1.2.3 -X api-gateway/internal/version.Commit=abc1234"
+package version
+
+// Build information; the defaults identify a development build
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
@@ -0,0 +1,48 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAdvance(t *testing.T) {
+	start := time.Unix(0, 0)
+	fake := NewFake(start)
+	ticker := fake.NewTicker(10 * time.Second)
+
+	tests := []struct {
+		advance   time.Duration
+		wantSince time.Duration
+		wantTick  bool
+	}{
+		{advance: 5 * time.Second, wantSince: 5 * time.Second},
+		{advance: 5 * time.Second, wantSince: 10 * time.Second, wantTick: true},
+		{advance: 9 * time.Second, wantSince: 19 * time.Second},
+		{advance: 25 * time.Second, wantSince: 44 * time.Second, wantTick: true}, // Missed ticks are dropped, not queued
+	}
+
+	for _, tt := range tests {
+		fake.Advance(tt.advance)
+		if got := fake.Since(start); got != tt.wantSince {
+			t.Errorf("Since = %v, want %v", got, tt.wantSince)
+		}
+		select {
+		case <-ticker.C():
+			if !tt.wantTick {
+				t.Errorf("ticker fired at %v", tt.wantSince)
+			}
+		default:
+			if tt.wantTick {
+				t.Errorf("ticker did not fire at %v", tt.wantSince)
+			}
+		}
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Error("stopped ticker fired")
+	default:
+	}
+}
@@ -0,0 +1,39 @@
+package jwt
+
+import (
+	"api-gateway/internal/config"
+)
+
+// DefaultProvider names the provider configured by JWT_SECRET, used by routes that don't select one
+const DefaultProvider = "default"
+
+// Providers holds the token verifiers routes can select by name
+type Providers struct {
+	services map[string]*Service
+}
+
+// NewProviders creates the default provider from cfg plus one per configured auth provider.
+// Additional providers share the default token expiration.
+func NewProviders(cfg config.JWTConfig, defaultService *Service) *Providers {
+	services := map[string]*Service{DefaultProvider: defaultService}
+	for _, provider := range cfg.Providers {
+		services[provider.Name] = NewService(config.JWTConfig{
+			Secret:     provider.Secret,
+			Algorithm:  provider.Algorithm,
+			Issuer:     provider.Issuer,
+			Expiration: cfg.Expiration,
+
+			ClockSkewLeeway: cfg.ClockSkewLeeway,
+		})
+	}
+	return &Providers{services: services}
+}
+
+// Get returns the named provider; an empty name selects the default one
+func (p *Providers) Get(name string) (*Service, bool) {
+	if name == "" {
+		name = DefaultProvider
+	}
+	service, exists := p.services[name]
+	return service, exists
+}
@@ -0,0 +1,59 @@
+package jwt
+
+import (
+	"api-gateway/internal/config"
+	"testing"
+	"time"
+)
+
+func TestProvidersVerifyIndependently(t *testing.T) {
+	cfg := config.JWTConfig{
+		Secret:     "gateway-secret",
+		Algorithm:  "HS256",
+		Expiration: time.Hour,
+		Providers: []config.AuthProviderConfig{
+			{Name: "partner", Secret: "partner-secret", Algorithm: "HS384", Issuer: "partner.example.com"},
+		},
+	}
+	providers := NewProviders(cfg, NewService(cfg))
+	partnerConfig := config.JWTConfig{Secret: "partner-secret", Algorithm: "HS384", Issuer: "partner.example.com", Expiration: time.Hour}
+
+	issuers := map[string]*Service{
+		"gateway":            NewService(cfg),
+		"partner":            NewService(partnerConfig),
+		"partner, no issuer": NewService(config.JWTConfig{Secret: "partner-secret", Algorithm: "HS384", Expiration: time.Hour}),
+		"partner, HS256":     NewService(config.JWTConfig{Secret: "partner-secret", Algorithm: "HS256", Issuer: "partner.example.com", Expiration: time.Hour}),
+	}
+	tests := []struct {
+		issuer    string
+		provider  string
+		wantValid bool
+	}{
+		{issuer: "gateway", provider: "", wantValid: true},
+		{issuer: "gateway", provider: DefaultProvider, wantValid: true},
+		{issuer: "gateway", provider: "partner"},
+		{issuer: "partner", provider: "partner", wantValid: true},
+		{issuer: "partner", provider: DefaultProvider},
+		{issuer: "partner, no issuer", provider: "partner"},
+		{issuer: "partner, HS256", provider: "partner"},
+	}
+
+	for _, tt := range tests {
+		token, err := issuers[tt.issuer].CreateToken("alice")
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		service, exists := providers.Get(tt.provider)
+		if !exists {
+			t.Fatalf("provider %q not found", tt.provider)
+		}
+		err = service.VerifyToken(token)
+		if got := err == nil; got != tt.wantValid {
+			t.Errorf("%s token on provider %q: valid = %v, want %v (err = %v)", tt.issuer, tt.provider, got, tt.wantValid, err)
+		}
+	}
+
+	if _, exists := providers.Get("unknown"); exists {
+		t.Error("unknown provider found")
+	}
+}
@@ -2,12 +2,20 @@ package jwt
 
 import (
 	"api-gateway/internal/config"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Token verification failures, distinguishable with errors.Is
+var (
+	ErrTokenExpired     = errors.New("token expired")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrInvalidToken     = errors.New("invalid token")
+)
+
 type Service struct {
 	config config.JWTConfig
 }
@@ -17,10 +25,14 @@ func NewService(cfg config.JWTConfig) *Service {
 }
 
 func (s *Service) CreateToken(username string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	claims := jwt.MapClaims{
 		"username": username,
 		"exp":      time.Now().Add(s.config.Expiration).Unix(),
-	})
+	}
+	if s.config.Issuer != "" {
+		claims["iss"] = s.config.Issuer
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(s.algorithm()), claims)
 
 	tokenString, err := token.SignedString([]byte(s.config.Secret))
 	if err != nil {
@@ -31,20 +43,75 @@ func (s *Service) CreateToken(username string) (string, error) {
 }
 
 func (s *Service) VerifyToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.Secret), nil
-	})
+	token, err := jwt.Parse(tokenString, s.key, s.parserOptions()...)
 	
 	if err != nil {
-		return fmt.Errorf("failed to parse token: %w", err)
+		return verificationError(err)
 	}
 
 	if !token.Valid {
-		return fmt.Errorf("invalid token")
+		return ErrInvalidToken
 	}
 
 	return nil
 }
+
+// Username verifies the token and returns its username claim
+func (s *Service) Username(tokenString string) (string, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.key, s.parserOptions()...)
+	if err != nil {
+		return "", verificationError(err)
+	}
+	if !token.Valid {
+		return "", ErrInvalidToken
+	}
+
+	username, _ := claims["username"].(string)
+	if username == "" {
+		return "", fmt.Errorf("%w: no username claim", ErrInvalidToken)
+	}
+	return username, nil
+}
+
+// algorithm is the HMAC algorithm tokens are signed with, HS256 unless configured
+func (s *Service) algorithm() string {
+	if s.config.Algorithm == "" {
+		return jwt.SigningMethodHS256.Alg()
+	}
+	return s.config.Algorithm
+}
+
+// key returns the secret for HMAC-signed tokens
+func (s *Service) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return []byte(s.config.Secret), nil
+}
+
+// parserOptions only accept tokens signed with the configured algorithm and,
+// when an issuer is configured, issued by it. Time claims allow the clock skew leeway.
+func (s *Service) parserOptions() []jwt.ParserOption {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{s.algorithm()}),
+		jwt.WithLeeway(s.config.ClockSkewLeeway),
+	}
+	if s.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(s.config.Issuer))
+	}
+	return options
+}
+
+// verificationError maps a parse failure to ErrTokenExpired, ErrInvalidSignature or
+// ErrInvalidToken, keeping the library's reason in the message
+func verificationError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: %v", ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+}
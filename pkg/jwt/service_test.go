@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"api-gateway/internal/config"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyTokenLeeway(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration time.Duration // Relative to now, negative for a token that already expired
+		secret     string        // Signing secret, the verifier's when empty
+		leeway     time.Duration
+		wantErr    error
+	}{
+		{name: "valid", expiration: time.Hour, leeway: 30 * time.Second},
+		{name: "expired within the leeway", expiration: -10 * time.Second, leeway: 30 * time.Second},
+		{name: "expired beyond the leeway", expiration: -time.Minute, leeway: 30 * time.Second, wantErr: ErrTokenExpired},
+		{name: "expired without a leeway", expiration: -10 * time.Second, wantErr: ErrTokenExpired},
+		{name: "signed with another secret", expiration: time.Hour, secret: "other-secret", leeway: 30 * time.Second, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.JWTConfig{Secret: "jwt-test-secret", Expiration: tt.expiration, ClockSkewLeeway: tt.leeway}
+			issuer := cfg
+			if tt.secret != "" {
+				issuer.Secret = tt.secret
+			}
+			token, err := NewService(issuer).CreateToken("alice")
+			if err != nil {
+				t.Fatalf("CreateToken: %v", err)
+			}
+
+			service := NewService(cfg)
+			err = service.VerifyToken(token)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("VerifyToken = %v, want accepted", err)
+				}
+				if username, err := service.Username(token); err != nil || username != "alice" {
+					t.Errorf("Username = %q, %v, want alice", username, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyToken = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := service.Username(token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Username error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := NewService(config.JWTConfig{Secret: "jwt-test-secret"}).VerifyToken("not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyToken(malformed) = %v, want %v", err, ErrInvalidToken)
+	}
+}
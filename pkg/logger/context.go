@@ -2,8 +2,12 @@ package logger
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 // Context keys for storing metadata
@@ -23,6 +27,31 @@ func GenerateCorrelationID() string {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
 }
 
+// correlationSignatureLength is the number of hex characters of HMAC appended to signed IDs
+const correlationSignatureLength = 32
+
+// GenerateSignedCorrelationID generates a correlation ID followed by "." and an
+// HMAC-SHA256 signature, so gateways sharing key can tell they issued it
+func GenerateSignedCorrelationID(key []byte) string {
+	id := GenerateCorrelationID()
+	return id + "." + correlationSignature(id, key)
+}
+
+// VerifyCorrelationID reports whether a correlation ID carries a valid signature for key
+func VerifyCorrelationID(correlationID string, key []byte) bool {
+	id, signature, found := strings.Cut(correlationID, ".")
+	if !found || len(signature) != correlationSignatureLength {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(correlationSignature(id, key)))
+}
+
+func correlationSignature(id string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:correlationSignatureLength]
+}
+
 // GenerateRequestID generates a new request ID
 func GenerateRequestID() string {
 	b := make([]byte, 8)
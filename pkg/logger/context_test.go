@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyCorrelationID(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	signed := GenerateSignedCorrelationID(key)
+	id, signature, _ := strings.Cut(signed, ".")
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "signed with the key", value: signed, want: true},
+		{name: "signed with another key", value: GenerateSignedCorrelationID([]byte("another-key-another-key-another!!"))},
+		{name: "unsigned", value: id},
+		{name: "ID changed", value: GenerateCorrelationID() + "." + signature},
+		{name: "signature changed", value: id + "." + strings.Repeat("0", correlationSignatureLength)},
+		{name: "signature truncated", value: id + "." + signature[:correlationSignatureLength-1]},
+		{name: "empty", value: ""},
+	}
+
+	for _, tt := range tests {
+		if got := VerifyCorrelationID(tt.value, key); got != tt.want {
+			t.Errorf("%s: VerifyCorrelationID(%q) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultHookBufferSize = 1000
+	defaultHookWorkers    = 2
+)
+
+// SynchronousHook is implemented by hooks that are cheap enough to fire inline
+// instead of through the background dispatcher
+type SynchronousHook interface {
+	Hook
+	Synchronous() bool
+}
+
+// hookJob is a single hook invocation queued for a background worker
+type hookJob struct {
+	hook  Hook
+	entry *LogEntry
+}
+
+// hookDispatcher fires hooks on background workers so slow hooks can't stall logging
+type hookDispatcher struct {
+	queue   chan hookJob
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+// newHookDispatcher creates a dispatcher and starts its workers
+func newHookDispatcher(bufferSize, workers int) *hookDispatcher {
+	if bufferSize <= 0 {
+		bufferSize = defaultHookBufferSize
+	}
+	if workers <= 0 {
+		workers = defaultHookWorkers
+	}
+
+	d := &hookDispatcher{
+		queue: make(chan hookJob, bufferSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+
+	return d
+}
+
+func (d *hookDispatcher) worker() {
+	defer d.wg.Done()
+
+	for job := range d.queue {
+		fireHook(job.hook, job.entry)
+	}
+}
+
+// fireHook fires a hook, reporting errors and panics to stderr so a broken hook
+// can't take down the code that logged or the dispatcher worker
+func fireHook(hook Hook, entry *LogEntry) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Use standard log to avoid recursion
+			log.Printf("Hook panic: %v", r)
+		}
+	}()
+
+	if err := hook.Fire(entry); err != nil {
+		// Use standard log to avoid recursion
+		log.Printf("Hook error: %v", err)
+	}
+}
+
+// dispatch queues a hook invocation, dropping it if the buffer is full or the
+// dispatcher has been closed
+func (d *hookDispatcher) dispatch(hook Hook, entry *LogEntry) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		d.dropped.Add(1)
+		return
+	}
+
+	select {
+	case d.queue <- hookJob{hook: hook, entry: entry}:
+	default:
+		d.dropped.Add(1)
+	}
+}
+
+// close stops accepting new jobs and waits for queued ones to be fired
+func (d *hookDispatcher) close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
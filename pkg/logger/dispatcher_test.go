@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingHook holds every invocation until released
+type blockingHook struct {
+	release chan struct{}
+	countingHook
+}
+
+func (h *blockingHook) Fire(entry *LogEntry) error {
+	<-h.release
+	return h.countingHook.Fire(entry)
+}
+
+func TestBlockingHookDoesNotDelayLogging(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *Logger, msg string)
+	}{
+		{name: "info", log: func(l *Logger, msg string) { l.Info(msg) }},
+		{name: "error", log: func(l *Logger, msg string) { l.Error(msg) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const buffer, entries = 4, 50
+			l := NewLogger(Config{Level: "info", HookBufferSize: buffer, HookWorkers: 1})
+			l.output = io.Discard
+			hook := &blockingHook{release: make(chan struct{})}
+			l.AddHook(hook)
+
+			start := time.Now()
+			for i := 0; i < entries; i++ {
+				tt.log(l, "request handled")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("logging %d entries took %v with a blocked hook", entries, elapsed)
+			}
+
+			// One entry is held by the worker and the buffer is full; the rest were dropped
+			if dropped := l.DroppedHookEntries(); dropped < entries-buffer-1 {
+				t.Errorf("dropped = %d, want at least %d", dropped, entries-buffer-1)
+			}
+
+			close(hook.release)
+			l.Close()
+			if fired, dropped := hook.fired.Load(), l.DroppedHookEntries(); fired+dropped != entries {
+				t.Errorf("fired %d + dropped %d, want %d entries accounted for", fired, dropped, entries)
+			}
+		})
+	}
+}
+
+func TestCloseFlushesQueuedHooks(t *testing.T) {
+	l := NewLogger(Config{Level: "info", HookWorkers: 1})
+	l.output = io.Discard
+	hook := &blockingHook{release: make(chan struct{})}
+	l.AddHook(hook)
+
+	for i := 0; i < 10; i++ {
+		l.Info("queued")
+	}
+	close(hook.release)
+	l.Close()
+
+	if got := hook.fired.Load(); got != 10 {
+		t.Errorf("hook fired %d times by Close, want 10", got)
+	}
+}
+
+func TestSynchronousHookFiresInline(t *testing.T) {
+	l := NewLogger(Config{Level: "info"})
+	l.output = io.Discard
+	defer l.Close()
+
+	counting := &countingHook{sync: true}
+	l.AddHook(counting)
+
+	l.Error("upstream failed")
+	if got := counting.fired.Load(); got != 1 {
+		t.Errorf("synchronous hook fired %d times before Error returned, want 1", got)
+	}
+}
+
+// mutatingHook changes the fields of every entry it is fired for
+type mutatingHook struct {
+	countingHook
+}
+
+func (h *mutatingHook) Fire(entry *LogEntry) error {
+	entry.Fields["hooked"] = true
+	if request, ok := entry.Fields["request"].(map[string]interface{}); ok {
+		request["hooked"] = true
+	}
+	return h.countingHook.Fire(entry)
+}
+
+func TestAsyncHooksGetTheirOwnFields(t *testing.T) {
+	l := NewLogger(Config{Level: "info", HookWorkers: 2})
+	var out bytes.Buffer
+	l.output = &out
+	first, second := &mutatingHook{}, &mutatingHook{}
+	l.AddHook(first)
+	l.AddHook(second)
+
+	// Run with -race: the hooks write to the fields while the formatter reads them
+	request := map[string]interface{}{"path": "/orders"}
+	for i := 0; i < 20; i++ {
+		l.Info("request handled", map[string]interface{}{"request": request})
+	}
+	l.Close()
+
+	if fired := first.fired.Load() + second.fired.Load(); fired != 40 {
+		t.Errorf("hooks fired %d times, want 40", fired)
+	}
+	if _, changed := request["hooked"]; changed {
+		t.Error("hook changed the caller's nested fields")
+	}
+	if strings.Contains(out.String(), "hooked") {
+		t.Errorf("hook changes reached the formatted output:\n%s", out.String())
+	}
+}
@@ -3,6 +3,10 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -73,3 +77,117 @@ func (f *TextFormatter) Format(entry *LogEntry) ([]byte, error) {
 
 	return []byte(result), nil
 }
+
+// ANSI color codes used by the console formatter
+const (
+	colorReset = "\x1b[0m"
+	colorGray  = "\x1b[90m"
+	colorRed   = "\x1b[31m"
+)
+
+var levelColors = map[string]string{
+	"DEBUG": "\x1b[37m",
+	"INFO":  "\x1b[36m",
+	"WARN":  "\x1b[33m",
+	"ERROR": "\x1b[31m",
+	"FATAL": "\x1b[35m",
+}
+
+// ConsoleFormatter formats logs for reading in a terminal during local development:
+// aligned level, component and message columns followed by sorted key=value fields,
+// colorized when Color is set
+type ConsoleFormatter struct {
+	Color bool
+}
+
+// consoleMessageWidth is the column the fields start at for short messages
+const consoleMessageWidth = 44
+
+func (f *ConsoleFormatter) Format(entry *LogEntry) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString(f.colorize(colorGray, entry.Timestamp.Format("15:04:05.000")))
+	b.WriteByte(' ')
+	b.WriteString(f.colorize(levelColors[entry.Level], fmt.Sprintf("%-5s", entry.Level)))
+	b.WriteByte(' ')
+	b.WriteString(f.colorize(colorGray, fmt.Sprintf("%-16s", entry.Component)))
+	b.WriteByte(' ')
+	b.WriteString(fmt.Sprintf("%-*s", consoleMessageWidth, entry.Message))
+
+	fields := map[string]interface{}{}
+	for key, value := range entry.Fields {
+		fields[key] = value
+	}
+	standard := map[string]string{
+		"correlation_id": entry.CorrelationID,
+		"request_id":     entry.RequestID,
+		"user_id":        entry.UserID,
+		"method":         entry.Method,
+		"path":           entry.Path,
+		"duration":       entry.Duration,
+		"client_ip":      entry.ClientIP,
+	}
+	for key, value := range standard {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if entry.StatusCode != 0 {
+		fields["status"] = entry.StatusCode
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		b.WriteByte(' ')
+		b.WriteString(f.colorize(levelColors[entry.Level], key))
+		b.WriteByte('=')
+		b.WriteString(consoleValue(fields[key]))
+	}
+	if entry.Error != "" {
+		b.WriteByte(' ')
+		b.WriteString(f.colorize(colorRed, "error="+consoleValue(entry.Error)))
+	}
+	b.WriteByte('\n')
+
+	if entry.StackTrace != "" {
+		for _, line := range strings.Split(strings.TrimRight(entry.StackTrace, "\n"), "\n") {
+			b.WriteString("    ")
+			b.WriteString(f.colorize(colorGray, line))
+			b.WriteByte('\n')
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+func (f *ConsoleFormatter) colorize(color, text string) string {
+	if !f.Color || color == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// consoleValue renders a field value, quoting strings that contain spaces or quotes
+func consoleValue(value interface{}) string {
+	text := fmt.Sprintf("%v", value)
+	if strings.ContainsAny(text, " \t\n\"=") {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// isTerminal reports whether w is a character device such as a terminal; redirected
+// output and files are not
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
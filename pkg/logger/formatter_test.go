@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsoleFormatter(t *testing.T) {
+	entry := &LogEntry{
+		Timestamp:     time.Date(2024, 5, 1, 9, 30, 15, 250e6, time.UTC),
+		Level:         "WARN",
+		Message:       "Upstream slow",
+		Component:     "proxy",
+		CorrelationID: "checkout-7f3a",
+		StatusCode:    504,
+		Error:         "context deadline exceeded",
+		Fields:        map[string]interface{}{"service": "orders", "attempt": 2, "note": "retry scheduled"},
+	}
+
+	tests := []struct {
+		name      string
+		color     bool
+		wantColor bool
+	}{
+		{name: "colorized", color: true, wantColor: true},
+		{name: "plain when not a terminal", color: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := (&ConsoleFormatter{Color: tt.color}).Format(entry)
+			if err != nil {
+				t.Fatalf("Format: %v", err)
+			}
+			line := string(out)
+
+			if got := strings.Contains(line, "\x1b["); got != tt.wantColor {
+				t.Errorf("escape codes present = %v, want %v in %q", got, tt.wantColor, line)
+			}
+			if tt.wantColor && !strings.Contains(line, levelColors["WARN"]+"WARN "+colorReset) {
+				t.Errorf("level not colorized in %q", line)
+			}
+
+			// Stripped of color, both modes render the same text
+			plain := line
+			for _, code := range []string{colorReset, colorGray, colorRed, levelColors["WARN"]} {
+				plain = strings.ReplaceAll(plain, code, "")
+			}
+			for _, want := range []string{
+				"09:30:15.250 WARN  proxy            Upstream slow ",
+				` attempt=2 correlation_id=checkout-7f3a note="retry scheduled" service=orders status=504`,
+				` error="context deadline exceeded"`,
+			} {
+				if !strings.Contains(plain, want) {
+					t.Errorf("line %q missing %q", plain, want)
+				}
+			}
+			if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+				t.Errorf("line %q is not a single line", line)
+			}
+		})
+	}
+}
+
+func TestConsoleFormatterIndentsStackTrace(t *testing.T) {
+	out, _ := (&ConsoleFormatter{}).Format(&LogEntry{Level: "ERROR", Message: "Panic recovered", StackTrace: "main.handler()\n\tmain.go:12\n"})
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != 3 || lines[1] != "    main.handler()" || lines[2] != "    \tmain.go:12" {
+		t.Errorf("stack trace lines = %q, want them indented under the entry", lines)
+	}
+}
+
+func TestConsoleFormatLoggerWithoutTerminal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.log")
+	l := NewLogger(Config{Level: "info", Format: "console", Output: "file", FilePath: path})
+	l.Info("Gateway started", map[string]interface{}{"port": 8080})
+	l.Close()
+
+	formatter, ok := l.formatter.(*ConsoleFormatter)
+	if !ok {
+		t.Fatalf("formatter = %T, want *ConsoleFormatter", l.formatter)
+	}
+	if formatter.Color {
+		t.Error("color enabled for output to a file")
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if !strings.Contains(string(written), "INFO ") || !strings.Contains(string(written), "port=8080") || bytes.Contains(written, []byte("\x1b[")) {
+		t.Errorf("log = %q, want plain console output with the level", written)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer file.Close()
+
+	for name, w := range map[string]io.Writer{"buffer": &bytes.Buffer{}, "file": file} {
+		if isTerminal(w) {
+			t.Errorf("isTerminal(%s) = true, want false", name)
+		}
+	}
+}
@@ -1,23 +1,49 @@
 package logger
 
 import (
+	"api-gateway/pkg/clock"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
 
-// ErrorTrackingHook tracks errors and sends alerts
+// Alerting defaults of ErrorTrackingHook
+const (
+	DefaultAlertCooldown = 5 * time.Minute
+	minAlertBackoff      = 5 * time.Second
+	maxAlertBackoff      = 10 * time.Minute
+)
+
+// DefaultAlertThresholds are the error counts that alert within the cooldown;
+// past the last one, every multiple of it alerts
+var DefaultAlertThresholds = []int{10, 50, 100}
+
+// ErrorTrackingHook tracks errors and sends alerts. While the webhook is failing or
+// rate limiting, alerts are dropped until its Retry-After or an exponential backoff
+// with jitter has passed.
 type ErrorTrackingHook struct {
-	webhookURL    string
-	errorCount    map[string]int
-	lastAlert     map[string]time.Time
-	alertCooldown time.Duration
-	mu            sync.RWMutex
-	client        *http.Client
+	webhookURL      string
+	errorCount      map[string]int
+	lastAlert       map[string]time.Time
+	alertCooldown   time.Duration
+	alertThresholds []int
+	mu              sync.RWMutex
+	client          *http.Client
+	clock           clock.Clock
+
+	// Webhook backoff
+	backoffUntil    time.Time
+	failures        int   // Consecutive failed deliveries
+	droppedAlerts   int64 // Alerts not delivered, skipped during backoff or failed
+	deliveredAlerts int64
 }
 
 // AlertPayload represents the structure sent to alerting systems
@@ -35,17 +61,63 @@ type AlertPayload struct {
 
 // NewErrorTrackingHook creates a new error tracking hook
 func NewErrorTrackingHook() *ErrorTrackingHook {
+	return NewErrorTrackingHookWithClock(clock.Real{})
+}
+
+// NewErrorTrackingHookWithClock creates an error tracking hook whose alert
+// cooldowns are measured with the given clock
+func NewErrorTrackingHookWithClock(clk clock.Clock) *ErrorTrackingHook {
 	return &ErrorTrackingHook{
-		webhookURL:    os.Getenv("ERROR_WEBHOOK_URL"), // Slack, Teams, or custom webhook
-		errorCount:    make(map[string]int),
-		lastAlert:     make(map[string]time.Time),
-		alertCooldown: 5 * time.Minute, // Don't spam alerts
+		webhookURL:      os.Getenv("ERROR_WEBHOOK_URL"), // Slack, Teams, or custom webhook
+		errorCount:      make(map[string]int),
+		lastAlert:       make(map[string]time.Time),
+		alertCooldown:   DefaultAlertCooldown, // Don't spam alerts
+		alertThresholds: DefaultAlertThresholds,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		clock: clk,
 	}
 }
 
+// SetWebhookURL sets the webhook alerts are posted to; empty disables sending
+func (h *ErrorTrackingHook) SetWebhookURL(url string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.webhookURL = url
+}
+
+// SetAlertCooldown sets how long a repeated error waits before alerting again;
+// zero or less keeps the current cooldown
+func (h *ErrorTrackingHook) SetAlertCooldown(cooldown time.Duration) {
+	if cooldown <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alertCooldown = cooldown
+}
+
+// SetAlertThresholds sets the ascending error counts that alert within the cooldown;
+// an empty list keeps the current thresholds
+func (h *ErrorTrackingHook) SetAlertThresholds(thresholds []int) {
+	if len(thresholds) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alertThresholds = append([]int(nil), thresholds...)
+}
+
+// DroppedAlerts returns how many alerts were not delivered
+func (h *ErrorTrackingHook) DroppedAlerts() int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.droppedAlerts
+}
+
 // Fire processes log entries for error tracking
 func (h *ErrorTrackingHook) Fire(entry *LogEntry) error {
 	// Only process ERROR and FATAL levels
@@ -65,8 +137,13 @@ func (h *ErrorTrackingHook) Fire(entry *LogEntry) error {
 
 	// Check if we should send an alert
 	if h.shouldSendAlert(errorKey, count) {
-		h.lastAlert[errorKey] = time.Now()
-		go h.sendAlert(entry, count)
+		if h.webhookURL != "" && h.clock.Now().Before(h.backoffUntil) {
+			// Left unrecorded so the error alerts again once the webhook recovers
+			h.droppedAlerts++
+		} else {
+			h.lastAlert[errorKey] = h.clock.Now()
+			go h.sendAlert(entry, count)
+		}
 	}
 
 	// Clean up old error counts periodically
@@ -95,21 +172,26 @@ func (h *ErrorTrackingHook) shouldSendAlert(errorKey string, count int) bool {
 	}
 
 	// Send alert if cooldown period has passed
-	if time.Since(lastAlert) > h.alertCooldown {
+	if h.clock.Since(lastAlert) > h.alertCooldown {
 		return true
 	}
 
-	// Send alert for critical thresholds (10, 50, 100, etc.)
-	if count == 10 || count == 50 || count == 100 || (count > 100 && count%100 == 0) {
-		return true
+	// Send alert for critical thresholds (10, 50, 100, then every 100 by default)
+	for _, threshold := range h.alertThresholds {
+		if count == threshold {
+			return true
+		}
 	}
-
-	return false
+	last := h.alertThresholds[len(h.alertThresholds)-1]
+	return count > last && count%last == 0
 }
 
 // sendAlert sends an alert to the configured webhook
 func (h *ErrorTrackingHook) sendAlert(entry *LogEntry, count int) {
-	if h.webhookURL == "" {
+	h.mu.RLock()
+	webhookURL := h.webhookURL
+	h.mu.RUnlock()
+	if webhookURL == "" {
 		return // No webhook configured
 	}
 
@@ -135,12 +217,14 @@ func (h *ErrorTrackingHook) sendAlert(entry *LogEntry, count int) {
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return // Can't marshal, skip alert
+		h.recordDelivery(nil) // Can't marshal, skip alert
+		return
 	}
 
 	// Send webhook (this is a generic webhook format)
-	req, err := http.NewRequest("POST", h.webhookURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
+		h.recordDelivery(nil)
 		return
 	}
 
@@ -148,14 +232,78 @@ func (h *ErrorTrackingHook) sendAlert(entry *LogEntry, count int) {
 
 	resp, err := h.client.Do(req)
 	if err != nil {
+		h.recordDelivery(nil)
 		return
 	}
 	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	h.recordDelivery(resp)
+}
+
+// recordDelivery counts an alert delivery and, when it failed, backs off the webhook
+// for its Retry-After or an exponential delay, either stretched by up to half
+// again as jitter. resp is nil when no response was received.
+func (h *ErrorTrackingHook) recordDelivery(resp *http.Response) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		h.failures = 0
+		h.deliveredAlerts++
+		return
+	}
+
+	h.droppedAlerts++
+	h.failures++
+
+	delay := minAlertBackoff << min(h.failures-1, 16)
+	if delay > maxAlertBackoff {
+		delay = maxAlertBackoff
+	}
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), h.clock.Now()); ok {
+			delay = retryAfter
+		}
+	}
+	delay += rand.N(delay/2 + 1)
+
+	if until := h.clock.Now().Add(delay); until.After(h.backoffUntil) {
+		h.backoffUntil = until
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// WriteMetrics writes alert delivery counters in the Prometheus text format
+func (h *ErrorTrackingHook) WriteMetrics(w io.Writer) {
+	h.mu.RLock()
+	delivered, dropped := h.deliveredAlerts, h.droppedAlerts
+	h.mu.RUnlock()
+
+	fmt.Fprintln(w, "# HELP gateway_alerts_delivered_total Error alerts delivered to the webhook")
+	fmt.Fprintln(w, "# TYPE gateway_alerts_delivered_total counter")
+	fmt.Fprintf(w, "gateway_alerts_delivered_total %d\n", delivered)
+	fmt.Fprintln(w, "# HELP gateway_alerts_dropped_total Error alerts dropped because the webhook failed or was backing off")
+	fmt.Fprintln(w, "# TYPE gateway_alerts_dropped_total counter")
+	fmt.Fprintf(w, "gateway_alerts_dropped_total %d\n", dropped)
 }
 
 // cleanupOldErrors removes old error counts to prevent memory leaks
 func (h *ErrorTrackingHook) cleanupOldErrors() {
-	cutoff := time.Now().Add(-1 * time.Hour) // Keep errors for 1 hour
+	cutoff := h.clock.Now().Add(-1 * time.Hour) // Keep errors for 1 hour
 
 	for errorKey, lastAlert := range h.lastAlert {
 		if lastAlert.Before(cutoff) {
@@ -278,26 +426,64 @@ func (h *SlackHook) Levels() []LogLevel {
 	return []LogLevel{ERROR, FATAL}
 }
 
+// DefaultMaxMetricSeries bounds the distinct service/component/level series a MetricsHook keeps
+const DefaultMaxMetricSeries = 1000
+
+// overflowComponent collects the counts of new series once a MetricsHook is full
+const overflowComponent = "_overflow"
+
+// metricKey identifies a MetricsHook series
+type metricKey struct {
+	service   string
+	component string
+	level     string
+}
+
 // MetricsHook tracks error metrics for Prometheus
 type MetricsHook struct {
-	errorCounter map[string]int
+	errorCounter map[metricKey]int
+	maxSeries    int
 	mu           sync.RWMutex
+	synchronous  bool
 }
 
-// NewMetricsHook creates a new metrics tracking hook
+// NewMetricsHook creates a new metrics tracking hook.
+// It is in-process and cheap, so it fires synchronously by default.
 func NewMetricsHook() *MetricsHook {
 	return &MetricsHook{
-		errorCounter: make(map[string]int),
+		errorCounter: make(map[metricKey]int),
+		maxSeries:    DefaultMaxMetricSeries,
+		synchronous:  true,
 	}
 }
 
+// SetSynchronous controls whether the hook fires inline or via the dispatcher
+func (h *MetricsHook) SetSynchronous(synchronous bool) {
+	h.synchronous = synchronous
+}
+
+// SetMaxSeries changes how many distinct series are kept; once the limit is reached,
+// entries for new components are counted under the "_overflow" component
+func (h *MetricsHook) SetMaxSeries(maxSeries int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxSeries = maxSeries
+}
+
+// Synchronous implements SynchronousHook
+func (h *MetricsHook) Synchronous() bool {
+	return h.synchronous
+}
+
 // Fire processes log entries for metrics tracking
 func (h *MetricsHook) Fire(entry *LogEntry) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// Create metric key
-	key := fmt.Sprintf("%s:%s:%s", entry.Service, entry.Component, entry.Level)
+	key := metricKey{service: entry.Service, component: entry.Component, level: entry.Level}
+	if _, exists := h.errorCounter[key]; !exists && h.maxSeries > 0 && len(h.errorCounter) >= h.maxSeries {
+		key.component = overflowComponent
+	}
 	h.errorCounter[key]++
 
 	return nil
@@ -308,14 +494,49 @@ func (h *MetricsHook) Levels() []LogLevel {
 	return []LogLevel{DEBUG, INFO, WARN, ERROR, FATAL}
 }
 
-// GetMetrics returns current error metrics
+// GetMetrics returns current error metrics keyed by "service:component:level"
 func (h *MetricsHook) GetMetrics() map[string]int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	metrics := make(map[string]int)
+	metrics := make(map[string]int, len(h.errorCounter))
 	for k, v := range h.errorCounter {
-		metrics[k] = v
+		metrics[fmt.Sprintf("%s:%s:%s", k.service, k.component, k.level)] = v
 	}
 	return metrics
 }
+
+// Reset zeroes all counts and frees the tracked series
+func (h *MetricsHook) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errorCounter = make(map[metricKey]int)
+}
+
+// WriteMetrics writes the log entry counts as gateway_log_entries_total in the Prometheus text format
+func (h *MetricsHook) WriteMetrics(w io.Writer) {
+	h.mu.RLock()
+	keys := make([]metricKey, 0, len(h.errorCounter))
+	counts := make(map[metricKey]int, len(h.errorCounter))
+	for k, v := range h.errorCounter {
+		keys = append(keys, k)
+		counts[k] = v
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].service != keys[j].service {
+			return keys[i].service < keys[j].service
+		}
+		if keys[i].component != keys[j].component {
+			return keys[i].component < keys[j].component
+		}
+		return keys[i].level < keys[j].level
+	})
+
+	fmt.Fprintln(w, "# HELP gateway_log_entries_total Log entries written by service, component and level")
+	fmt.Fprintln(w, "# TYPE gateway_log_entries_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "gateway_log_entries_total{service=%q,component=%q,level=%q} %d\n", k.service, k.component, k.level, counts[k])
+	}
+}
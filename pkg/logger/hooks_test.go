@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"api-gateway/pkg/clock"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetricsHookBoundsSeries(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxSeries    int
+		components   int
+		wantSeries   int
+		wantOverflow int
+	}{
+		{name: "under the limit", maxSeries: 10, components: 5, wantSeries: 5},
+		{name: "at the limit", maxSeries: 10, components: 10, wantSeries: 10},
+		{name: "over the limit", maxSeries: 10, components: 25, wantSeries: 11, wantOverflow: 15},
+		{name: "unbounded", maxSeries: 0, components: 25, wantSeries: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := NewMetricsHook()
+			hook.SetMaxSeries(tt.maxSeries)
+			for i := 0; i < tt.components; i++ {
+				hook.Fire(&LogEntry{Service: "api-gateway", Component: fmt.Sprintf("worker-%d", i), Level: "error"})
+			}
+			// Known series keep counting once the hook is full
+			hook.Fire(&LogEntry{Service: "api-gateway", Component: "worker-0", Level: "error"})
+
+			metrics := hook.GetMetrics()
+			if len(metrics) != tt.wantSeries {
+				t.Errorf("series = %d, want %d", len(metrics), tt.wantSeries)
+			}
+			if got := metrics["api-gateway:"+overflowComponent+":error"]; got != tt.wantOverflow {
+				t.Errorf("overflow count = %d, want %d", got, tt.wantOverflow)
+			}
+			if got := metrics["api-gateway:worker-0:error"]; got != 2 {
+				t.Errorf("worker-0 count = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestMetricsHookReset(t *testing.T) {
+	hook := NewMetricsHook()
+	for i := 0; i < 3; i++ {
+		hook.Fire(&LogEntry{Service: "api-gateway", Component: "proxy", Level: "error"})
+	}
+	hook.Fire(&LogEntry{Service: "api-gateway", Component: "discovery", Level: "warn"})
+
+	hook.Reset()
+	if metrics := hook.GetMetrics(); len(metrics) != 0 {
+		t.Fatalf("metrics after reset = %v, want none", metrics)
+	}
+
+	hook.Fire(&LogEntry{Service: "api-gateway", Component: "proxy", Level: "error"})
+	if got := hook.GetMetrics()["api-gateway:proxy:error"]; got != 1 {
+		t.Errorf("count after reset = %d, want 1", got)
+	}
+}
+
+// alertCounts returns how many alerts the hook delivered and dropped
+func alertCounts(h *ErrorTrackingHook) (delivered, dropped int64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.deliveredAlerts, h.droppedAlerts
+}
+
+// waitForAlerts waits until the hook has delivered or dropped sent alerts in total
+func waitForAlerts(t *testing.T, h *ErrorTrackingHook, sent int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		delivered, dropped := alertCounts(h)
+		if delivered+dropped >= sent {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("alerts delivered %d, dropped %d, want %d in total", delivered, dropped, sent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestErrorTrackingHookThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int // Nil keeps the defaults
+		errors     int
+		wantAlerts int64
+	}{
+		{name: "defaults", errors: 99, wantAlerts: 3},
+		{name: "defaults past the last threshold", errors: 300, wantAlerts: 6},
+		{name: "configured", thresholds: []int{3, 5}, errors: 12, wantAlerts: 4},
+		{name: "single threshold repeats", thresholds: []int{2}, errors: 7, wantAlerts: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received atomic.Int64
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received.Add(1) }))
+			defer webhook.Close()
+
+			// A frozen clock keeps every repeat within the cooldown
+			h := NewErrorTrackingHookWithClock(clock.NewFake(time.Unix(0, 0)))
+			h.SetWebhookURL(webhook.URL)
+			h.SetAlertThresholds(tt.thresholds)
+			for i := 0; i < tt.errors; i++ {
+				h.Fire(&LogEntry{Level: "ERROR", Service: "api-gateway", Component: "proxy", Error: "upstream failed"})
+			}
+
+			waitForAlerts(t, h, tt.wantAlerts)
+			if delivered, dropped := alertCounts(h); delivered != tt.wantAlerts || dropped != 0 {
+				t.Errorf("delivered %d, dropped %d, want %d delivered", delivered, dropped, tt.wantAlerts)
+			}
+			if got := received.Load(); got != tt.wantAlerts {
+				t.Errorf("webhook received %d alerts, want %d", got, tt.wantAlerts)
+			}
+		})
+	}
+}
+
+func TestErrorTrackingHookBacksOffFailingWebhook(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		retryAfter  string
+		stillFailed time.Duration // Elapsed time at which the hook is still backing off
+		recovered   time.Duration // Elapsed time past the backoff and its jitter
+	}{
+		{name: "429 with Retry-After seconds", status: http.StatusTooManyRequests, retryAfter: "60", stillFailed: 59 * time.Second, recovered: 91 * time.Second},
+		{name: "429 with Retry-After date", status: http.StatusTooManyRequests, retryAfter: "Thu, 01 Jan 1970 00:02:00 GMT", stillFailed: 119 * time.Second, recovered: 181 * time.Second},
+		{name: "5xx without Retry-After", status: http.StatusServiceUnavailable, stillFailed: 4 * time.Second, recovered: 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			status := tt.status
+			var received atomic.Int64
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received.Add(1)
+				mu.Lock()
+				defer mu.Unlock()
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer webhook.Close()
+
+			fake := clock.NewFake(time.Unix(0, 0))
+			h := NewErrorTrackingHookWithClock(fake)
+			h.SetWebhookURL(webhook.URL)
+			fire := func(message string) {
+				h.Fire(&LogEntry{Level: "ERROR", Service: "api-gateway", Component: "proxy", Error: message})
+			}
+
+			fire("upstream failed")
+			waitForAlerts(t, h, 1)
+
+			// A new error during the backoff is dropped without reaching the webhook
+			fake.Advance(tt.stillFailed)
+			fire("discovery failed")
+			if got := received.Load(); got != 1 {
+				t.Errorf("webhook received %d alerts during the backoff, want 1", got)
+			}
+
+			mu.Lock()
+			status = http.StatusOK
+			mu.Unlock()
+			fake.Advance(tt.recovered - tt.stillFailed)
+			fire("discovery failed")
+			waitForAlerts(t, h, 3)
+
+			if delivered, dropped := alertCounts(h); delivered != 1 || dropped != 2 {
+				t.Errorf("delivered %d, dropped %d, want 1 and 2", delivered, dropped)
+			}
+			if got := received.Load(); got != 2 {
+				t.Errorf("webhook received %d alerts, want 2", got)
+			}
+			var metrics strings.Builder
+			h.WriteMetrics(&metrics)
+			if !strings.Contains(metrics.String(), "gateway_alerts_dropped_total 2\n") {
+				t.Errorf("metrics missing the dropped count:\n%s", metrics.String())
+			}
+		})
+	}
+}
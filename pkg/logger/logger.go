@@ -2,13 +2,12 @@ package logger
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"log"
 	"os"
-	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -60,6 +59,36 @@ type LogEntry struct {
 	Fields        map[string]interface{} `json:"fields,omitempty"`
 }
 
+// clone copies the entry along with its fields, nested field maps included, so the
+// copy can be changed without touching the original
+func (e *LogEntry) clone() *LogEntry {
+	copied := *e
+	copied.Fields = cloneFields(e.Fields)
+	return &copied
+}
+
+// cloneFields copies fields, descending into nested maps
+func cloneFields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			value = cloneFields(nested)
+		case map[string]string:
+			values := make(map[string]string, len(nested))
+			for k, v := range nested {
+				values[k] = v
+			}
+			value = values
+		}
+		copied[key] = value
+	}
+	return copied
+}
+
 // Hook interface for extending logging functionality
 type Hook interface {
 	Fire(entry *LogEntry) error
@@ -71,16 +100,22 @@ type Formatter interface {
 	Format(entry *LogEntry) ([]byte, error)
 }
 
-// Logger provides structured logging capabilities
+// Logger provides structured logging capabilities.
+// The level is shared with every logger derived via WithContext or WithComponent,
+// so SetLevel on any of them takes effect everywhere.
 type Logger struct {
-	level     LogLevel
-	service   string
-	component string
-	ctx       context.Context
-	output    io.Writer
-	mu        sync.RWMutex
-	hooks     []Hook
-	formatter Formatter
+	level      *atomic.Int32
+	service    string
+	component  string
+	ctx        context.Context
+	output     io.Writer
+	mu         sync.RWMutex
+	hooks      []Hook
+	formatter  Formatter
+	dispatcher *hookDispatcher
+	sampler    *sampler
+	redactor   *redactor
+	stack      *stackTracer
 }
 
 // Config holds logger configuration
@@ -90,38 +125,91 @@ type Config struct {
 	Service     string `yaml:"service" json:"service"`
 	Output      string `yaml:"output" json:"output"`
 	EnableHooks bool   `yaml:"enable_hooks" json:"enable_hooks"`
+
+	// Asynchronous hook dispatch
+	HookBufferSize int `yaml:"hook_buffer_size" json:"hook_buffer_size"`
+	HookWorkers    int `yaml:"hook_workers" json:"hook_workers"`
+
+	// File output, used when Output is "file"
+	FilePath          string        `yaml:"file_path" json:"file_path"`
+	FileMaxSizeMB     int           `yaml:"file_max_size_mb" json:"file_max_size_mb"`
+	FileMaxBackups    int           `yaml:"file_max_backups" json:"file_max_backups"`
+	FileMaxAge        time.Duration `yaml:"file_max_age" json:"file_max_age"`
+	FileFlushInterval time.Duration `yaml:"file_flush_interval" json:"file_flush_interval"`
+
+	// Field keys matching any of these regular expressions have their values
+	// masked before formatting and hook firing; empty uses DefaultRedactPatterns
+	RedactPatterns []string `yaml:"redact_patterns" json:"redact_patterns"`
+
+	// Sampling of DEBUG/INFO entries; a rate of 0 or 1 disables sampling
+	SampleRate  float64 `yaml:"sample_rate" json:"sample_rate"`
+	SampleBurst int     `yaml:"sample_burst" json:"sample_burst"`
+
+	// Stack traces of ERROR entries: frames captured, and package paths whose frames
+	// (and their subpackages') are dropped; zero and nil use DefaultStackDepth and DefaultStackFilter
+	StackDepth  int      `yaml:"stack_depth" json:"stack_depth"`
+	StackFilter []string `yaml:"stack_filter" json:"stack_filter"`
 }
 
 // NewLogger creates a new structured logger
 func NewLogger(config Config) *Logger {
-	level := INFO
-	if l, exists := logLevelMap[strings.ToLower(config.Level)]; exists {
-		level = l
+	level, ok := ParseLevel(config.Level)
+	if !ok {
+		level = INFO
 	}
 
-	output := os.Stdout
-	if config.Output == "stderr" {
+	var output io.Writer = os.Stdout
+	switch config.Output {
+	case "stderr":
 		output = os.Stderr
+	case "file":
+		fileWriter, err := NewRotatingFileWriter(RotatingFileConfig{
+			Path:          config.FilePath,
+			MaxSizeBytes:  int64(config.FileMaxSizeMB) * 1024 * 1024,
+			MaxBackups:    config.FileMaxBackups,
+			MaxAge:        config.FileMaxAge,
+			FlushInterval: config.FileFlushInterval,
+		})
+		if err != nil {
+			log.Printf("Falling back to stdout, could not open log file: %v", err)
+		} else {
+			output = fileWriter
+		}
 	}
 
 	var formatter Formatter
 	switch strings.ToLower(config.Format) {
 	case "json":
 		formatter = &JSONFormatter{}
+	case "console":
+		// Color only when a person is watching; NO_COLOR turns it off everywhere
+		formatter = &ConsoleFormatter{Color: isTerminal(output) && os.Getenv("NO_COLOR") == ""}
 	default:
 		formatter = &TextFormatter{}
 	}
 
+	redactPatterns := config.RedactPatterns
+	if len(redactPatterns) == 0 {
+		redactPatterns = DefaultRedactPatterns
+	}
+
+	sharedLevel := &atomic.Int32{}
+	sharedLevel.Store(int32(level))
+
 	logger := &Logger{
-		level:     level,
-		service:   config.Service,
-		output:    output,
-		formatter: formatter,
-		hooks:     make([]Hook, 0),
+		level:      sharedLevel,
+		service:    config.Service,
+		output:     output,
+		formatter:  formatter,
+		hooks:      make([]Hook, 0),
+		dispatcher: newHookDispatcher(config.HookBufferSize, config.HookWorkers),
+		sampler:    newSampler(config.SampleRate, config.SampleBurst),
+		redactor:   newRedactor(redactPatterns),
+		stack:      newStackTracer(config.StackDepth, config.StackFilter),
 	}
 
 	if config.EnableHooks {
-		logger.AddHook(&ErrorTrackingHook{})
+		logger.AddHook(NewErrorTrackingHook())
 	}
 
 	return logger
@@ -134,43 +222,89 @@ func (l *Logger) AddHook(hook Hook) {
 	l.hooks = append(l.hooks, hook)
 }
 
+// Close stops the hook workers after firing any queued hooks, then closes
+// hooks that buffer internally and any file output. Entries logged after Close
+// no longer reach async hooks.
+func (l *Logger) Close() {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	l.shutdownHooks()
+
+	// Flush and close file output; stdout/stderr are left open
+	if fileWriter, ok := l.output.(*RotatingFileWriter); ok {
+		if err := fileWriter.Close(); err != nil {
+			log.Printf("Log file close error: %v", err)
+		}
+	}
+}
+
+// shutdownHooks drains the dispatcher and closes buffering hooks; the caller holds l.mu
+func (l *Logger) shutdownHooks() {
+	l.dispatcher.close()
+
+	// Let buffering hooks (e.g. Loki) push whatever they still hold
+	for _, hook := range l.hooks {
+		if closer, ok := hook.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("Hook close error: %v", err)
+			}
+		}
+	}
+}
+
+// DroppedHookEntries returns how many hook invocations were dropped because
+// the hook buffer was full or the logger was closed
+func (l *Logger) DroppedHookEntries() int64 {
+	return l.dispatcher.dropped.Load()
+}
+
 // SetLevel sets the logging level
 func (l *Logger) SetLevel(level LogLevel) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	l.level.Store(int32(level))
+}
+
+// ParseLevel converts a level name such as "debug" to a LogLevel
+func ParseLevel(name string) (LogLevel, bool) {
+	level, exists := logLevelMap[strings.ToLower(name)]
+	return level, exists
 }
 
 // GetLevel returns the current logging level
 func (l *Logger) GetLevel() LogLevel {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	return l.level
+	return LogLevel(l.level.Load())
 }
 
 // WithContext returns a new logger with context information
 func (l *Logger) WithContext(ctx context.Context) *Logger {
 	return &Logger{
-		level:     l.level,
-		service:   l.service,
-		component: l.component,
-		ctx:       ctx,
-		output:    l.output,
-		hooks:     l.hooks,
-		formatter: l.formatter,
+		level:      l.level,
+		service:    l.service,
+		component:  l.component,
+		ctx:        ctx,
+		output:     l.output,
+		hooks:      l.hooks,
+		formatter:  l.formatter,
+		dispatcher: l.dispatcher,
+		sampler:    l.sampler,
+		redactor:   l.redactor,
+		stack:      l.stack,
 	}
 }
 
 // WithComponent returns a new logger with component information
 func (l *Logger) WithComponent(component string) *Logger {
 	return &Logger{
-		level:     l.level,
-		service:   l.service,
-		component: component,
-		ctx:       l.ctx,
-		output:    l.output,
-		hooks:     l.hooks,
-		formatter: l.formatter,
+		level:      l.level,
+		service:    l.service,
+		component:  component,
+		ctx:        l.ctx,
+		output:     l.output,
+		hooks:      l.hooks,
+		formatter:  l.formatter,
+		dispatcher: l.dispatcher,
+		sampler:    l.sampler,
+		redactor:   l.redactor,
+		stack:      l.stack,
 	}
 }
 
@@ -179,12 +313,17 @@ func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{})
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
-	if level < l.level {
+	if level < l.GetLevel() {
+		return
+	}
+
+	now := time.Now().UTC()
+	if !l.sampler.allow(level, now) {
 		return
 	}
 
 	entry := &LogEntry{
-		Timestamp: time.Now().UTC(),
+		Timestamp: now,
 		Level:     logLevelNames[level],
 		Message:   msg,
 		Service:   l.service,
@@ -207,11 +346,17 @@ func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{})
 	if err, ok := fields["error"].(error); ok {
 		entry.Error = err.Error()
 		if level >= ERROR {
-			entry.StackTrace = getStackTrace()
+			entry.StackTrace = l.stack.capture(1)
 		}
 		delete(fields, "error")
 	}
 
+	// A stack captured elsewhere, such as at a recovered panic, replaces the logging call's
+	if stackTrace, ok := fields["stack_trace"].(string); ok {
+		entry.StackTrace = stackTrace
+		delete(fields, "stack_trace")
+	}
+
 	if method, ok := fields["method"].(string); ok {
 		entry.Method = method
 		delete(fields, "method")
@@ -237,13 +382,22 @@ func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{})
 		delete(fields, "user_agent")
 	}
 
+	// Mask sensitive fields before anything leaves the process
+	l.redactor.redact(entry.Fields)
+
 	for _, hook := range l.hooks {
-		if l.shouldFireHook(hook, level) {
-			if err := hook.Fire(entry); err != nil {
-				// Use standard log to avoid recursion
-				log.Printf("Hook error: %v", err)
-			}
+		if !l.shouldFireHook(hook, level) {
+			continue
 		}
+
+		if syncHook, ok := hook.(SynchronousHook); ok && syncHook.Synchronous() {
+			fireHook(hook, entry)
+			continue
+		}
+
+		// Async hooks get their own copy, fields included, so they never race with the
+		// formatter, each other or the caller's nested maps
+		l.dispatcher.dispatch(hook, entry.clone())
 	}
 
 	formatted, err := l.formatter.Format(entry)
@@ -262,6 +416,11 @@ func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{})
 	}
 
 	if level == FATAL {
+		// Give async hooks (e.g. alerting) a chance to deliver the fatal entry
+		l.shutdownHooks()
+		if fileWriter, ok := l.output.(*RotatingFileWriter); ok {
+			fileWriter.Close()
+		}
 		os.Exit(1)
 	}
 }
@@ -314,24 +473,3 @@ func (l *Logger) mergeFields(fieldMaps ...map[string]interface{}) map[string]int
 	}
 	return result
 }
-
-// getStackTrace captures the current stack trace
-func getStackTrace() string {
-	const depth = 32
-	var pcs [depth]uintptr
-	n := runtime.Callers(3, pcs[:])
-	frames := runtime.CallersFrames(pcs[:n])
-
-	var stack []string
-	for {
-		frame, more := frames.Next()
-		if !strings.Contains(frame.File, "runtime/") &&
-			!strings.Contains(frame.File, "logger/") {
-			stack = append(stack, fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function))
-		}
-		if !more {
-			break
-		}
-	}
-	return strings.Join(stack, "\n")
-}
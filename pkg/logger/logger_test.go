@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// panickingHook panics on every entry
+type panickingHook struct {
+	sync bool
+}
+
+func (h *panickingHook) Fire(entry *LogEntry) error { panic("hook exploded") }
+func (h *panickingHook) Levels() []LogLevel         { return nil }
+func (h *panickingHook) Synchronous() bool          { return h.sync }
+
+// countingHook counts the entries it is fired for
+type countingHook struct {
+	sync  bool
+	fired atomic.Int64
+}
+
+func (h *countingHook) Fire(entry *LogEntry) error {
+	h.fired.Add(1)
+	return nil
+}
+func (h *countingHook) Levels() []LogLevel { return nil }
+func (h *countingHook) Synchronous() bool  { return h.sync }
+
+func TestPanickingHookDoesNotStopLogging(t *testing.T) {
+	tests := []struct {
+		name string
+		sync bool
+	}{
+		{name: "synchronous hooks", sync: true},
+		{name: "dispatched hooks", sync: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLogger(Config{Level: "info", HookWorkers: 1})
+			l.output = io.Discard
+
+			counting := &countingHook{sync: tt.sync}
+			l.AddHook(&panickingHook{sync: tt.sync})
+			l.AddHook(counting)
+
+			l.Info("first")
+			l.Info("second")
+			// Close waits for dispatched hooks, so a worker killed by the panic would lose entries
+			l.Close()
+
+			if got := counting.fired.Load(); got != 2 {
+				t.Errorf("counting hook fired %d times, want 2", got)
+			}
+		})
+	}
+}
+
+func TestSetLevelReachesDerivedLoggers(t *testing.T) {
+	tests := []struct {
+		name      string
+		setOn     string // Which logger SetLevel is called on
+		level     LogLevel
+		wantDebug bool
+		wantInfo  bool
+	}{
+		{name: "root lowers to debug", setOn: "root", level: DEBUG, wantDebug: true, wantInfo: true},
+		{name: "root raises to error", setOn: "root", level: ERROR},
+		{name: "component raises to warn", setOn: "component", level: WARN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			root := NewLogger(Config{Level: "info", Format: "json"})
+			root.output = &buf
+			defer root.Close()
+
+			// Derived before the change, as long-lived managers hold theirs
+			component := root.WithComponent("dynamic_routes").WithContext(context.Background())
+			if tt.setOn == "root" {
+				root.SetLevel(tt.level)
+			} else {
+				component.SetLevel(tt.level)
+			}
+
+			component.Debug("debug entry")
+			component.Info("info entry")
+			root.Info("root info entry")
+
+			if got := strings.Contains(buf.String(), "debug entry"); got != tt.wantDebug {
+				t.Errorf("debug entry logged = %v, want %v", got, tt.wantDebug)
+			}
+			if got := strings.Contains(buf.String(), `"info entry"`); got != tt.wantInfo {
+				t.Errorf("component info entry logged = %v, want %v", got, tt.wantInfo)
+			}
+			if got := strings.Contains(buf.String(), "root info entry"); got != tt.wantInfo {
+				t.Errorf("root info entry logged = %v, want %v", got, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestSetLevelConcurrentWithLogging(t *testing.T) {
+	root := NewLogger(Config{Level: "info"})
+	root.output = io.Discard
+	defer root.Close()
+	component := root.WithComponent("proxy")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				component.Info("request")
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				root.SetLevel(LogLevel((i + j) % 2))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -5,13 +5,50 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// LokiHookConfig controls batching and retries for the Loki hook
+type LokiHookConfig struct {
+	Endpoint      string
+	BatchSize     int           // Entries per push
+	FlushInterval time.Duration // Max time an entry waits before being pushed
+	BufferSize    int           // Entries held in memory before new ones are dropped
+	MaxRetries    int           // Retries on 429 and 5xx responses; 0 disables them
+	RetryBackoff  time.Duration // Initial backoff, doubled after each retry
+}
+
 type LokiHook struct {
-	endpoint string
-	client   *http.Client
+	endpoint      string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	bufferSize    int
+	maxRetries    int
+	retryBackoff  time.Duration
+
+	mu        sync.Mutex
+	buffer    []lokiBufferedEntry
+	flushCh   chan struct{}
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	closeOnce sync.Once
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+type lokiBufferedEntry struct {
+	labels    map[string]string
+	timestamp string
+	line      string
 }
 
 type LokiLogEntry struct {
@@ -23,15 +60,60 @@ type LokiStream struct {
 	Values [][]string        `json:"values"`
 }
 
+// DefaultLokiHookConfig returns the default batching and retry settings for endpoint
+func DefaultLokiHookConfig(endpoint string) LokiHookConfig {
+	return LokiHookConfig{
+		Endpoint:   endpoint,
+		MaxRetries: 3,
+	}
+}
+
+// NewLokiHook creates a Loki hook with default batching settings
 func NewLokiHook(endpoint string) *LokiHook {
-	return &LokiHook{
-		endpoint: endpoint,
+	return NewLokiHookWithConfig(DefaultLokiHookConfig(endpoint))
+}
+
+// NewLokiHookWithConfig creates a Loki hook and starts its background flusher.
+// Unset sizes and intervals take their defaults; MaxRetries is used as given.
+func NewLokiHookWithConfig(config LokiHookConfig) *LokiHook {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 1 * time.Second
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 10000
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 500 * time.Millisecond
+	}
+
+	h := &LokiHook{
+		endpoint:      config.Endpoint,
+		batchSize:     config.BatchSize,
+		flushInterval: config.FlushInterval,
+		bufferSize:    config.BufferSize,
+		maxRetries:    config.MaxRetries,
+		retryBackoff:  config.RetryBackoff,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		buffer:  make([]lokiBufferedEntry, 0, config.BatchSize),
+		flushCh: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
 	}
+
+	go h.run()
+
+	return h
 }
 
+// Fire buffers the entry; it is pushed with the next batch
 func (h *LokiHook) Fire(entry *LogEntry) error {
 	// Convert log entry to Loki format
 	labels := map[string]string{
@@ -45,32 +127,184 @@ func (h *LokiHook) Fire(entry *LogEntry) error {
 	}
 
 	// Convert entry to JSON line
-	logLine, _ := json.Marshal(entry)
-	timestamp := fmt.Sprintf("%d", entry.Timestamp.UnixNano())
-
-	lokiEntry := LokiLogEntry{
-		Streams: []LokiStream{
-			{
-				Stream: labels,
-				Values: [][]string{
-					{timestamp, string(logLine)},
-				},
-			},
-		},
-	}
-
-	// Send to Loki
-	jsonData, _ := json.Marshal(lokiEntry)
-	req, err := http.NewRequest("POST", h.endpoint+"/loki/api/v1/push", bytes.NewBuffer(jsonData))
+	logLine, err := json.Marshal(entry)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	_, err = h.client.Do(req)
-	return err
+	h.mu.Lock()
+	if len(h.buffer) >= h.bufferSize {
+		h.mu.Unlock()
+		h.dropped.Add(1)
+		return nil
+	}
+	h.buffer = append(h.buffer, lokiBufferedEntry{
+		labels:    labels,
+		timestamp: fmt.Sprintf("%d", entry.Timestamp.UnixNano()),
+		line:      string(logLine),
+	})
+	full := len(h.buffer) >= h.batchSize
+	h.mu.Unlock()
+
+	if full {
+		select {
+		case h.flushCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
 }
 
 func (h *LokiHook) Levels() []LogLevel {
 	return []LogLevel{DEBUG, INFO, WARN, ERROR, FATAL}
 }
+
+// Close pushes any buffered entries and stops the background flusher
+func (h *LokiHook) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.stopCh)
+		<-h.doneCh
+	})
+	return nil
+}
+
+// Dropped returns how many entries were discarded because the buffer was full
+func (h *LokiHook) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Failed returns how many entries were lost after exhausting push retries
+func (h *LokiHook) Failed() int64 {
+	return h.failed.Load()
+}
+
+// run flushes the buffer on every interval tick or when a batch fills up
+func (h *LokiHook) run() {
+	defer close(h.doneCh)
+
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.flush()
+		case <-h.flushCh:
+			h.flush()
+		case <-h.stopCh:
+			h.flush()
+			return
+		}
+	}
+}
+
+// flush pushes everything buffered so far, one batch at a time
+func (h *LokiHook) flush() {
+	for {
+		h.mu.Lock()
+		n := len(h.buffer)
+		if n == 0 {
+			h.mu.Unlock()
+			return
+		}
+		if n > h.batchSize {
+			n = h.batchSize
+		}
+		batch := make([]lokiBufferedEntry, n)
+		copy(batch, h.buffer[:n])
+		h.buffer = append(h.buffer[:0], h.buffer[n:]...)
+		h.mu.Unlock()
+
+		if err := h.push(batch); err != nil {
+			h.failed.Add(int64(len(batch)))
+			// Use standard log to avoid recursion
+			log.Printf("Loki push failed, dropped %d entries: %v", len(batch), err)
+		}
+	}
+}
+
+// push sends a batch to Loki, retrying with backoff on 429 and 5xx responses
+func (h *LokiHook) push(batch []lokiBufferedEntry) error {
+	jsonData, err := json.Marshal(buildLokiPayload(batch))
+	if err != nil {
+		return err
+	}
+
+	backoff := h.retryBackoff
+	var lastErr error
+
+	for attempt := 0; attempt <= h.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest("POST", h.endpoint+"/loki/api/v1/push", bytes.NewReader(jsonData))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := h.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+
+		lastErr = fmt.Errorf("loki returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return lastErr
+		}
+	}
+
+	return lastErr
+}
+
+// buildLokiPayload groups entries that share a label set into a single stream
+func buildLokiPayload(batch []lokiBufferedEntry) LokiLogEntry {
+	streams := make(map[string]*LokiStream)
+	order := make([]string, 0)
+
+	for _, entry := range batch {
+		key := lokiLabelKey(entry.labels)
+		stream, exists := streams[key]
+		if !exists {
+			stream = &LokiStream{Stream: entry.labels}
+			streams[key] = stream
+			order = append(order, key)
+		}
+		stream.Values = append(stream.Values, []string{entry.timestamp, entry.line})
+	}
+
+	payload := LokiLogEntry{Streams: make([]LokiStream, 0, len(order))}
+	for _, key := range order {
+		payload.Streams = append(payload.Streams, *streams[key])
+	}
+	return payload
+}
+
+// lokiLabelKey builds a stable key for a label set
+func lokiLabelKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLoki records pushes and answers them with the next queued status, 204 once none are left
+type fakeLoki struct {
+	mu       sync.Mutex
+	statuses []int
+	pushes   []LokiLogEntry
+	attempts int
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempts++
+	status := http.StatusNoContent
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	if status < 300 {
+		var payload LokiLogEntry
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			f.pushes = append(f.pushes, payload)
+		}
+	}
+	w.WriteHeader(status)
+}
+
+func newLokiEntry(level, component string) *LogEntry {
+	return &LogEntry{Timestamp: time.Now(), Level: level, Component: component, Message: "request handled"}
+}
+
+func TestLokiHookBatchesIntoStreams(t *testing.T) {
+	loki := &fakeLoki{}
+	server := httptest.NewServer(loki)
+	defer server.Close()
+
+	hook := NewLokiHookWithConfig(LokiHookConfig{Endpoint: server.URL, BatchSize: 4, FlushInterval: time.Hour})
+	for _, entry := range []*LogEntry{
+		newLokiEntry("INFO", "proxy"),
+		newLokiEntry("ERROR", "proxy"),
+		newLokiEntry("INFO", "proxy"),
+		newLokiEntry("INFO", "discovery"),
+		newLokiEntry("INFO", "proxy"), // Left for Close to push
+	} {
+		hook.Fire(entry)
+	}
+	hook.Close()
+
+	if len(loki.pushes) != 2 {
+		t.Fatalf("Loki received %d pushes, want a full batch and the rest on close", len(loki.pushes))
+	}
+	streams := map[string]int{}
+	for _, stream := range loki.pushes[0].Streams {
+		streams[stream.Stream["level"]+"/"+stream.Stream["component"]] = len(stream.Values)
+	}
+	want := map[string]int{"INFO/proxy": 2, "ERROR/proxy": 1, "INFO/discovery": 1}
+	if len(streams) != len(want) {
+		t.Errorf("first push has streams %v, want %v", streams, want)
+	}
+	for key, values := range want {
+		if streams[key] != values {
+			t.Errorf("stream %s has %d values, want %d", key, streams[key], values)
+		}
+	}
+}
+
+func TestLokiHookRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantAttempts int
+		wantFailed   int64
+	}{
+		{name: "success", wantAttempts: 1, maxRetries: 3},
+		{name: "retried 429", statuses: []int{http.StatusTooManyRequests}, maxRetries: 3, wantAttempts: 2},
+		{name: "retried 5xx", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable}, maxRetries: 3, wantAttempts: 3},
+		{name: "4xx not retried", statuses: []int{http.StatusBadRequest}, maxRetries: 3, wantAttempts: 1, wantFailed: 1},
+		{name: "retries exhausted", statuses: []int{500, 500, 500}, maxRetries: 2, wantAttempts: 3, wantFailed: 1},
+		{name: "retries disabled", statuses: []int{http.StatusServiceUnavailable}, wantAttempts: 1, wantFailed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loki := &fakeLoki{statuses: tt.statuses}
+			server := httptest.NewServer(loki)
+			defer server.Close()
+
+			hook := NewLokiHookWithConfig(LokiHookConfig{
+				Endpoint:      server.URL,
+				FlushInterval: time.Hour,
+				MaxRetries:    tt.maxRetries,
+				RetryBackoff:  time.Millisecond,
+			})
+			hook.Fire(newLokiEntry("INFO", "proxy"))
+			hook.Close()
+
+			if loki.attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", loki.attempts, tt.wantAttempts)
+			}
+			if got := hook.Failed(); got != tt.wantFailed {
+				t.Errorf("Failed() = %d, want %d", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestLokiHookDropsBeyondBuffer(t *testing.T) {
+	hook := NewLokiHookWithConfig(LokiHookConfig{Endpoint: "http://127.0.0.1:1", BatchSize: 100, BufferSize: 3, FlushInterval: time.Hour})
+	for i := 0; i < 5; i++ {
+		hook.Fire(newLokiEntry("INFO", "proxy"))
+	}
+	if got := hook.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+	hook.Close()
+}
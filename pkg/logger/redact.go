@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"log"
+	"regexp"
+)
+
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactPatterns are field-key patterns masked when none are configured
+var DefaultRedactPatterns = []string{
+	`(?i)password`,
+	`(?i)token`,
+	`(?i)secret`,
+	`(?i)_key$`,
+}
+
+// redactor masks field values whose keys match any configured pattern
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// newRedactor compiles the patterns, skipping invalid ones; nil disables redaction
+func newRedactor(patterns []string) *redactor {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			// Use standard log to avoid recursion
+			log.Printf("Ignoring invalid log redaction pattern %q: %v", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+
+	if len(compiled) == 0 {
+		return nil
+	}
+	return &redactor{patterns: compiled}
+}
+
+func (r *redactor) matches(key string) bool {
+	for _, re := range r.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact masks matching keys in fields in place. Nested maps are copied
+// before masking so values owned by the caller are never modified.
+func (r *redactor) redact(fields map[string]interface{}) {
+	if r == nil {
+		return
+	}
+
+	for key, value := range fields {
+		if r.matches(key) {
+			fields[key] = redactedValue
+			continue
+		}
+
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			copied := make(map[string]interface{}, len(nested))
+			for k, v := range nested {
+				copied[k] = v
+			}
+			r.redact(copied)
+			fields[key] = copied
+		case map[string]string:
+			copied := make(map[string]string, len(nested))
+			for k, v := range nested {
+				if r.matches(k) {
+					v = redactedValue
+				}
+				copied[k] = v
+			}
+			fields[key] = copied
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureHook keeps the entries it is fired for
+type captureHook struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+}
+
+func (h *captureHook) Fire(entry *LogEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+	return nil
+}
+func (h *captureHook) Levels() []LogLevel { return nil }
+func (h *captureHook) Synchronous() bool  { return true }
+
+func TestRedactedFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		fields     map[string]interface{}
+		wantMasked []string
+		wantKept   []string
+	}{
+		{
+			name:       "default patterns",
+			fields:     map[string]interface{}{"access_token": "eyJhbGci", "api_key": "k-123", "service": "orders"},
+			wantMasked: []string{"access_token", "api_key"},
+			wantKept:   []string{"service"},
+		},
+		{
+			name:       "custom pattern",
+			patterns:   []string{`^card_number$`},
+			fields:     map[string]interface{}{"card_number": "4111", "access_token": "eyJhbGci"},
+			wantMasked: []string{"card_number"},
+			wantKept:   []string{"access_token"},
+		},
+		{
+			name:       "nested map",
+			fields:     map[string]interface{}{"headers": map[string]string{"x-auth-token": "t-1", "accept": "json"}},
+			wantMasked: []string{"x-auth-token"},
+			wantKept:   []string{"accept"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := NewLogger(Config{Level: "info", Format: "json", RedactPatterns: tt.patterns})
+			l.output = &buf
+			defer l.Close()
+			hook := &captureHook{}
+			l.AddHook(hook)
+
+			l.Info("login", tt.fields)
+
+			if len(hook.entries) != 1 {
+				t.Fatalf("hook got %d entries, want 1", len(hook.entries))
+			}
+			hookFields := flatten(hook.entries[0].Fields)
+			for _, key := range tt.wantMasked {
+				if hookFields[key] != redactedValue {
+					t.Errorf("hook payload %s = %v, want it masked", key, hookFields[key])
+				}
+			}
+			for _, key := range tt.wantKept {
+				if hookFields[key] == redactedValue {
+					t.Errorf("hook payload %s was masked", key)
+				}
+			}
+			original := flatten(tt.fields)
+			for _, key := range tt.wantMasked {
+				if secret := original[key].(string); strings.Contains(buf.String(), secret) {
+					t.Errorf("output contains %s's value %q", key, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactionLeavesCallerFieldsAlone(t *testing.T) {
+	l := NewLogger(Config{Level: "info"})
+	l.output = &bytes.Buffer{}
+	defer l.Close()
+
+	headers := map[string]string{"authorization_token": "t-1"}
+	l.Info("request", map[string]interface{}{"headers": headers})
+	if headers["authorization_token"] != "t-1" {
+		t.Errorf("caller's map was modified: %v", headers)
+	}
+}
+
+// flatten returns every key in fields, nested maps included, with its value
+func flatten(fields map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	for key, value := range fields {
+		switch nested := value.(type) {
+		case map[string]string:
+			for k, v := range nested {
+				flat[k] = v
+			}
+		case map[string]interface{}:
+			for k, v := range flatten(nested) {
+				flat[k] = v
+			}
+		default:
+			flat[key] = value
+		}
+	}
+	return flat
+}
@@ -0,0 +1,208 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultFlushInterval = 1 * time.Second
+
+// RotatingFileConfig controls file output rotation
+type RotatingFileConfig struct {
+	Path          string
+	MaxSizeBytes  int64         // Rotate once the file would exceed this size (0 disables rotation)
+	MaxBackups    int           // Rotated files to keep (0 keeps all)
+	MaxAge        time.Duration // Rotated files older than this are removed (0 keeps all)
+	FlushInterval time.Duration // How often buffered writes are flushed to disk
+}
+
+// RotatingFileWriter is a buffered io.Writer that rotates its file by size
+type RotatingFileWriter struct {
+	config RotatingFileConfig
+	file   *os.File
+	buf    *bufio.Writer
+	size   int64
+	mu     sync.Mutex
+	stopCh chan struct{}
+	doneCh chan struct{}
+	closed bool
+}
+
+// NewRotatingFileWriter opens (or appends to) the log file and starts the periodic flusher
+func NewRotatingFileWriter(config RotatingFileConfig) (*RotatingFileWriter, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("log file path is required")
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	w := &RotatingFileWriter{
+		config: config,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	if err := w.openFile(); err != nil {
+		return nil, err
+	}
+
+	go w.flushLoop()
+
+	return w, nil
+}
+
+// Write buffers p, rotating first if it would push the file past the size limit
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	if w.config.MaxSizeBytes > 0 && w.size > 0 && w.size+int64(len(p)) > w.config.MaxSizeBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.buf.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush writes buffered data to the file
+func (w *RotatingFileWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	return w.buf.Flush()
+}
+
+// Close flushes buffered data, stops the flusher and closes the file
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.stopCh)
+
+	flushErr := w.buf.Flush()
+	closeErr := w.file.Close()
+	w.mu.Unlock()
+
+	<-w.doneCh
+
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+func (w *RotatingFileWriter) flushLoop() {
+	defer close(w.doneCh)
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush()
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// openFile opens the current log file for appending; the caller holds w.mu or owns w
+func (w *RotatingFileWriter) openFile() error {
+	file, err := os.OpenFile(w.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and opens a fresh one; the caller holds w.mu
+func (w *RotatingFileWriter) rotate() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s", w.config.Path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(w.config.Path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.openFile(); err != nil {
+		return err
+	}
+
+	w.prune()
+	return nil
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAge
+func (w *RotatingFileWriter) prune() {
+	matches, err := filepath.Glob(w.config.Path + ".*")
+	if err != nil {
+		return
+	}
+
+	type backupFile struct {
+		path    string
+		modTime time.Time
+	}
+
+	backups := make([]backupFile, 0, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		backups = append(backups, backupFile{path: match, modTime: info.ModTime()})
+	}
+
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+
+	cutoff := time.Now().Add(-w.config.MaxAge)
+	for i, backup := range backups {
+		tooMany := w.config.MaxBackups > 0 && i >= w.config.MaxBackups
+		tooOld := w.config.MaxAge > 0 && backup.modTime.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(backup.path)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLines writes count lines of length bytes, newline included
+func writeLines(t *testing.T, w *RotatingFileWriter, count, length int) {
+	t.Helper()
+	line := strings.Repeat("x", length-1) + "\n"
+	for i := 0; i < count; i++ {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+}
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	return matches
+}
+
+func TestRotatingFileWriterRotatesAtSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int64
+		lines       int
+		wantBackups int
+		wantSize    int64
+	}{
+		{name: "under the limit", maxSize: 100, lines: 2, wantBackups: 0, wantSize: 80},
+		{name: "exactly at the limit", maxSize: 120, lines: 3, wantBackups: 0, wantSize: 120},
+		{name: "past the limit", maxSize: 100, lines: 3, wantBackups: 1, wantSize: 40},
+		{name: "several rotations", maxSize: 100, lines: 7, wantBackups: 3, wantSize: 40},
+		{name: "rotation disabled", maxSize: 0, lines: 7, wantBackups: 0, wantSize: 280},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gateway.log")
+			w, err := NewRotatingFileWriter(RotatingFileConfig{Path: path, MaxSizeBytes: tt.maxSize})
+			if err != nil {
+				t.Fatalf("NewRotatingFileWriter: %v", err)
+			}
+			writeLines(t, w, tt.lines, 40)
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			if got := len(backups(t, path)); got != tt.wantBackups {
+				t.Errorf("backups = %d, want %d", got, tt.wantBackups)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if info.Size() != tt.wantSize {
+				t.Errorf("current file is %d bytes, want %d", info.Size(), tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestRotatingFileWriterPrunesBackups(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBackups  int
+		maxAge      time.Duration
+		wantBackups int
+		wantStale   bool // Whether the pre-existing old backup survives
+	}{
+		{name: "keeps MaxBackups", maxBackups: 2, wantBackups: 2},
+		{name: "removes backups past MaxAge", maxAge: time.Hour, wantBackups: 4},
+		{name: "keeps everything", wantBackups: 5, wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gateway.log")
+			stale := path + ".20200101T000000.000000000"
+			if err := os.WriteFile(stale, []byte("old\n"), 0o644); err != nil {
+				t.Fatalf("write stale backup: %v", err)
+			}
+			old := time.Now().Add(-48 * time.Hour)
+			os.Chtimes(stale, old, old)
+
+			w, err := NewRotatingFileWriter(RotatingFileConfig{
+				Path:         path,
+				MaxSizeBytes: 50,
+				MaxBackups:   tt.maxBackups,
+				MaxAge:       tt.maxAge,
+			})
+			if err != nil {
+				t.Fatalf("NewRotatingFileWriter: %v", err)
+			}
+			writeLines(t, w, 5, 40) // Four rotations
+			w.Close()
+
+			remaining := backups(t, path)
+			if len(remaining) != tt.wantBackups {
+				t.Errorf("backups = %v, want %d", remaining, tt.wantBackups)
+			}
+			_, err = os.Stat(stale)
+			if survived := err == nil; survived != tt.wantStale {
+				t.Errorf("stale backup survived = %v, want %v", survived, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestRotatingFileWriterFlushesOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.log")
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Path: path, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRotatingFileWriter: %v", err)
+	}
+	writeLines(t, w, 1, 20)
+
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("file has %d bytes before a flush, want writes buffered", info.Size())
+	}
+	w.Close()
+	if info, _ := os.Stat(path); info.Size() != 20 {
+		t.Errorf("file has %d bytes after Close, want 20", info.Size())
+	}
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
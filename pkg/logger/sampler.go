@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// sampler probabilistically drops DEBUG and INFO entries to bound log volume.
+// The first burst entries in every second always pass; WARN and above are never sampled.
+type sampler struct {
+	rate        float64
+	burst       int
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// newSampler returns nil when sampling is disabled (rate outside (0, 1))
+func newSampler(rate float64, burst int) *sampler {
+	if rate <= 0 || rate >= 1 {
+		return nil
+	}
+	if burst < 0 {
+		burst = 0
+	}
+	return &sampler{rate: rate, burst: burst}
+}
+
+// allow reports whether an entry at the given level should be logged
+func (s *sampler) allow(level LogLevel, now time.Time) bool {
+	if s == nil || level >= WARN {
+		return true
+	}
+
+	s.mu.Lock()
+	if now.Sub(s.windowStart) >= time.Second {
+		s.windowStart = now
+		s.count = 0
+	}
+	s.count++
+	inBurst := s.count <= s.burst
+	s.mu.Unlock()
+
+	if inBurst {
+		return true
+	}
+	return rand.Float64() < s.rate
+}
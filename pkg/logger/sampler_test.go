@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingKeepsFractionOfInfo(t *testing.T) {
+	const infos, errorEntries = 4000, 100
+
+	var buf bytes.Buffer
+	l := NewLogger(Config{Level: "debug", Format: "json", SampleRate: 0.25})
+	l.output = &buf
+	defer l.Close()
+
+	for i := 0; i < infos; i++ {
+		l.Info("sampled entry")
+	}
+	for i := 0; i < errorEntries; i++ {
+		l.Error("kept entry")
+	}
+
+	gotInfos := strings.Count(buf.String(), "sampled entry")
+	if gotInfos < infos*20/100 || gotInfos > infos*30/100 {
+		t.Errorf("%d of %d INFO entries emitted, want about 25%%", gotInfos, infos)
+	}
+	if gotErrors := strings.Count(buf.String(), "kept entry"); gotErrors != errorEntries {
+		t.Errorf("%d of %d ERROR entries emitted, want all", gotErrors, errorEntries)
+	}
+}
+
+func TestSamplerAllow(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler *sampler
+		level   LogLevel
+		entries int
+		want    int
+	}{
+		{name: "disabled", sampler: newSampler(0, 0), level: DEBUG, entries: 50, want: 50},
+		{name: "rate of 1 disables", sampler: newSampler(1, 0), level: INFO, entries: 50, want: 50},
+		{name: "burst passes", sampler: newSampler(0.0000001, 10), level: INFO, entries: 50, want: 10},
+		{name: "warn never sampled", sampler: newSampler(0.0000001, 0), level: WARN, entries: 50, want: 50},
+		{name: "fatal never sampled", sampler: newSampler(0.0000001, 0), level: FATAL, entries: 50, want: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			allowed := 0
+			for i := 0; i < tt.entries; i++ {
+				if tt.sampler.allow(tt.level, now) {
+					allowed++
+				}
+			}
+			if allowed != tt.want {
+				t.Errorf("allowed %d of %d, want %d", allowed, tt.entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplerBurstResetsEachSecond(t *testing.T) {
+	s := newSampler(0.0000001, 5)
+	now := time.Unix(0, 0)
+	for second := 0; second < 3; second++ {
+		allowed := 0
+		for i := 0; i < 20; i++ {
+			if s.allow(INFO, now) {
+				allowed++
+			}
+		}
+		if allowed != 5 {
+			t.Errorf("second %d: allowed %d, want the burst of 5", second, allowed)
+		}
+		now = now.Add(time.Second)
+	}
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// DefaultStackDepth is how many frames ERROR entries capture when Config.StackDepth is unset
+const DefaultStackDepth = 32
+
+// DefaultStackFilter drops Go runtime frames and this package's own from stack traces
+var DefaultStackFilter = []string{"runtime", reflect.TypeOf(Logger{}).PkgPath()}
+
+// stackTracer captures stack traces for ERROR entries, skipping frames of filtered packages
+type stackTracer struct {
+	depth  int
+	filter []string // Package paths whose frames, and their subpackages', are dropped
+}
+
+func newStackTracer(depth int, filter []string) *stackTracer {
+	if depth <= 0 {
+		depth = DefaultStackDepth
+	}
+	if filter == nil {
+		filter = DefaultStackFilter
+	}
+	return &stackTracer{depth: depth, filter: filter}
+}
+
+// capture returns the caller's stack, skip frames above capture's own caller
+func (st *stackTracer) capture(skip int) string {
+	pcs := make([]uintptr, st.depth)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		if !st.filtered(framePackage(frame.Function)) {
+			stack = append(stack, fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function))
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.Join(stack, "\n")
+}
+
+// filtered reports whether a package is one of the filtered ones or below one
+func (st *stackTracer) filtered(pkg string) bool {
+	for _, prefix := range st.filter {
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// framePackage returns the import path of a function named like
+// "example.com/app/pkg.(*Type).Method"
+func framePackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
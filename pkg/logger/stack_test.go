@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStackTracerFiltersByPackage(t *testing.T) {
+	tests := []struct {
+		function string
+		filter   []string // Nil uses DefaultStackFilter
+		want     bool
+	}{
+		{function: "api-gateway/pkg/logger.(*Logger).log", want: true},
+		{function: "api-gateway/pkg/logger.(*Logger).Error.func1", want: true},
+		{function: "api-gateway/pkg/logger/internal/sink.Write", want: true},
+		{function: "runtime.goexit", want: true},
+		{function: "runtime/debug.Stack", want: true},
+		{function: "example.com/app/mylogger.(*Client).Send"},
+		{function: "example.com/app/pkg/logger.Init"},
+		{function: "api-gateway/pkg/loggerx.Run"},
+		{function: "example.com/runtime/worker.Run"},
+		{function: "main.main"},
+		{function: "example.com/app/mylogger.(*Client).Send", filter: []string{"example.com/app"}, want: true},
+		{function: "api-gateway/pkg/logger.(*Logger).log", filter: []string{}},
+	}
+
+	for _, tt := range tests {
+		st := newStackTracer(0, tt.filter)
+		if got := st.filtered(framePackage(tt.function)); got != tt.want {
+			t.Errorf("filtered(%q) with filter %q = %v, want %v", tt.function, st.filter, got, tt.want)
+		}
+	}
+}
+
+func TestFramePackage(t *testing.T) {
+	tests := []struct {
+		function string
+		want     string
+	}{
+		{function: "main.main", want: "main"},
+		{function: "runtime.goexit", want: "runtime"},
+		{function: "example.com/app/mylogger.(*Client).Send", want: "example.com/app/mylogger"},
+		{function: "api-gateway/pkg/logger.TestFramePackage.func1", want: "api-gateway/pkg/logger"},
+	}
+
+	for _, tt := range tests {
+		if got := framePackage(tt.function); got != tt.want {
+			t.Errorf("framePackage(%q) = %q, want %q", tt.function, got, tt.want)
+		}
+	}
+}
+
+// entryHook keeps the last entry it is fired for
+type entryHook struct {
+	entry *LogEntry
+}
+
+func (h *entryHook) Fire(entry *LogEntry) error {
+	h.entry = entry
+	return nil
+}
+func (h *entryHook) Levels() []LogLevel { return nil }
+func (h *entryHook) Synchronous() bool  { return true }
+
+func TestErrorStackTrace(t *testing.T) {
+	tests := []struct {
+		name       string
+		depth      int
+		filter     []string
+		wantFrames int // Zero for any number
+		want       []string
+		wantNot    []string
+	}{
+		{
+			name:    "defaults drop this package and the runtime",
+			want:    []string{"testing.tRunner"},
+			wantNot: []string{"api-gateway/pkg/logger.", "runtime."},
+		},
+		{
+			name:    "configured filter",
+			filter:  []string{"runtime", "testing"},
+			want:    []string{"api-gateway/pkg/logger.(*Logger).Error", "api-gateway/pkg/logger.TestErrorStackTrace"},
+			wantNot: []string{"testing.tRunner", "runtime."},
+		},
+		{
+			name:       "configured depth",
+			depth:      2,
+			filter:     []string{},
+			wantFrames: 2,
+			want:       []string{"api-gateway/pkg/logger.(*Logger).Error", "api-gateway/pkg/logger.TestErrorStackTrace"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLogger(Config{Level: "info", StackDepth: tt.depth, StackFilter: tt.filter})
+			defer l.Close()
+			l.output = &strings.Builder{}
+			hook := &entryHook{}
+			l.AddHook(hook)
+
+			l.Error("upstream failed", map[string]interface{}{"error": errors.New("connection refused")})
+			if hook.entry == nil {
+				t.Fatal("no entry logged")
+			}
+			stack := hook.entry.StackTrace
+			if frames := len(strings.Split(stack, "\n")); tt.wantFrames > 0 && frames != tt.wantFrames {
+				t.Errorf("stack has %d frames, want %d:\n%s", frames, tt.wantFrames, stack)
+			}
+			for _, function := range tt.want {
+				if !strings.Contains(stack, " "+function) {
+					t.Errorf("stack missing %s:\n%s", function, stack)
+				}
+			}
+			for _, function := range tt.wantNot {
+				if strings.Contains(stack, " "+function) {
+					t.Errorf("stack contains %s:\n%s", function, stack)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels are the dimensions attached to a metric sample
+type Labels map[string]string
+
+// Recorder receives the gateway's metrics. Implement it to send them to a
+// backend other than the built-in Prometheus text endpoint (StatsD, OpenMetrics...).
+type Recorder interface {
+	// IncCounter adds one to a monotonically increasing counter
+	IncCounter(name string, labels Labels)
+	// SetGauge sets a value that can go up and down
+	SetGauge(name string, labels Labels, value float64)
+	// ObserveHistogram records one observation, such as a latency in seconds
+	ObserveHistogram(name string, labels Labels, value float64)
+}
+
+// Discard is a Recorder that drops everything
+var Discard Recorder = discard{}
+
+type discard struct{}
+
+func (discard) IncCounter(string, Labels)                {}
+func (discard) SetGauge(string, Labels, float64)         {}
+func (discard) ObserveHistogram(string, Labels, float64) {}
+
+// DefaultBuckets are the histogram upper bounds, in seconds, used by the Registry
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry is the default Recorder. It keeps metrics in memory and writes them
+// in the Prometheus text format, so it can be registered as a /metrics collector.
+type Registry struct {
+	counters   map[string]*sample
+	gauges     map[string]*sample
+	histograms map[string]*histogram
+	mu         sync.Mutex
+}
+
+type sample struct {
+	name   string
+	labels Labels
+	value  float64
+}
+
+type histogram struct {
+	name   string
+	labels Labels
+	counts []uint64 // Per DefaultBuckets bound, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   make(map[string]*sample),
+		gauges:     make(map[string]*sample),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// IncCounter implements Recorder
+func (r *Registry) IncCounter(name string, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(name, labels)
+	s, exists := r.counters[key]
+	if !exists {
+		s = &sample{name: name, labels: copyLabels(labels)}
+		r.counters[key] = s
+	}
+	s.value++
+}
+
+// SetGauge implements Recorder
+func (r *Registry) SetGauge(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(name, labels)
+	s, exists := r.gauges[key]
+	if !exists {
+		s = &sample{name: name, labels: copyLabels(labels)}
+		r.gauges[key] = s
+	}
+	s.value = value
+}
+
+// ObserveHistogram implements Recorder
+func (r *Registry) ObserveHistogram(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(name, labels)
+	h, exists := r.histograms[key]
+	if !exists {
+		h = &histogram{name: name, labels: copyLabels(labels), counts: make([]uint64, len(DefaultBuckets))}
+		r.histograms[key] = h
+	}
+	for i, bound := range DefaultBuckets {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// WriteMetrics writes every recorded series in the Prometheus text format
+func (r *Registry) WriteMetrics(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	writeSamples(w, "counter", r.counters)
+	writeSamples(w, "gauge", r.gauges)
+
+	keys := sortedKeys(r.histograms)
+	typed := make(map[string]bool)
+	for _, key := range keys {
+		h := r.histograms[key]
+		if !typed[h.name] {
+			fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+			typed[h.name] = true
+		}
+
+		var cumulative uint64
+		for i, bound := range DefaultBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, "", ""), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, "", ""), h.count)
+	}
+}
+
+// writeSamples writes counters or gauges grouped by metric name
+func writeSamples(w io.Writer, metricType string, samples map[string]*sample) {
+	typed := make(map[string]bool)
+	for _, key := range sortedKeys(samples) {
+		s := samples[key]
+		if !typed[s.name] {
+			fmt.Fprintf(w, "# TYPE %s %s\n", s.name, metricType)
+			typed[s.name] = true
+		}
+		fmt.Fprintf(w, "%s%s %s\n", s.name, formatLabels(s.labels, "", ""), formatFloat(s.value))
+	}
+}
+
+// seriesKey identifies a series by its name and sorted labels
+func seriesKey(name string, labels Labels) string {
+	return name + formatLabels(labels, "", "")
+}
+
+// formatLabels renders labels as {k="v",...} in key order, optionally with one extra label
+func formatLabels(labels Labels, extraKey, extraValue string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		parts = append(parts, k+"="+quoteLabelValue(labels[k]))
+	}
+	if extraKey != "" {
+		parts = append(parts, extraKey+"="+quoteLabelValue(extraValue))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// labelValueEscaper escapes the only characters the exposition format allows escaped;
+// Go's %q would produce \t and \u escapes that Prometheus rejects
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabelValue(value string) string {
+	return `"` + labelValueEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+func copyLabels(labels Labels) Labels {
+	copied := make(Labels, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.IncCounter("gateway_http_requests_total", Labels{"method": "GET", "status": "200"})
+	r.IncCounter("gateway_http_requests_total", Labels{"status": "200", "method": "GET"})
+	r.IncCounter("gateway_http_requests_total", Labels{"method": "POST", "status": "500"})
+	r.SetGauge("gateway_circuit_breaker_state", Labels{"service": "orders"}, 1)
+	r.SetGauge("gateway_circuit_breaker_state", Labels{"service": "orders"}, 2)
+	r.SetGauge("gateway_info", Labels{"path": "a\"b\\c\nd"}, 1)
+	r.ObserveHistogram("gateway_http_request_duration_seconds", Labels{"method": "GET"}, 0.003)
+	r.ObserveHistogram("gateway_http_request_duration_seconds", Labels{"method": "GET"}, 0.2)
+	r.ObserveHistogram("gateway_http_request_duration_seconds", Labels{"method": "GET"}, 30)
+
+	var buf bytes.Buffer
+	r.WriteMetrics(&buf)
+	output := buf.String()
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "counter type", line: "# TYPE gateway_http_requests_total counter"},
+		{name: "label order does not split a series", line: `gateway_http_requests_total{method="GET",status="200"} 2`},
+		{name: "second counter series", line: `gateway_http_requests_total{method="POST",status="500"} 1`},
+		{name: "gauge keeps the last value", line: `gateway_circuit_breaker_state{service="orders"} 2`},
+		{name: "label values are escaped", line: `gateway_info{path="a\"b\\c\nd"} 1`},
+		{name: "histogram type", line: "# TYPE gateway_http_request_duration_seconds histogram"},
+		{name: "first bucket", line: `gateway_http_request_duration_seconds_bucket{method="GET",le="0.005"} 1`},
+		{name: "buckets are cumulative", line: `gateway_http_request_duration_seconds_bucket{method="GET",le="0.25"} 2`},
+		{name: "overflow only in +Inf", line: `gateway_http_request_duration_seconds_bucket{method="GET",le="10"} 2`},
+		{name: "+Inf bucket", line: `gateway_http_request_duration_seconds_bucket{method="GET",le="+Inf"} 3`},
+		{name: "sum", line: `gateway_http_request_duration_seconds_sum{method="GET"} 30.203`},
+		{name: "count", line: `gateway_http_request_duration_seconds_count{method="GET"} 3`},
+	}
+
+	for _, tt := range tests {
+		if !strings.Contains(output, tt.line+"\n") {
+			t.Errorf("%s: output missing %q:\n%s", tt.name, tt.line, output)
+		}
+	}
+}